@@ -0,0 +1,48 @@
+// Command backfill-locations replaces the synthetic coordinates of stored
+// restaurants with the ones found in their Google Maps link.
+//
+//	go run ./cmd/backfill-locations -dry-run
+//
+// Restaurants whose link has no coordinates are flagged with approximate_location.
+package main
+
+import (
+	"HalalMate/config/database"
+	"HalalMate/config/environment"
+	"HalalMate/repositories"
+	"HalalMate/services"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("⚠️  No .env file found, using default values")
+	}
+
+	if environment.GetStorageDriver() == "memory" {
+		repositories.SetStore(repositories.NewMemoryStore())
+	} else {
+		database.InitFirebase()
+		repositories.SetStore(repositories.NewFirestoreStore(database.GetFirestoreClient()))
+	}
+
+	result, err := services.NewRestaurantService().BackfillLocations(context.Background(), *dryRun)
+	if err != nil {
+		log.Fatalf("Backfill failed: %v", err)
+	}
+
+	prefix := ""
+	if *dryRun {
+		prefix = "(dry run) "
+	}
+	fmt.Printf("%sscanned %d, updated %d, approximate %d, unchanged %d\n",
+		prefix, result.Scanned, result.Updated, result.Approximate, result.Unchanged)
+}
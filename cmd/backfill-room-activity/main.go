@@ -0,0 +1,45 @@
+// Command backfill-room-activity sets the LastActivityAt of the chat rooms saved
+// before rooms were paged by it, so they show up in the room list again.
+//
+//	go run ./cmd/backfill-room-activity -dry-run
+package main
+
+import (
+	"HalalMate/config/database"
+	"HalalMate/config/environment"
+	"HalalMate/repositories"
+	"HalalMate/services"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("⚠️  No .env file found, using default values")
+	}
+
+	if environment.GetStorageDriver() == "memory" {
+		repositories.SetStore(repositories.NewMemoryStore())
+	} else {
+		database.InitFirebase()
+		repositories.SetStore(repositories.NewFirestoreStore(database.GetFirestoreClient()))
+	}
+
+	result, err := services.NewRoomService().BackfillActivity(context.Background(), *dryRun)
+	if err != nil {
+		log.Fatalf("Backfill failed: %v", err)
+	}
+
+	prefix := ""
+	if *dryRun {
+		prefix = "(dry run) "
+	}
+	fmt.Printf("%sscanned %d, updated %d\n", prefix, result.Scanned, result.Updated)
+}
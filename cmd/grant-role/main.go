@@ -0,0 +1,60 @@
+// Command grant-role grants or revokes a role of a user, which is how the
+// first admin is created before the admin routes can be used.
+//
+//	go run ./cmd/grant-role -user <id> -role admin
+//	go run ./cmd/grant-role -user <id> -role moderator -revoke
+package main
+
+import (
+	"HalalMate/config/database"
+	"HalalMate/config/environment"
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"HalalMate/services"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	userID := flag.String("user", "", "id of the user")
+	role := flag.String("role", "", "moderator or admin")
+	revoke := flag.Bool("revoke", false, "revoke the role instead of granting it")
+	flag.Parse()
+
+	if *userID == "" || *role == "" {
+		flag.Usage()
+		log.Fatal("-user and -role are required")
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("⚠️  No .env file found, using default values")
+	}
+
+	if environment.GetStorageDriver() == "memory" {
+		repositories.SetStore(repositories.NewMemoryStore())
+	} else {
+		database.InitFirebase()
+		repositories.SetStore(repositories.NewFirestoreStore(database.GetFirestoreClient()))
+	}
+
+	userService := services.NewUserService()
+	update := userService.GrantRole
+	if *revoke {
+		// No admin is acting, so the guard against revoking one's own admin role does not apply
+		update = func(ctx context.Context, userID, role string) (*models.Profile, error) {
+			return userService.RevokeRole(ctx, "", userID, role)
+		}
+	}
+
+	profile, err := update(context.Background(), *userID, *role)
+	if err != nil {
+		log.Fatalf("Failed to update roles: %v", err)
+	}
+
+	fmt.Printf("user %s now has roles %s, they apply from the next login or token refresh\n", *userID, strings.Join(profile.Roles, ", "))
+}
@@ -17,3 +17,107 @@ func GetFirebaseKey() string {
 func GetFirebaseProjectID() string {
 	return os.Getenv("FIREBASE_PROJECT_ID")
 }
+
+func GetStorageDriver() string {
+	return os.Getenv("STORAGE_DRIVER") // "firestore" (default) atau "memory" untuk menjalankan API secara offline
+}
+
+func GetOpenAIBaseURL() string {
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		return baseURL
+	}
+	return "https://api.openai.com/v1"
+}
+
+// GetLLMSetting returns LLM_<CALLSITE>_<KEY>, falling back to LLM_<KEY>.
+// Keys: PROVIDER, BASE_URL, API_KEY, MODEL, TEMPERATURE, TIMEOUT
+func GetLLMSetting(callSite, key string) string {
+	if value := os.Getenv("LLM_" + callSite + "_" + key); value != "" {
+		return value
+	}
+	return os.Getenv("LLM_" + key)
+}
+
+func GetScrapeWorkers() string {
+	return os.Getenv("SCRAPE_WORKERS") // Jumlah worker scraper, default 2. "0" menonaktifkan worker
+}
+
+func GetScraperLocale() string {
+	return os.Getenv("SCRAPER_LOCALE") // Locale selector Google Maps (config/selectors/maps), default "id"
+}
+
+func GetJWTAlgorithm() string {
+	return os.Getenv("JWT_ALGORITHM") // "HS256" (default), "RS256" atau "EdDSA"
+}
+
+func GetJWTSecret() string {
+	return os.Getenv("JWT_SECRET") // Secret HS256, wajib diisi kecuali JWT_ALLOW_EPHEMERAL_SECRET=true
+}
+
+func GetJWTAllowEphemeralSecret() string {
+	return os.Getenv("JWT_ALLOW_EPHEMERAL_SECRET") // "true" mengizinkan secret acak saat JWT_SECRET kosong, hanya untuk pengembangan lokal
+}
+
+func GetJWTPrivateKey() string {
+	return os.Getenv("JWT_PRIVATE_KEY") // Kunci privat PEM RS256/EdDSA, isi PEM atau path file
+}
+
+func GetJWTKeyID() string {
+	return os.Getenv("JWT_KEY_ID") // kid kunci yang dipakai untuk menandatangani token
+}
+
+func GetJWTPreviousKeys() string {
+	return os.Getenv("JWT_PREVIOUS_KEYS") // Kunci lama yang masih diterima, "kid=secret" (HS256) atau "kid=path PEM publik" dipisah koma
+}
+
+func GetJWTAccessTTL() string {
+	return os.Getenv("JWT_ACCESS_TTL") // Masa berlaku access token, default "15m"
+}
+
+func GetJWTRefreshTTL() string {
+	return os.Getenv("JWT_REFRESH_TTL") // Masa berlaku refresh token sejak terakhir dipakai, default "720h"
+}
+
+func GetMailerDriver() string {
+	return os.Getenv("MAILER_DRIVER") // "log" (default), "file" atau "smtp"
+}
+
+func GetMailerFile() string {
+	return os.Getenv("MAILER_FILE") // File tujuan email untuk driver "file", satu JSON per baris
+}
+
+func GetMailFrom() string {
+	return os.Getenv("MAIL_FROM") // Alamat pengirim email
+}
+
+func GetSMTPHost() string {
+	return os.Getenv("SMTP_HOST")
+}
+
+func GetSMTPPort() string {
+	return os.Getenv("SMTP_PORT") // Default 587
+}
+
+func GetSMTPUsername() string {
+	return os.Getenv("SMTP_USERNAME")
+}
+
+func GetSMTPPassword() string {
+	return os.Getenv("SMTP_PASSWORD")
+}
+
+func GetAppBaseURL() string {
+	return os.Getenv("APP_BASE_URL") // URL aplikasi untuk link di email, tanpa "/" di akhir
+}
+
+func GetNotifierDriver() string {
+	return os.Getenv("NOTIFIER_DRIVER") // "log" (default), "file" atau "fcm"
+}
+
+func GetNotifierFile() string {
+	return os.Getenv("NOTIFIER_FILE") // File tujuan notifikasi untuk driver "file", satu JSON per baris
+}
+
+func GetChatHistoryTokens() string {
+	return os.Getenv("CHAT_HISTORY_TOKENS") // Perkiraan token riwayat chat yang dikirim apa adanya, default 3000
+}
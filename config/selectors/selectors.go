@@ -0,0 +1,110 @@
+// Package selectors holds the versioned Google Maps selectors used by the scraper, one file per locale.
+//
+// Google changes its markup without notice. When it does, edit the locale file, bump its version
+// and save new fixtures under services/testdata/maps/<locale>/<version>, then run
+// go test ./services -run TestMapsFixtures -update.
+package selectors
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is the locale used when SCRAPER_LOCALE is not set
+const DefaultLocale = "id"
+
+//go:embed maps/*.json
+var mapsFiles embed.FS
+
+// MapsSelectors are the CSS selectors of one Maps locale. Values containing %s are formatted with
+// the search query or the place title.
+type MapsSelectors struct {
+	Version string `json:"version"`
+	Locale  string `json:"locale"`
+	// Language is sent as the hl parameter so Maps answers with the labels below
+	Language string `json:"language"`
+
+	// Search results page
+	ResultsFeed         string `json:"results_feed"`
+	ResultCard          string `json:"result_card"`
+	ResultTitle         string `json:"result_title"`
+	ResultRating        string `json:"result_rating"`
+	ResultReviewCount   string `json:"result_review_count"`
+	ResultOpeningStatus string `json:"result_opening_status"`
+	ResultImage         string `json:"result_image"`
+	ResultLink          string `json:"result_link"`
+
+	// Place page
+	PlaceTitle        string `json:"place_title"`
+	PlaceAddress      string `json:"place_address"`
+	PlaceRating       string `json:"place_rating"`
+	PlaceReviewCount  string `json:"place_review_count"`
+	PlaceImage        string `json:"place_image"`
+	CopyAddressButton string `json:"copy_address_button"`
+	MenuButton        string `json:"menu_button"`
+	ReviewButton      string `json:"review_button"`
+
+	// Panels opened from the place page
+	ScrollPanel string `json:"scroll_panel"`
+	MenuPanel   string `json:"menu_panel"`
+	MenuImage   string `json:"menu_image"`
+	ReviewText  string `json:"review_text"`
+}
+
+// ResultsFeedFor returns the selector of the scrollable results list of a search
+func (s *MapsSelectors) ResultsFeedFor(searchQuery string) string {
+	return fmt.Sprintf(s.ResultsFeed, searchQuery)
+}
+
+// ReviewButtonFor returns the selector of the reviews tab of a place
+func (s *MapsSelectors) ReviewButtonFor(title string) string {
+	return fmt.Sprintf(s.ReviewButton, title)
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*MapsSelectors)
+)
+
+// Maps returns the selectors of a locale, e.g. "id" or "en"
+func Maps(locale string) (*MapsSelectors, error) {
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	locale = strings.ToLower(locale)
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if selectors, ok := cache[locale]; ok {
+		return selectors, nil
+	}
+
+	data, err := mapsFiles.ReadFile("maps/" + locale + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no Maps selectors for locale %q", locale)
+	}
+
+	var selectors MapsSelectors
+	if err := json.Unmarshal(data, &selectors); err != nil {
+		return nil, fmt.Errorf("invalid Maps selectors for locale %q: %w", locale, err)
+	}
+	if selectors.Version == "" || selectors.ResultCard == "" {
+		return nil, fmt.Errorf("incomplete Maps selectors for locale %q", locale)
+	}
+
+	cache[locale] = &selectors
+	return &selectors, nil
+}
+
+// Locales lists every locale with a selector file
+func Locales() []string {
+	entries, _ := mapsFiles.ReadDir("maps")
+	locales := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		locales = append(locales, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	return locales
+}
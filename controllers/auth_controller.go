@@ -34,6 +34,7 @@ func (h *AuthController) RegisterUser(c *gin.Context) {
 		Email           string `json:"email" binding:"required"`
 		Password        string `json:"password" binding:"required"`
 		RetypedPassword string `json:"retyped_password" binding:"required"`
+		DeviceID        string `json:"device_id"`
 		// FCMToken        string `json:"fcm_token"`
 	}
 
@@ -56,15 +57,17 @@ func (h *AuthController) RegisterUser(c *gin.Context) {
 		return
 	}
 
-	user, token, err := h.AuthService.Register(req.Email, req.Username, req.Password)
+	user, tokens, err := h.AuthService.Register(req.Email, req.Username, req.Password, req.DeviceID)
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusCreated, "User registered successfully", gin.H{
-		"token": token,
-		"user":  user,
+		"token":         tokens.Token,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          user,
 	})
 }
 
@@ -73,6 +76,7 @@ func (h *AuthController) LoginUser(c *gin.Context) {
 	var req struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		DeviceID string `json:"device_id"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -83,7 +87,7 @@ func (h *AuthController) LoginUser(c *gin.Context) {
 
 	log.Printf("[INFO] User login attempt: Email - %s", req.Email)
 
-	token, err := h.AuthService.Login(req.Email, req.Password)
+	tokens, err := h.AuthService.Login(req.Email, req.Password, req.DeviceID)
 	if err != nil {
 		log.Println("[ERROR] Login failed:", err)
 		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
@@ -92,16 +96,15 @@ func (h *AuthController) LoginUser(c *gin.Context) {
 
 	log.Println("[INFO] Login successful, token generated")
 
-	utils.SuccessResponse(c, http.StatusOK, "Login successful", gin.H{
-		"token": token,
-	})
+	utils.SuccessResponse(c, http.StatusOK, "Login successful", tokens)
 }
 
 // made it when use google idtoken
 // GoogleLogin handles Google login verification
 func (h *AuthController) GoogleLogin(c *gin.Context) {
 	var req struct {
-		IDToken string `json:"idToken"`
+		IDToken  string `json:"idToken"`
+		DeviceID string `json:"device_id"`
 	}
 
 	// Bind JSON request body
@@ -111,22 +114,134 @@ func (h *AuthController) GoogleLogin(c *gin.Context) {
 	}
 
 	// Verify Google ID Token
-	token, err := h.AuthService.VerifyGoogleIDToken(req.IDToken)
+	tokens, err := h.AuthService.VerifyGoogleIDToken(req.IDToken, req.DeviceID)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
 	// Respond with user details
-	utils.SuccessResponse(c, http.StatusOK, "Login or Register successful", gin.H{
-		"token": token,
-	})
+	utils.SuccessResponse(c, http.StatusOK, "Login or Register successful", tokens)
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token
+func (h *AuthController) RefreshToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	tokens, err := h.AuthService.Refresh(c, req.RefreshToken)
+	if err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Token refreshed", tokens)
+}
+
+// Logout revokes the session of the current device, or of every device with all_devices
+func (h *AuthController) Logout(c *gin.Context) {
+	var req struct {
+		AllDevices bool `json:"all_devices"`
+	}
+
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+			return
+		}
+	}
+
+	if err := h.AuthService.Logout(c, c.GetString("userId"), c.GetString("sessionId"), req.AllDevices); err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Logout successful", nil)
+}
+
+// VerifyEmail redeems the token of a verification email
+func (h *AuthController) VerifyEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if err := h.AuthService.VerifyEmail(c, req.Token); err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Email verified", nil)
+}
+
+// ResendVerification sends a new verification email to the current user
+func (h *AuthController) ResendVerification(c *gin.Context) {
+	if err := h.AuthService.ResendVerification(c, c.GetString("userId")); err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Verification email sent", nil)
+}
+
+// ForgotPassword sends a password reset email when the email has an account
+func (h *AuthController) ForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if err := h.AuthService.ForgotPassword(c, req.Email); err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "If this email has an account, a reset link was sent to it", nil)
+}
+
+// ResetPassword sets a new password with the token of a reset email
+func (h *AuthController) ResetPassword(c *gin.Context) {
+	var req struct {
+		Token           string `json:"token" binding:"required"`
+		Password        string `json:"password" binding:"required"`
+		RetypedPassword string `json:"retyped_password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+	if req.Password != req.RetypedPassword {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Passwords do not match")
+		return
+	}
+
+	if err := h.AuthService.ResetPassword(c, req.Token, req.Password); err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Password reset, please log in again", nil)
 }
 
 // store fcm token
 func (h *AuthController) StoreFCMToken(c *gin.Context) {
 	var req struct {
-		FCMToken string `json:"fcm_token"`
+		FCMToken string `json:"fcm_token" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -134,11 +249,30 @@ func (h *AuthController) StoreFCMToken(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetString("userID")
+	userID := c.GetString("userId")
 	if err := h.AuthService.StoreFCMToken(userID, req.FCMToken); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		c.Error(err) // Middleware akan menangani error ini
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "FCM token stored successfully", nil)
 }
+
+// RemoveFCMToken stops the push notifications to one device
+func (h *AuthController) RemoveFCMToken(c *gin.Context) {
+	var req struct {
+		FCMToken string `json:"fcm_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if err := h.AuthService.RemoveFCMToken(c.GetString("userId"), req.FCMToken); err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "FCM token removed", nil)
+}
@@ -4,24 +4,31 @@ import (
 	"HalalMate/models"
 	"HalalMate/services"
 	"HalalMate/utils"
-	"fmt"
+	"context"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 )
 
 // RecommendationController struct
 type ChatController struct {
 	ChatService *services.ChatService
+	// Sessions is checked again while a socket stays open
+	Sessions *services.SessionService
+	// hub pushes the answers of a room to the sockets the user opened on it
+	hub *chatHub
 }
 
 // NewChatController initializes ChatController with the service layer
 func NewChatController() *ChatController {
 	return &ChatController{
 		ChatService: services.NewChatService(),
+		Sessions:    services.NewSessionService(),
+		hub:         newChatHub(),
 	}
 }
 
@@ -60,80 +67,282 @@ func (c *ChatController) ChatRecomendation(ctx *gin.Context) {
 
 	// Convert request data to GeoLocation
 
-	latitude, err := strconv.ParseFloat(req.Latitude, 64)
+	location, ok := parseChatLocation(ctx, req.Latitude, req.Longitude)
+	if !ok {
+		return
+	}
+	formattedPrompt := strings.TrimSpace(req.Prompt)
+
+	log.Println("Streaming recommendations for:", formattedPrompt)
+
+	// A reconnecting client sends the id of the last event it got and receives the rest of that answer
+	if lastEventID := ctx.GetHeader("Last-Event-ID"); lastEventID != "" {
+		// The stream the client lost may still be stopping on this instance
+		waitCtx, stopWaiting := context.WithTimeout(ctx.Request.Context(), socketResumeWait)
+		c.hub.await(waitCtx, userId.(string), roomId)
+		stopWaiting()
+
+		reqCtx, ok := c.beginAnswer(ctx, userId.(string), roomId)
+		if !ok {
+			return
+		}
+		defer c.hub.end(userId.(string), roomId)
+
+		answer, cursor, missed, err := c.ChatService.ResumeAnswer(reqCtx, userId.(string), roomId, lastEventID)
+		if err != nil {
+			ctx.Error(err) // Middleware akan menangani error ini
+			return
+		}
+		startEventStream(ctx)
+		c.streamAnswer(reqCtx, ctx, userId.(string), formattedPrompt, location, answer, cursor, missed, false)
+		return
+	}
+
+	reqCtx, ok := c.beginAnswer(ctx, userId.(string), roomId)
+	if !ok {
+		return
+	}
+	defer c.hub.end(userId.(string), roomId)
+
+	chat, err := c.ChatService.SaveChat(reqCtx, userId.(string), req.Prompt, roomId, false, nil)
 	if err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid latitude format")
+		if customErr, ok := err.(*utils.CustomError); ok {
+			utils.ErrorResponse(ctx, customErr.StatusCode, customErr.Message)
+			return
+		}
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to save chat user")
 		return
 	}
-	longitude, err := strconv.ParseFloat(req.Longitude, 64)
+
+	answer, err := c.ChatService.StartAnswer(reqCtx, userId.(string), roomId)
 	if err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid longitude format")
+		ctx.Error(err) // Middleware akan menangani error ini
 		return
 	}
-	location := models.GeoLocation{Latitude: latitude, Longitude: longitude}
-	formattedPrompt := strings.TrimSpace(req.Prompt)
+	c.hub.publish(userId.(string), roomId, socketEvent{Type: socketMessage, Chat: chat})
+	c.hub.publish(userId.(string), roomId, socketEvent{Type: socketMessage, Chat: answer})
+	startEventStream(ctx)
+	c.streamAnswer(reqCtx, ctx, userId.(string), formattedPrompt, location, answer, services.AnswerCursor{ChatID: answer.ChatID}, nil, true)
+}
 
-	log.Println("Streaming recommendations for:", formattedPrompt)
+// RegenerateRequest is the body of POST /hoca/room/:roomId/regenerate
+type RegenerateRequest struct {
+	Latitude  string `json:"latitude" binding:"required"`
+	Longitude string `json:"longitude" binding:"required"`
+}
+
+// Regenerate streams a new answer in place of the last answer of the room
+func (c *ChatController) Regenerate(ctx *gin.Context) {
+	var req RegenerateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	location, ok := parseChatLocation(ctx, req.Latitude, req.Longitude)
+	if !ok {
+		return
+	}
+
+	userId := ctx.GetString("userId")
+	roomId := ctx.Param("roomId")
+	reqCtx, ok := c.beginAnswer(ctx, userId, roomId)
+	if !ok {
+		return
+	}
+	defer c.hub.end(userId, roomId)
+
+	prompt, answer, err := c.ChatService.RegenerateAnswer(reqCtx, userId, roomId)
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	c.hub.publish(userId, answer.RoomID, socketEvent{Type: socketMessage, Chat: answer})
+	startEventStream(ctx)
+	c.streamAnswer(reqCtx, ctx, userId, prompt, location, answer, services.AnswerCursor{ChatID: answer.ChatID}, nil, false)
+}
+
+// EditPrompt branches the room from an edited prompt and streams the answer in the new room,
+// which is sent first as a "room" event
+func (c *ChatController) EditPrompt(ctx *gin.Context) {
+	var req ChatRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	location, ok := parseChatLocation(ctx, req.Latitude, req.Longitude)
+	if !ok {
+		return
+	}
+
+	userId := ctx.GetString("userId")
+	// The answer streaming in the room would be copied half written
+	if c.hub.streaming(userId, ctx.Param("roomId")) {
+		utils.ErrorResponse(ctx, http.StatusConflict, "An answer is already streaming in this room")
+		return
+	}
+	room, answer, err := c.ChatService.BranchChat(ctx.Request.Context(), userId, ctx.Param("roomId"), ctx.Param("chatId"), req.Prompt)
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	reqCtx, ok := c.beginAnswer(ctx, userId, room.RoomID)
+	if !ok {
+		return
+	}
+	defer c.hub.end(userId, room.RoomID)
+
+	c.hub.publishUser(userId, socketEvent{Type: socketRoom, RoomID: room.RoomID, Room: room})
+	startEventStream(ctx)
+	ctx.SSEvent("room", room)
+	ctx.Writer.Flush()
+	c.streamAnswer(reqCtx, ctx, userId, strings.TrimSpace(req.Prompt), location, answer, services.AnswerCursor{ChatID: answer.ChatID}, nil, true)
+}
+
+// RatingRequest is the body of PUT /hoca/room/:roomId/chat/:chatId/rating
+type RatingRequest struct {
+	Rating string `json:"rating" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// RateChat stores the thumbs up or down of the user on a HocaAI answer
+func (c *ChatController) RateChat(ctx *gin.Context) {
+	var req RatingRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	rating, err := c.ChatService.RateChat(ctx.Request.Context(), ctx.GetString("userId"), ctx.Param("roomId"), ctx.Param("chatId"), req.Rating, req.Reason)
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Rating saved", rating)
+}
+
+// ListRatings returns the ratings of every user, optionally filtered by ?rating=up|down
+func (c *ChatController) ListRatings(ctx *gin.Context) {
+	ratings, err := c.ChatService.ListRatings(ctx.Request.Context(), ctx.Query("rating"))
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Ratings fetched successfully", ratings)
+}
+
+// parseChatLocation converts the coordinates of a chat request, writing the error response when they are invalid
+func parseChatLocation(ctx *gin.Context, lat, lng string) (models.GeoLocation, bool) {
+	latitude, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid latitude format")
+		return models.GeoLocation{}, false
+	}
+	longitude, err := strconv.ParseFloat(lng, 64)
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid longitude format")
+		return models.GeoLocation{}, false
+	}
+	return models.GeoLocation{Latitude: latitude, Longitude: longitude}, true
+}
+
+// beginAnswer registers the answer the request streams in the room, so it is the only one and any
+// socket can cancel it. It writes a 409 and returns false while another answer streams there.
+func (c *ChatController) beginAnswer(ctx *gin.Context, userId, roomId string) (context.Context, bool) {
+	reqCtx, cancel := context.WithCancel(ctx.Request.Context())
+	if !c.hub.begin(userId, roomId, cancel, false) {
+		cancel()
+		utils.ErrorResponse(ctx, http.StatusConflict, "An answer is already streaming in this room")
+		return nil, false
+	}
+	return reqCtx, true
+}
 
-	// Set SSE headers
+// startEventStream sets the SSE headers
+func startEventStream(ctx *gin.Context) {
 	ctx.Writer.Header().Set("Content-Type", "text/event-stream")
 	ctx.Writer.Header().Set("Cache-Control", "no-cache")
 	ctx.Writer.Header().Set("Connection", "keep-alive")
 	ctx.Writer.Flush()
+}
 
-	// Create channels for streaming responses
-	recommendationChan := make(chan string)
-	doneChan := make(chan bool)
+// streamAnswer streams the answer as SSE, the sockets of the user on the room receive it too.
+// generateTitle names a room created without a title.
+func (c *ChatController) streamAnswer(
+	reqCtx context.Context,
+	ctx *gin.Context,
+	userId string,
+	prompt string,
+	location models.GeoLocation,
+	answer *models.Chat,
+	cursor services.AnswerCursor,
+	missed []services.ChatEvent,
+	generateTitle bool,
+) {
+	// The stream stops with the request, so a client that goes away does not keep the model running
+	c.ChatService.StreamAnswer(reqCtx, services.AnswerRequest{
+		UserID:        userId,
+		Prompt:        prompt,
+		Location:      location,
+		Answer:        answer,
+		Cursor:        cursor,
+		Missed:        missed,
+		GenerateTitle: generateTitle,
+	}, &hubAnswerStream{
+		hub:    c.hub,
+		userID: userId,
+		roomID: answer.RoomID,
+		next:   sseAnswerStream{ctx: ctx},
+		// Replayed events were already pushed to the sockets
+		skip: len(missed),
+	})
+}
 
-	if err := c.ChatService.SaveChat(ctx, userId.(string), req.Prompt, roomId, false); err != nil {
-		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to save chat user")
-		return
-	}
+// sseAnswerStream writes an answer as server-sent events, each carrying the cursor a client resumes from as its id
+type sseAnswerStream struct {
+	ctx *gin.Context
+}
 
-	// Start streaming recommendations in a separate goroutine
-	go c.ChatService.StreamRecommendations(ctx, recommendationChan, doneChan, location, formattedPrompt, userId.(string), roomId)
-
-	// Stream results via SSE
-	// Stream results via SSE
-	var recommendations []string // Use []string for better handling
-
-	for {
-		select {
-		case recommendation, ok := <-recommendationChan:
-			if !ok {
-				recommendationChan = nil // Channel closed, stop reading
-			} else {
-				// Convert recommendation to string
-				recStr := fmt.Sprint(recommendation)
-				recommendations = append(recommendations, recStr)
-
-				// Send recommendation event to client
-				ctx.SSEvent("recommendation", recStr)
-				ctx.Writer.Flush() // Ensure event is sent immediately
-			}
-
-		case <-doneChan:
-			// Save all collected recommendations to Firestore
-			err := c.ChatService.SaveChat(ctx, userId.(string), strings.Join(recommendations, ""), roomId, true)
-
-			if err != nil {
-				ctx.SSEvent("error", gin.H{
-					"statusCode": 500,
-					"message":    "Failed to save recommendations",
-				})
-				ctx.Writer.Flush()
-				return
-			}
-
-			// Send final event with all recommendations
-			ctx.SSEvent("done_recommendations", gin.H{
-				"statusCode": 200,
-				"message":    "Recommendation process completed",
-				"data":       recommendations,
-			})
-			ctx.Writer.Flush() // Ensure final event is sent
-			return
-		}
+func (s sseAnswerStream) Event(cursor services.AnswerCursor, event services.ChatEvent) {
+	name, data := "recommendation", interface{}(event.Text)
+	if event.Tool != "" {
+		// Let the client show what HocaAI is looking up
+		name, data = "tool_call", gin.H{"name": event.Tool}
+	} else if event.Card != nil {
+		// Send the restaurant card apart from the prose
+		name, data = "recommendation_card", event.Card
 	}
+	s.ctx.Render(-1, sse.Event{Id: cursor.ID(), Event: name, Data: data})
+	s.ctx.Writer.Flush() // Ensure event is sent immediately
+}
+
+func (s sseAnswerStream) RoomTitled(room *models.Room) {
+	s.ctx.SSEvent("room_title", room)
+	s.ctx.Writer.Flush()
+}
+
+func (s sseAnswerStream) Done(cursor services.AnswerCursor, answer *models.Chat, complete bool) {
+	// Send final event with the whole answer
+	s.ctx.Render(-1, sse.Event{Id: cursor.ID(), Event: "done_recommendations", Data: gin.H{
+		"statusCode": 200,
+		"message":    "Recommendation process completed",
+		"chatId":     answer.ChatID,
+		"complete":   complete,
+		"data":       []string{answer.Chat},
+		"cards":      answer.Recommendations,
+	}})
+	s.ctx.Writer.Flush() // Ensure final event is sent
+}
 
+func (s sseAnswerStream) Failed(err error) {
+	s.ctx.SSEvent("error", gin.H{
+		"statusCode": 500,
+		"message":    "Failed to save recommendations",
+	})
+	s.ctx.Writer.Flush()
 }
@@ -0,0 +1,572 @@
+package controllers
+
+import (
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"HalalMate/services"
+	"HalalMate/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// Types of the frames a client sends on the chat socket
+const (
+	socketPrompt = "prompt"
+	socketCancel = "cancel"
+)
+
+// Types of the frames the server sends on the chat socket
+const (
+	// socketMessage carries a chat saved in the room: a prompt, or the answer about to stream
+	socketMessage            = "message"
+	socketDelta              = "delta"
+	socketRecommendationCard = "recommendation_card"
+	socketToolCall           = "tool_call"
+	socketRoomTitle          = "room_title"
+	socketDone               = "done"
+	socketError              = "error"
+	// socketRoom carries a room created by editing a prompt, it is sent to every socket of the user
+	socketRoom = "room"
+)
+
+const (
+	maxSocketMessageSize = 16 << 10
+	// socketQueueSize is the number of frames a socket may lag behind before it is dropped
+	socketQueueSize    = 256
+	socketWriteTimeout = 10 * time.Second
+	// socketSessionCheck is how often an open socket checks its session was not revoked
+	socketSessionCheck = time.Minute
+	// socketResumeWait bounds how long a resumed answer waits for the stream it replaces to stop
+	socketResumeWait = 5 * time.Second
+)
+
+// socketStatusUnauthorized closes a socket whose token expired or whose session was revoked
+const socketStatusUnauthorized ws.StatusCode = 4401
+
+// socketRequest is a frame sent by the client
+type socketRequest struct {
+	Type      string   `json:"type"`
+	Prompt    string   `json:"prompt"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// socketEvent is a frame sent by the server
+type socketEvent struct {
+	Type string `json:"type"`
+	// RoomID is the room of the frame, the frames of an answer are only sent to the sockets of its room
+	RoomID string `json:"room_id,omitempty"`
+	// ID is the cursor of the answer after the frame, as sent in the SSE ids
+	ID       string                     `json:"id,omitempty"`
+	ChatID   string                     `json:"chat_id,omitempty"`
+	Text     string                     `json:"text,omitempty"`
+	Card     *models.RecommendationCard `json:"card,omitempty"`
+	Tool     string                     `json:"tool,omitempty"`
+	Chat     *models.Chat               `json:"chat,omitempty"`
+	Room     *models.Room               `json:"room,omitempty"`
+	Complete *bool                      `json:"complete,omitempty"`
+	// StatusCode and Message describe an error frame
+	StatusCode int    `json:"statusCode,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// chatSocket is a WebSocket a user opened on a room. Frames are queued and written by writeLoop,
+// so a slow device does not hold the answer back for the others.
+type chatSocket struct {
+	conn   net.Conn
+	userID string
+	roomID string
+
+	queue chan []byte
+	// closing holds the close frame written before the connection is closed
+	closing   chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newChatSocket(conn net.Conn, userID, roomID string) *chatSocket {
+	return &chatSocket{
+		conn:    conn,
+		userID:  userID,
+		roomID:  roomID,
+		queue:   make(chan []byte, socketQueueSize),
+		closing: make(chan []byte, 1),
+		closed:  make(chan struct{}),
+	}
+}
+
+// send queues a JSON frame, the socket is closed when its queue is full
+func (s *chatSocket) send(event socketEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Println("Failed to encode socket frame:", err)
+		return
+	}
+	var frame bytes.Buffer
+	if err := wsutil.WriteServerText(&frame, data); err != nil {
+		return
+	}
+	s.write(frame.Bytes())
+}
+
+func (s *chatSocket) sendError(err error) {
+	event := socketEvent{Type: socketError, StatusCode: http.StatusInternalServerError, Message: "Internal server error"}
+	var customErr *utils.CustomError
+	if errors.As(err, &customErr) {
+		event.StatusCode, event.Message = customErr.StatusCode, customErr.Message
+	}
+	s.send(event)
+}
+
+func (s *chatSocket) write(frame []byte) {
+	select {
+	case <-s.closed:
+	case s.queue <- frame:
+	default:
+		log.Printf("[Chat] Dropping a socket of %s lagging behind room %s", s.userID, s.roomID)
+		s.close()
+	}
+}
+
+func (s *chatSocket) writeLoop() {
+	defer s.conn.Close()
+	for {
+		select {
+		case frame := <-s.queue:
+			if !s.writeFrame(frame) {
+				s.close()
+				return
+			}
+		case frame := <-s.closing:
+			s.writeFrame(frame)
+			s.close()
+			return
+		case <-s.closed:
+			select {
+			case frame := <-s.closing:
+				s.writeFrame(frame)
+			default:
+			}
+			return
+		}
+	}
+}
+
+func (s *chatSocket) writeFrame(frame []byte) bool {
+	s.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	_, err := s.conn.Write(frame)
+	return err == nil
+}
+
+func (s *chatSocket) close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+// shutdown closes the socket with a close frame telling the client why
+func (s *chatSocket) shutdown(code ws.StatusCode, reason string) {
+	var frame bytes.Buffer
+	if err := wsutil.WriteServerMessage(&frame, ws.OpClose, ws.NewCloseFrameBody(code, reason)); err != nil {
+		s.close()
+		return
+	}
+	select {
+	case s.closing <- frame.Bytes():
+	default:
+	}
+}
+
+// chatHub fans the frames of a user out to the sockets they opened, and keeps the answers
+// streaming in each room so any device can cancel them. It only knows the sockets of this instance.
+type chatHub struct {
+	mu sync.Mutex
+	// sockets are indexed by user, a socket receives the answers of its room and the room events of the user
+	sockets map[string]map[*chatSocket]struct{}
+	answers map[string]*hubAnswer
+}
+
+// hubAnswer is an answer streaming in a room
+type hubAnswer struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	// detached answers were asked over a socket, they stop once no socket of the user is left on the room
+	detached bool
+}
+
+func newChatHub() *chatHub {
+	return &chatHub{
+		sockets: make(map[string]map[*chatSocket]struct{}),
+		answers: make(map[string]*hubAnswer),
+	}
+}
+
+func chatHubKey(userID, roomID string) string {
+	return userID + "/" + roomID
+}
+
+func (h *chatHub) join(socket *chatSocket) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.sockets[socket.userID] == nil {
+		h.sockets[socket.userID] = make(map[*chatSocket]struct{})
+	}
+	h.sockets[socket.userID][socket] = struct{}{}
+}
+
+// leave removes the socket, the detached answer of its room is cancelled when it was the last socket on it
+func (h *chatHub) leave(socket *chatSocket) {
+	h.mu.Lock()
+	delete(h.sockets[socket.userID], socket)
+	if len(h.sockets[socket.userID]) == 0 {
+		delete(h.sockets, socket.userID)
+	}
+	for other := range h.sockets[socket.userID] {
+		if other.roomID == socket.roomID {
+			h.mu.Unlock()
+			return
+		}
+	}
+	answer, ok := h.answers[chatHubKey(socket.userID, socket.roomID)]
+	h.mu.Unlock()
+
+	if ok && answer.detached {
+		answer.cancel()
+	}
+}
+
+// publish sends the frame to every socket of the user on the room
+func (h *chatHub) publish(userID, roomID string, event socketEvent) {
+	event.RoomID = roomID
+	for _, socket := range h.userSockets(userID) {
+		if socket.roomID == roomID {
+			socket.send(event)
+		}
+	}
+}
+
+// publishUser sends the frame to every socket of the user, whatever their room
+func (h *chatHub) publishUser(userID string, event socketEvent) {
+	for _, socket := range h.userSockets(userID) {
+		socket.send(event)
+	}
+}
+
+func (h *chatHub) userSockets(userID string) []*chatSocket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sockets := make([]*chatSocket, 0, len(h.sockets[userID]))
+	for socket := range h.sockets[userID] {
+		sockets = append(sockets, socket)
+	}
+	return sockets
+}
+
+// begin registers the answer streaming in the room, it returns false while another one streams
+func (h *chatHub) begin(userID, roomID string, cancel context.CancelFunc, detached bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := chatHubKey(userID, roomID)
+	if _, streaming := h.answers[key]; streaming {
+		return false
+	}
+	h.answers[key] = &hubAnswer{cancel: cancel, done: make(chan struct{}), detached: detached}
+	return true
+}
+
+func (h *chatHub) end(userID, roomID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := chatHubKey(userID, roomID)
+	if answer, ok := h.answers[key]; ok {
+		close(answer.done)
+		delete(h.answers, key)
+	}
+}
+
+// streaming reports whether an answer streams in the room
+func (h *chatHub) streaming(userID, roomID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, ok := h.answers[chatHubKey(userID, roomID)]
+	return ok
+}
+
+// await waits until no answer streams in the room or ctx is done
+func (h *chatHub) await(ctx context.Context, userID, roomID string) {
+	h.mu.Lock()
+	answer, ok := h.answers[chatHubKey(userID, roomID)]
+	h.mu.Unlock()
+
+	if ok {
+		select {
+		case <-answer.done:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// cancel stops the answer streaming in the room, it returns false when there is none
+func (h *chatHub) cancel(userID, roomID string) bool {
+	h.mu.Lock()
+	answer, ok := h.answers[chatHubKey(userID, roomID)]
+	h.mu.Unlock()
+
+	if ok {
+		answer.cancel()
+	}
+	return ok
+}
+
+// hubAnswerStream pushes an answer to the sockets of the room before passing it to next, if any
+type hubAnswerStream struct {
+	hub    *chatHub
+	userID string
+	roomID string
+	next   services.AnswerStream
+	// skip is the number of first events the sockets already received
+	skip int
+}
+
+func (s *hubAnswerStream) Event(cursor services.AnswerCursor, event services.ChatEvent) {
+	if s.skip > 0 {
+		s.skip--
+	} else {
+		frame := socketEvent{Type: socketDelta, ID: cursor.ID(), ChatID: cursor.ChatID, Text: event.Text}
+		if event.Tool != "" {
+			frame = socketEvent{Type: socketToolCall, ID: cursor.ID(), ChatID: cursor.ChatID, Tool: event.Tool}
+		} else if event.Card != nil {
+			frame = socketEvent{Type: socketRecommendationCard, ID: cursor.ID(), ChatID: cursor.ChatID, Card: event.Card}
+		}
+		s.hub.publish(s.userID, s.roomID, frame)
+	}
+	if s.next != nil {
+		s.next.Event(cursor, event)
+	}
+}
+
+func (s *hubAnswerStream) RoomTitled(room *models.Room) {
+	// Every device of the user lists the rooms, not only the ones on this room
+	s.hub.publishUser(s.userID, socketEvent{Type: socketRoomTitle, RoomID: s.roomID, Room: room})
+	if s.next != nil {
+		s.next.RoomTitled(room)
+	}
+}
+
+func (s *hubAnswerStream) Done(cursor services.AnswerCursor, answer *models.Chat, complete bool) {
+	s.hub.publish(s.userID, s.roomID, socketEvent{Type: socketDone, ID: cursor.ID(), ChatID: answer.ChatID, Chat: answer, Complete: &complete})
+	if s.next != nil {
+		s.next.Done(cursor, answer, complete)
+	}
+}
+
+func (s *hubAnswerStream) Failed(err error) {
+	s.hub.publish(s.userID, s.roomID, socketEvent{Type: socketError, StatusCode: http.StatusInternalServerError, Message: "Failed to save recommendations"})
+	if s.next != nil {
+		s.next.Failed(err)
+	}
+}
+
+// ChatSocket upgrades to a WebSocket on the room. The client sends prompt and cancel frames,
+// every socket of the user on the room receives the chats and the answers streamed in it,
+// including the answers requested over SSE, and every socket of the user receives the room events.
+// The socket is closed with 4401 once its token expires or its session is revoked.
+func (c *ChatController) ChatSocket(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+	roomId := ctx.Param("roomId")
+	sessionId := ctx.GetString("sessionId")
+	expiresAt := ctx.GetTime("tokenExpiresAt")
+
+	if _, err := c.ChatService.Rooms.GetRoom(ctx.Request.Context(), userId, roomId); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			utils.ErrorResponse(ctx, http.StatusNotFound, "Room not found")
+			return
+		}
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to get room")
+		return
+	}
+
+	conn, _, _, err := ws.UpgradeHTTP(ctx.Request, ctx.Writer)
+	if err != nil {
+		log.Println("Failed to upgrade chat socket:", err)
+		return
+	}
+
+	socket := newChatSocket(conn, userId, roomId)
+	c.hub.join(socket)
+	defer c.hub.leave(socket)
+	defer socket.close()
+	go socket.writeLoop()
+	go c.watchSocketSession(socket, sessionId, expiresAt)
+
+	// Answers outlive the socket that asked for them, the other devices on the room still receive them
+	answerCtx := context.WithoutCancel(ctx.Request.Context())
+
+	// Control frames are answered through the queue so they never interleave with a data frame
+	var control bytes.Buffer
+	controlHandler := wsutil.ControlFrameHandler(&control, ws.StateServerSide)
+	reader := &wsutil.Reader{
+		Source:         conn,
+		State:          ws.StateServerSide,
+		CheckUTF8:      true,
+		MaxFrameSize:   maxSocketMessageSize,
+		OnIntermediate: controlHandler,
+	}
+	for {
+		header, err := reader.NextFrame()
+		if err != nil {
+			return
+		}
+		if header.OpCode.IsControl() {
+			err := controlHandler(header, reader)
+			if control.Len() > 0 {
+				socket.write(append([]byte(nil), control.Bytes()...))
+				control.Reset()
+			}
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(reader, maxSocketMessageSize+1))
+		if err != nil || len(data) > maxSocketMessageSize {
+			// The rest of an oversized message cannot be skipped, the socket is closed
+			return
+		}
+		if header.OpCode != ws.OpText {
+			continue
+		}
+
+		var req socketRequest
+		if json.Unmarshal(data, &req) != nil {
+			socket.sendError(utils.NewCustomError(http.StatusBadRequest, "Invalid request format"))
+			continue
+		}
+
+		switch req.Type {
+		case socketPrompt:
+			// The token is checked again before any work is done on its behalf
+			if reason := c.socketUnauthorized(answerCtx, sessionId, expiresAt); reason != "" {
+				socket.shutdown(socketStatusUnauthorized, reason)
+				return
+			}
+			c.socketPrompt(answerCtx, socket, req)
+		case socketCancel:
+			if !c.hub.cancel(userId, roomId) {
+				socket.sendError(utils.NewCustomError(http.StatusConflict, "No answer is streaming in this room"))
+			}
+		default:
+			socket.sendError(utils.NewCustomError(http.StatusBadRequest, "type must be one of prompt, cancel"))
+		}
+	}
+}
+
+// socketUnauthorized returns why the token of a socket is no longer accepted, or "" while it is
+func (c *ChatController) socketUnauthorized(ctx context.Context, sessionID string, expiresAt time.Time) string {
+	if !expiresAt.IsZero() && !time.Now().Before(expiresAt) {
+		return "Token has expired"
+	}
+	active, err := c.Sessions.IsSessionActive(ctx, sessionID)
+	if err != nil {
+		// A failed lookup is retried on the next check rather than closing the socket
+		log.Println("Failed to check socket session:", err)
+		return ""
+	}
+	if !active {
+		return "Session has been revoked, please log in again"
+	}
+	return ""
+}
+
+// watchSocketSession closes the socket when its token expires or its session is revoked
+func (c *ChatController) watchSocketSession(socket *chatSocket, sessionID string, expiresAt time.Time) {
+	ticker := time.NewTicker(socketSessionCheck)
+	defer ticker.Stop()
+
+	var expired <-chan time.Time
+	if !expiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(expiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case <-socket.closed:
+			return
+		case <-expired:
+			socket.shutdown(socketStatusUnauthorized, "Token has expired")
+			return
+		case <-ticker.C:
+			if reason := c.socketUnauthorized(context.Background(), sessionID, expiresAt); reason != "" {
+				socket.shutdown(socketStatusUnauthorized, reason)
+				return
+			}
+		}
+	}
+}
+
+// socketPrompt saves the prompt and streams its answer to the sockets of the room
+func (c *ChatController) socketPrompt(parent context.Context, socket *chatSocket, req socketRequest) {
+	prompt := strings.TrimSpace(req.Prompt)
+	if prompt == "" {
+		socket.sendError(utils.NewCustomError(http.StatusBadRequest, "Prompt is required"))
+		return
+	}
+	if req.Latitude == nil || req.Longitude == nil {
+		socket.sendError(utils.NewCustomError(http.StatusBadRequest, "latitude and longitude are required"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	if !c.hub.begin(socket.userID, socket.roomID, cancel, true) {
+		cancel()
+		socket.sendError(utils.NewCustomError(http.StatusConflict, "An answer is already streaming in this room"))
+		return
+	}
+
+	go func() {
+		defer cancel()
+		defer c.hub.end(socket.userID, socket.roomID)
+
+		chat, err := c.ChatService.SaveChat(ctx, socket.userID, prompt, socket.roomID, false, nil)
+		if err != nil {
+			socket.sendError(err)
+			return
+		}
+		c.hub.publish(socket.userID, socket.roomID, socketEvent{Type: socketMessage, Chat: chat})
+
+		answer, err := c.ChatService.StartAnswer(ctx, socket.userID, socket.roomID)
+		if err != nil {
+			socket.sendError(err)
+			return
+		}
+		c.hub.publish(socket.userID, socket.roomID, socketEvent{Type: socketMessage, Chat: answer})
+
+		c.ChatService.StreamAnswer(ctx, services.AnswerRequest{
+			UserID:        socket.userID,
+			Prompt:        prompt,
+			Location:      models.GeoLocation{Latitude: *req.Latitude, Longitude: *req.Longitude},
+			Answer:        answer,
+			Cursor:        services.AnswerCursor{ChatID: answer.ChatID},
+			GenerateTitle: true,
+		}, &hubAnswerStream{hub: c.hub, userID: socket.userID, roomID: socket.roomID})
+	}()
+}
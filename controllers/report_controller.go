@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"HalalMate/services"
+	"HalalMate/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportController struct {
+	ReportService *services.ReportService
+}
+
+// ReportRequest is the body of POST /restaurants/:id/reports
+type ReportRequest struct {
+	Type            string `json:"type" binding:"required"`
+	SuggestedStatus string `json:"suggested_status"`
+	MenuItem        string `json:"menu_item"`
+	Comment         string `json:"comment"`
+	PhotoURL        string `json:"photo_url"`
+}
+
+// ModerationRequest is the optional body of the accept and reject routes
+type ModerationRequest struct {
+	Note string `json:"note"`
+}
+
+func NewReportController() *ReportController {
+	return &ReportController{
+		ReportService: services.NewReportService(),
+	}
+}
+
+// CreateReport lets a user report a restaurant to the moderators
+func (r *ReportController) CreateReport(c *gin.Context) {
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	report, err := r.ReportService.CreateReport(c, c.GetString("userId"), c.Param("id"), services.ReportInput{
+		Type:            req.Type,
+		SuggestedStatus: req.SuggestedStatus,
+		MenuItem:        req.MenuItem,
+		Comment:         req.Comment,
+		PhotoURL:        req.PhotoURL,
+	})
+	if err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Report sent, a moderator will review it", report)
+}
+
+// GetMyReports returns the reports sent by the current user
+func (r *ReportController) GetMyReports(c *gin.Context) {
+	reports, err := r.ReportService.ListUserReports(c, c.GetString("userId"))
+	if err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reports fetched successfully", reports)
+}
+
+// ListReports returns the moderation queue
+func (r *ReportController) ListReports(c *gin.Context) {
+	reports, err := r.ReportService.ListReports(c, c.Query("status"))
+	if err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reports fetched successfully", reports)
+}
+
+// AcceptReport accepts a report and re-evaluates its restaurant
+func (r *ReportController) AcceptReport(c *gin.Context) {
+	r.moderate(c, true)
+}
+
+// RejectReport rejects a report, the restaurant is left unchanged
+func (r *ReportController) RejectReport(c *gin.Context) {
+	r.moderate(c, false)
+}
+
+func (r *ReportController) moderate(c *gin.Context, accept bool) {
+	var req ModerationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+			return
+		}
+	}
+
+	report, restaurant, err := r.ReportService.ModerateReport(c, c.GetString("userId"), c.Param("id"), accept, req.Note)
+	if err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	message := "Report rejected"
+	if accept {
+		message = "Report accepted"
+	}
+	utils.SuccessResponse(c, http.StatusOK, message, gin.H{"report": report, "restaurant": restaurant})
+}
@@ -5,6 +5,7 @@ import (
 	"HalalMate/utils"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +14,19 @@ type RestaurantController struct {
 	RestaurantService *services.RestaurantService
 }
 
+// RestaurantSearchQuery holds the optional filters of GET /restaurants
+type RestaurantSearchQuery struct {
+	Radius     float64 `form:"radius"`
+	Category   string  `form:"category"`
+	MinRating  float64 `form:"min_rating"`
+	PriceRange string  `form:"price_range"`
+	OpenNow    bool    `form:"open_now"`
+	Query      string  `form:"q"`
+	Sort       string  `form:"sort"`
+	Cursor     string  `form:"cursor"`
+	Limit      int     `form:"limit"`
+}
+
 func NewRestaurantController() *RestaurantController {
 	return &RestaurantController{
 		RestaurantService: services.NewRestaurantService(),
@@ -43,17 +57,37 @@ func (s *RestaurantController) GetAllRestaurants(c *gin.Context) {
 		return
 	}
 
-	
+	var query RestaurantSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid search parameters")
+		return
+	}
 
-	restaurants, err := s.RestaurantService.GetAllRestaurantByLocation(c, latitude, longitude, userId.(string))
+	page, err := s.RestaurantService.SearchRestaurants(c, userId.(string), services.RestaurantSearch{
+		Latitude:   latitude,
+		Longitude:  longitude,
+		RadiusKm:   query.Radius,
+		Category:   strings.TrimSpace(query.Category),
+		MinRating:  query.MinRating,
+		PriceRange: strings.TrimSpace(query.PriceRange),
+		OpenNow:    query.OpenNow,
+		Query:      strings.TrimSpace(query.Query),
+		Sort:       query.Sort,
+		Cursor:     query.Cursor,
+		Limit:      query.Limit,
+	})
 	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			utils.ErrorResponse(c, customErr.StatusCode, customErr.Message)
+			return
+		}
 		// Log the error for debugging purposes
 		print("Error fetching restaurants: ", err)
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Error fetching restaurants")
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Restaurants fetched successfully", restaurants)
+	utils.SuccessResponse(c, http.StatusOK, "Restaurants fetched successfully", page)
 }
 
 func (s *RestaurantController) GetRestaurantByID(c *gin.Context) {
@@ -28,22 +28,21 @@ func (c *RoomController) CreateRoom(ctx *gin.Context) {
 		return
 	}
 
-	// Extract title from request body
+	// Extract title from request body, without a title auto_title lets the first prompt name the room
 	var requestBody struct {
-		Title string `json:"title" binding:"required"`
+		Title     string `json:"title"`
+		AutoTitle bool   `json:"auto_title"`
 	}
 
 	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, "Title is required")
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid request format")
 		return
 	}
 
-	title := requestBody.Title
-
-	room, err := c.RoomService.SaveRoom(ctx, userId.(string), title)
+	room, err := c.RoomService.SaveRoom(ctx, userId.(string), requestBody.Title, requestBody.AutoTitle)
 
 	if err != nil {
-		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to create room chat")
+		ctx.Error(err) // Middleware akan menangani error ini
 		return
 	}
 
@@ -62,14 +61,23 @@ func (c *RoomController) GetAllRoom(ctx *gin.Context) {
 		return
 	}
 
-	rooms, err := c.RoomService.GetRooms(ctx, userId.(string))
+	var query struct {
+		Cursor string `form:"cursor"`
+		Limit  int    `form:"limit"`
+	}
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid query parameters")
+		return
+	}
+
+	page, err := c.RoomService.ListRooms(ctx, userId.(string), query.Cursor, query.Limit)
 
 	if err != nil {
-		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to get rooms")
+		ctx.Error(err) // Middleware akan menangani error ini
 		return
 	}
 
-	utils.SuccessResponse(ctx, http.StatusOK, "Rooms fetched successfully", rooms)
+	utils.SuccessResponse(ctx, http.StatusOK, "Rooms fetched successfully", page)
 }
 
 func (c *RoomController) GetSpesificRoom(ctx *gin.Context) {
@@ -97,3 +105,44 @@ func (c *RoomController) GetSpesificRoom(ctx *gin.Context) {
 	// Response sukses
 	utils.SuccessResponse(ctx, http.StatusOK, "Room fetched successfully", roomWithChat)
 }
+
+// RenameRoom sets the title of a room
+func (c *RoomController) RenameRoom(ctx *gin.Context) {
+	userId, exists := ctx.Get("userId")
+	if !exists {
+		utils.ErrorResponse(ctx, http.StatusUnauthorized, "UserId is required")
+		return
+	}
+
+	var requestBody struct {
+		Title string `json:"title" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Title is required")
+		return
+	}
+
+	room, err := c.RoomService.RenameRoom(ctx.Request.Context(), userId.(string), ctx.Param("roomId"), requestBody.Title)
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Room renamed successfully", room)
+}
+
+// DeleteRoom deletes a room and its chats
+func (c *RoomController) DeleteRoom(ctx *gin.Context) {
+	userId, exists := ctx.Get("userId")
+	if !exists {
+		utils.ErrorResponse(ctx, http.StatusUnauthorized, "UserId is required")
+		return
+	}
+
+	if err := c.RoomService.DeleteRoom(ctx.Request.Context(), userId.(string), ctx.Param("roomId")); err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Room deleted successfully", nil)
+}
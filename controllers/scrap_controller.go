@@ -4,23 +4,28 @@ import (
 	"HalalMate/models"
 	"HalalMate/services"
 	"HalalMate/utils"
-	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// scrapeViewerPoll is how often a live view re-reads its job, to follow a job a worker
+// of another instance runs, whose events never reach this one
+const scrapeViewerPoll = 5 * time.Second
+
 // AuthHandler struct
 type ScrapController struct {
-	ScrapService *services.ScrapService
+	ScrapService     *services.ScrapService
+	ScrapeJobService *services.ScrapeJobService
 }
 
-// NewAuthHandler initializes AuthHandler
-func NewScrapController() *ScrapController {
+// NewScrapController initializes ScrapController on the job service whose workers main started
+func NewScrapController(scrapeJobService *services.ScrapeJobService) *ScrapController {
 	return &ScrapController{
-		ScrapService: services.NewScrapService(services.NewOpenAIService()),
+		ScrapService:     scrapeJobService.ScrapService,
+		ScrapeJobService: scrapeJobService,
 	}
 }
 
@@ -31,23 +36,40 @@ type ScrapeRequest struct {
 	Keyword   string  `json:"keyword"`
 }
 
-// ScrapeHandler processes the incoming request and calls the service
+// GetAllScrapePlaces streams a scrape job over SSE. Without ?job_id= it queues a new job from the body.
+// Places come from the workers of this instance as they are scraped, and from the stored job otherwise.
+// Closing the connection only stops the stream, the job keeps running.
 func (h *ScrapController) GetAllScrapePlaces(c *gin.Context) {
-	var req ScrapeRequest
+	var job *models.ScrapeJob
+	var err error
 
-	// Bind JSON request
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+	if jobID := c.Query("job_id"); jobID != "" {
+		job, err = h.ScrapeJobService.GetJob(c, jobID)
+	} else {
+		var req ScrapeRequest
+
+		// Bind JSON request
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+			return
+		}
+
+		job, err = h.ScrapeJobService.CreateJob(c, req.Latitude, req.Longitude, req.Keyword, c.GetString("userId"))
+	}
+	if err != nil {
+		c.Error(err) // Middleware akan menangani error ini
 		return
 	}
 
-	formattedKeyword := strings.ReplaceAll(req.Keyword, " ", "+")
-	latitude := fmt.Sprintf("%.7f", req.Latitude)
-	longitude := fmt.Sprintf("%.7f", req.Longitude)
+	log.Println("Streaming scrape job:", job.ID, job.URL)
 
-	url := fmt.Sprintf("https://www.google.com/maps/search/%s/@%s,%s,18.5z", formattedKeyword, latitude, longitude)
+	// Subscribe before reading the snapshot so no event is missed in between
+	events, unsubscribe := h.ScrapeJobService.Subscribe(job.ID)
+	defer unsubscribe()
 
-	log.Println("Scraping URL:", url)
+	if snapshot, err := h.ScrapeJobService.GetJob(c, job.ID); err == nil {
+		job = snapshot
+	}
 
 	// Set SSE headers ONCE and FIRST (before any writing)
 	c.Header("Content-Type", "text/event-stream")
@@ -55,33 +77,112 @@ func (h *ScrapController) GetAllScrapePlaces(c *gin.Context) {
 	c.Header("Connection", "keep-alive")
 	// Remove manual CORS headers - your middleware handles this
 
+	// The job with the progress made so far
+	c.SSEvent("job_scrap", job)
 	c.Writer.Flush()
 
-	// Create channels to stream results
-	placeChan := make(chan models.Place)
-	doneChan := make(chan bool)
+	if job.IsFinished() {
+		h.sendScrapeDone(c, job)
+		return
+	}
+
+	// Places are sent once, whether the event or the stored job brings them first
+	sent := make(map[string]bool, len(job.Places))
+	for _, place := range job.Places {
+		sent[place.MapsLink] = true
+	}
 
-	// Start scraping in a separate goroutine
-	go h.ScrapService.ScrapePlaces([]string{url}, placeChan, doneChan, latitude, longitude)
+	poll := time.NewTicker(scrapeViewerPoll)
+	defer poll.Stop()
 
 	// Stream results via SSE
 	for {
 		select {
-		case place, ok := <-placeChan:
-			if !ok {
-				placeChan = nil // Channel closed, stop reading
-			} else {
+		case <-poll.C:
+			snapshot, err := h.ScrapeJobService.GetJob(c, job.ID)
+			if err != nil {
+				continue
+			}
+			for _, place := range snapshot.Places {
+				if sent[place.MapsLink] {
+					continue
+				}
+				sent[place.MapsLink] = true
 				c.SSEvent("place_scrap", place)
-				c.Writer.Flush()
 			}
-		case <-doneChan:
-			c.SSEvent("done_scrap", gin.H{"statusCode": 200, "message": "Scraping completed", "data": nil})
 			c.Writer.Flush()
+			if snapshot.IsFinished() {
+				h.sendScrapeDone(c, snapshot)
+				return
+			}
+		case event, ok := <-events:
+			if !ok || event.Type == services.ScrapeJobEventDone {
+				finished := event.Job
+				if finished == nil {
+					if finished, err = h.ScrapeJobService.GetJob(c, job.ID); err != nil {
+						finished = job
+					}
+				}
+				h.sendScrapeDone(c, finished)
+				return
+			}
+			if sent[event.Place.MapsLink] {
+				continue
+			}
+			sent[event.Place.MapsLink] = true
+			c.SSEvent("place_scrap", event.Place)
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			log.Println("Scrape stream closed by client, job continues:", job.ID)
 			return
 		}
 	}
 }
 
+func (h *ScrapController) sendScrapeDone(c *gin.Context, job *models.ScrapeJob) {
+	c.SSEvent("done_scrap", gin.H{"statusCode": 200, "message": "Scraping " + job.Status, "data": job})
+	c.Writer.Flush()
+}
+
+// CreateScrapeJob queues a scrape job and returns it immediately
+func (h *ScrapController) CreateScrapeJob(c *gin.Context) {
+	var req ScrapeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	job, err := h.ScrapeJobService.CreateJob(c, req.Latitude, req.Longitude, req.Keyword, c.GetString("userId"))
+	if err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusAccepted, "Scrape job queued", job)
+}
+
+// GetScrapeJob returns the state and progress of a job
+func (h *ScrapController) GetScrapeJob(c *gin.Context) {
+	job, err := h.ScrapeJobService.GetJob(c, c.Param("id"))
+	if err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scrape job fetched successfully", job)
+}
+
+// CancelScrapeJob cancels a queued or running job
+func (h *ScrapController) CancelScrapeJob(c *gin.Context) {
+	job, err := h.ScrapeJobService.CancelJob(c, c.Param("id"))
+	if err != nil {
+		c.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scrape job cancelled", job)
+}
+
 func (c *ScrapController) ScrapeSinglePlace(ctx *gin.Context) {
 	mapsLink := ctx.Query("mapsLink")
 	if mapsLink == "" {
@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"HalalMate/llm"
+	"HalalMate/models"
 	"HalalMate/services"
 	"HalalMate/utils"
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,6 +20,7 @@ import (
 type SnackController struct {
 	SnackService  *services.SnackService
 	OpenAIService *services.OpenAIService
+	UserService   *services.UserService
 }
 
 type ScanRequest struct {
@@ -27,9 +31,21 @@ func NewSnackController() *SnackController {
 	return &SnackController{
 		SnackService:  services.NewSnackService(),
 		OpenAIService: services.NewOpenAIService(),
+		UserService:   services.NewUserService(),
 	}
 }
 
+// withDietaryRules appends the dietary preferences of the signed in user to the verdict prompt,
+// anonymous scans keep the default standard. It answers the request itself when it fails.
+func (sc *SnackController) withDietaryRules(c *gin.Context, systemPrompt string) (string, bool) {
+	prefs, err := sc.UserService.GetDietaryPreferences(c, c.GetString("userId"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get dietary preferences")
+		return "", false
+	}
+	return systemPrompt + services.SnackDietaryRules(prefs), true
+}
+
 func (sc *SnackController) ScanSnackByBarcode(c *gin.Context) {
 	barcode := c.Param("barcode")
 	if barcode == "" {
@@ -53,14 +69,14 @@ func (sc *SnackController) ScanSnackByBarcode(c *gin.Context) {
 		return
 	}
 
-	var hasil map[string]interface{}
+	var hasil *models.SnackVerdict
 
 	if product == nil {
-		hasil = map[string]interface{}{
-			"Status":      "Tidak Dapat Menentukan",
-			"Reason":      "Produk tidak ditemukan di database OpenFoodFacts",
-			"ProductName": "",
-			"Suggest":     []interface{}{},
+		hasil = &models.SnackVerdict{
+			Status:      models.SnackStatusUnknown,
+			Reason:      "Produk tidak ditemukan di database OpenFoodFacts",
+			ProductName: "",
+			Suggest:     []models.SnackSuggestion{},
 		}
 	} else {
 		fmt.Println("Product:", product)
@@ -97,9 +113,14 @@ func (sc *SnackController) ScanSnackByBarcode(c *gin.Context) {
 		Kembalikan hanya JSON murni tanpa tanda apapun di sekelilingnya.`, location)
 
 		productString := fmt.Sprintf("%v", product) // Convert product to string
-		hasil, err = sc.OpenAIService.Chat(c, systemPrompt, productString)
+		systemPrompt, ok := sc.withDietaryRules(c, systemPrompt)
+		if !ok {
+			return
+		}
+
+		hasil, err = sc.OpenAIService.SnackVerdict(c, systemPrompt, productString)
 		if err != nil {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process snack information")
+			utils.ErrorResponse(c, verdictErrorStatus(err), "Failed to process snack information")
 			return
 
 		}
@@ -191,9 +212,14 @@ func (sc *SnackController) ScanSnackByImage(c *gin.Context) {
 	`, location)
 
 	// Call ke OpenAI
-	result, err := sc.OpenAIService.ChatWithVision(c, systemPrompt, []string{frontBase64, backBase64})
+	systemPrompt, ok := sc.withDietaryRules(c, systemPrompt)
+	if !ok {
+		return
+	}
+
+	result, err := sc.OpenAIService.SnackVerdictFromImages(c, systemPrompt, []string{frontBase64, backBase64})
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process images with AI")
+		utils.ErrorResponse(c, verdictErrorStatus(err), "Failed to process images with AI")
 		return
 	}
 
@@ -212,8 +238,6 @@ func (sc *SnackController) SearchSnackByInput(c *gin.Context) {
 		return
 	}
 
-	log.Printf("[INFO] User login attempt: Email - %s", req.NameProduct)
-
 	systemPrompt := fmt.Sprintf(`Kamu adalah pakar makanan halal.
 
 Langkah-langkahmu adalah:
@@ -248,10 +272,15 @@ Jika produk tersebut berasal dari merek ternama dan tidak mengandung bahan mencu
 
 Jika kamu tidak menemukan bahan-bahannya, berikan alternatif nama produk nyata yang mirip.`, req.NameProduct)
 
-	result, err := sc.OpenAIService.Chat(c, systemPrompt, userPrompt)
+	systemPrompt, ok := sc.withDietaryRules(c, systemPrompt)
+	if !ok {
+		return
+	}
+
+	result, err := sc.OpenAIService.SnackVerdict(c, systemPrompt, userPrompt)
 	if err != nil {
 		log.Println("[ERROR] Failed to process snack search:", err)
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process snack search")
+		utils.ErrorResponse(c, verdictErrorStatus(err), "Failed to process snack search")
 		return
 	}
 	utils.SuccessResponse(c, http.StatusOK, "Search snack berhasil", result)
@@ -311,9 +340,14 @@ func (sc *SnackController) ScanWithFrontOnly(c *gin.Context) {
 
 	Jangan gunakan markdown atau format tambahan lain.`, location)
 
-	result, err := sc.OpenAIService.ChatWithVision(c, systemPrompt, []string{frontBase64})
+	systemPrompt, ok := sc.withDietaryRules(c, systemPrompt)
+	if !ok {
+		return
+	}
+
+	result, err := sc.OpenAIService.SnackVerdictFromImages(c, systemPrompt, []string{frontBase64})
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process front image")
+		utils.ErrorResponse(c, verdictErrorStatus(err), "Failed to process front image")
 		return
 	}
 
@@ -386,9 +420,14 @@ Tugasmu:
 Balas hanya dengan JSON valid. Jangan beri narasi tambahan.
 `, location)
 
-	result, err := sc.OpenAIService.ChatWithVision(c, systemPrompt, []string{frontBase64, backBase64})
+	systemPrompt, ok := sc.withDietaryRules(c, systemPrompt)
+	if !ok {
+		return
+	}
+
+	result, err := sc.OpenAIService.SnackVerdictFromImages(c, systemPrompt, []string{frontBase64, backBase64})
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process front and back images")
+		utils.ErrorResponse(c, verdictErrorStatus(err), "Failed to process front and back images")
 		return
 	}
 
@@ -508,10 +547,15 @@ Aturan tambahan:
 
 `, productInfo, location)
 
-	result, err := sc.OpenAIService.ChatWithVisionAndData(c, systemPrompt, []string{frontBase64, backBase64}, userPrompt)
+	systemPrompt, ok := sc.withDietaryRules(c, systemPrompt)
+	if !ok {
+		return
+	}
+
+	result, err := sc.OpenAIService.SnackVerdictFromImagesAndData(c, systemPrompt, []string{frontBase64, backBase64}, userPrompt)
 
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process full data")
+		utils.ErrorResponse(c, verdictErrorStatus(err), "Failed to process full data")
 		return
 	}
 
@@ -528,3 +572,11 @@ func EncodeImageToBase64(file multipart.File) (string, error) {
 	base64Str := base64.StdEncoding.EncodeToString(buf.Bytes())
 	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64Str), nil
 }
+
+// verdictErrorStatus answers 502 when the model kept returning an invalid verdict
+func verdictErrorStatus(err error) int {
+	if errors.Is(err, llm.ErrInvalidOutput) {
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
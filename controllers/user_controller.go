@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"HalalMate/models"
 	"HalalMate/services"
 	"HalalMate/utils"
 	"net/http"
@@ -39,3 +40,142 @@ func (h *UserController) GetUserProfile(ctx *gin.Context) {
 
 	utils.SuccessResponse(ctx, http.StatusOK, "success fetch User profile", user)
 }
+
+// GrantRole grants the :role of the path to the user :id
+func (h *UserController) GrantRole(ctx *gin.Context) {
+	profile, err := h.UserService.GrantRole(ctx, ctx.Param("id"), ctx.Param("role"))
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Role granted", profile)
+}
+
+// RevokeRole revokes the :role of the path from the user :id
+func (h *UserController) RevokeRole(ctx *gin.Context) {
+	profile, err := h.UserService.RevokeRole(ctx, ctx.GetString("userId"), ctx.Param("id"), ctx.Param("role"))
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Role revoked", profile)
+}
+
+// UpdateProfile changes the username, avatar, preferred language or home city, fields left out are kept
+func (h *UserController) UpdateProfile(ctx *gin.Context) {
+	var req struct {
+		Username          *string `json:"username"`
+		AvatarURL         *string `json:"avatar_url"`
+		PreferredLanguage *string `json:"preferred_language"`
+		HomeCity          *string `json:"home_city"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	profile, err := h.UserService.UpdateProfile(ctx, ctx.GetString("userId"), services.ProfileUpdate{
+		Username:          req.Username,
+		AvatarURL:         req.AvatarURL,
+		PreferredLanguage: req.PreferredLanguage,
+		HomeCity:          req.HomeCity,
+	})
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Profile updated", profile)
+}
+
+// UpdateDietaryPreferences replaces the dietary preferences applied to restaurants, recommendations and snack scans
+func (h *UserController) UpdateDietaryPreferences(ctx *gin.Context) {
+	var req models.DietaryPreferences
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	profile, err := h.UserService.UpdateDietaryPreferences(ctx, ctx.GetString("userId"), req)
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Dietary preferences updated", profile)
+}
+
+// ChangePassword replaces the password of an account that has one
+func (h *UserController) ChangePassword(ctx *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+		RetypedPassword string `json:"retyped_password" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+	if req.NewPassword != req.RetypedPassword {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Passwords do not match")
+		return
+	}
+
+	err := h.UserService.ChangePassword(ctx, ctx.GetString("userId"), ctx.GetString("sessionId"), req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Password changed", nil)
+}
+
+// LinkPassword sets a password on an account created with Google
+func (h *UserController) LinkPassword(ctx *gin.Context) {
+	var req struct {
+		Password        string `json:"password" binding:"required"`
+		RetypedPassword string `json:"retyped_password" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+	if req.Password != req.RetypedPassword {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Passwords do not match")
+		return
+	}
+
+	err := h.UserService.LinkPassword(ctx, ctx.GetString("userId"), ctx.GetString("sessionId"), req.Password)
+	if err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Password linked", nil)
+}
+
+// DeleteAccount deletes the user with its bookmarks, rooms and chats
+func (h *UserController) DeleteAccount(ctx *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+	}
+
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid request format")
+			return
+		}
+	}
+
+	if err := h.UserService.DeleteAccount(ctx, ctx.GetString("userId"), req.Password); err != nil {
+		ctx.Error(err) // Middleware akan menangani error ini
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Account deleted", nil)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"HalalMate/controllers"
+	"HalalMate/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,6 +16,14 @@ func RegisterAuthRoutes(router *gin.RouterGroup, authController *controllers.Aut
 		scraperGroup.POST("/register", authController.RegisterUser)
 		scraperGroup.POST("/login", authController.LoginUser)
 		scraperGroup.POST("/google", authController.GoogleLogin)
+		scraperGroup.POST("/refresh", authController.RefreshToken)
+		scraperGroup.POST("/logout", middleware.AuthMiddleware(), authController.Logout)
+		scraperGroup.POST("/verify-email", authController.VerifyEmail)
+		scraperGroup.POST("/resend-verification", middleware.AuthMiddleware(), authController.ResendVerification)
+		scraperGroup.POST("/forgot-password", authController.ForgotPassword)
+		scraperGroup.POST("/reset-password", authController.ResetPassword)
+		scraperGroup.POST("/fcm-token", middleware.AuthMiddleware(), authController.StoreFCMToken)
+		scraperGroup.DELETE("/fcm-token", middleware.AuthMiddleware(), authController.RemoveFCMToken)
 	}
 
 }
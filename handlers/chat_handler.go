@@ -3,6 +3,7 @@ package handlers
 import (
 	"HalalMate/controllers"
 	"HalalMate/middleware"
+	"HalalMate/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,6 +12,16 @@ func RegisterChatRoutes(router *gin.RouterGroup, chatController *controllers.Cha
 	chatGroup := router.Group("/hoca")
 	{
 		chatGroup.POST("/chat/:roomId", middleware.AuthMiddleware(),chatController.ChatRecomendation)
+		chatGroup.POST("/room/:roomId/regenerate", middleware.AuthMiddleware(), chatController.Regenerate)
+		chatGroup.POST("/room/:roomId/chat/:chatId/edit", middleware.AuthMiddleware(), chatController.EditPrompt)
+		chatGroup.PUT("/room/:roomId/chat/:chatId/rating", middleware.AuthMiddleware(), chatController.RateChat)
+		chatGroup.GET("/ws/:roomId", middleware.AuthMiddleware(), chatController.ChatSocket)
 
 	}
+
+	// Rated answers are exported by the admins to build evaluation datasets
+	adminGroup := router.Group("/admin", middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin))
+	{
+		adminGroup.GET("/chat-ratings", chatController.ListRatings)
+	}
 }
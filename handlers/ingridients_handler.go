@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"HalalMate/controllers"
+	"HalalMate/middleware"
+	"HalalMate/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -9,7 +11,7 @@ import (
 func RegisterIngridentsRoutes(router *gin.RouterGroup, ingridentController *controllers.IngridientController) {
 	ingredientsRoutes := router.Group("/ingridients")
 	{
-		ingredientsRoutes.POST("", ingridentController.CreateIngridient)
+		ingredientsRoutes.POST("", middleware.AuthMiddleware(), middleware.RequireRole(models.RoleModerator, models.RoleAdmin), ingridentController.CreateIngridient)
 		ingredientsRoutes.GET("", ingridentController.GetAllIngridient)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"HalalMate/controllers"
+	"HalalMate/middleware"
+	"HalalMate/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterReportRoutes sets up the community report and moderation routes
+func RegisterReportRoutes(router *gin.RouterGroup, reportController *controllers.ReportController) {
+	router.POST("/restaurants/:id/reports", middleware.AuthMiddleware(), reportController.CreateReport)
+	router.GET("/reports", middleware.AuthMiddleware(), reportController.GetMyReports)
+
+	moderationGroup := router.Group("/moderation", middleware.AuthMiddleware(), middleware.RequireRole(models.RoleModerator, models.RoleAdmin))
+	{
+		moderationGroup.GET("/reports", reportController.ListReports)
+		moderationGroup.POST("/reports/:id/accept", reportController.AcceptReport)
+		moderationGroup.POST("/reports/:id/reject", reportController.RejectReport)
+	}
+}
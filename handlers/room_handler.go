@@ -13,6 +13,8 @@ func RegisterRoomRoutes(router *gin.RouterGroup, roomController *controllers.Roo
 		roomGroup.POST("/room", middleware.AuthMiddleware(), roomController.CreateRoom)
 		roomGroup.GET("/room", middleware.AuthMiddleware(), roomController.GetAllRoom)
 		roomGroup.GET("/room/:roomId", middleware.AuthMiddleware(), roomController.GetSpesificRoom)
+		roomGroup.PATCH("/room/:roomId", middleware.AuthMiddleware(), roomController.RenameRoom)
+		roomGroup.DELETE("/room/:roomId", middleware.AuthMiddleware(), roomController.DeleteRoom)
 
 	}
 }
@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"HalalMate/controllers"
+	"HalalMate/middleware"
+	"HalalMate/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // RegisterScraperRoutes sets up the scraper-related routes
 func RegisterScraperRoutes(router *gin.RouterGroup, scraperController *controllers.ScrapController) {
-	scraperGroup := router.Group("/scraper")
+	// Scraping drives a browser and writes restaurants, only admins may start it
+	scraperGroup := router.Group("/scraper", middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin))
 	{
 		// Routes with trailing slash
 		// scraperGroup.GET("/", scraperController.GetAllScrapePlaces)
@@ -19,6 +22,10 @@ func RegisterScraperRoutes(router *gin.RouterGroup, scraperController *controlle
 		scraperGroup.POST("", scraperController.GetAllScrapePlaces)
 
 		scraperGroup.POST("/restaurant", scraperController.ScrapeSinglePlace)
+
+		scraperGroup.POST("/jobs", scraperController.CreateScrapeJob)
+		scraperGroup.GET("/jobs/:id", scraperController.GetScrapeJob)
+		scraperGroup.DELETE("/jobs/:id", scraperController.CancelScrapeJob)
 		scraperGroup.GET("/test", func(c *gin.Context) {
 			c.JSON(200, gin.H{"message": "CORS test successful"})
 		})
@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"HalalMate/controllers"
+	"HalalMate/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterSnackRoutes(router *gin.RouterGroup, snackController *controllers.SnackController) {
-	snackGroup := router.Group("/snack")
+	// Signed in users get the verdict for their own dietary preferences
+	snackGroup := router.Group("/snack", middleware.OptionalAuthMiddleware())
 	{
 		// snackGroup.POST("/:barcode", snackController.ScanSnackByBarcode)
 		snackGroup.POST("/image", snackController.ScanSnackByImage)
@@ -3,6 +3,7 @@ package handlers
 import (
 	"HalalMate/controllers"
 	"HalalMate/middleware"
+	"HalalMate/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,6 +13,17 @@ func RegisterUserRoutes(router *gin.RouterGroup, userController *controllers.Use
 	userGroup := router.Group("/users")
 	{
 		userGroup.GET("/profile", middleware.AuthMiddleware(), userController.GetUserProfile)
+		userGroup.PATCH("/profile", middleware.AuthMiddleware(), userController.UpdateProfile)
+		userGroup.PUT("/preferences", middleware.AuthMiddleware(), userController.UpdateDietaryPreferences)
+		userGroup.PUT("/password", middleware.AuthMiddleware(), userController.ChangePassword)
+		userGroup.POST("/password", middleware.AuthMiddleware(), userController.LinkPassword)
+		userGroup.DELETE("/me", middleware.AuthMiddleware(), userController.DeleteAccount)
+	}
+
+	adminGroup := router.Group("/admin", middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin))
+	{
+		adminGroup.PUT("/users/:id/roles/:role", userController.GrantRole)
+		adminGroup.DELETE("/users/:id/roles/:role", userController.RevokeRole)
 	}
 
 }
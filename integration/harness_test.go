@@ -0,0 +1,334 @@
+// Package integration tests the v1 routes end to end against the in-memory store,
+// or the Firestore emulator when FIRESTORE_EMULATOR_HOST is set, and a stubbed OpenAI server.
+//
+//	go test ./integration
+//	FIRESTORE_EMULATOR_HOST=localhost:8081 go test ./integration -run Emulator
+//
+// The in-memory store does not enforce Firestore query rules, make test-emulator
+// starts the emulator and runs the scenarios against it.
+package integration
+
+import (
+	"HalalMate/mailer"
+	"HalalMate/middleware"
+	"HalalMate/notifier"
+	"HalalMate/repositories"
+	v1 "HalalMate/routes/v1"
+	"HalalMate/services"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultEmulatorProjectID = "halalmate-integration"
+
+// Ids of the users the harness mints staff tokens for, they have no user document
+const (
+	ModeratorUserID = "integration-moderator"
+	AdminUserID     = "integration-admin"
+)
+
+// Harness owns the API server, the OpenAI stub and the backing store
+type Harness struct {
+	Server *httptest.Server
+	OpenAI *OpenAIStub
+	Store  *repositories.Store
+
+	projectID    string
+	emulatorHost string
+	firestore    *firestore.Client
+	// previousEnv holds the values replaced by setEnv, restored by Close
+	previousEnv map[string]string
+	mailDir     string
+}
+
+// Response mirrors utils.Response with a raw data payload
+type Response struct {
+	StatusCode int             `json:"-"`
+	Message    string          `json:"message"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Event is one server-sent event
+type Event struct {
+	ID   string
+	Name string
+	Data string
+}
+
+// NewHarness starts the API against the Firestore emulator. When useMemory is
+// true the in-memory store is used instead, which needs no emulator.
+func NewHarness(ctx context.Context, useMemory bool) (*Harness, error) {
+	h := &Harness{
+		OpenAI:      NewOpenAIStub(),
+		previousEnv: make(map[string]string),
+	}
+
+	if useMemory {
+		h.Store = repositories.NewMemoryStore()
+	} else {
+		h.emulatorHost = os.Getenv("FIRESTORE_EMULATOR_HOST")
+		if h.emulatorHost == "" {
+			h.OpenAI.Close()
+			return nil, errors.New("FIRESTORE_EMULATOR_HOST is not set")
+		}
+
+		h.projectID = os.Getenv("FIREBASE_PROJECT_ID")
+		if h.projectID == "" {
+			h.projectID = defaultEmulatorProjectID
+		}
+
+		if err := h.ResetEmulator(ctx); err != nil {
+			h.OpenAI.Close()
+			return nil, err
+		}
+
+		// The client talks to the emulator because FIRESTORE_EMULATOR_HOST is set
+		client, err := firestore.NewClient(ctx, h.projectID)
+		if err != nil {
+			h.OpenAI.Close()
+			return nil, fmt.Errorf("failed to create emulator client: %w", err)
+		}
+		h.firestore = client
+		h.Store = repositories.NewFirestoreStore(client)
+	}
+
+	mailDir, err := os.MkdirTemp("", "halalmate-mail")
+	if err != nil {
+		h.OpenAI.Close()
+		return nil, err
+	}
+	h.mailDir = mailDir
+
+	// Services read their dependencies when the routes are registered
+	h.setEnv("OPENAI_BASE_URL", h.OpenAI.URL())
+	// Tokens only need to live as long as the run
+	h.setEnv("JWT_ALLOW_EPHEMERAL_SECRET", "true")
+	// Emails land in a file the scenarios read back, their tokens are not turned into links
+	h.setEnv("MAILER_DRIVER", mailer.DriverFile)
+	h.setEnv("MAILER_FILE", filepath.Join(mailDir, "outbox.jsonl"))
+	h.setEnv("APP_BASE_URL", "")
+	// Push notifications are recorded in the same directory
+	h.setEnv("NOTIFIER_DRIVER", notifier.DriverFile)
+	h.setEnv("NOTIFIER_FILE", filepath.Join(mailDir, "pushes.jsonl"))
+	repositories.SetStore(h.Store)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.RedirectTrailingSlash = false
+	router.RedirectFixedPath = false
+	router.Use(middleware.ErrorHandlerMiddleware())
+	// Scrape workers are never started, jobs stay queued as there is no browser to run them
+	v1.RegisterRoutes(router, services.NewScrapeJobService(services.NewScrapService(services.NewOpenAIService())))
+
+	h.Server = httptest.NewServer(router)
+	return h, nil
+}
+
+// Close stops every server and restores the environment
+func (h *Harness) Close() {
+	h.Server.Close()
+	h.OpenAI.Close()
+	if h.firestore != nil {
+		h.firestore.Close()
+	}
+	for key, value := range h.previousEnv {
+		os.Setenv(key, value)
+	}
+	os.RemoveAll(h.mailDir)
+}
+
+func (h *Harness) setEnv(key, value string) {
+	if _, saved := h.previousEnv[key]; !saved {
+		h.previousEnv[key] = os.Getenv(key)
+	}
+	os.Setenv(key, value)
+}
+
+// Mails returns every email sent so far, oldest first
+func (h *Harness) Mails() ([]mailer.Message, error) {
+	return mailer.ReadMessages(filepath.Join(h.mailDir, "outbox.jsonl"))
+}
+
+// Pushes returns every push notification sent so far, oldest first
+func (h *Harness) Pushes() ([]notifier.Push, error) {
+	return notifier.ReadPushes(filepath.Join(h.mailDir, "pushes.jsonl"))
+}
+
+// ResetEmulator deletes every document of the emulator project
+func (h *Harness) ResetEmulator(ctx context.Context) error {
+	url := fmt.Sprintf("http://%s/emulator/v1/projects/%s/databases/(default)/documents", h.emulatorHost, h.projectID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Firestore emulator: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to reset Firestore emulator: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (h *Harness) newRequest(method, path, token string, body io.Reader, contentType string) (*http.Request, error) {
+	req, err := http.NewRequest(method, h.Server.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// Do sends a JSON request and decodes the standard response envelope
+func (h *Harness) Do(method, path, token string, body interface{}) (*Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := h.newRequest(method, path, token, reader, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	return h.send(req)
+}
+
+// Multipart sends a multipart form with the given fields and files
+func (h *Harness) Multipart(path, token string, fields map[string]string, files map[string][]byte) (*Response, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	for name, content := range files {
+		part, err := writer.CreateFormFile(name, name+".png")
+		if err != nil {
+			return nil, err
+		}
+		part.Write(content)
+	}
+	writer.Close()
+
+	req, err := h.newRequest(http.MethodPost, path, token, &buf, writer.FormDataContentType())
+	if err != nil {
+		return nil, err
+	}
+	return h.send(req)
+}
+
+// Stream sends a JSON request and collects every server-sent event of the response
+func (h *Harness) Stream(method, path, token string, body interface{}) ([]Event, error) {
+	return h.StreamFrom(method, path, token, "", body, nil)
+}
+
+// StreamFrom is Stream sending lastEventID as the Last-Event-ID header when set. When stop
+// returns true for an event, the connection is closed and the events so far are returned.
+func (h *Harness) StreamFrom(method, path, token, lastEventID string, body interface{}, stop func(Event) bool) ([]Event, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := h.newRequest(method, path, token, bytes.NewReader(payload), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	reqCtx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	req = req.WithContext(reqCtx)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var events []Event
+	var current Event
+	hasData := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id:"):
+			current.ID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
+			current.Name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			// Data spread over several lines carried newlines
+			if hasData {
+				current.Data += "\n"
+			}
+			current.Data += strings.TrimPrefix(line, "data:")
+			hasData = true
+		case line == "" && (current.Name != "" || hasData):
+			events = append(events, current)
+			if stop != nil && stop(current) {
+				return events, nil
+			}
+			current = Event{}
+			hasData = false
+		}
+	}
+	return events, scanner.Err()
+}
+
+func (h *Harness) send(req *http.Request) (*Response, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Response{StatusCode: resp.StatusCode}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, result); err != nil {
+			return nil, fmt.Errorf("invalid response body %q: %w", string(raw), err)
+		}
+	}
+	return result, nil
+}
+
+// Decode unmarshals the data field of the response
+func (r *Response) Decode(out interface{}) error {
+	return json.Unmarshal(r.Data, out)
+}
@@ -0,0 +1,168 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// StubVerdict is the JSON content returned for every non-streaming completion
+const StubVerdict = `{"Status":"Halal","Reason":"Tidak ditemukan bahan haram","ProductName":"Stub Snack","Suggest":[]}`
+
+// StubStreamChunks are the deltas streamed for every streaming completion
+var StubStreamChunks = []string{"Coba ", "restoran ", "ini!"}
+
+// OpenAIStub is a fake OpenAI chat completions server
+type OpenAIStub struct {
+	Server *httptest.Server
+
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	queued   []string
+	streams  []stubStream
+}
+
+// StubToolCall is a tool call streamed by QueueToolCalls
+type StubToolCall struct {
+	Name      string
+	Arguments string
+}
+
+// stubStream is a queued streaming completion, answering either with chunks or with tool calls.
+// A stalled stream never finishes, it waits for the caller to give up.
+type stubStream struct {
+	chunks    []string
+	toolCalls []StubToolCall
+	stall     bool
+}
+
+// NewOpenAIStub starts a fake server answering POST /chat/completions
+func NewOpenAIStub() *OpenAIStub {
+	stub := &OpenAIStub{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(stub.handle))
+	return stub
+}
+
+// URL is the base URL to use as OPENAI_BASE_URL
+func (s *OpenAIStub) URL() string {
+	return s.Server.URL
+}
+
+// Requests returns the decoded payloads received so far
+func (s *OpenAIStub) Requests() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]interface{}(nil), s.requests...)
+}
+
+// QueueReplies makes the next non-streaming completions answer with replies, in order, before falling back to StubVerdict
+func (s *OpenAIStub) QueueReplies(replies ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queued = append(s.queued, replies...)
+}
+
+// QueueStream makes the next streaming completion send chunks instead of StubStreamChunks
+func (s *OpenAIStub) QueueStream(chunks ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams = append(s.streams, stubStream{chunks: chunks})
+}
+
+// QueueStalledStream makes the next streaming completion send chunks and then hang until the request is cancelled
+func (s *OpenAIStub) QueueStalledStream(chunks ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams = append(s.streams, stubStream{chunks: chunks, stall: true})
+}
+
+// QueueToolCalls makes the next streaming completion ask for calls instead of answering
+func (s *OpenAIStub) QueueToolCalls(calls ...StubToolCall) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams = append(s.streams, stubStream{toolCalls: calls})
+}
+
+func (s *OpenAIStub) Close() {
+	s.Server.Close()
+}
+
+func (s *OpenAIStub) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		http.Error(w, `{"error":{"message":"not found"}}`, http.StatusNotFound)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, `{"error":{"message":"invalid json"}}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, payload)
+	content := StubVerdict
+	queuedStream := stubStream{chunks: StubStreamChunks}
+	stream, _ := payload["stream"].(bool)
+	if !stream && len(s.queued) > 0 {
+		content = s.queued[0]
+		s.queued = s.queued[1:]
+	}
+	if stream && len(s.streams) > 0 {
+		queuedStream = s.streams[0]
+		s.streams = s.streams[1:]
+	}
+	s.mu.Unlock()
+
+	if stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeToolCallChunks(w, queuedStream.toolCalls)
+		for _, chunk := range queuedStream.chunks {
+			data, _ := json.Marshal(map[string]interface{}{
+				"choices": []map[string]interface{}{
+					{"delta": map[string]string{"content": chunk}},
+				},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		if queuedStream.stall {
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{"message": map[string]string{"role": "assistant", "content": content}},
+		},
+	})
+}
+
+// writeToolCallChunks streams each call like OpenAI does: the id and name first, then the arguments in two fragments
+func writeToolCallChunks(w http.ResponseWriter, calls []StubToolCall) {
+	for i, call := range calls {
+		half := len(call.Arguments) / 2
+		fragments := []map[string]interface{}{
+			{"index": i, "id": fmt.Sprintf("call_%d", i), "type": "function", "function": map[string]string{"name": call.Name, "arguments": ""}},
+			{"index": i, "function": map[string]string{"arguments": call.Arguments[:half]}},
+			{"index": i, "function": map[string]string{"arguments": call.Arguments[half:]}},
+		}
+		for _, fragment := range fragments {
+			data, _ := json.Marshal(map[string]interface{}{
+				"choices": []map[string]interface{}{
+					{"delta": map[string]interface{}{"tool_calls": []map[string]interface{}{fragment}}},
+				},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	runScenarios(t, true)
+}
+
+func TestRoutesEmulator(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	runScenarios(t, false)
+}
+
+// runScenarios runs every scenario as a subtest and stops at the first failure,
+// since later scenarios depend on the state of earlier ones
+func runScenarios(t *testing.T, useMemory bool) {
+	ctx := context.Background()
+	h, err := NewHarness(ctx, useMemory)
+	if err != nil {
+		t.Fatalf("Failed to start integration harness: %v", err)
+	}
+	defer h.Close()
+
+	state := &State{}
+	for _, scenario := range Scenarios() {
+		passed := t.Run(scenario.Name, func(t *testing.T) {
+			if err := scenario.Run(ctx, h, state); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if !passed {
+			t.Fatalf("skipping the scenarios after %s", scenario.Name)
+		}
+	}
+}
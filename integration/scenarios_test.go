@@ -0,0 +1,2210 @@
+package integration
+
+import (
+	"HalalMate/models"
+	"HalalMate/notifier"
+	"HalalMate/repositories"
+	"HalalMate/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gobwas/ws/wsutil"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mmcloughlin/geohash"
+)
+
+// Scenario is one end to end check. Scenarios run in order and share State.
+type Scenario struct {
+	Name string
+	Run  func(ctx context.Context, h *Harness, state *State) error
+}
+
+// State carries ids created by earlier scenarios
+type State struct {
+	Token          string
+	UserID         string
+	ModeratorToken string
+	AdminToken     string
+	Latitude       float64
+	Longitude      float64
+	NearID         string
+	FartherID      string
+	RoomID         string
+	BookmarkID     string
+}
+
+// Scenarios lists every check in execution order
+func Scenarios() []Scenario {
+	return []Scenario{
+		{Name: "auth", Run: authScenario},
+		{Name: "roles", Run: roleScenario},
+		{Name: "email", Run: emailScenario},
+		{Name: "restaurants", Run: restaurantScenario},
+		{Name: "bookmarks", Run: bookmarkScenario},
+		{Name: "rooms", Run: roomScenario},
+		{Name: "chat", Run: chatScenario},
+		{Name: "chat memory", Run: chatMemoryScenario},
+		{Name: "chat resume", Run: chatResumeScenario},
+		{Name: "chat feedback", Run: chatFeedbackScenario},
+		{Name: "chat socket", Run: chatSocketScenario},
+		{Name: "snack", Run: snackScenario},
+		{Name: "ingridients", Run: ingridientScenario},
+		{Name: "scrape jobs", Run: scrapeJobScenario},
+		{Name: "reports", Run: reportScenario},
+		{Name: "dietary preferences", Run: dietaryScenario},
+		{Name: "notifications", Run: notificationScenario},
+		{Name: "account", Run: accountScenario},
+	}
+}
+
+func expectStatus(step string, resp *Response, err error, status int) error {
+	if err != nil {
+		return fmt.Errorf("%s: %w", step, err)
+	}
+	if resp.StatusCode != status {
+		return fmt.Errorf("%s: expected status %d, got %d (%s)", step, status, resp.StatusCode, resp.Message)
+	}
+	return nil
+}
+
+func authScenario(ctx context.Context, h *Harness, state *State) error {
+	register := map[string]string{
+		"username":         "integration",
+		"email":            "integration@halalmate.test",
+		"password":         "rahasia123",
+		"retyped_password": "rahasia123",
+	}
+
+	short := map[string]string{"username": "pendek", "email": "pendek@halalmate.test", "password": "pendek", "retyped_password": "pendek"}
+	resp, err := h.Do(http.MethodPost, "/v1/auth/register", "", short)
+	if err := expectStatus("register with a short password", resp, err, http.StatusBadRequest); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/auth/register", "", register)
+	if err := expectStatus("register", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/auth/register", "", register)
+	if err := expectStatus("duplicate register", resp, err, http.StatusConflict); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{
+		"email":    register["email"],
+		"password": "salah",
+	})
+	if err := expectStatus("login with wrong password", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{
+		"email":    register["email"],
+		"password": register["password"],
+	})
+	if err := expectStatus("login", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	var login models.AuthTokens
+	if err := resp.Decode(&login); err != nil || login.Token == "" || login.RefreshToken == "" || login.ExpiresIn <= 0 {
+		return fmt.Errorf("login: tokens missing from response")
+	}
+	state.Token = login.Token
+
+	resp, err = h.Do(http.MethodGet, "/v1/users/profile", "", nil)
+	if err := expectStatus("profile without token", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/users/profile", state.Token, nil)
+	if err := expectStatus("profile", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	var profile models.Profile
+	if err := resp.Decode(&profile); err != nil || profile.Email != register["email"] {
+		return fmt.Errorf("profile: unexpected profile %s", string(resp.Data))
+	}
+	if strings.Join(profile.Roles, ",") != models.RoleUser {
+		return fmt.Errorf("profile: expected a plain user, got roles %v", profile.Roles)
+	}
+	state.UserID = profile.ID
+
+	// A token signed with the old hard-coded secret is rejected
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uid": profile.ID,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("your-secret-key"))
+	if err != nil {
+		return fmt.Errorf("forge token: %w", err)
+	}
+	resp, err = h.Do(http.MethodGet, "/v1/users/profile", forged, nil)
+	if err := expectStatus("profile with forged token", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+
+	return sessionChecks(h, register["email"], register["password"])
+}
+
+// sessionChecks signs in on other devices so the session of state.Token stays untouched
+func sessionChecks(h *Harness, email, password string) error {
+	login := func(deviceID string) (*models.AuthTokens, error) {
+		resp, err := h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{
+			"email":     email,
+			"password":  password,
+			"device_id": deviceID,
+		})
+		if err := expectStatus("login on "+deviceID, resp, err, http.StatusOK); err != nil {
+			return nil, err
+		}
+		var tokens models.AuthTokens
+		if err := resp.Decode(&tokens); err != nil || tokens.RefreshToken == "" {
+			return nil, fmt.Errorf("login on %s: tokens missing from response", deviceID)
+		}
+		return &tokens, nil
+	}
+	refresh := func(step, refreshToken string, status int) (*models.AuthTokens, error) {
+		resp, err := h.Do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": refreshToken})
+		if err := expectStatus(step, resp, err, status); err != nil {
+			return nil, err
+		}
+		var tokens models.AuthTokens
+		resp.Decode(&tokens)
+		return &tokens, nil
+	}
+	profile := func(step, token string, status int) error {
+		resp, err := h.Do(http.MethodGet, "/v1/users/profile", token, nil)
+		return expectStatus(step, resp, err, status)
+	}
+
+	tablet, err := login("integration-tablet")
+	if err != nil {
+		return err
+	}
+	rotated, err := refresh("refresh token", tablet.RefreshToken, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	if rotated.Token == "" || rotated.RefreshToken == "" || rotated.RefreshToken == tablet.RefreshToken {
+		return fmt.Errorf("refresh token: expected rotated tokens, got %+v", rotated)
+	}
+	if err := profile("profile with refreshed token", rotated.Token, http.StatusOK); err != nil {
+		return err
+	}
+
+	// Replaying the rotated refresh token revokes the whole session
+	if _, err := refresh("reuse refresh token", tablet.RefreshToken, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	if _, err := refresh("refresh after reuse", rotated.RefreshToken, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	if err := profile("profile after reuse", rotated.Token, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	if _, err := refresh("malformed refresh token", "nope", http.StatusUnauthorized); err != nil {
+		return err
+	}
+
+	// Signing in again on a device replaces its session
+	laptop, err := login("integration-laptop")
+	if err != nil {
+		return err
+	}
+	again, err := login("integration-laptop")
+	if err != nil {
+		return err
+	}
+	if err := profile("profile of replaced session", laptop.Token, http.StatusUnauthorized); err != nil {
+		return err
+	}
+
+	resp, err := h.Do(http.MethodPost, "/v1/auth/logout", again.Token, nil)
+	if err := expectStatus("logout", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	if err := profile("profile after logout", again.Token, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	_, err = refresh("refresh after logout", again.RefreshToken, http.StatusUnauthorized)
+	return err
+}
+
+// emailTokenPattern finds the token of a verification or reset email, with or without APP_BASE_URL
+var emailTokenPattern = regexp.MustCompile(`(?i)token[:=] ?([A-Za-z0-9_-]+)`)
+
+// lastMailToken returns the token of the last email sent to address
+func lastMailToken(h *Harness, address string) (string, int, error) {
+	mails, err := h.Mails()
+	if err != nil {
+		return "", 0, err
+	}
+
+	count := 0
+	token := ""
+	for _, mail := range mails {
+		if mail.To != address {
+			continue
+		}
+		count++
+		if match := emailTokenPattern.FindStringSubmatch(mail.Body); match != nil {
+			token = match[1]
+		}
+	}
+	return token, count, nil
+}
+
+// emailScenario uses its own account, resetting the password signs every device of the account out
+func emailScenario(ctx context.Context, h *Harness, state *State) error {
+	const email = "lupa@halalmate.test"
+	resp, err := h.Do(http.MethodPost, "/v1/auth/register", "", map[string]string{
+		"username":         "lupa",
+		"email":            email,
+		"password":         "rahasia123",
+		"retyped_password": "rahasia123",
+	})
+	if err := expectStatus("register", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+	var registered models.AuthTokens
+	if err := resp.Decode(&registered); err != nil || registered.Token == "" {
+		return fmt.Errorf("register: token missing from response")
+	}
+
+	profile := func(step, token string) (*models.Profile, error) {
+		resp, err := h.Do(http.MethodGet, "/v1/users/profile", token, nil)
+		if err := expectStatus(step, resp, err, http.StatusOK); err != nil {
+			return nil, err
+		}
+		var profile models.Profile
+		if err := resp.Decode(&profile); err != nil {
+			return nil, fmt.Errorf("%s: %w", step, err)
+		}
+		return &profile, nil
+	}
+	current, err := profile("profile before verification", registered.Token)
+	if err != nil {
+		return err
+	}
+	if current.EmailVerified {
+		return fmt.Errorf("profile before verification: expected an unverified email")
+	}
+
+	verifyToken, sent, err := lastMailToken(h, email)
+	if err != nil || sent != 1 || verifyToken == "" {
+		return fmt.Errorf("verification email: expected one email with a token, got %d (err=%v)", sent, err)
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/verify-email", "", map[string]string{"token": verifyToken})
+	if err := expectStatus("verify email", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/verify-email", "", map[string]string{"token": verifyToken})
+	if err := expectStatus("verify email twice", resp, err, http.StatusBadRequest); err != nil {
+		return err
+	}
+	if current, err = profile("profile after verification", registered.Token); err != nil {
+		return err
+	}
+	if !current.EmailVerified {
+		return fmt.Errorf("profile after verification: expected a verified email")
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/resend-verification", registered.Token, nil)
+	if err := expectStatus("resend verification when verified", resp, err, http.StatusConflict); err != nil {
+		return err
+	}
+
+	// Unknown emails get the same answer and no email
+	resp, err = h.Do(http.MethodPost, "/v1/auth/forgot-password", "", map[string]string{"email": "nobody@halalmate.test"})
+	if err := expectStatus("forgot password of unknown email", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	if _, sent, err := lastMailToken(h, "nobody@halalmate.test"); err != nil || sent != 0 {
+		return fmt.Errorf("forgot password of unknown email: expected no email, got %d (err=%v)", sent, err)
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/auth/forgot-password", "", map[string]string{"email": email})
+	if err := expectStatus("forgot password", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	resetToken, sent, err := lastMailToken(h, email)
+	if err != nil || sent != 2 || resetToken == "" || resetToken == verifyToken {
+		return fmt.Errorf("reset email: expected a second email with a new token, got %d (err=%v)", sent, err)
+	}
+
+	invalid := []map[string]string{
+		{"token": resetToken, "password": "barurahasia", "retyped_password": "lain"},
+		{"token": resetToken, "password": "pendek", "retyped_password": "pendek"},
+		{"token": verifyToken, "password": "barurahasia", "retyped_password": "barurahasia"},
+	}
+	for i, body := range invalid {
+		resp, err = h.Do(http.MethodPost, "/v1/auth/reset-password", "", body)
+		if err := expectStatus(fmt.Sprintf("invalid reset %d", i), resp, err, http.StatusBadRequest); err != nil {
+			return err
+		}
+	}
+
+	reset := map[string]string{"token": resetToken, "password": "barurahasia", "retyped_password": "barurahasia"}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/reset-password", "", reset)
+	if err := expectStatus("reset password", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/reset-password", "", reset)
+	if err := expectStatus("reset password twice", resp, err, http.StatusBadRequest); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/users/profile", registered.Token, nil)
+	if err := expectStatus("profile after reset", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{"email": email, "password": "rahasia123"})
+	if err := expectStatus("login with old password", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{"email": email, "password": "barurahasia"})
+	return expectStatus("login with new password", resp, err, http.StatusOK)
+}
+
+func roleScenario(ctx context.Context, h *Harness, state *State) error {
+	// Staff accounts are not stored, their tokens still get real sessions
+	sessions := services.NewSessionServiceWithStore(h.Store)
+	moderator := &models.User{ID: ModeratorUserID, Roles: []string{models.RoleUser, models.RoleModerator}}
+	tokens, err := sessions.StartSession(ctx, moderator, "integration")
+	if err != nil {
+		return fmt.Errorf("moderator session: %w", err)
+	}
+	state.ModeratorToken = tokens.Token
+	admin := &models.User{ID: AdminUserID, Roles: []string{models.RoleUser, models.RoleAdmin}}
+	if tokens, err = sessions.StartSession(ctx, admin, "integration"); err != nil {
+		return fmt.Errorf("admin session: %w", err)
+	}
+	state.AdminToken = tokens.Token
+
+	resp, err := h.Do(http.MethodPost, "/v1/scraper/jobs", "", nil)
+	if err := expectStatus("scraper without token", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/scraper/jobs", state.ModeratorToken, nil)
+	if err := expectStatus("scraper as a moderator", resp, err, http.StatusForbidden); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/ingridients", state.Token, map[string]string{"name": "gelatin babi"})
+	if err := expectStatus("create ingridient as a user", resp, err, http.StatusForbidden); err != nil {
+		return err
+	}
+
+	rolePath := "/v1/admin/users/" + state.UserID + "/roles/"
+	resp, err = h.Do(http.MethodPut, rolePath+models.RoleModerator, state.ModeratorToken, nil)
+	if err := expectStatus("grant role as a moderator", resp, err, http.StatusForbidden); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPut, rolePath+"owner", state.AdminToken, nil)
+	if err := expectStatus("grant unknown role", resp, err, http.StatusBadRequest); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPut, "/v1/admin/users/missing/roles/"+models.RoleModerator, state.AdminToken, nil)
+	if err := expectStatus("grant role to unknown user", resp, err, http.StatusNotFound); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodDelete, "/v1/admin/users/"+AdminUserID+"/roles/"+models.RoleAdmin, state.AdminToken, nil)
+	if err := expectStatus("revoke own admin role", resp, err, http.StatusConflict); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPut, rolePath+models.RoleModerator, state.AdminToken, nil)
+	if err := expectStatus("grant moderator", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var profile models.Profile
+	if err := resp.Decode(&profile); err != nil || strings.Join(profile.Roles, ",") != "user,moderator" {
+		return fmt.Errorf("grant moderator: unexpected profile %s", string(resp.Data))
+	}
+
+	// The role is carried by the tokens issued after the grant
+	resp, err = h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{
+		"email":    "integration@halalmate.test",
+		"password": "rahasia123",
+	})
+	if err := expectStatus("login as moderator", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var login models.AuthTokens
+	if err := resp.Decode(&login); err != nil || login.Token == "" {
+		return fmt.Errorf("login as moderator: token missing from response")
+	}
+	resp, err = h.Do(http.MethodGet, "/v1/moderation/reports", login.Token, nil)
+	if err := expectStatus("moderation queue as granted moderator", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodDelete, rolePath+models.RoleModerator, state.AdminToken, nil)
+	if err := expectStatus("revoke moderator", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	profile = models.Profile{}
+	if err := resp.Decode(&profile); err != nil || strings.Join(profile.Roles, ",") != models.RoleUser {
+		return fmt.Errorf("revoke moderator: unexpected profile %s", string(resp.Data))
+	}
+	return nil
+}
+
+func restaurantScenario(ctx context.Context, h *Harness, state *State) error {
+	// Search from the centre of a geohash cell so every seeded place shares its 5 character prefix
+	state.Latitude, state.Longitude = geohash.DecodeCenter(geohash.EncodeWithPrecision(-6.2, 106.8166, 5))
+
+	restaurantService := services.NewRestaurantServiceWithStore(h.Store)
+	halal := []*models.Place{
+		{Title: "Warung Dekat", Rating: "4,2", Category: "Warung", OpeningStatus: "Tutup", Location: models.GeoLocation{Latitude: state.Latitude + 0.001, Longitude: state.Longitude}},
+		{Title: "Sate Agak Jauh", Rating: "4,7", Category: "Restoran Sate", OpeningStatus: "Buka", Location: models.GeoLocation{Latitude: state.Latitude + 0.01, Longitude: state.Longitude}},
+		{Title: "Bakso Luar Kota", Location: models.GeoLocation{Latitude: state.Latitude + 1, Longitude: state.Longitude}},
+	}
+	haram := []*models.Place{
+		{Title: "Babi Panggang", Location: models.GeoLocation{Latitude: state.Latitude + 0.002, Longitude: state.Longitude}},
+	}
+	if err := seedRestaurants(ctx, restaurantService, models.RestaurantStatusHalal, halal); err != nil {
+		return fmt.Errorf("seed halal restaurants: %w", err)
+	}
+	if err := seedRestaurants(ctx, restaurantService, models.RestaurantStatusHaram, haram); err != nil {
+		return fmt.Errorf("seed haram restaurants: %w", err)
+	}
+	// Without a verdict a place is unknown, which must not be listed as halal nor saved as haram
+	unknown := &models.Place{Title: "Kedai Misterius", Location: models.GeoLocation{Latitude: state.Latitude + 0.003, Longitude: state.Longitude}}
+	if err := restaurantService.SaveRestaurants(ctx, []*models.Place{unknown}); err != nil {
+		return fmt.Errorf("seed unknown restaurant: %w", err)
+	}
+	exists, status, err := restaurantService.CheckRestaurantExists(ctx, unknown.Location.Latitude, unknown.Location.Longitude, unknown.Title)
+	if err != nil || !exists || status != models.RestaurantStatusUnknown {
+		return fmt.Errorf("seed unknown restaurant: expected status unknown, got %q (exists=%v, err=%v)", status, exists, err)
+	}
+
+	query := fmt.Sprintf("latitude=%f&longitude=%f", state.Latitude, state.Longitude)
+	resp, err := h.Do(http.MethodGet, "/v1/restaurants?"+query, state.Token, nil)
+	if err := expectStatus("list restaurants", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	var page services.RestaurantPage
+	if err := resp.Decode(&page); err != nil {
+		return fmt.Errorf("list restaurants: %w", err)
+	}
+	restaurants := page.Restaurants
+	if titles := restaurantTitles(restaurants); titles != "Warung Dekat,Sate Agak Jauh" || page.NextCursor != "" {
+		return fmt.Errorf("list restaurants: expected nearby halal restaurants sorted by distance, got %v", titles)
+	}
+	for _, restaurant := range restaurants {
+		if restaurant.ID == "" || restaurant.Status != models.RestaurantStatusHalal || restaurant.Distance == nil ||
+			restaurant.Geohash == "" || restaurant.CreatedAt.IsZero() || restaurant.UpdatedAt.IsZero() {
+			return fmt.Errorf("list restaurants: incomplete restaurant %+v", restaurant)
+		}
+	}
+	state.NearID = restaurants[0].ID
+	state.FartherID = restaurants[1].ID
+
+	// Page through the results sorted by rating, one restaurant at a time
+	var pages []string
+	cursor, firstCursor := "", ""
+	for {
+		resp, err = h.Do(http.MethodGet, "/v1/restaurants?"+query+"&sort=rating&limit=1&cursor="+cursor, state.Token, nil)
+		if err := expectStatus("page restaurants", resp, err, http.StatusOK); err != nil {
+			return err
+		}
+		page = services.RestaurantPage{}
+		if err := resp.Decode(&page); err != nil || len(page.Restaurants) != 1 {
+			return fmt.Errorf("page restaurants: expected one restaurant per page, got %s", string(resp.Data))
+		}
+		pages = append(pages, page.Restaurants[0].Title)
+		if page.NextCursor == "" {
+			break
+		}
+		if firstCursor == "" {
+			firstCursor = page.NextCursor
+		}
+		cursor = page.NextCursor
+	}
+	if strings.Join(pages, ",") != "Sate Agak Jauh,Warung Dekat" {
+		return fmt.Errorf("page restaurants: expected best rated first, got %v", pages)
+	}
+
+	// A cursor does not carry over to another search
+	for _, other := range []string{"&sort=distance", "&sort=rating&radius=1", "&sort=rating&q=sate"} {
+		resp, err = h.Do(http.MethodGet, "/v1/restaurants?"+query+other+"&limit=1&cursor="+firstCursor, state.Token, nil)
+		if err := expectStatus("reuse cursor with "+other, resp, err, http.StatusBadRequest); err != nil {
+			return err
+		}
+	}
+
+	filters := map[string]string{
+		"open_now=true":          "Sate Agak Jauh",
+		"min_rating=4.5":         "Sate Agak Jauh",
+		"q=warung":               "Warung Dekat",
+		"category=restoran+sate": "Sate Agak Jauh",
+		"radius=0.5":             "Warung Dekat",
+	}
+	for filter, expected := range filters {
+		resp, err = h.Do(http.MethodGet, "/v1/restaurants?"+query+"&"+filter, state.Token, nil)
+		if err := expectStatus("filter restaurants "+filter, resp, err, http.StatusOK); err != nil {
+			return err
+		}
+		page = services.RestaurantPage{}
+		if err := resp.Decode(&page); err != nil || restaurantTitles(page.Restaurants) != expected {
+			return fmt.Errorf("filter restaurants %s: expected %s, got %s", filter, expected, string(resp.Data))
+		}
+	}
+
+	for _, invalid := range []string{"sort=name", "limit=1000", "cursor=nope", "radius=abc"} {
+		resp, err = h.Do(http.MethodGet, "/v1/restaurants?"+query+"&"+invalid, state.Token, nil)
+		if err := expectStatus("invalid search "+invalid, resp, err, http.StatusBadRequest); err != nil {
+			return err
+		}
+	}
+
+	// A restaurant a few hundred metres away in the next geohash cell must still be found
+	edge := geohash.BoundingBox(geohash.EncodeWithPrecision(-7.0, 110.4, 5))
+	across := []*models.Place{
+		{Title: "Mie Seberang", Location: models.GeoLocation{Latitude: edge.MaxLat + 0.002, Longitude: edge.MinLng + 0.01}},
+	}
+	if err := seedRestaurants(ctx, restaurantService, models.RestaurantStatusHalal, across); err != nil {
+		return fmt.Errorf("seed restaurant across the cell edge: %w", err)
+	}
+	edgeQuery := fmt.Sprintf("latitude=%f&longitude=%f&radius=1", edge.MaxLat-0.001, edge.MinLng+0.01)
+	resp, err = h.Do(http.MethodGet, "/v1/restaurants?"+edgeQuery, state.Token, nil)
+	if err := expectStatus("search across cell edge", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	page = services.RestaurantPage{}
+	if err := resp.Decode(&page); err != nil || restaurantTitles(page.Restaurants) != "Mie Seberang" {
+		return fmt.Errorf("search across cell edge: expected Mie Seberang, got %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/restaurants/"+state.NearID+"?"+query, state.Token, nil)
+	if err := expectStatus("get restaurant", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	var restaurant map[string]interface{}
+	if err := resp.Decode(&restaurant); err != nil {
+		return fmt.Errorf("get restaurant: %w", err)
+	}
+	if restaurant["isBookmarked"] != false {
+		return fmt.Errorf("get restaurant: expected isBookmarked=false, got %v", restaurant["isBookmarked"])
+	}
+	verdict, _ := restaurant["verdict"].(map[string]interface{})
+	if verdict["status"] != models.RestaurantStatusHalal || verdict["source"] != models.VerdictSourceAdmin || verdict["confidence"] != 1.0 {
+		return fmt.Errorf("get restaurant: expected the halal verdict evidence, got %v", restaurant["verdict"])
+	}
+	return nil
+}
+
+// seedRestaurants saves places with an admin verdict of the given status
+func seedRestaurants(ctx context.Context, restaurantService *services.RestaurantService, status string, places []*models.Place) error {
+	for _, place := range places {
+		var offending []string
+		if status != models.RestaurantStatusHalal {
+			offending = []string{place.Title}
+		}
+		place.Verdict = models.NewHalalVerdict(status, models.VerdictSourceAdmin, 1, "Seeded by the integration run", offending)
+	}
+	return restaurantService.SaveRestaurants(ctx, places)
+}
+
+func restaurantTitles(restaurants []models.Restaurant) string {
+	titles := make([]string, 0, len(restaurants))
+	for _, restaurant := range restaurants {
+		titles = append(titles, restaurant.Title)
+	}
+	return strings.Join(titles, ",")
+}
+
+func bookmarkScenario(ctx context.Context, h *Harness, state *State) error {
+	body := map[string]string{"restaurantId": state.NearID}
+
+	resp, err := h.Do(http.MethodPost, "/v1/bookmark", state.Token, body)
+	if err := expectStatus("create bookmark", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+
+	var bookmark struct {
+		ID string `json:"id"`
+	}
+	if err := resp.Decode(&bookmark); err != nil || bookmark.ID == "" {
+		return fmt.Errorf("create bookmark: id missing from response")
+	}
+	state.BookmarkID = bookmark.ID
+
+	resp, err = h.Do(http.MethodPost, "/v1/bookmark", state.Token, body)
+	if err := expectStatus("duplicate bookmark", resp, err, http.StatusConflict); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/bookmark", state.Token, map[string]string{"restaurantId": "missing"})
+	if err := expectStatus("bookmark unknown restaurant", resp, err, http.StatusNotFound); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("latitude=%f&longitude=%f", state.Latitude, state.Longitude)
+	resp, err = h.Do(http.MethodGet, "/v1/bookmark?"+query, state.Token, nil)
+	if err := expectStatus("list bookmarks", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	var bookmarks []models.Bookmark
+	if err := resp.Decode(&bookmarks); err != nil {
+		return fmt.Errorf("list bookmarks: %w", err)
+	}
+	if len(bookmarks) != 1 || bookmarks[0].Restaurant == nil {
+		return fmt.Errorf("list bookmarks: expected one bookmark with its restaurant, got %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/bookmark/"+state.BookmarkID, state.Token, nil)
+	if err := expectStatus("get bookmark", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/restaurants/"+state.NearID+"?"+query, state.Token, nil)
+	if err := expectStatus("get bookmarked restaurant", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var restaurant map[string]interface{}
+	if err := resp.Decode(&restaurant); err != nil || restaurant["isBookmarked"] != true {
+		return fmt.Errorf("get bookmarked restaurant: expected isBookmarked=true, got %s", string(resp.Data))
+	}
+
+	// The delete route takes the restaurant id
+	resp, err = h.Do(http.MethodDelete, "/v1/bookmark/"+state.NearID, state.Token, nil)
+	if err := expectStatus("delete bookmark", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodDelete, "/v1/bookmark/"+state.NearID, state.Token, nil)
+	return expectStatus("delete missing bookmark", resp, err, http.StatusNotFound)
+}
+
+func roomScenario(ctx context.Context, h *Harness, state *State) error {
+	resp, err := h.Do(http.MethodPost, "/v1/hoca/room", state.Token, map[string]string{"title": "Makan siang"})
+	if err := expectStatus("create room", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+
+	var room models.Room
+	if err := resp.Decode(&room); err != nil || room.RoomID == "" {
+		return fmt.Errorf("create room: room_id missing from response")
+	}
+	state.RoomID = room.RoomID
+
+	resp, err = h.Do(http.MethodPost, "/v1/hoca/room", state.Token, map[string]string{})
+	if err := expectStatus("create room without title", resp, err, http.StatusBadRequest); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/hoca/room", state.Token, map[string]bool{"auto_title": true})
+	if err := expectStatus("create auto titled room", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+	var autoRoom models.Room
+	if err := resp.Decode(&autoRoom); err != nil || !autoRoom.AutoTitle || autoRoom.RoomTitle != "" {
+		return fmt.Errorf("create auto titled room: unexpected room %s", string(resp.Data))
+	}
+
+	// The first prompt names the room, the title arrives before the end of the stream
+	h.OpenAI.QueueReplies("\"Sarapan Halal di Bandung\"")
+	events, err := h.Stream(http.MethodPost, "/v1/hoca/chat/"+autoRoom.RoomID, state.Token, map[string]string{
+		"latitude":  fmt.Sprint(state.Latitude),
+		"longitude": fmt.Sprint(state.Longitude),
+		"prompt":    "Cari sarapan halal dekat sini",
+	})
+	if err != nil {
+		return fmt.Errorf("auto title stream: %w", err)
+	}
+	titled := false
+	for _, event := range events {
+		if event.Name == "room_title" {
+			var room models.Room
+			if err := json.Unmarshal([]byte(event.Data), &room); err != nil || room.RoomTitle != "Sarapan Halal di Bandung" {
+				return fmt.Errorf("auto title stream: unexpected room_title event %s", event.Data)
+			}
+			titled = true
+		}
+	}
+	if !titled {
+		return fmt.Errorf("auto title stream: room_title event missing")
+	}
+
+	// The room with the latest message comes first and pages follow the cursor
+	resp, err = h.Do(http.MethodGet, "/v1/hoca/room?limit=1", state.Token, nil)
+	if err := expectStatus("list rooms", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var page services.RoomPage
+	if err := resp.Decode(&page); err != nil || len(page.Rooms) != 1 || page.NextCursor == "" {
+		return fmt.Errorf("list rooms: unexpected page %s", string(resp.Data))
+	}
+	if first := page.Rooms[0]; first.RoomID != autoRoom.RoomID || first.MessageCount != 2 || first.LastMessageAt == "" || first.AutoTitle {
+		return fmt.Errorf("list rooms: expected the auto titled room with 2 messages first, got %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/hoca/room?limit=1&cursor="+url.QueryEscape(page.NextCursor), state.Token, nil)
+	if err := expectStatus("list rooms next page", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	page = services.RoomPage{}
+	if err := resp.Decode(&page); err != nil || len(page.Rooms) != 1 || page.Rooms[0].RoomTitle != "Makan siang" || page.NextCursor != "" {
+		return fmt.Errorf("list rooms next page: unexpected page %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/hoca/room?cursor=garbage", state.Token, nil)
+	if err := expectStatus("list rooms with a bad cursor", resp, err, http.StatusBadRequest); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPatch, "/v1/hoca/room/"+autoRoom.RoomID, state.Token, map[string]string{"title": "  Sarapan  "})
+	if err := expectStatus("rename room", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var renamed models.Room
+	if err := resp.Decode(&renamed); err != nil || renamed.RoomTitle != "Sarapan" || renamed.MessageCount != 2 {
+		return fmt.Errorf("rename room: unexpected room %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodPatch, "/v1/hoca/room/missing", state.Token, map[string]string{"title": "Sarapan"})
+	if err := expectStatus("rename missing room", resp, err, http.StatusNotFound); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodDelete, "/v1/hoca/room/"+autoRoom.RoomID, state.Token, nil)
+	if err := expectStatus("delete room", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	if chats, err := h.Store.Rooms.ListChats(ctx, state.UserID, autoRoom.RoomID); err != nil || len(chats) != 0 {
+		return fmt.Errorf("delete room: chats left behind: %v %v", chats, err)
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/hoca/room/"+autoRoom.RoomID, state.Token, nil)
+	if err := expectStatus("get deleted room", resp, err, http.StatusNotFound); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodDelete, "/v1/hoca/room/"+autoRoom.RoomID, state.Token, nil)
+	if err := expectStatus("delete deleted room", resp, err, http.StatusNotFound); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/hoca/room/missing", state.Token, nil)
+	return expectStatus("get missing room", resp, err, http.StatusNotFound)
+}
+
+func chatScenario(ctx context.Context, h *Harness, state *State) error {
+	// HocaAI looks the restaurants up first, then answers. The marker is cut across chunks
+	// and the made up restaurant is dropped.
+	h.OpenAI.QueueToolCalls(
+		StubToolCall{Name: "search_restaurants", Arguments: `{"sort":"distance","limit":2}`},
+		StubToolCall{Name: "check_ingredient", Arguments: `{"name":"gelatin"}`},
+	)
+	h.OpenAI.QueueStream(
+		"Coba ", "restoran ini!\n[[restau", "rant:"+state.NearID+"|Menu Karangan]]\n",
+		"[[restaurant:ngarang]]", "Selamat makan!",
+	)
+	events, err := h.Stream(http.MethodPost, "/v1/hoca/chat/"+state.RoomID, state.Token, map[string]string{
+		"latitude":  fmt.Sprint(state.Latitude),
+		"longitude": fmt.Sprint(state.Longitude),
+		"prompt":    "Rekomendasi makan siang dong",
+	})
+	if err != nil {
+		return fmt.Errorf("chat stream: %w", err)
+	}
+
+	const expectedAnswer = "Coba restoran ini!\n\nSelamat makan!"
+	var answer strings.Builder
+	var cards []models.RecommendationCard
+	var tools []string
+	done := false
+	for _, event := range events {
+		switch event.Name {
+		case "tool_call":
+			var call struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal([]byte(event.Data), &call); err != nil {
+				return fmt.Errorf("chat stream: invalid tool_call %s", event.Data)
+			}
+			tools = append(tools, call.Name)
+		case "recommendation":
+			answer.WriteString(event.Data)
+		case "recommendation_card":
+			var card models.RecommendationCard
+			if err := json.Unmarshal([]byte(event.Data), &card); err != nil {
+				return fmt.Errorf("chat stream: invalid recommendation_card %s", event.Data)
+			}
+			cards = append(cards, card)
+		case "done_recommendations":
+			done = true
+		case "error":
+			return fmt.Errorf("chat stream: error event %s", event.Data)
+		}
+	}
+	if !done {
+		return fmt.Errorf("chat stream: done_recommendations event missing")
+	}
+	if answer.String() != expectedAnswer {
+		return fmt.Errorf("chat stream: unexpected answer %q", answer.String())
+	}
+	if len(cards) != 1 || cards[0].RestaurantID != state.NearID || cards[0].Name == "" || cards[0].DistanceKm == nil {
+		return fmt.Errorf("chat stream: expected one card of %s, got %+v", state.NearID, cards)
+	}
+	if strings.Join(tools, ",") != "search_restaurants,check_ingredient" {
+		return fmt.Errorf("chat stream: unexpected tool_call events %v", tools)
+	}
+	if err := checkToolResults(h, state); err != nil {
+		return err
+	}
+
+	resp, err := h.Do(http.MethodGet, "/v1/hoca/room/"+state.RoomID, state.Token, nil)
+	if err := expectStatus("get room", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	var room models.RoomWithChat
+	if err := resp.Decode(&room); err != nil {
+		return fmt.Errorf("get room: %w", err)
+	}
+	if len(room.Chats) != 2 || room.Chats[0].Chat != "Rekomendasi makan siang dong" || room.Chats[1].UserID != "HocaAI" {
+		return fmt.Errorf("get room: expected the prompt followed by the HocaAI answer, got %s", string(resp.Data))
+	}
+	if answer := room.Chats[1]; answer.Chat != expectedAnswer || len(answer.Recommendations) != 1 || answer.Recommendations[0].RestaurantID != state.NearID {
+		return fmt.Errorf("get room: expected the answer with its card, got %s", string(resp.Data))
+	}
+	if answer := room.Chats[1]; answer.Complete == nil || !*answer.Complete {
+		return fmt.Errorf("get room: expected the answer to be complete, got %s", string(resp.Data))
+	}
+	return nil
+}
+
+func chatResumeScenario(ctx context.Context, h *Harness, state *State) error {
+	room := &models.Room{UserID: state.UserID, RoomTitle: "Putus nyambung", CreatedAt: time.Now().Format(time.RFC3339Nano)}
+	if err := h.Store.Rooms.CreateRoom(ctx, room); err != nil {
+		return fmt.Errorf("seed room: %w", err)
+	}
+	path := "/v1/hoca/chat/" + room.RoomID
+	body := map[string]string{
+		"latitude":  fmt.Sprint(state.Latitude),
+		"longitude": fmt.Sprint(state.Longitude),
+		"prompt":    "Ada yang dekat?",
+	}
+
+	// The client goes away after the card while the model is still writing
+	h.OpenAI.QueueStalledStream("Halo, ", "coba ini:\n[[restaurant:"+state.NearID+"|]]\n")
+	events, err := h.StreamFrom(http.MethodPost, path, state.Token, "", body, func(event Event) bool {
+		return event.Name == "recommendation_card"
+	})
+	if err != nil {
+		return fmt.Errorf("chat resume: first stream: %w", err)
+	}
+	for _, event := range events {
+		if event.ID == "" {
+			return fmt.Errorf("chat resume: event %s without id", event.Name)
+		}
+	}
+	last := events[len(events)-1]
+	if last.Name != "recommendation_card" {
+		return fmt.Errorf("chat resume: expected to stop at the card, got %+v", events)
+	}
+	chatID, _, _ := strings.Cut(last.ID, ":")
+
+	// The partial answer is stored once the server notices the disconnect
+	var partial *models.Chat
+	for i := 0; i < 100; i++ {
+		partial, err = h.Store.Rooms.GetChat(ctx, state.UserID, room.RoomID, chatID)
+		if err == nil && partial.Chat != "" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil || partial.Chat == "" || partial.Complete == nil || *partial.Complete || len(partial.Recommendations) != 1 {
+		return fmt.Errorf("chat resume: partial answer not stored: %+v %v", partial, err)
+	}
+
+	// Reconnecting replays what the client missed, then the model goes on from the partial answer
+	h.OpenAI.QueueStream("Selamat mencoba!")
+	events, err = h.StreamFrom(http.MethodPost, path, state.Token, last.ID, body, nil)
+	if err != nil {
+		return fmt.Errorf("chat resume: resumed stream: %w", err)
+	}
+	var rest strings.Builder
+	var done *Event
+	for i, event := range events {
+		switch event.Name {
+		case "recommendation":
+			rest.WriteString(event.Data)
+		case "recommendation_card":
+			return fmt.Errorf("chat resume: card sent twice: %s", event.Data)
+		case "done_recommendations":
+			done = &events[i]
+		}
+	}
+	expectedAnswer := "Halo, coba ini:\n\nSelamat mencoba!"
+	if done == nil || done.ID != fmt.Sprintf("%s:%d:1", chatID, len(expectedAnswer)) || !strings.Contains(done.Data, `"complete":true`) {
+		return fmt.Errorf("chat resume: unexpected done event %+v", done)
+	}
+	if "Halo, coba ini:\n"+rest.String() != expectedAnswer {
+		return fmt.Errorf("chat resume: unexpected rest of the answer %q", rest.String())
+	}
+
+	// The prompt is not saved twice and the partial answer is sent once, before the request to continue it
+	_, history := lastStreamMessages(h)
+	if n := len(history); n != 3 || history[0] != "user: Ada yang dekat?" || !strings.HasPrefix(history[1], "assistant: Halo, coba ini:") || !strings.HasPrefix(history[2], "user: ") {
+		return fmt.Errorf("chat resume: unexpected history %q", history)
+	}
+
+	stored, err := h.Store.Rooms.GetChat(ctx, state.UserID, room.RoomID, chatID)
+	if err != nil || stored.Chat != expectedAnswer || stored.Complete == nil || !*stored.Complete || len(stored.Recommendations) != 1 {
+		return fmt.Errorf("chat resume: answer not completed: %+v %v", stored, err)
+	}
+	chats, err := h.Store.Rooms.ListChats(ctx, state.UserID, room.RoomID)
+	if err != nil || len(chats) != 2 {
+		return fmt.Errorf("chat resume: expected the prompt and one answer, got %+v %v", chats, err)
+	}
+
+	// A finished answer is replayed without asking the model again
+	calls := len(h.OpenAI.Requests())
+	events, err = h.StreamFrom(http.MethodPost, path, state.Token, chatID+":0:0", body, nil)
+	if err != nil {
+		return fmt.Errorf("chat resume: replay: %w", err)
+	}
+	if len(events) != 3 || events[0].Data != expectedAnswer || events[1].Name != "recommendation_card" || events[2].Name != "done_recommendations" {
+		return fmt.Errorf("chat resume: unexpected replay %+v", events)
+	}
+	if len(h.OpenAI.Requests()) != calls {
+		return fmt.Errorf("chat resume: replaying a finished answer called the model")
+	}
+
+	if _, err := h.StreamFrom(http.MethodPost, path, state.Token, "not-an-event", body, nil); err == nil || !strings.Contains(err.Error(), "400") {
+		return fmt.Errorf("chat resume: expected 400 for an invalid Last-Event-ID, got %v", err)
+	}
+	return nil
+}
+
+func chatMemoryScenario(ctx context.Context, h *Harness, state *State) error {
+	room := &models.Room{UserID: state.UserID, RoomTitle: "Obrolan panjang", CreatedAt: time.Now().Add(-time.Hour).Format(time.RFC3339Nano)}
+	if err := h.Store.Rooms.CreateRoom(ctx, room); err != nil {
+		return fmt.Errorf("seed room: %w", err)
+	}
+
+	// Six turns of about 26 tokens each overflow a budget of 120
+	var turns []string
+	for i := 1; i <= 6; i++ {
+		userID, text := state.UserID, fmt.Sprintf("Pertanyaan %d: aku lagi cari tempat makan halal yang enak dan murah di sekitar kantor ya", i)
+		if i%2 == 0 {
+			userID, text = "HocaAI", fmt.Sprintf("Jawaban %d: ada beberapa pilihan tempat makan halal yang enak dan murah dekat kantormu nih", i)
+		}
+		turns = append(turns, text)
+		chat := &models.Chat{RoomID: room.RoomID, UserID: userID, Chat: text, CreatedAt: time.Now().Add(time.Duration(i-60) * time.Minute).Format(time.RFC3339Nano)}
+		if err := h.Store.Rooms.CreateChat(ctx, state.UserID, chat); err != nil {
+			return fmt.Errorf("seed chat: %w", err)
+		}
+	}
+
+	previous, wasSet := os.LookupEnv("CHAT_HISTORY_TOKENS")
+	os.Setenv("CHAT_HISTORY_TOKENS", "120")
+	defer func() {
+		if wasSet {
+			os.Setenv("CHAT_HISTORY_TOKENS", previous)
+		} else {
+			os.Unsetenv("CHAT_HISTORY_TOKENS")
+		}
+	}()
+
+	const summary = "User mencari makan siang halal murah dekat kantor."
+	h.OpenAI.QueueReplies(summary)
+	if err := streamChat(h, state, room.RoomID, "Yang buka sekarang?"); err != nil {
+		return err
+	}
+
+	// The four oldest turns are folded into the summary, the two newest stay verbatim in order
+	system, history := lastStreamMessages(h)
+	if !strings.Contains(system, summary) {
+		return fmt.Errorf("chat memory: summary missing from the system prompt: %s", system)
+	}
+	expected := []string{"user: " + turns[4], "assistant: " + turns[5], "user: Yang buka sekarang?"}
+	if strings.Join(history, "\n") != strings.Join(expected, "\n") {
+		return fmt.Errorf("chat memory: unexpected history %q", history)
+	}
+
+	stored, err := h.Store.Rooms.GetRoom(ctx, state.UserID, room.RoomID)
+	if err != nil || stored.Summary != summary || stored.SummarizedChatID == "" {
+		return fmt.Errorf("chat memory: summary not stored on the room: %+v %v", stored, err)
+	}
+
+	// The next answer still fits the budget, so the summary is reused as is
+	summaryCalls := len(h.OpenAI.Requests())
+	if err := streamChat(h, state, room.RoomID, "Oke makasih"); err != nil {
+		return err
+	}
+	if calls := len(h.OpenAI.Requests()) - summaryCalls; calls != 1 {
+		return fmt.Errorf("chat memory: expected only the answer to call the model, got %d calls", calls)
+	}
+	system, history = lastStreamMessages(h)
+	if !strings.Contains(system, summary) || len(history) != 5 || history[0] != "user: "+turns[4] {
+		return fmt.Errorf("chat memory: unexpected second history %q", history)
+	}
+	return nil
+}
+
+func chatFeedbackScenario(ctx context.Context, h *Harness, state *State) error {
+	chats, err := h.Store.Rooms.ListChats(ctx, state.UserID, state.RoomID)
+	if err != nil || len(chats) != 2 {
+		return fmt.Errorf("seeded chats: expected the prompt and its answer, got %d (err=%v)", len(chats), err)
+	}
+	prompt, answer := chats[0], chats[1]
+	ratingPath := func(chatID string) string {
+		return "/v1/hoca/room/" + state.RoomID + "/chat/" + chatID + "/rating"
+	}
+
+	resp, err := h.Do(http.MethodPut, ratingPath(answer.ChatID), state.Token, map[string]string{"rating": "meh"})
+	if err := expectStatus("rate with an unknown rating", resp, err, http.StatusBadRequest); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPut, ratingPath(prompt.ChatID), state.Token, map[string]string{"rating": models.ChatRatingUp})
+	if err := expectStatus("rate a prompt", resp, err, http.StatusBadRequest); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPut, ratingPath(answer.ChatID), state.Token, map[string]string{"rating": models.ChatRatingUp})
+	if err := expectStatus("rate the answer", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	// Rating again replaces the first rating
+	resp, err = h.Do(http.MethodPut, ratingPath(answer.ChatID), state.Token, map[string]string{"rating": models.ChatRatingDown, "reason": "Terlalu jauh"})
+	if err := expectStatus("rate the answer again", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var rating models.ChatRating
+	if err := resp.Decode(&rating); err != nil {
+		return fmt.Errorf("rate the answer: %w", err)
+	}
+	if rating.Rating != models.ChatRatingDown || rating.Reason != "Terlalu jauh" || rating.Prompt != prompt.Chat || rating.Answer != answer.Chat || len(rating.Recommendations) != 1 {
+		return fmt.Errorf("rate the answer: unexpected rating %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/admin/chat-ratings?rating=down", state.Token, nil)
+	if err := expectStatus("list ratings as a user", resp, err, http.StatusForbidden); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodGet, "/v1/admin/chat-ratings?rating=down", state.AdminToken, nil)
+	if err := expectStatus("list ratings", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var ratings []models.ChatRating
+	if err := resp.Decode(&ratings); err != nil || len(ratings) != 1 || ratings[0].ChatID != answer.ChatID {
+		return fmt.Errorf("list ratings: expected the rating of %s, got %s", answer.ChatID, string(resp.Data))
+	}
+
+	// Regenerating replaces the last answer, its rating keeps the old one
+	h.OpenAI.QueueStream("Kalau begitu coba yang lebih dekat ya.")
+	events, err := h.Stream(http.MethodPost, "/v1/hoca/room/"+state.RoomID+"/regenerate", state.Token, map[string]string{
+		"latitude":  fmt.Sprint(state.Latitude),
+		"longitude": fmt.Sprint(state.Longitude),
+	})
+	if err != nil {
+		return fmt.Errorf("regenerate: %w", err)
+	}
+	if last := events[len(events)-1]; last.Name != "done_recommendations" || !strings.HasPrefix(last.ID, answer.ChatID+":") {
+		return fmt.Errorf("regenerate: unexpected events %+v", events)
+	}
+	if _, history := lastStreamMessages(h); len(history) != 1 || history[0] != "user: "+prompt.Chat {
+		return fmt.Errorf("regenerate: expected only the prompt to be sent, got %q", history)
+	}
+	regenerated, err := h.Store.Rooms.GetChat(ctx, state.UserID, state.RoomID, answer.ChatID)
+	if err != nil || regenerated.Chat != "Kalau begitu coba yang lebih dekat ya." || regenerated.Rating != "" || len(regenerated.Recommendations) != 0 {
+		return fmt.Errorf("regenerate: answer not replaced: %+v %v", regenerated, err)
+	}
+	if stored, err := h.Store.ChatRatings.Find(ctx, repositories.ChatRatingQuery{UserID: state.UserID}); err != nil || len(stored) != 1 || stored[0].Answer != answer.Chat {
+		return fmt.Errorf("regenerate: rating lost its answer: %+v %v", stored, err)
+	}
+
+	// Editing the last prompt of the long conversation branches it into a new room
+	rooms, err := h.Store.Rooms.ListRooms(ctx, state.UserID)
+	if err != nil {
+		return fmt.Errorf("list rooms: %w", err)
+	}
+	var source *models.Room
+	for _, room := range rooms {
+		if room.RoomTitle == "Obrolan panjang" {
+			source = room
+		}
+	}
+	if source == nil {
+		return fmt.Errorf("edit prompt: room of the chat memory scenario missing")
+	}
+	sourceChats, err := h.Store.Rooms.ListChats(ctx, state.UserID, source.RoomID)
+	if err != nil || len(sourceChats) != 10 {
+		return fmt.Errorf("edit prompt: expected 10 chats in the source room, got %d (err=%v)", len(sourceChats), err)
+	}
+	edited := sourceChats[8]
+
+	h.OpenAI.QueueStream("Sama-sama, selamat makan malam!")
+	events, err = h.Stream(http.MethodPost, "/v1/hoca/room/"+source.RoomID+"/chat/"+edited.ChatID+"/edit", state.Token, map[string]string{
+		"latitude":  fmt.Sprint(state.Latitude),
+		"longitude": fmt.Sprint(state.Longitude),
+		"prompt":    "Oke makasih, kalau makan malam?",
+	})
+	if err != nil {
+		return fmt.Errorf("edit prompt: %w", err)
+	}
+	if len(events) < 2 || events[0].Name != "room" || events[len(events)-1].Name != "done_recommendations" {
+		return fmt.Errorf("edit prompt: unexpected events %+v", events)
+	}
+	var branch models.Room
+	if err := json.Unmarshal([]byte(events[0].Data), &branch); err != nil || branch.RoomID == "" || branch.BranchedFromRoomID != source.RoomID || branch.BranchedFromChatID != edited.ChatID {
+		return fmt.Errorf("edit prompt: unexpected room event %s", events[0].Data)
+	}
+
+	branchChats, err := h.Store.Rooms.ListChats(ctx, state.UserID, branch.RoomID)
+	if err != nil || len(branchChats) != 10 || branchChats[8].Chat != "Oke makasih, kalau makan malam?" || branchChats[9].Chat != "Sama-sama, selamat makan malam!" {
+		return fmt.Errorf("edit prompt: unexpected chats in the new room: %+v %v", branchChats, err)
+	}
+	if sourceChats, err := h.Store.Rooms.ListChats(ctx, state.UserID, source.RoomID); err != nil || len(sourceChats) != 10 || sourceChats[8].Chat != edited.Chat {
+		return fmt.Errorf("edit prompt: source room changed: %+v %v", sourceChats, err)
+	}
+	stored, err := h.Store.Rooms.GetRoom(ctx, state.UserID, branch.RoomID)
+	if err != nil || stored.Summary == "" || stored.SummarizedChatID == "" || stored.SummarizedChatID == source.SummarizedChatID {
+		return fmt.Errorf("edit prompt: summary not carried to the copied chats: %+v %v", stored, err)
+	}
+	if system, _ := lastStreamMessages(h); !strings.Contains(system, stored.Summary) {
+		return fmt.Errorf("edit prompt: summary missing from the system prompt")
+	}
+	return nil
+}
+
+func chatSocketScenario(ctx context.Context, h *Harness, state *State) error {
+	room := &models.Room{UserID: state.UserID, RoomTitle: "Lewat socket", CreatedAt: time.Now().Format(time.RFC3339Nano)}
+	if err := h.Store.Rooms.CreateRoom(ctx, room); err != nil {
+		return fmt.Errorf("seed room: %w", err)
+	}
+	path := "/v1/hoca/ws/" + room.RoomID
+	const timeout = 5 * time.Second
+
+	if _, err := h.Socket(path, ""); err == nil || !strings.Contains(err.Error(), "401") {
+		return fmt.Errorf("socket without token: expected 401, got %v", err)
+	}
+	if _, err := h.Socket("/v1/hoca/ws/missing", state.Token); err == nil || !strings.Contains(err.Error(), "404") {
+		return fmt.Errorf("socket on a missing room: expected 404, got %v", err)
+	}
+
+	phone, err := h.Socket(path, state.Token)
+	if err != nil {
+		return fmt.Errorf("phone socket: %w", err)
+	}
+	defer phone.Close()
+	tablet, err := h.Socket(path, state.Token)
+	if err != nil {
+		return fmt.Errorf("tablet socket: %w", err)
+	}
+	defer tablet.Close()
+
+	prompt := func(text string) map[string]interface{} {
+		return map[string]interface{}{"type": "prompt", "prompt": text, "latitude": state.Latitude, "longitude": state.Longitude}
+	}
+	expectError := func(name string, socket *Socket, status int) error {
+		events, err := socket.Until("error", timeout)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if last := events[len(events)-1]; last.StatusCode != status {
+			return fmt.Errorf("%s: expected status %d, got %+v", name, status, last)
+		}
+		return nil
+	}
+
+	if err := phone.Send(map[string]string{"type": "cancel"}); err != nil {
+		return fmt.Errorf("cancel without answer: %w", err)
+	}
+	if err := expectError("cancel without answer", phone, http.StatusConflict); err != nil {
+		return err
+	}
+	if err := phone.Send("nope"); err != nil {
+		return fmt.Errorf("invalid frame: %w", err)
+	}
+	if err := expectError("invalid frame", phone, http.StatusBadRequest); err != nil {
+		return err
+	}
+	if err := phone.Send(map[string]string{"type": "prompt", "prompt": "Di mana saja?"}); err != nil {
+		return fmt.Errorf("prompt without location: %w", err)
+	}
+	if err := expectError("prompt without location", phone, http.StatusBadRequest); err != nil {
+		return err
+	}
+
+	// A prompt sent from the phone streams to both devices
+	h.OpenAI.QueueStream("Halo ", "[[restaurant:"+state.NearID+"|]]", "dari socket!")
+	if err := phone.Send(prompt("Ada yang dekat?")); err != nil {
+		return fmt.Errorf("prompt: %w", err)
+	}
+	for name, socket := range map[string]*Socket{"phone": phone, "tablet": tablet} {
+		events, err := socket.Until("done", timeout)
+		if err != nil {
+			return fmt.Errorf("%s answer: %w", name, err)
+		}
+		var text strings.Builder
+		cards := 0
+		for _, event := range events {
+			switch event.Type {
+			case "delta":
+				text.WriteString(event.Text)
+			case "recommendation_card":
+				cards++
+			}
+		}
+		done := events[len(events)-1]
+		if len(events) < 2 || events[0].Type != "message" || events[0].Chat == nil || events[0].Chat.Chat != "Ada yang dekat?" ||
+			events[1].Type != "message" || events[1].Chat == nil || events[1].Chat.ChatID != done.ChatID {
+			return fmt.Errorf("%s answer: expected the prompt and the answer first, got %+v", name, events)
+		}
+		if text.String() != "Halo dari socket!" || cards != 1 || done.Complete == nil || !*done.Complete || done.Chat.Chat != "Halo dari socket!" {
+			return fmt.Errorf("%s answer: unexpected frames %+v", name, events)
+		}
+	}
+
+	// Any device can cancel the answer streaming in the room
+	h.OpenAI.QueueStalledStream("Sebentar ")
+	if err := phone.Send(prompt("Yang buka 24 jam?")); err != nil {
+		return fmt.Errorf("second prompt: %w", err)
+	}
+	if _, err := tablet.Until("delta", timeout); err != nil {
+		return fmt.Errorf("second answer: %w", err)
+	}
+	if err := tablet.Send(prompt("Sekalian yang murah")); err != nil {
+		return fmt.Errorf("prompt while streaming: %w", err)
+	}
+	if err := expectError("prompt while streaming", tablet, http.StatusConflict); err != nil {
+		return err
+	}
+	if err := streamChat(h, state, room.RoomID, "Lewat HTTP saja"); err == nil || !strings.Contains(err.Error(), "409") {
+		return fmt.Errorf("SSE prompt while streaming: expected 409, got %v", err)
+	}
+	if err := tablet.Send(map[string]string{"type": "cancel"}); err != nil {
+		return fmt.Errorf("cancel: %w", err)
+	}
+	events, err := phone.Until("done", timeout)
+	if err != nil {
+		return fmt.Errorf("cancelled answer: %w", err)
+	}
+	done := events[len(events)-1]
+	if done.Complete == nil || *done.Complete || done.Chat.Chat != "Sebentar " {
+		return fmt.Errorf("cancelled answer: unexpected done frame %+v", done)
+	}
+	if _, err := tablet.Until("done", timeout); err != nil {
+		return fmt.Errorf("cancelled answer on the tablet: %w", err)
+	}
+	stored, err := h.Store.Rooms.GetChat(ctx, state.UserID, room.RoomID, done.ChatID)
+	if err != nil || stored.Chat != "Sebentar " || stored.Complete == nil || *stored.Complete {
+		return fmt.Errorf("cancelled answer: expected the partial answer stored, got %+v %v", stored, err)
+	}
+
+	// Answers asked over SSE reach the sockets too
+	h.OpenAI.QueueStream("Dari SSE.")
+	if err := streamChat(h, state, room.RoomID, "Lewat HTTP ya"); err != nil {
+		return err
+	}
+	events, err = tablet.Until("done", timeout)
+	if err != nil {
+		return fmt.Errorf("SSE answer on the tablet: %w", err)
+	}
+	if events[0].Type != "message" || events[0].Chat == nil || events[0].Chat.Chat != "Lewat HTTP ya" || events[len(events)-1].Chat.Chat != "Dari SSE." {
+		return fmt.Errorf("SSE answer on the tablet: unexpected frames %+v", events)
+	}
+	for _, event := range events {
+		if event.RoomID != room.RoomID {
+			return fmt.Errorf("SSE answer on the tablet: frame without its room %+v", event)
+		}
+	}
+
+	if _, err := phone.Until("done", timeout); err != nil {
+		return fmt.Errorf("SSE answer on the phone: %w", err)
+	}
+
+	// An answer asked over a socket stops once no socket is left on the room
+	h.OpenAI.QueueStalledStream("Tunggu ")
+	if err := phone.Send(prompt("Yang ada parkir?")); err != nil {
+		return fmt.Errorf("abandoned prompt: %w", err)
+	}
+	events, err = phone.Until("delta", timeout)
+	if err != nil {
+		return fmt.Errorf("abandoned answer: %w", err)
+	}
+	abandoned := events[len(events)-1].ChatID
+	phone.Close()
+	tablet.Close()
+	for deadline := time.Now().Add(timeout); ; time.Sleep(50 * time.Millisecond) {
+		stored, err := h.Store.Rooms.GetChat(ctx, state.UserID, room.RoomID, abandoned)
+		if err == nil && stored.Chat == "Tunggu " && stored.StreamLeaseUntil == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("abandoned answer: expected it stopped and saved, got %+v %v", stored, err)
+		}
+	}
+
+	// A socket is closed once its session is revoked
+	tokens, err := services.NewSessionServiceWithStore(h.Store).StartSession(ctx, &models.User{ID: state.UserID}, "integration")
+	if err != nil {
+		return fmt.Errorf("second session: %w", err)
+	}
+	laptop, err := h.Socket(path, tokens.Token)
+	if err != nil {
+		return fmt.Errorf("laptop socket: %w", err)
+	}
+	defer laptop.Close()
+	resp, err := h.Do(http.MethodPost, "/v1/auth/logout", tokens.Token, nil)
+	if err := expectStatus("logout", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	if err := laptop.Send(prompt("Masih bisa?")); err != nil {
+		return fmt.Errorf("prompt after logout: %w", err)
+	}
+	var closed wsutil.ClosedError
+	if _, err := laptop.Until("done", timeout); !errors.As(err, &closed) || closed.Code != 4401 {
+		return fmt.Errorf("prompt after logout: expected the socket closed with 4401, got %v", err)
+	}
+	return nil
+}
+
+// streamChat sends a prompt to the room and waits for the end of the answer
+func streamChat(h *Harness, state *State, roomID, prompt string) error {
+	events, err := h.Stream(http.MethodPost, "/v1/hoca/chat/"+roomID, state.Token, map[string]string{
+		"latitude":  fmt.Sprint(state.Latitude),
+		"longitude": fmt.Sprint(state.Longitude),
+		"prompt":    prompt,
+	})
+	if err != nil {
+		return fmt.Errorf("chat stream: %w", err)
+	}
+	for _, event := range events {
+		if event.Name == "done_recommendations" {
+			return nil
+		}
+	}
+	return fmt.Errorf("chat stream: done_recommendations event missing")
+}
+
+// lastStreamMessages returns the system prompt of the last streaming completion and its other messages as "role: content"
+func lastStreamMessages(h *Harness) (string, []string) {
+	var messages []interface{}
+	for _, request := range h.OpenAI.Requests() {
+		if stream, _ := request["stream"].(bool); stream {
+			messages, _ = request["messages"].([]interface{})
+		}
+	}
+
+	var system string
+	var history []string
+	for _, raw := range messages {
+		message, _ := raw.(map[string]interface{})
+		role, _ := message["role"].(string)
+		content, _ := message["content"].(string)
+		if role == "system" {
+			system = content
+			continue
+		}
+		history = append(history, role+": "+content)
+	}
+	return system, history
+}
+
+// checkToolResults checks the last streaming completion got the tool calls back with their results
+func checkToolResults(h *Harness, state *State) error {
+	var messages []interface{}
+	for _, request := range h.OpenAI.Requests() {
+		if stream, _ := request["stream"].(bool); stream {
+			messages, _ = request["messages"].([]interface{})
+		}
+	}
+
+	var calls, results []map[string]interface{}
+	for _, raw := range messages {
+		message, _ := raw.(map[string]interface{})
+		if message["role"] == "assistant" {
+			toolCalls, _ := message["tool_calls"].([]interface{})
+			for _, call := range toolCalls {
+				calls = append(calls, call.(map[string]interface{}))
+			}
+		}
+		if message["role"] == "tool" {
+			results = append(results, message)
+		}
+	}
+	if len(calls) != 2 || len(results) != 2 {
+		return fmt.Errorf("chat tools: expected 2 calls and 2 results in the last request, got %v", messages)
+	}
+
+	function, _ := calls[0]["function"].(map[string]interface{})
+	if function["arguments"] != `{"sort":"distance","limit":2}` || results[0]["tool_call_id"] != calls[0]["id"] {
+		return fmt.Errorf("chat tools: search call not echoed back: %v", calls[0])
+	}
+	if content, _ := results[0]["content"].(string); !strings.Contains(content, state.NearID) || !strings.Contains(content, state.FartherID) {
+		return fmt.Errorf("chat tools: search result misses the seeded restaurants: %s", content)
+	}
+	if content, _ := results[1]["content"].(string); !strings.Contains(content, `"listed":false`) {
+		return fmt.Errorf("chat tools: unexpected ingredient check: %s", content)
+	}
+	return nil
+}
+
+func snackScenario(ctx context.Context, h *Harness, state *State) error {
+	resp, err := h.Do(http.MethodPost, "/v1/snack/scan", "", map[string]string{
+		"name_product": "Stub Snack",
+		"location":     "Indonesia",
+	})
+	if err := expectStatus("search snack", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	var verdict struct {
+		Status string `json:"Status"`
+	}
+	if err := resp.Decode(&verdict); err != nil || verdict.Status != "Halal" {
+		return fmt.Errorf("search snack: unexpected verdict %s", string(resp.Data))
+	}
+
+	image := []byte("\x89PNG\r\n\x1a\n")
+	resp, err = h.Multipart("/v1/snack/scan/front", "", map[string]string{"location": "Indonesia"}, map[string][]byte{
+		"frontImage": image,
+	})
+	if err := expectStatus("scan front image", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	resp, err = h.Multipart("/v1/snack/scan/front-back", "", map[string]string{"location": "Indonesia"}, map[string][]byte{
+		"frontImage": image,
+		"backImage":  image,
+	})
+	if err := expectStatus("scan front and back image", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	// An answer outside the Status enum is sent back to the model for repair
+	h.OpenAI.QueueReplies(`{"Status":"Mungkin","Reason":"Ragu","ProductName":"Stub Snack","Suggest":[]}`)
+	before := len(h.OpenAI.Requests())
+	resp, err = h.Do(http.MethodPost, "/v1/snack/scan", "", map[string]string{
+		"name_product": "Stub Snack",
+		"location":     "Indonesia",
+	})
+	if err := expectStatus("repair snack verdict", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	requests := h.OpenAI.Requests()
+	if len(requests)-before != 2 {
+		return fmt.Errorf("repair snack verdict: expected 2 completions, got %d", len(requests)-before)
+	}
+	if _, ok := requests[len(requests)-1]["response_format"]; !ok {
+		return fmt.Errorf("repair snack verdict: request has no response_format")
+	}
+
+	// A model that never produces a valid verdict surfaces as a bad gateway
+	h.OpenAI.QueueReplies("bukan json", "bukan json", "bukan json")
+	resp, err = h.Do(http.MethodPost, "/v1/snack/scan", "", map[string]string{
+		"name_product": "Stub Snack",
+		"location":     "Indonesia",
+	})
+	if err := expectStatus("invalid snack verdict", resp, err, http.StatusBadGateway); err != nil {
+		return err
+	}
+
+	resp, err = h.Multipart("/v1/snack/scan/front", "", map[string]string{}, nil)
+	return expectStatus("scan without location", resp, err, http.StatusBadRequest)
+}
+
+func ingridientScenario(ctx context.Context, h *Harness, state *State) error {
+	resp, err := h.Do(http.MethodPost, "/v1/ingridients", state.ModeratorToken, map[string]string{"name": "gelatin babi"})
+	if err := expectStatus("create ingridient", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/ingridients", "", nil)
+	if err := expectStatus("list ingridients", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	var ingridients []models.Ingrident
+	if err := resp.Decode(&ingridients); err != nil || len(ingridients) != 1 || ingridients[0].Name != "gelatin babi" {
+		return fmt.Errorf("list ingridients: unexpected ingridients %s", string(resp.Data))
+	}
+	return nil
+}
+
+func scrapeJobScenario(ctx context.Context, h *Harness, state *State) error {
+	resp, err := h.Do(http.MethodPost, "/v1/scraper/jobs", state.AdminToken, map[string]interface{}{
+		"latitude":  -6.2,
+		"longitude": 106.8,
+		"keyword":   "restoran halal",
+	})
+	if err := expectStatus("create scrape job", resp, err, http.StatusAccepted); err != nil {
+		return err
+	}
+
+	var job models.ScrapeJob
+	if err := resp.Decode(&job); err != nil || job.ID == "" || job.Status != models.ScrapeJobQueued {
+		return fmt.Errorf("create scrape job: expected a queued job, got %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/scraper/jobs/"+job.ID, state.AdminToken, nil)
+	if err := expectStatus("get scrape job", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodDelete, "/v1/scraper/jobs/"+job.ID, state.AdminToken, nil)
+	if err := expectStatus("cancel scrape job", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	if err := resp.Decode(&job); err != nil || job.Status != models.ScrapeJobCancelled || job.FinishedAt == nil {
+		return fmt.Errorf("cancel scrape job: expected a cancelled job, got %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodDelete, "/v1/scraper/jobs/"+job.ID, state.AdminToken, nil)
+	if err := expectStatus("cancel finished scrape job", resp, err, http.StatusConflict); err != nil {
+		return err
+	}
+
+	// The SSE endpoint replays a finished job and ends
+	events, err := h.Stream(http.MethodPost, "/v1/scraper?job_id="+job.ID, state.AdminToken, nil)
+	if err != nil {
+		return fmt.Errorf("stream scrape job: %w", err)
+	}
+	if len(events) != 2 || events[0].Name != "job_scrap" || events[1].Name != "done_scrap" {
+		return fmt.Errorf("stream scrape job: expected job_scrap then done_scrap, got %+v", events)
+	}
+
+	// A job run by a worker of another instance is followed through the stored job
+	now := time.Now()
+	leaseUntil := now.Add(time.Minute)
+	remote := &models.ScrapeJob{Status: models.ScrapeJobRunning, StartedAt: &now, WorkerID: "other-instance", LeaseUntil: &leaseUntil, Places: []models.ScrapeJobPlace{}}
+	if err := h.Store.ScrapeJobs.Create(ctx, remote); err != nil {
+		return fmt.Errorf("seed remote scrape job: %w", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		h.Store.ScrapeJobs.Update(ctx, remote.ID, func(job *models.ScrapeJob) error {
+			finished := time.Now()
+			job.Places = append(job.Places, models.ScrapeJobPlace{Title: "Bakso Jauh", MapsLink: "https://maps.google.com/?cid=remote", ScrapedAt: finished})
+			job.Status = models.ScrapeJobSucceeded
+			job.FinishedAt = &finished
+			return nil
+		})
+	}()
+	events, err = h.Stream(http.MethodPost, "/v1/scraper?job_id="+remote.ID, state.AdminToken, nil)
+	if err != nil {
+		return fmt.Errorf("stream remote scrape job: %w", err)
+	}
+	if len(events) != 3 || events[1].Name != "place_scrap" || !strings.Contains(events[1].Data, "Bakso Jauh") || events[2].Name != "done_scrap" {
+		return fmt.Errorf("stream remote scrape job: expected job_scrap, place_scrap then done_scrap, got %+v", events)
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/scraper/jobs/unknown", state.AdminToken, nil)
+	return expectStatus("get unknown scrape job", resp, err, http.StatusNotFound)
+}
+
+func reportScenario(ctx context.Context, h *Harness, state *State) error {
+	restaurantService := services.NewRestaurantServiceWithStore(h.Store)
+	place := &models.Place{Title: "Ayam Goreng Laporan", Location: models.GeoLocation{Latitude: state.Latitude - 0.001, Longitude: state.Longitude}}
+	if err := seedRestaurants(ctx, restaurantService, models.RestaurantStatusHalal, []*models.Place{place}); err != nil {
+		return fmt.Errorf("seed reported restaurant: %w", err)
+	}
+	seeded, err := h.Store.Restaurants.Find(ctx, repositories.RestaurantQuery{Title: place.Title})
+	if err != nil || len(seeded) != 1 {
+		return fmt.Errorf("seed reported restaurant: expected one restaurant, got %d (err=%v)", len(seeded), err)
+	}
+	restaurantID := seeded[0].ID
+	reportsPath := "/v1/restaurants/" + restaurantID + "/reports"
+
+	moderatorToken := state.ModeratorToken
+	pork := map[string]string{"type": models.ReportTypePorkOnMenu, "menu_item": "Nasi goreng babi", "comment": "Listed under the rice dishes", "photo_url": "https://example.com/menu.jpg"}
+	resp, err := h.Do(http.MethodPost, reportsPath, state.Token, pork)
+	if err := expectStatus("report before verifying the email", resp, err, http.StatusForbidden); err != nil {
+		return err
+	}
+	verifyToken, _, err := lastMailToken(h, "integration@halalmate.test")
+	if err != nil || verifyToken == "" {
+		return fmt.Errorf("verification email: no token found (err=%v)", err)
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/verify-email", "", map[string]string{"token": verifyToken})
+	if err := expectStatus("verify email", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPost, reportsPath, state.Token, pork)
+	if err := expectStatus("create report", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+	var porkReport models.RestaurantReport
+	if err := resp.Decode(&porkReport); err != nil || porkReport.ID == "" || porkReport.Status != models.ReportPending {
+		return fmt.Errorf("create report: expected a pending report, got %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodPost, reportsPath, state.Token, pork)
+	if err := expectStatus("duplicate report", resp, err, http.StatusConflict); err != nil {
+		return err
+	}
+
+	invalid := []map[string]string{
+		{"type": "tasteless"},
+		{"type": models.ReportTypeWrongStatus, "suggested_status": "maybe"},
+		{"type": models.ReportTypeCertificateSeen, "photo_url": "ftp://example.com/cert.jpg"},
+		{"type": models.ReportTypePorkOnMenu, "menu_item": strings.Repeat("babi ", 30)},
+		{"type": models.ReportTypePorkOnMenu, "menu_item": "Nasi goreng\nbabi"},
+	}
+	for _, body := range invalid {
+		resp, err = h.Do(http.MethodPost, reportsPath, state.Token, body)
+		if err := expectStatus("invalid report "+body["type"], resp, err, http.StatusBadRequest); err != nil {
+			return err
+		}
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/restaurants/missing/reports", state.Token, pork)
+	if err := expectStatus("report unknown restaurant", resp, err, http.StatusNotFound); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPost, reportsPath, state.Token, map[string]string{"type": models.ReportTypeClosed})
+	if err := expectStatus("create closed report", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+	var closedReport models.RestaurantReport
+	if err := resp.Decode(&closedReport); err != nil || closedReport.ID == "" {
+		return fmt.Errorf("create closed report: id missing from response")
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/reports", state.Token, nil)
+	if err := expectStatus("list my reports", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var reports []models.RestaurantReport
+	if err := resp.Decode(&reports); err != nil || len(reports) != 2 {
+		return fmt.Errorf("list my reports: expected 2 reports, got %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/moderation/reports", state.Token, nil)
+	if err := expectStatus("moderation queue as a user", resp, err, http.StatusForbidden); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/moderation/reports", moderatorToken, nil)
+	if err := expectStatus("moderation queue", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	reports = nil
+	if err := resp.Decode(&reports); err != nil || len(reports) != 2 || reports[0].ID != porkReport.ID {
+		return fmt.Errorf("moderation queue: expected both pending reports, oldest first, got %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/moderation/reports/"+porkReport.ID+"/accept", moderatorToken, map[string]string{"note": "Menu photo checked"})
+	if err := expectStatus("accept report", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var accepted struct {
+		Report     models.RestaurantReport `json:"report"`
+		Restaurant *models.Restaurant      `json:"restaurant"`
+	}
+	if err := resp.Decode(&accepted); err != nil || accepted.Report.Status != models.ReportAccepted || accepted.Report.ModeratorID != ModeratorUserID {
+		return fmt.Errorf("accept report: expected an accepted report, got %s", string(resp.Data))
+	}
+	restaurant := accepted.Restaurant
+	if restaurant == nil || restaurant.Status != models.RestaurantStatusHaram || restaurant.Verdict == nil ||
+		restaurant.Verdict.Source != models.VerdictSourceUserReport || strings.Join(restaurant.Verdict.OffendingItems, ",") != pork["menu_item"] {
+		return fmt.Errorf("accept report: expected the restaurant to turn haram from the user report, got %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/moderation/reports/"+porkReport.ID+"/reject", moderatorToken, nil)
+	if err := expectStatus("moderate reviewed report", resp, err, http.StatusConflict); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/moderation/reports/"+closedReport.ID+"/reject", moderatorToken, nil)
+	if err := expectStatus("reject report", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	stored, err := h.Store.Restaurants.GetByID(ctx, restaurantID)
+	if err != nil || stored.Closed {
+		return fmt.Errorf("reject report: expected the restaurant to stay open (err=%v)", err)
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/moderation/reports/missing/accept", moderatorToken, nil)
+	return expectStatus("moderate unknown report", resp, err, http.StatusNotFound)
+}
+
+func accountScenario(ctx context.Context, h *Harness, state *State) error {
+	const email = "akun@halalmate.test"
+	resp, err := h.Do(http.MethodPost, "/v1/auth/register", "", map[string]string{
+		"username":         "akun",
+		"email":            email,
+		"password":         "rahasia123",
+		"retyped_password": "rahasia123",
+		"device_id":        "phone",
+	})
+	if err := expectStatus("register", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+	var phone models.AuthTokens
+	if err := resp.Decode(&phone); err != nil || phone.Token == "" {
+		return fmt.Errorf("register: token missing from response")
+	}
+
+	invalid := []map[string]string{
+		{"username": "ab"},
+		{"avatar_url": "javascript:alert(1)"},
+		{"preferred_language": "fr"},
+	}
+	for i, body := range invalid {
+		resp, err = h.Do(http.MethodPatch, "/v1/users/profile", phone.Token, body)
+		if err := expectStatus(fmt.Sprintf("invalid profile update %d", i), resp, err, http.StatusBadRequest); err != nil {
+			return err
+		}
+	}
+
+	resp, err = h.Do(http.MethodPatch, "/v1/users/profile", phone.Token, map[string]string{
+		"username":           " akun baru ",
+		"avatar_url":         "https://cdn.halalmate.test/akun.png",
+		"preferred_language": "EN",
+		"home_city":          "Bandung",
+	})
+	if err := expectStatus("update profile", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var profile models.Profile
+	if err := resp.Decode(&profile); err != nil {
+		return fmt.Errorf("update profile: %w", err)
+	}
+	if profile.Username != "akun baru" || profile.PreferredLanguage != "en" || profile.HomeCity != "Bandung" || !profile.HasPassword {
+		return fmt.Errorf("update profile: unexpected profile %s", string(resp.Data))
+	}
+
+	// Fields left out are kept, an empty avatar removes it
+	resp, err = h.Do(http.MethodPatch, "/v1/users/profile", phone.Token, map[string]string{"avatar_url": ""})
+	if err := expectStatus("remove avatar", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	profile = models.Profile{}
+	if err := resp.Decode(&profile); err != nil || profile.AvatarURL != "" || profile.HomeCity != "Bandung" {
+		return fmt.Errorf("remove avatar: unexpected profile %s", string(resp.Data))
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/users/password", phone.Token, map[string]string{"password": "rahasia456", "retyped_password": "rahasia456"})
+	if err := expectStatus("link password when one is set", resp, err, http.StatusConflict); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{"email": email, "password": "rahasia123", "device_id": "tablet"})
+	if err := expectStatus("login on a second device", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var tablet models.AuthTokens
+	if err := resp.Decode(&tablet); err != nil || tablet.Token == "" {
+		return fmt.Errorf("login on a second device: token missing from response")
+	}
+
+	resp, err = h.Do(http.MethodPut, "/v1/users/password", phone.Token, map[string]string{
+		"current_password": "salah12345",
+		"new_password":     "rahasia456",
+		"retyped_password": "rahasia456",
+	})
+	if err := expectStatus("change password with a wrong current password", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPut, "/v1/users/password", phone.Token, map[string]string{
+		"current_password": "rahasia123",
+		"new_password":     "rahasia456",
+		"retyped_password": "rahasia456",
+	})
+	if err := expectStatus("change password", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	// The device that changed the password stays signed in, the others are signed out
+	resp, err = h.Do(http.MethodGet, "/v1/users/profile", phone.Token, nil)
+	if err := expectStatus("profile on the same device", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodGet, "/v1/users/profile", tablet.Token, nil)
+	if err := expectStatus("profile on the other device", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{"email": email, "password": "rahasia123"})
+	if err := expectStatus("login with old password", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{"email": email, "password": "rahasia456"})
+	if err := expectStatus("login with new password", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	if err := googlePasswordChecks(ctx, h); err != nil {
+		return err
+	}
+
+	// Give the account data for the deletion to cascade to
+	resp, err = h.Do(http.MethodPost, "/v1/bookmark", phone.Token, map[string]string{"restaurantId": state.NearID})
+	if err := expectStatus("create bookmark", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/hoca/room", phone.Token, map[string]string{"title": "Sarapan"})
+	if err := expectStatus("create room", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+	var room models.Room
+	if err := resp.Decode(&room); err != nil || room.RoomID == "" {
+		return fmt.Errorf("create room: room_id missing from response")
+	}
+	if err := h.Store.Rooms.CreateChat(ctx, profile.ID, &models.Chat{RoomID: room.RoomID, UserID: profile.ID, Chat: "Halo"}); err != nil {
+		return fmt.Errorf("seed chat: %w", err)
+	}
+	if err := h.Store.ChatRatings.Save(ctx, &models.ChatRating{UserID: profile.ID, RoomID: room.RoomID, ChatID: "rated-answer", Rating: models.ChatRatingUp}); err != nil {
+		return fmt.Errorf("seed chat rating: %w", err)
+	}
+
+	resp, err = h.Do(http.MethodDelete, "/v1/users/me", phone.Token, map[string]string{"password": "salah12345"})
+	if err := expectStatus("delete account with a wrong password", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodDelete, "/v1/users/me", phone.Token, map[string]string{"password": "rahasia456"})
+	if err := expectStatus("delete account", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+
+	resp, err = h.Do(http.MethodGet, "/v1/users/profile", phone.Token, nil)
+	if err := expectStatus("profile after deletion", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{"email": email, "password": "rahasia456"})
+	if err := expectStatus("login after deletion", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	if bookmarks, err := h.Store.Bookmarks.List(ctx, profile.ID); err != nil || len(bookmarks) != 0 {
+		return fmt.Errorf("bookmarks after deletion: expected none, got %d (err=%v)", len(bookmarks), err)
+	}
+	if rooms, err := h.Store.Rooms.ListRooms(ctx, profile.ID); err != nil || len(rooms) != 0 {
+		return fmt.Errorf("rooms after deletion: expected none, got %d (err=%v)", len(rooms), err)
+	}
+	if chats, err := h.Store.Rooms.ListChats(ctx, profile.ID, room.RoomID); err != nil || len(chats) != 0 {
+		return fmt.Errorf("chats after deletion: expected none, got %d (err=%v)", len(chats), err)
+	}
+	if ratings, err := h.Store.ChatRatings.Find(ctx, repositories.ChatRatingQuery{UserID: profile.ID}); err != nil || len(ratings) != 0 {
+		return fmt.Errorf("chat ratings after deletion: expected none, got %d (err=%v)", len(ratings), err)
+	}
+	return nil
+}
+
+// googlePasswordChecks links a password to an account created with Google, which can then log in with it
+func googlePasswordChecks(ctx context.Context, h *Harness) error {
+	const email = "google@halalmate.test"
+	user := &models.User{
+		ID:            "integration-google",
+		Email:         email,
+		Username:      "google",
+		IsGoogleUser:  true,
+		EmailVerified: true,
+	}
+	if err := h.Store.Users.Create(ctx, user); err != nil {
+		return fmt.Errorf("seed google user: %w", err)
+	}
+	tokens, err := services.NewSessionServiceWithStore(h.Store).StartSession(ctx, user, "")
+	if err != nil {
+		return fmt.Errorf("google session: %w", err)
+	}
+
+	resp, err := h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{"email": email, "password": "rahasia123"})
+	if err := expectStatus("google login with a password", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPut, "/v1/users/password", tokens.Token, map[string]string{
+		"current_password": "rahasia123",
+		"new_password":     "rahasia123",
+		"retyped_password": "rahasia123",
+	})
+	if err := expectStatus("change password without one", resp, err, http.StatusConflict); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/users/password", tokens.Token, map[string]string{"password": "pendek", "retyped_password": "pendek"})
+	if err := expectStatus("link a short password", resp, err, http.StatusBadRequest); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/users/password", tokens.Token, map[string]string{"password": "rahasia123", "retyped_password": "rahasia123"})
+	if err := expectStatus("link password", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/login", "", map[string]string{"email": email, "password": "rahasia123"})
+	return expectStatus("google login with the linked password", resp, err, http.StatusOK)
+}
+
+func dietaryScenario(ctx context.Context, h *Harness, state *State) error {
+	latitude, longitude := geohash.DecodeCenter(geohash.EncodeWithPrecision(-6.9, 107.6, 5))
+	place := func(title, category, source string, confidence float64, menu ...string) *models.Place {
+		place := &models.Place{
+			Title:    title,
+			Category: category,
+			Location: models.GeoLocation{Latitude: latitude + 0.001, Longitude: longitude},
+			Verdict:  models.NewHalalVerdict(models.RestaurantStatusHalal, source, confidence, "Seeded by the integration run", nil),
+		}
+		if len(menu) > 0 {
+			item := models.MenuItem{SubMenu: "Menu"}
+			for _, name := range menu {
+				item.MenuList = append(item.MenuList, models.MenuList{Name: name})
+			}
+			place.Menu = []models.MenuItem{item}
+		}
+		return place
+	}
+	places := []*models.Place{
+		place("Restoran Bersertifikat", "Restoran", models.VerdictSourceCertificate, 1),
+		place("Seafood Pak Udin", "Restoran Makanan Laut", models.VerdictSourceAdmin, 1, "Udang saus padang"),
+		place("Warung Ragu", "Warung", models.VerdictSourceMenuAI, 0.5),
+		place("Ramen Kuah", "Restoran Ramen", models.VerdictSourceAdmin, 1, "Shoyu ramen dengan mirin", "Ramen udang"),
+		place("Rumah Makan Padang", "Rumah Makan", models.VerdictSourceAdmin, 1, "Rendang"),
+	}
+	if err := services.NewRestaurantServiceWithStore(h.Store).SaveRestaurants(ctx, places); err != nil {
+		return fmt.Errorf("seed restaurants: %w", err)
+	}
+
+	setPreferences := func(step string, prefs models.DietaryPreferences) error {
+		resp, err := h.Do(http.MethodPut, "/v1/users/preferences", state.Token, prefs)
+		if err := expectStatus(step, resp, err, http.StatusOK); err != nil {
+			return err
+		}
+		var profile models.Profile
+		if err := resp.Decode(&profile); err != nil || profile.DietaryPreferences != prefs {
+			return fmt.Errorf("%s: unexpected profile %s", step, string(resp.Data))
+		}
+		return nil
+	}
+	// The main user keeps the default standard for the scenarios that follow
+	defer setPreferences("reset preferences", models.DietaryPreferences{})
+
+	cases := []struct {
+		name     string
+		prefs    models.DietaryPreferences
+		expected []string
+	}{
+		{"default", models.DietaryPreferences{}, []string{"Restoran Bersertifikat", "Seafood Pak Udin", "Warung Ragu", "Ramen Kuah", "Rumah Makan Padang"}},
+		{"certified only", models.DietaryPreferences{CertifiedOnly: true}, []string{"Restoran Bersertifikat"}},
+		{"avoid syubhat", models.DietaryPreferences{AvoidSyubhat: true}, []string{"Restoran Bersertifikat", "Seafood Pak Udin", "Ramen Kuah", "Rumah Makan Padang"}},
+		{"avoid seafood", models.DietaryPreferences{AvoidSeafood: true}, []string{"Restoran Bersertifikat", "Warung Ragu", "Rumah Makan Padang"}},
+		{"avoid alcohol flavouring", models.DietaryPreferences{AvoidAlcoholFlavouring: true}, []string{"Restoran Bersertifikat", "Seafood Pak Udin", "Warung Ragu", "Rumah Makan Padang"}},
+	}
+	query := fmt.Sprintf("latitude=%f&longitude=%f&sort=rating", latitude, longitude)
+	for _, c := range cases {
+		if err := setPreferences("set preferences "+c.name, c.prefs); err != nil {
+			return err
+		}
+		resp, err := h.Do(http.MethodGet, "/v1/restaurants?"+query, state.Token, nil)
+		if err := expectStatus("list restaurants "+c.name, resp, err, http.StatusOK); err != nil {
+			return err
+		}
+		var page services.RestaurantPage
+		if err := resp.Decode(&page); err != nil {
+			return fmt.Errorf("list restaurants %s: %w", c.name, err)
+		}
+		var titles []string
+		for _, restaurant := range page.Restaurants {
+			titles = append(titles, restaurant.Title)
+		}
+		sort.Strings(titles)
+		sort.Strings(c.expected)
+		if strings.Join(titles, ",") != strings.Join(c.expected, ",") {
+			return fmt.Errorf("list restaurants %s: expected %v, got %v", c.name, c.expected, titles)
+		}
+	}
+
+	// The recommendation and snack prompts carry the standard of the user
+	if err := setPreferences("set preferences for prompts", models.DietaryPreferences{AvoidSeafood: true}); err != nil {
+		return err
+	}
+	events, err := h.Stream(http.MethodPost, "/v1/hoca/chat/"+state.RoomID, state.Token, map[string]string{
+		"latitude":  fmt.Sprint(latitude),
+		"longitude": fmt.Sprint(longitude),
+		"prompt":    "Makan malam apa ya?",
+	})
+	if err != nil || len(events) == 0 {
+		return fmt.Errorf("chat stream: no events (err=%v)", err)
+	}
+	if prompt := lastSystemPrompt(h); !strings.Contains(prompt, "User's Dietary Standard") || !strings.Contains(prompt, "seafood") {
+		return fmt.Errorf("chat stream: system prompt misses the dietary standard")
+	}
+	if prompt := lastSystemPrompt(h); strings.Contains(prompt, "Seafood Pak Udin") {
+		return fmt.Errorf("chat stream: system prompt lists a restaurant the user avoids")
+	}
+
+	snack := map[string]string{"name_product": "Stub Snack", "location": "Indonesia"}
+	resp, err := h.Do(http.MethodPost, "/v1/snack/scan", state.Token, snack)
+	if err := expectStatus("signed in snack search", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	if prompt := lastSystemPrompt(h); !strings.Contains(prompt, "Standar kehalalan pengguna") || !strings.Contains(prompt, "udang") {
+		return fmt.Errorf("signed in snack search: system prompt misses the dietary standard")
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/snack/scan", "", snack)
+	if err := expectStatus("anonymous snack search", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	if strings.Contains(lastSystemPrompt(h), "Standar kehalalan pengguna") {
+		return fmt.Errorf("anonymous snack search: system prompt has a dietary standard")
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/snack/scan", "invalid", snack)
+	return expectStatus("snack search with an invalid token", resp, err, http.StatusUnauthorized)
+}
+
+// lastSystemPrompt returns the system message of the last completion sent to the OpenAI stub
+func lastSystemPrompt(h *Harness) string {
+	requests := h.OpenAI.Requests()
+	if len(requests) == 0 {
+		return ""
+	}
+	messages, _ := requests[len(requests)-1]["messages"].([]interface{})
+	for _, message := range messages {
+		message, _ := message.(map[string]interface{})
+		if message["role"] == "system" {
+			content, _ := message["content"].(string)
+			return content
+		}
+	}
+	return ""
+}
+
+func notificationScenario(ctx context.Context, h *Harness, state *State) error {
+	resp, err := h.Do(http.MethodPost, "/v1/auth/register", "", map[string]string{
+		"username":         "notif",
+		"email":            "notif@halalmate.test",
+		"password":         "rahasia123",
+		"retyped_password": "rahasia123",
+	})
+	if err := expectStatus("register", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+	var tokens models.AuthTokens
+	if err := resp.Decode(&tokens); err != nil || tokens.Token == "" {
+		return fmt.Errorf("register: token missing from response")
+	}
+	resp, err = h.Do(http.MethodPatch, "/v1/users/profile", tokens.Token, map[string]string{"preferred_language": "en"})
+	if err := expectStatus("prefer english", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	var profile models.Profile
+	if err := resp.Decode(&profile); err != nil || profile.ID == "" {
+		return fmt.Errorf("prefer english: id missing from response")
+	}
+
+	resp, err = h.Do(http.MethodPost, "/v1/auth/fcm-token", "", map[string]string{"fcm_token": "phone-token"})
+	if err := expectStatus("register device without token", resp, err, http.StatusUnauthorized); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/auth/fcm-token", tokens.Token, map[string]string{})
+	if err := expectStatus("register empty device token", resp, err, http.StatusBadRequest); err != nil {
+		return err
+	}
+	// Registering a device again keeps a single copy of its token
+	for _, token := range []string{"phone-token", "tablet-token", "phone-token", "old-token"} {
+		resp, err = h.Do(http.MethodPost, "/v1/auth/fcm-token", tokens.Token, map[string]string{"fcm_token": token})
+		if err := expectStatus("register device "+token, resp, err, http.StatusOK); err != nil {
+			return err
+		}
+	}
+	resp, err = h.Do(http.MethodDelete, "/v1/auth/fcm-token", tokens.Token, map[string]string{"fcm_token": "old-token"})
+	if err := expectStatus("remove device token", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	user, err := h.Store.Users.GetByID(ctx, profile.ID)
+	if err != nil || strings.Join(user.DeviceTokens(), ",") != "tablet-token,phone-token" {
+		return fmt.Errorf("device tokens: expected tablet-token,phone-token, got %v (err=%v)", user.DeviceTokens(), err)
+	}
+
+	// A halal status change reaches every device of the users who bookmarked the restaurant
+	restaurantService := services.NewRestaurantServiceWithStore(h.Store)
+	place := &models.Place{Title: "Bakmi Notifikasi", Location: models.GeoLocation{Latitude: state.Latitude - 0.002, Longitude: state.Longitude}}
+	if err := seedRestaurants(ctx, restaurantService, models.RestaurantStatusHalal, []*models.Place{place}); err != nil {
+		return fmt.Errorf("seed restaurant: %w", err)
+	}
+	seeded, err := h.Store.Restaurants.Find(ctx, repositories.RestaurantQuery{Title: place.Title})
+	if err != nil || len(seeded) != 1 {
+		return fmt.Errorf("seed restaurant: expected one restaurant, got %d (err=%v)", len(seeded), err)
+	}
+	restaurantID := seeded[0].ID
+
+	resp, err = h.Do(http.MethodPost, "/v1/bookmark", tokens.Token, map[string]string{"restaurantId": restaurantID})
+	if err := expectStatus("bookmark restaurant", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/restaurants/"+restaurantID+"/reports", state.Token, map[string]string{
+		"type":             models.ReportTypeWrongStatus,
+		"suggested_status": models.RestaurantStatusSyubhat,
+	})
+	if err := expectStatus("report restaurant", resp, err, http.StatusCreated); err != nil {
+		return err
+	}
+	var report models.RestaurantReport
+	if err := resp.Decode(&report); err != nil || report.ID == "" {
+		return fmt.Errorf("report restaurant: id missing from response")
+	}
+
+	before, err := h.Pushes()
+	if err != nil {
+		return fmt.Errorf("read pushes: %w", err)
+	}
+	resp, err = h.Do(http.MethodPost, "/v1/moderation/reports/"+report.ID+"/accept", state.ModeratorToken, nil)
+	if err := expectStatus("accept report", resp, err, http.StatusOK); err != nil {
+		return err
+	}
+	pushes, err := h.Pushes()
+	if err != nil || len(pushes) != len(before)+1 {
+		return fmt.Errorf("status change push: expected one push, got %d (err=%v)", len(pushes)-len(before), err)
+	}
+	push := pushes[len(pushes)-1]
+	data := push.Notification.Data
+	if strings.Join(push.Tokens, ",") != "tablet-token,phone-token" || push.Notification.Title != "Halal status changed" ||
+		data["type"] != services.NotificationRestaurantStatus || data["restaurant_id"] != restaurantID ||
+		data["status"] != models.RestaurantStatusSyubhat || data["previous_status"] != models.RestaurantStatusHalal {
+		return fmt.Errorf("status change push: unexpected push %+v", push)
+	}
+
+	notifications := services.NewNotificationServiceWithStore(h.Store)
+	notifications.NotifyScrapeJobFinished(ctx, &models.ScrapeJob{
+		ID:        "integration-job",
+		Status:    models.ScrapeJobSucceeded,
+		Keyword:   "restoran halal",
+		CreatedBy: profile.ID,
+		Places:    []models.ScrapeJobPlace{{Title: "Satu"}, {Title: "Dua"}},
+	})
+	pushes, err = h.Pushes()
+	if err != nil || len(pushes) != len(before)+2 {
+		return fmt.Errorf("scrape job push: expected one more push (err=%v)", err)
+	}
+	push = pushes[len(pushes)-1]
+	if push.Notification.Data["type"] != services.NotificationScrapeJob || push.Notification.Data["job_id"] != "integration-job" ||
+		!strings.Contains(push.Notification.Body, "2 places") {
+		return fmt.Errorf("scrape job push: unexpected push %+v", push)
+	}
+
+	// Tokens the backend reports as unregistered are forgotten
+	notifications.Notifier = staleNotifier{stale: "tablet-token"}
+	if err := notifications.NotifyUser(ctx, user, notifier.Notification{Title: "Tes"}); err != nil {
+		return fmt.Errorf("notify with a stale token: %w", err)
+	}
+	user, err = h.Store.Users.GetByID(ctx, profile.ID)
+	if err != nil || strings.Join(user.DeviceTokens(), ",") != "phone-token" {
+		return fmt.Errorf("stale device token: expected only phone-token, got %v (err=%v)", user.DeviceTokens(), err)
+	}
+	return nil
+}
+
+// staleNotifier reports one token as no longer registered
+type staleNotifier struct {
+	stale string
+}
+
+func (n staleNotifier) Send(ctx context.Context, tokens []string, notification notifier.Notification) ([]string, error) {
+	return []string{n.stale}, nil
+}
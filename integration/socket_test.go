@@ -0,0 +1,103 @@
+package integration
+
+import (
+	"HalalMate/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// Socket is a WebSocket opened on the API
+type Socket struct {
+	conn net.Conn
+	rw   io.ReadWriter
+}
+
+// SocketEvent is a frame received on a Socket
+type SocketEvent struct {
+	Type       string                     `json:"type"`
+	RoomID     string                     `json:"room_id"`
+	ID         string                     `json:"id"`
+	ChatID     string                     `json:"chat_id"`
+	Text       string                     `json:"text"`
+	Card       *models.RecommendationCard `json:"card"`
+	Tool       string                     `json:"tool"`
+	Chat       *models.Chat               `json:"chat"`
+	Room       *models.Room               `json:"room"`
+	Complete   *bool                      `json:"complete"`
+	StatusCode int                        `json:"statusCode"`
+	Message    string                     `json:"message"`
+}
+
+// Socket opens a WebSocket on path, authenticated with token when set
+func (h *Harness) Socket(path, token string) (*Socket, error) {
+	var dialer ws.Dialer
+	if token != "" {
+		dialer.Header = ws.HandshakeHeaderHTTP(http.Header{"Authorization": []string{"Bearer " + token}})
+	}
+
+	conn, buffered, _, err := dialer.Dial(context.Background(), "ws"+strings.TrimPrefix(h.Server.URL, "http")+path)
+	if err != nil {
+		return nil, err
+	}
+	// Frames sent right after the handshake may already sit in the handshake buffer
+	var reader io.Reader = conn
+	if buffered != nil {
+		reader = io.MultiReader(buffered, conn)
+	}
+	return &Socket{
+		conn: conn,
+		rw: struct {
+			io.Reader
+			io.Writer
+		}{reader, conn},
+	}, nil
+}
+
+// Send writes v as a JSON text frame
+func (s *Socket) Send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return wsutil.WriteClientText(s.conn, data)
+}
+
+// Until reads frames up to the first one of frameType, failing once timeout passed
+func (s *Socket) Until(frameType string, timeout time.Duration) ([]SocketEvent, error) {
+	s.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer s.conn.SetReadDeadline(time.Time{})
+
+	var events []SocketEvent
+	for {
+		data, op, err := wsutil.ReadServerData(s.rw)
+		if err != nil {
+			return events, fmt.Errorf("waiting for a %s frame: %w", frameType, err)
+		}
+		if op != ws.OpText {
+			continue
+		}
+
+		var event SocketEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return events, fmt.Errorf("invalid frame %s", string(data))
+		}
+		events = append(events, event)
+		if event.Type == frameType {
+			return events, nil
+		}
+	}
+}
+
+// Close closes the connection without a closing handshake
+func (s *Socket) Close() error {
+	return s.conn.Close()
+}
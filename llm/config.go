@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ProviderOpenAI           = "openai"
+	ProviderOpenAICompatible = "openai-compatible"
+	ProviderFake             = "fake"
+)
+
+// Config selects and tunes the provider of one call site
+type Config struct {
+	Provider    string
+	BaseURL     string
+	APIKey      string
+	Model       string
+	Temperature *float64
+	Timeout     time.Duration
+}
+
+// NewProvider builds the provider described by cfg
+func NewProvider(cfg Config) (LLMProvider, error) {
+	switch cfg.Provider {
+	case "", ProviderOpenAI:
+		return NewOpenAIProvider(cfg.APIKey, cfg.BaseURL), nil
+	case ProviderOpenAICompatible:
+		return NewCompatibleProvider(cfg.BaseURL, cfg.APIKey, cfg.Model)
+	case ProviderFake:
+		return NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+// FakeProvider is a deterministic LLMProvider for tests and offline runs.
+// Replies are returned in order and the last one repeats; without replies
+// it echoes the last user message.
+type FakeProvider struct {
+	mu       sync.Mutex
+	replies  []string
+	requests []Request
+
+	// Err, when set, is returned by every call
+	Err error
+}
+
+// NewFakeProvider creates a fake returning the given replies in order
+func NewFakeProvider(replies ...string) *FakeProvider {
+	return &FakeProvider{replies: replies}
+}
+
+// Requests returns every request received so far
+func (p *FakeProvider) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Request(nil), p.requests...)
+}
+
+func (p *FakeProvider) next(req Request) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests = append(p.requests, req)
+	if p.Err != nil {
+		return "", p.Err
+	}
+
+	if len(p.replies) == 0 {
+		for i := len(req.Messages) - 1; i >= 0; i-- {
+			if req.Messages[i].Role == RoleUser {
+				return req.Messages[i].Content, nil
+			}
+		}
+		return "", nil
+	}
+
+	reply := p.replies[0]
+	if len(p.replies) > 1 {
+		p.replies = p.replies[1:]
+	}
+	return reply, nil
+}
+
+func (p *FakeProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	for _, message := range req.Messages {
+		if len(message.Images) > 0 {
+			return nil, errImagesNotSupported
+		}
+	}
+	return p.CompleteVision(ctx, req)
+}
+
+func (p *FakeProvider) CompleteVision(ctx context.Context, req Request) (*Response, error) {
+	reply, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Content: reply, Model: req.Model, FinishReason: "stop"}, nil
+}
+
+func (p *FakeProvider) CompleteStream(ctx context.Context, req Request) (CompletionStream, error) {
+	reply, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeStream{ctx: ctx, chunks: strings.SplitAfter(reply, " ")}, nil
+}
+
+// fakeStream yields the reply word by word
+type fakeStream struct {
+	ctx    context.Context
+	chunks []string
+}
+
+func (s *fakeStream) Recv() (string, error) {
+	if err := s.ctx.Err(); err != nil {
+		return "", err
+	}
+	for len(s.chunks) > 0 {
+		chunk := s.chunks[0]
+		s.chunks = s.chunks[1:]
+		if chunk != "" {
+			return chunk, nil
+		}
+	}
+	return "", io.EOF
+}
+
+func (s *fakeStream) Close() error {
+	s.chunks = nil
+	return nil
+}
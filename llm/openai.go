@@ -0,0 +1,376 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	OpenAIBaseURL      = "https://api.openai.com/v1"
+	DefaultOpenAIModel = "gpt-4o"
+)
+
+// maxStreamedToolCalls bounds the index of streamed tool call fragments
+const maxStreamedToolCalls = 32
+
+var errImagesNotSupported = errors.New("messages with images must use CompleteVision")
+
+// chatCompletionsClient speaks the OpenAI /chat/completions wire protocol
+type chatCompletionsClient struct {
+	BaseURL      string
+	APIKey       string
+	DefaultModel string
+	HTTPClient   *http.Client
+}
+
+// OpenAIProvider calls the hosted OpenAI API
+type OpenAIProvider struct {
+	chatCompletionsClient
+}
+
+// NewOpenAIProvider creates a provider for api.openai.com. An empty baseURL uses the public endpoint.
+func NewOpenAIProvider(apiKey, baseURL string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = OpenAIBaseURL
+	}
+	return &OpenAIProvider{chatCompletionsClient{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		APIKey:       apiKey,
+		DefaultModel: DefaultOpenAIModel,
+		HTTPClient:   &http.Client{},
+	}}
+}
+
+// CompatibleProvider calls any server exposing an OpenAI compatible API
+// (self-hosted gateways, Ollama, vLLM, ...). The API key is optional.
+type CompatibleProvider struct {
+	chatCompletionsClient
+}
+
+// NewCompatibleProvider creates a provider for an OpenAI compatible server, e.g. http://localhost:11434/v1
+func NewCompatibleProvider(baseURL, apiKey, defaultModel string) (*CompatibleProvider, error) {
+	if baseURL == "" {
+		return nil, errors.New("base URL is required for an OpenAI compatible provider")
+	}
+	return &CompatibleProvider{chatCompletionsClient{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		APIKey:       apiKey,
+		DefaultModel: defaultModel,
+		HTTPClient:   &http.Client{},
+	}}, nil
+}
+
+func (c *chatCompletionsClient) Complete(ctx context.Context, req Request) (*Response, error) {
+	for _, message := range req.Messages {
+		if len(message.Images) > 0 {
+			return nil, errImagesNotSupported
+		}
+	}
+	return c.complete(ctx, req)
+}
+
+func (c *chatCompletionsClient) CompleteVision(ctx context.Context, req Request) (*Response, error) {
+	return c.complete(ctx, req)
+}
+
+func (c *chatCompletionsClient) complete(ctx context.Context, req Request) (*Response, error) {
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+
+	resp, err := c.send(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content   string         `json:"content"`
+				ToolCalls []wireToolCall `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("no choices returned in response")
+	}
+
+	toolCalls := make([]ToolCall, 0, len(result.Choices[0].Message.ToolCalls))
+	for _, call := range result.Choices[0].Message.ToolCalls {
+		toolCalls = append(toolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+
+	return &Response{
+		Content:      result.Choices[0].Message.Content,
+		Model:        result.Model,
+		FinishReason: result.Choices[0].FinishReason,
+		ToolCalls:    toolCalls,
+	}, nil
+}
+
+func (c *chatCompletionsClient) CompleteStream(ctx context.Context, req Request) (CompletionStream, error) {
+	cancel := func() {}
+	if req.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+	}
+
+	resp, err := c.send(ctx, req, true)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Return the response stream (caller must close it)
+	return &sseStream{body: resp.Body, reader: bufio.NewReader(resp.Body), cancel: cancel}, nil
+}
+
+func (c *chatCompletionsClient) send(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	model := req.Model
+	if model == "" {
+		model = c.DefaultModel
+	}
+
+	payload := map[string]interface{}{
+		"model":    model,
+		"messages": encodeMessages(req.Messages),
+	}
+	if req.Temperature != nil {
+		payload["temperature"] = *req.Temperature
+	}
+	if req.ResponseFormat != nil {
+		payload["response_format"] = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   req.ResponseFormat.Name,
+				"schema": req.ResponseFormat.Schema,
+				"strict": req.ResponseFormat.Strict,
+			},
+		}
+	}
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			tools = append(tools, map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  tool.Parameters,
+				},
+			})
+		}
+		payload["tools"] = tools
+	}
+	if stream {
+		payload["stream"] = true // Enable streaming mode
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Println("LLM API error response:", string(body))
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
+// encodeMessages converts messages into the OpenAI payload, using content parts when images are attached
+func encodeMessages(messages []Message) []map[string]interface{} {
+	encoded := make([]map[string]interface{}, 0, len(messages))
+	for _, message := range messages {
+		if len(message.ToolCalls) > 0 || message.ToolCallID != "" {
+			encoded = append(encoded, encodeToolMessage(message))
+			continue
+		}
+		if len(message.Images) == 0 {
+			encoded = append(encoded, map[string]interface{}{
+				"role":    message.Role,
+				"content": message.Content,
+			})
+			continue
+		}
+
+		content := []interface{}{
+			map[string]interface{}{"type": "text", "text": message.Content},
+		}
+		for _, image := range message.Images {
+			content = append(content, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": image},
+			})
+		}
+		encoded = append(encoded, map[string]interface{}{
+			"role":    message.Role,
+			"content": content,
+		})
+	}
+	return encoded
+}
+
+// wireToolCall is a tool call in the OpenAI payloads, streamed calls come in fragments keyed by Index
+type wireToolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// encodeToolMessage encodes an assistant message calling tools or the result of a call
+func encodeToolMessage(message Message) map[string]interface{} {
+	encoded := map[string]interface{}{"role": message.Role}
+	if message.ToolCallID != "" {
+		encoded["tool_call_id"] = message.ToolCallID
+		encoded["content"] = message.Content
+		return encoded
+	}
+
+	calls := make([]map[string]interface{}, 0, len(message.ToolCalls))
+	for _, call := range message.ToolCalls {
+		calls = append(calls, map[string]interface{}{
+			"id":   call.ID,
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":      call.Name,
+				"arguments": call.Arguments,
+			},
+		})
+	}
+	encoded["tool_calls"] = calls
+	// The API expects null rather than an empty string next to tool calls
+	if message.Content != "" {
+		encoded["content"] = message.Content
+	} else {
+		encoded["content"] = nil
+	}
+	return encoded
+}
+
+// sseStream parses the "data: {...}" lines of a streaming completion
+type sseStream struct {
+	body      io.ReadCloser
+	reader    *bufio.Reader
+	cancel    context.CancelFunc
+	toolCalls []ToolCall
+}
+
+func (s *sseStream) Recv() (string, error) {
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && strings.TrimSpace(line) == "" {
+				return "", io.EOF
+			}
+			if err != io.EOF {
+				return "", err
+			}
+		}
+
+		// OpenAI responses are prefixed with "data: "
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			if err == io.EOF {
+				return "", io.EOF
+			}
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return "", io.EOF // End of stream
+		}
+
+		var parsed struct {
+			Choices []struct {
+				Delta struct {
+					Content   string         `json:"content"`
+					ToolCalls []wireToolCall `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if jsonErr := json.Unmarshal([]byte(data), &parsed); jsonErr != nil {
+			log.Println("Error parsing streaming data:", jsonErr)
+			continue
+		}
+
+		if len(parsed.Choices) > 0 {
+			s.addToolCalls(parsed.Choices[0].Delta.ToolCalls)
+		}
+		if len(parsed.Choices) > 0 && parsed.Choices[0].Delta.Content != "" {
+			return parsed.Choices[0].Delta.Content, nil
+		}
+		if err == io.EOF {
+			return "", io.EOF
+		}
+	}
+}
+
+// addToolCalls merges the fragments of streamed tool calls, only the first fragment carries the id and name
+func (s *sseStream) addToolCalls(fragments []wireToolCall) {
+	for _, fragment := range fragments {
+		if fragment.Index < 0 || fragment.Index >= maxStreamedToolCalls {
+			continue
+		}
+		for len(s.toolCalls) <= fragment.Index {
+			s.toolCalls = append(s.toolCalls, ToolCall{})
+		}
+		call := &s.toolCalls[fragment.Index]
+		if fragment.ID != "" {
+			call.ID = fragment.ID
+		}
+		if fragment.Function.Name != "" {
+			call.Name = fragment.Function.Name
+		}
+		call.Arguments += fragment.Function.Arguments
+	}
+}
+
+// ToolCalls returns the tool calls of the answer, complete once Recv returned io.EOF
+func (s *sseStream) ToolCalls() []ToolCall {
+	return s.toolCalls
+}
+
+func (s *sseStream) Close() error {
+	defer s.cancel()
+	return s.body.Close()
+}
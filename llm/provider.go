@@ -0,0 +1,96 @@
+// Package llm abstracts the chat completion backends used by the services.
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is one chat message. Images are URLs or base64 data URLs.
+type Message struct {
+	Role    string
+	Content string
+	Images  []string
+	// ToolCalls are the tools an assistant message asked to run
+	ToolCalls []ToolCall
+	// ToolCallID links a RoleTool message to the call it answers
+	ToolCallID string
+}
+
+// Tool is a function the model may ask to call, Parameters is its JSON schema
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// ToolCall is a call of a Tool requested by the model, Arguments is a JSON object
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// Request describes a single completion call
+type Request struct {
+	Model       string
+	Messages    []Message
+	Temperature *float64
+	// Timeout bounds the whole call, including reading a stream. Zero means no timeout.
+	Timeout time.Duration
+	// ResponseFormat, when set, constrains the answer to a JSON schema
+	ResponseFormat *JSONSchema
+	// Tools the model may call instead of answering
+	Tools []Tool
+}
+
+// JSONSchema is a named JSON schema sent as an OpenAI json_schema response_format
+type JSONSchema struct {
+	Name   string
+	Schema map[string]interface{}
+	// Strict requires every property to be listed in "required" and additionalProperties to be false
+	Strict bool
+}
+
+// Response is the result of a non-streaming completion
+type Response struct {
+	Content      string
+	Model        string
+	FinishReason string
+	ToolCalls    []ToolCall
+}
+
+// CompletionStream yields content deltas. Recv returns io.EOF once the answer is complete.
+type CompletionStream interface {
+	Recv() (string, error)
+	Close() error
+}
+
+// ToolCallStream is implemented by the streams of providers supporting tools.
+// ToolCalls is complete once Recv returned io.EOF.
+type ToolCallStream interface {
+	CompletionStream
+	ToolCalls() []ToolCall
+}
+
+// LLMProvider is implemented by every chat completion backend
+type LLMProvider interface {
+	// Complete runs a text-only completion
+	Complete(ctx context.Context, req Request) (*Response, error)
+	// CompleteStream runs a completion and streams the answer
+	CompleteStream(ctx context.Context, req Request) (CompletionStream, error)
+	// CompleteVision runs a completion whose messages may carry images
+	CompleteVision(ctx context.Context, req Request) (*Response, error)
+}
+
+// Float returns a pointer to v, handy for Request.Temperature
+func Float(v float64) *float64 {
+	return &v
+}
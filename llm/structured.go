@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidOutput is returned when the answer still fails validation after every repair attempt
+var ErrInvalidOutput = errors.New("model returned an invalid structured output")
+
+// Validator is implemented by structured outputs that check their own invariants
+type Validator interface {
+	Validate() error
+}
+
+// CompleteFunc is Complete or CompleteVision of a provider
+type CompleteFunc func(ctx context.Context, req Request) (*Response, error)
+
+var codeFence = regexp.MustCompile("(?s)^```(?:json)?(.*?)```$")
+
+// CompleteStructured runs req, decodes the answer into out and validates it. When decoding or
+// validation fails, the invalid answer and the error are sent back to the model, asking it to
+// repair its reply, up to maxRepairs times.
+func CompleteStructured(ctx context.Context, complete CompleteFunc, req Request, out Validator, maxRepairs int) error {
+	messages := append([]Message(nil), req.Messages...)
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRepairs; attempt++ {
+		req.Messages = messages
+		resp, err := complete(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		lastErr = decodeStructured(resp.Content, out)
+		if lastErr == nil {
+			return nil
+		}
+
+		messages = append(messages,
+			Message{Role: RoleAssistant, Content: resp.Content},
+			Message{Role: RoleUser, Content: fmt.Sprintf(
+				"Your previous answer is invalid: %v. Reply again with only the corrected JSON object, without markdown or explanation.", lastErr)},
+		)
+	}
+
+	return fmt.Errorf("%w: %v", ErrInvalidOutput, lastErr)
+}
+
+// decodeStructured resets out, fills it from content and runs its validation
+func decodeStructured(content string, out Validator) error {
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return fmt.Errorf("structured output must be a non-nil pointer, got %T", out)
+	}
+	value.Elem().Set(reflect.Zero(value.Elem().Type()))
+
+	cleaned := strings.TrimSpace(content)
+	if match := codeFence.FindStringSubmatch(cleaned); match != nil {
+		cleaned = strings.TrimSpace(match[1])
+	}
+
+	if err := json.Unmarshal([]byte(cleaned), out); err != nil {
+		return fmt.Errorf("not valid JSON: %v", err)
+	}
+	return out.Validate()
+}
@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+)
+
+// FileMailer logs every message and, when Path is set, appends it to Path as a JSON line.
+// It is meant for local development and tests, where ReadMessages reads the outbox back.
+type FileMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewFileMailer creates a mailer writing to path, an empty path only logs
+func NewFileMailer(path string) *FileMailer {
+	return &FileMailer{Path: path}
+}
+
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	log.Printf("[Mailer] To: %s | Subject: %s\n%s", message.To, message.Subject, message.Body)
+	if m.Path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// ReadMessages returns every message a FileMailer appended to path, oldest first
+func ReadMessages(path string) ([]Message, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var messages []Message
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var message Message
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, scanner.Err()
+}
@@ -0,0 +1,56 @@
+// Package mailer sends the transactional emails of the API.
+package mailer
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+	DriverSMTP = "smtp"
+)
+
+// Message is one plain text email
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer is implemented by every email backend
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Config selects and configures the backend
+type Config struct {
+	Driver string
+	// From is the sender address of every message
+	From string
+	// Path is the file the file driver appends messages to
+	Path string
+
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// New builds the mailer described by cfg
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case "", DriverLog:
+		return NewFileMailer(""), nil
+	case DriverFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("the file mailer needs a path")
+		}
+		return NewFileMailer(cfg.Path), nil
+	case DriverSMTP:
+		return NewSMTPMailer(cfg)
+	default:
+		return nil, fmt.Errorf("unknown mailer driver %q", cfg.Driver)
+	}
+}
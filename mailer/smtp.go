@@ -0,0 +1,70 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends messages through an SMTP server, with STARTTLS when the server offers it
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer for cfg.SMTPHost, port 587 by default.
+// Credentials are optional, servers on localhost often accept mail without them.
+func NewSMTPMailer(cfg Config) (*SMTPMailer, error) {
+	if cfg.SMTPHost == "" || cfg.From == "" {
+		return nil, errors.New("the SMTP mailer needs a host and a from address")
+	}
+
+	port := cfg.SMTPPort
+	if port == "" {
+		port = "587"
+	}
+
+	mailer := &SMTPMailer{
+		addr: net.JoinHostPort(cfg.SMTPHost, port),
+		from: cfg.From,
+	}
+	if cfg.SMTPUsername != "" {
+		mailer.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return mailer, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	if strings.ContainsAny(message.To, "\r\n") {
+		return errors.New("invalid recipient")
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", m.from)
+	fmt.Fprintf(&body, "To: %s\r\n", message.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	// net/smtp has no context support, run it aside so a cancelled request does not wait for the server
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, m.auth, m.from, []string{message.To}, []byte(body.String()))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
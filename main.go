@@ -2,8 +2,12 @@ package main
 
 import (
 	"HalalMate/config/database"
+	"HalalMate/config/environment"
 	"HalalMate/middleware"
+	"HalalMate/repositories"
 	v1 "HalalMate/routes/v1"
+	"HalalMate/services"
+	"HalalMate/utils"
 	"log"
 	"os"
 	"time"
@@ -23,8 +27,20 @@ func main() {
 		log.Println("⚠️  No .env file found, using default values")
 	}
 
-	//firebase init
-	database.InitFirebase()
+	//storage init
+	if environment.GetStorageDriver() == "memory" {
+		log.Println("⚠️  STORAGE_DRIVER=memory, data will not be persisted")
+		repositories.SetStore(repositories.NewMemoryStore())
+	} else {
+		//firebase init
+		database.InitFirebase()
+		repositories.SetStore(repositories.NewFirestoreStore(database.GetFirestoreClient()))
+	}
+
+	// Fail now rather than on the first login when the JWT keys are misconfigured
+	if err := utils.LoadJWTConfig(); err != nil {
+		log.Fatalf("Invalid JWT configuration: %v", err)
+	}
 
 	// Setup Gin router
 	r := gin.Default()
@@ -47,14 +63,18 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowAllOrigins:  true,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "Last-Event-ID"},
 		ExposeHeaders:    []string{"Content-Length", "Content-Type", "Cache-Control", "Connection"},
 		AllowCredentials: false,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// Scrape workers belong to the server process, the routes share their job service
+	scrapeJobService := services.NewScrapeJobService(services.NewScrapService(services.NewOpenAIService()))
+	scrapeJobService.Start(services.ScrapeWorkers())
+
 	// Register all routes
-	v1.RegisterRoutes(r)
+	v1.RegisterRoutes(r, scrapeJobService)
 
 	// Add global OPTIONS handler to prevent redirects
 	r.OPTIONS("/*path", func(c *gin.Context) {
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"HalalMate/models"
+	"HalalMate/services"
 	"HalalMate/utils"
 	"net/http"
 	"strings"
@@ -9,50 +11,105 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// AuthMiddleware validates JWT tokens
+// AuthMiddleware validates JWT tokens and rejects the tokens of revoked sessions
 func AuthMiddleware() gin.HandlerFunc {
+	sessions := services.NewSessionService()
+
 	return func(c *gin.Context) {
-		tokenHeader := c.GetHeader("Authorization")
-		if tokenHeader == "" {
-			utils.ErrorResponse(c, http.StatusUnauthorized, "Authorization header is required")
+		if status, message := authenticate(c, sessions); status != 0 {
+			utils.ErrorResponse(c, status, message)
 			c.Abort()
 			return
 		}
 
-		tokenParts := strings.Split(tokenHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token format")
-			c.Abort()
-			return
-		}
+		c.Next()
+	}
+}
 
-		tokenString := tokenParts[1]
-		token, err := utils.ValidateToken(tokenString)
-		if err != nil || !token.Valid {
-			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired token")
-			c.Abort()
-			return
-		}
+// OptionalAuthMiddleware authenticates the requests that carry a token and lets anonymous ones through
+// without a userId. A token that is sent but invalid is still rejected.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	sessions := services.NewSessionService()
 
-		// Extract user ID from token claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token claims")
-			c.Abort()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
 			return
 		}
-
-		// Store the user ID in the context
-		userID, ok := claims["uid"].(string)
-		if !ok {
-			utils.ErrorResponse(c, http.StatusUnauthorized, "User ID not found in token")
+		if status, message := authenticate(c, sessions); status != 0 {
+			utils.ErrorResponse(c, status, message)
 			c.Abort()
 			return
 		}
 
-		// Pass userID to the context
-		c.Set("userId", userID)
-
 		c.Next()
 	}
 }
+
+// authenticate validates the bearer token and stores its claims in the context.
+// It returns a zero status on success, otherwise the status and message to answer with.
+func authenticate(c *gin.Context, sessions *services.SessionService) (int, string) {
+	tokenHeader := c.GetHeader("Authorization")
+	if tokenHeader == "" {
+		return http.StatusUnauthorized, "Authorization header is required"
+	}
+
+	tokenParts := strings.Split(tokenHeader, " ")
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		return http.StatusUnauthorized, "Invalid token format"
+	}
+
+	tokenString := tokenParts[1]
+	token, err := utils.ValidateToken(tokenString)
+	if err != nil || !token.Valid {
+		return http.StatusUnauthorized, "Invalid or expired token"
+	}
+
+	// Extract user ID from token claims
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return http.StatusUnauthorized, "Invalid token claims"
+	}
+
+	// Store the user ID in the context
+	userID, ok := claims["uid"].(string)
+	if !ok {
+		return http.StatusUnauthorized, "User ID not found in token"
+	}
+
+	// Every token belongs to a session, so it can be revoked before it expires
+	sessionID, _ := claims["sid"].(string)
+	if sessionID == "" {
+		return http.StatusUnauthorized, "Session not found in token"
+	}
+	active, err := sessions.IsSessionActive(c, sessionID)
+	if err != nil {
+		return http.StatusInternalServerError, "Failed to check session"
+	}
+	if !active {
+		return http.StatusUnauthorized, "Session has been revoked, please log in again"
+	}
+
+	// Pass userID and roles to the context, tokens issued before roles existed belong to plain users
+	c.Set("userId", userID)
+	c.Set("sessionId", sessionID)
+	c.Set("roles", tokenRoles(claims))
+	if expiresAt, err := claims.GetExpirationTime(); err == nil && expiresAt != nil {
+		c.Set("tokenExpiresAt", expiresAt.Time)
+	}
+	return 0, ""
+}
+
+func tokenRoles(claims jwt.MapClaims) []string {
+	values, _ := claims["roles"].([]interface{})
+	roles := make([]string, 0, len(values))
+	for _, value := range values {
+		if role, ok := value.(string); ok {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return []string{models.RoleUser}
+	}
+	return roles
+}
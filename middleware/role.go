@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"HalalMate/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole only lets through users whose token carries one of roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := c.GetStringSlice("roles")
+		for _, role := range roles {
+			for _, userRole := range granted {
+				if userRole == role {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		utils.ErrorResponse(c, http.StatusForbidden, "You do not have access to this resource")
+		c.Abort()
+	}
+}
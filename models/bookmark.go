@@ -4,9 +4,9 @@ import "time"
 
 // Bookmark represents a bookmark entity
 type Bookmark struct {
-	ID           string                 `json:"id"`
-	UserID       string                 `firestore:"userId"`
-	RestaurantID string                 `firestore:"restaurantId"`
-	Restaurant   map[string]interface{} `json:"Restaurant"`
-	CreatedAt    time.Time              `firestore:"createdAt"`
+	ID           string      `json:"id"`
+	UserID       string      `firestore:"userId"`
+	RestaurantID string      `firestore:"restaurantId"`
+	Restaurant   *Restaurant `json:"Restaurant" firestore:"-"`
+	CreatedAt    time.Time   `firestore:"createdAt"`
 }
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 //type struct of chat
 
 //type Room With Chat
@@ -15,6 +17,32 @@ type Room struct {
 	UserID    string `json:"user_id"`
 	RoomTitle string `json:"room_title"`
 	CreatedAt string `json:"created_at"`
+	// LastMessageAt is the CreatedAt of the newest chat, empty until the first chat
+	LastMessageAt string `json:"last_message_at"`
+	MessageCount  int    `json:"message_count"`
+	// LastActivityAt is LastMessageAt, or CreatedAt before the first chat, as the timestamp rooms are paged by
+	LastActivityAt time.Time `json:"-"`
+	// AutoTitle is set while the title waits to be generated from the first prompt
+	AutoTitle bool `json:"auto_title"`
+	// Summary condenses the chats up to SummarizedChatID, which no longer fit the history sent to HocaAI
+	Summary          string `json:"-"`
+	SummarizedChatID string `json:"-"`
+	// BranchedFromRoomID and BranchedFromChatID point to the room and the prompt an edited prompt was branched from
+	BranchedFromRoomID string `json:"branched_from_room_id,omitempty"`
+	BranchedFromChatID string `json:"branched_from_chat_id,omitempty"`
+}
+
+// ActivityTime parses LastMessageAt, or CreatedAt for a room without chats, zero when neither parses
+func (r *Room) ActivityTime() time.Time {
+	value := r.LastMessageAt
+	if value == "" {
+		value = r.CreatedAt
+	}
+	at, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return at
 }
 
 type Chat struct {
@@ -23,4 +51,24 @@ type Chat struct {
 	UserID    string `json:"user_id"`
 	Chat      string `json:"chat"`
 	CreatedAt string `json:"created_at"`
+	// Recommendations are the restaurants recommended in a HocaAI answer, in the order they were mentioned
+	Recommendations []RecommendationCard `json:"recommendations,omitempty"`
+	// Complete is false while a HocaAI answer streams or after its stream was cut, nil for user chats
+	Complete *bool `json:"complete,omitempty"`
+	// Rating is the last ChatRating.Rating the user gave to a HocaAI answer
+	Rating string `json:"rating,omitempty"`
+	// StreamLeaseUntil is renewed while a stream fills the answer, so only one stream continues it
+	StreamLeaseUntil *time.Time `json:"-"`
+	// StreamToken identifies the stream holding the lease, only it may save the answer
+	StreamToken string `json:"-"`
+}
+
+// RecommendationCard is a restaurant recommended by HocaAI, filled from the restaurants data
+type RecommendationCard struct {
+	RestaurantID string   `json:"restaurant_id"`
+	Name         string   `json:"name"`
+	DistanceKm   *float64 `json:"distance_km,omitempty"`
+	ImageURL     string   `json:"image_url"`
+	// MenuHighlights are the menu items picked by HocaAI, or the first items of the menu
+	MenuHighlights []MenuList `json:"menu_highlights"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Values of ChatRating.Rating
+const (
+	ChatRatingUp   = "up"
+	ChatRatingDown = "down"
+)
+
+// ChatRating is the thumbs up or down of a user on a HocaAI answer. The prompt and the answer
+// are copied so evaluation datasets keep them after the answer is regenerated.
+type ChatRating struct {
+	// ID is the ChatID of the rated answer, a user rates an answer once
+	ID     string `json:"id" firestore:"id"`
+	UserID string `json:"user_id" firestore:"userId"`
+	RoomID string `json:"room_id" firestore:"roomId"`
+	ChatID string `json:"chat_id" firestore:"chatId"`
+	Rating string `json:"rating" firestore:"rating"`
+	Reason string `json:"reason,omitempty" firestore:"reason,omitempty"`
+	// Prompt is the user chat the answer replied to
+	Prompt          string               `json:"prompt" firestore:"prompt"`
+	Answer          string               `json:"answer" firestore:"answer"`
+	Recommendations []RecommendationCard `json:"recommendations,omitempty" firestore:"recommendations,omitempty"`
+	CreatedAt       time.Time            `json:"created_at" firestore:"createdAt"`
+	UpdatedAt       time.Time            `json:"updated_at" firestore:"updatedAt"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// DietaryPreferences is the halal standard of a user. The zero value is the default standard:
+// any halal verdict is trusted, and seafood and alcohol-free flavourings are allowed.
+type DietaryPreferences struct {
+	// CertifiedOnly only accepts a halal certificate as proof
+	CertifiedOnly bool `json:"certified_only" firestore:"certifiedOnly"`
+	// AvoidSyubhat treats doubtful items and low confidence verdicts as not halal
+	AvoidSyubhat bool `json:"avoid_syubhat" firestore:"avoidSyubhat"`
+	// AvoidSeafood excludes seafood other than fish, such as shrimp, crab, squid and shellfish
+	AvoidSeafood bool `json:"avoid_seafood" firestore:"avoidSeafood"`
+	// AvoidAlcoholFlavouring excludes flavourings made with alcohol, such as mirin, angciu or vanilla extract
+	AvoidAlcoholFlavouring bool `json:"avoid_alcohol_flavouring" firestore:"avoidAlcoholFlavouring"`
+}
+
+// IsDefault reports whether no preference is set
+func (p DietaryPreferences) IsDefault() bool {
+	return p == DietaryPreferences{}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Values of EmailToken.Purpose
+const (
+	EmailTokenVerifyEmail   = "verify_email"
+	EmailTokenResetPassword = "reset_password"
+)
+
+// EmailToken is a single-use token sent by email, a document of the "email_tokens" collection.
+// The document ID is the SHA-256 of the token, the token itself is never stored.
+type EmailToken struct {
+	ID      string `json:"-" firestore:"id"`
+	UserID  string `json:"user_id" firestore:"userId"`
+	Email   string `json:"email" firestore:"email"`
+	Purpose string `json:"purpose" firestore:"purpose"`
+	// UsedAt is set once the token was redeemed
+	UsedAt    *time.Time `json:"used_at,omitempty" firestore:"usedAt,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at" firestore:"expiresAt"`
+	CreatedAt time.Time  `json:"created_at" firestore:"createdAt"`
+}
+
+// IsUsable reports whether the token can still be redeemed at the given time
+func (t *EmailToken) IsUsable(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Values of HalalVerdict.Source
+const (
+	VerdictSourceMenuAI      = "menu_ai"
+	VerdictSourceReviews     = "reviews"
+	VerdictSourceCertificate = "certificate"
+	VerdictSourceUserReport  = "user_report"
+	VerdictSourceAdmin       = "admin"
+)
+
+// VerdictSources lists every allowed HalalVerdict.Source
+var VerdictSources = []string{VerdictSourceMenuAI, VerdictSourceReviews, VerdictSourceCertificate, VerdictSourceUserReport, VerdictSourceAdmin}
+
+// HalalVerdict is the evidence behind the halal status of a restaurant
+type HalalVerdict struct {
+	// Status is one of RestaurantStatuses
+	Status string `json:"status" firestore:"status"`
+	// Confidence goes from 0 (a guess) to 1 (certain)
+	Confidence float64 `json:"confidence" firestore:"confidence"`
+	// Source is one of VerdictSources
+	Source string `json:"source" firestore:"source"`
+	Reason string `json:"reason" firestore:"reason"`
+	// OffendingItems are the menu items that made the restaurant haram or syubhat
+	OffendingItems []string  `json:"offending_items" firestore:"offendingItems"`
+	CreatedAt      time.Time `json:"created_at" firestore:"createdAt"`
+	UpdatedAt      time.Time `json:"updated_at" firestore:"updatedAt"`
+}
+
+// NewHalalVerdict creates a verdict decided now
+func NewHalalVerdict(status, source string, confidence float64, reason string, offendingItems []string) *HalalVerdict {
+	if offendingItems == nil {
+		offendingItems = []string{}
+	}
+	now := time.Now()
+	return &HalalVerdict{
+		Status:         status,
+		Confidence:     confidence,
+		Source:         source,
+		Reason:         reason,
+		OffendingItems: offendingItems,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// Validate checks the status and source enums and the confidence range
+func (v *HalalVerdict) Validate() error {
+	if !IsRestaurantStatus(v.Status) {
+		return fmt.Errorf("status must be one of %q, got %q", RestaurantStatuses, v.Status)
+	}
+	if !containsString(VerdictSources, v.Source) {
+		return fmt.Errorf("source must be one of %q, got %q", VerdictSources, v.Source)
+	}
+	if v.Confidence < 0 || v.Confidence > 1 {
+		return fmt.Errorf("confidence must be between 0 and 1, got %v", v.Confidence)
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package models
 
+import "fmt"
+
 type Place struct {
 	Title         string      `json:"title"`
 	Rating        string      `json:"rating"`
@@ -14,6 +16,11 @@ type Place struct {
 	MenuLink      []string    `json:"menu_link"`
 	Reviews       []string    `json:"reviews"`
 	Menu          []MenuItem  `json:"menu"`
+
+	// ApproximateLocation is set when the real coordinates were not found and Location is only the search center
+	ApproximateLocation bool `json:"approximate_location"`
+	// Verdict is the halal decision once the menu was analysed
+	Verdict *HalalVerdict `json:"verdict,omitempty"`
 }
 
 type GeoLocation struct {
@@ -31,7 +38,50 @@ type MenuList struct {
 	Price int64 `json:"price"`
 }
 
+// Values of AIResponsAnalyzeMenu.HalalStatus
+const (
+	MenuStatusHalal   = "halal"
+	MenuStatusHaram   = "haram"
+	MenuStatusSyubhat = "syubhat"
+)
+
+// MenuStatuses lists every allowed AIResponsAnalyzeMenu.HalalStatus
+var MenuStatuses = []string{MenuStatusHalal, MenuStatusHaram, MenuStatusSyubhat}
+
 type AIResponsAnalyzeMenu struct {
-	HalalStatus string       `json:"halal_status"` // "halal" or "haram"
+	HalalStatus string       `json:"halal_status"` // "halal", "haram" or "syubhat"
 	Menu        []MenuItem   `json:"menu"`
+
+	// Confidence of HalalStatus, from 0 to 1
+	Confidence float64 `json:"confidence"`
+	// HaramItems names the dishes that made the menu haram or syubhat
+	HaramItems []string `json:"haram_items"`
+}
+
+// Validate checks the status enum, the confidence range, that a haram or syubhat menu
+// names its offending dishes and that every dish has a name and a non-negative price
+func (r *AIResponsAnalyzeMenu) Validate() error {
+	if !containsString(MenuStatuses, r.HalalStatus) {
+		return fmt.Errorf("halal_status must be one of %q, got %q", MenuStatuses, r.HalalStatus)
+	}
+	if r.Confidence < 0 || r.Confidence > 1 {
+		return fmt.Errorf("confidence must be between 0 and 1, got %v", r.Confidence)
+	}
+	if r.HalalStatus != MenuStatusHalal && len(r.HaramItems) == 0 {
+		return fmt.Errorf("haram_items must list the dishes that make the menu %s", r.HalalStatus)
+	}
+	for i, item := range r.Menu {
+		if item.SubMenu == "" {
+			return fmt.Errorf("menu[%d].sub_menu is required", i)
+		}
+		for j, dish := range item.MenuList {
+			if dish.Name == "" {
+				return fmt.Errorf("menu[%d].menu_list[%d].name is required, use 'N/A' when unclear", i, j)
+			}
+			if dish.Price < 0 {
+				return fmt.Errorf("menu[%d].menu_list[%d].price must not be negative", i, j)
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/genproto/googleapis/type/latlng"
+)
+
+// Values of Restaurant.Status and HalalVerdict.Status
+const (
+	RestaurantStatusHalal = "halal"
+	RestaurantStatusHaram = "haram"
+	// RestaurantStatusSyubhat is doubtful: nothing clearly haram, but some items are questionable
+	RestaurantStatusSyubhat = "syubhat"
+	// RestaurantStatusUnknown means there was not enough evidence to decide
+	RestaurantStatusUnknown = "unknown"
+)
+
+// RestaurantStatuses lists every allowed Restaurant.Status
+var RestaurantStatuses = []string{RestaurantStatusHalal, RestaurantStatusHaram, RestaurantStatusSyubhat, RestaurantStatusUnknown}
+
+// IsRestaurantStatus reports whether status is one of RestaurantStatuses
+func IsRestaurantStatus(status string) bool {
+	return containsString(RestaurantStatuses, status)
+}
+
+// Restaurant is a document of the "restaurants" collection and the restaurant returned by the API
+type Restaurant struct {
+	ID            string         `json:"id" firestore:"id"`
+	Title         string         `json:"title" firestore:"title"`
+	Rating        string         `json:"rating" firestore:"rating"`
+	Address       string         `json:"address" firestore:"address"`
+	ReviewCount   string         `json:"review_count" firestore:"review_count"`
+	Location      *latlng.LatLng `json:"location" firestore:"location"`
+	Geohash       string         `json:"geohash" firestore:"geohash"`
+	PriceRange    string         `json:"price_range" firestore:"price_range"`
+	Category      string         `json:"category" firestore:"category"`
+	OpeningStatus string         `json:"opening_status" firestore:"opening_status"`
+	ImageURL      string         `json:"image_url" firestore:"image_url"`
+	MapsLink      string         `json:"maps_link" firestore:"maps_link"`
+	MenuLink      []string       `json:"menu_link" firestore:"menu_link"`
+	Reviews       []string       `json:"reviews" firestore:"reviews"`
+	Menu          []MenuItem     `json:"menu" firestore:"menu"`
+	// ApproximateLocation is true when Location is only the search center
+	ApproximateLocation bool `json:"approximate_location" firestore:"approximate_location"`
+	// Closed is set when an accepted report says the restaurant closed for good
+	Closed bool `json:"closed" firestore:"closed"`
+	// Status is one of RestaurantStatuses, a copy of Verdict.Status kept for queries
+	Status string `json:"status" firestore:"status"`
+	// Verdict explains Status, nil for restaurants saved before verdicts existed
+	Verdict   *HalalVerdict `json:"verdict" firestore:"verdict"`
+	CreatedAt time.Time     `json:"created_at" firestore:"createdAt"`
+	UpdatedAt time.Time     `json:"updated_at" firestore:"updatedAt"`
+
+	// Distance in km from the requested location, only set by location aware lookups
+	Distance *float64 `json:"distance,omitempty" firestore:"-"`
+	// IsBookmarked reports whether the requesting user bookmarked the restaurant
+	IsBookmarked bool `json:"isBookmarked" firestore:"-"`
+}
+
+// DistanceKm returns Distance, or zero when it was not computed
+func (r *Restaurant) DistanceKm() float64 {
+	if r.Distance == nil {
+		return 0
+	}
+	return *r.Distance
+}
+
+// RatingValue parses Rating, which Maps formats as "4,5" or "4.5". Unrated restaurants return zero.
+func (r *Restaurant) RatingValue() float64 {
+	rating, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(r.Rating), ",", ".", 1), 64)
+	if err != nil {
+		return 0
+	}
+	return rating
+}
+
+// ReviewCountValue parses ReviewCount, ignoring thousand separators such as "1.234" or "1,234"
+func (r *Restaurant) ReviewCountValue() int {
+	digits := strings.Map(func(c rune) rune {
+		if unicode.IsDigit(c) {
+			return c
+		}
+		return -1
+	}, r.ReviewCount)
+
+	count, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// IsOpen reports whether OpeningStatus said the restaurant was open when it was scraped
+func (r *Restaurant) IsOpen() bool {
+	status := strings.ToLower(strings.TrimSpace(r.OpeningStatus))
+	return strings.HasPrefix(status, "buka") || strings.HasPrefix(status, "open")
+}
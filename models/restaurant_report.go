@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Values of RestaurantReport.Type
+const (
+	ReportTypeWrongStatus     = "wrong_status"
+	ReportTypeClosed          = "closed"
+	ReportTypePorkOnMenu      = "pork_on_menu"
+	ReportTypeCertificateSeen = "certificate_seen"
+)
+
+// ReportTypes lists every allowed RestaurantReport.Type
+var ReportTypes = []string{ReportTypeWrongStatus, ReportTypeClosed, ReportTypePorkOnMenu, ReportTypeCertificateSeen}
+
+// States of a RestaurantReport
+const (
+	ReportPending  = "pending"
+	ReportAccepted = "accepted"
+	ReportRejected = "rejected"
+)
+
+// RestaurantReport is a user's correction of a restaurant, applied once a moderator accepts it
+type RestaurantReport struct {
+	ID           string `json:"id" firestore:"id"`
+	RestaurantID string `json:"restaurant_id" firestore:"restaurantId"`
+	UserID       string `json:"user_id" firestore:"userId"`
+	// Type is one of ReportTypes
+	Type string `json:"type" firestore:"type"`
+	// SuggestedStatus is the correct halal status, required for ReportTypeWrongStatus
+	SuggestedStatus string `json:"suggested_status,omitempty" firestore:"suggestedStatus,omitempty"`
+	// MenuItem is the name of the dish seen on the menu, optional for ReportTypePorkOnMenu
+	MenuItem string `json:"menu_item,omitempty" firestore:"menuItem,omitempty"`
+	Comment  string `json:"comment" firestore:"comment"`
+	// PhotoURL is an optional picture of the menu, the certificate or the storefront
+	PhotoURL string `json:"photo_url,omitempty" firestore:"photoUrl,omitempty"`
+	Status   string `json:"status" firestore:"status"`
+	// ModeratorID and ModeratorNote are set once the report was accepted or rejected
+	ModeratorID   string     `json:"moderator_id,omitempty" firestore:"moderatorId,omitempty"`
+	ModeratorNote string     `json:"moderator_note,omitempty" firestore:"moderatorNote,omitempty"`
+	CreatedAt     time.Time  `json:"created_at" firestore:"createdAt"`
+	UpdatedAt     time.Time  `json:"updated_at" firestore:"updatedAt"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty" firestore:"reviewedAt,omitempty"`
+}
+
+// IsReportType reports whether reportType is one of ReportTypes
+func IsReportType(reportType string) bool {
+	return containsString(ReportTypes, reportType)
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// States of a ScrapeJob
+const (
+	ScrapeJobQueued    = "queued"
+	ScrapeJobRunning   = "running"
+	ScrapeJobSucceeded = "succeeded"
+	ScrapeJobFailed    = "failed"
+	ScrapeJobCancelled = "cancelled"
+)
+
+// ScrapeJob is a Google Maps search processed in the background by the scraper workers
+type ScrapeJob struct {
+	ID        string  `json:"id" firestore:"id"`
+	Status    string  `json:"status" firestore:"status"`
+	Latitude  float64 `json:"latitude" firestore:"latitude"`
+	Longitude float64 `json:"longitude" firestore:"longitude"`
+	Keyword   string  `json:"keyword" firestore:"keyword"`
+	URL       string  `json:"url" firestore:"url"`
+	// Places lists every place scraped so far, in order
+	Places     []ScrapeJobPlace `json:"places" firestore:"places"`
+	Error      string           `json:"error,omitempty" firestore:"error,omitempty"`
+	CreatedBy  string           `json:"created_by,omitempty" firestore:"createdBy,omitempty"`
+	CreatedAt  time.Time        `json:"created_at" firestore:"createdAt"`
+	UpdatedAt  time.Time        `json:"updated_at" firestore:"updatedAt"`
+	StartedAt  *time.Time       `json:"started_at,omitempty" firestore:"startedAt,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty" firestore:"finishedAt,omitempty"`
+
+	// WorkerID is the worker running the job, it keeps the job while LeaseUntil is ahead
+	WorkerID   string     `json:"worker_id,omitempty" firestore:"workerId,omitempty"`
+	LeaseUntil *time.Time `json:"lease_until,omitempty" firestore:"leaseUntil,omitempty"`
+}
+
+// ScrapeJobPlace is the progress entry of one scraped place
+type ScrapeJobPlace struct {
+	Title     string    `json:"title" firestore:"title"`
+	MapsLink  string    `json:"maps_link" firestore:"mapsLink"`
+	ScrapedAt time.Time `json:"scraped_at" firestore:"scrapedAt"`
+}
+
+// IsFinished reports whether the job reached a terminal state
+func (j *ScrapeJob) IsFinished() bool {
+	return j.Status == ScrapeJobSucceeded || j.Status == ScrapeJobFailed || j.Status == ScrapeJobCancelled
+}
+
+// LeaseExpired reports whether a running job lost its worker, such as after a crash
+func (j *ScrapeJob) LeaseExpired(now time.Time) bool {
+	return j.Status == ScrapeJobRunning && (j.LeaseUntil == nil || j.LeaseUntil.Before(now))
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Session is a device signed in to an account, a document of the "sessions" collection.
+// The access tokens of the device carry its ID, so revoking the session logs the device out.
+type Session struct {
+	ID       string `json:"id" firestore:"id"`
+	UserID   string `json:"user_id" firestore:"userId"`
+	DeviceID string `json:"device_id" firestore:"deviceId"`
+	// RefreshTokenHash is the SHA-256 of the current refresh token, the token itself is never stored
+	RefreshTokenHash string `json:"-" firestore:"refreshTokenHash"`
+	// PreviousTokenHash is the token replaced by the last refresh, presenting it again means it leaked
+	PreviousTokenHash string     `json:"-" firestore:"previousTokenHash,omitempty"`
+	ExpiresAt         time.Time  `json:"expires_at" firestore:"expiresAt"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty" firestore:"revokedAt,omitempty"`
+	CreatedAt         time.Time  `json:"created_at" firestore:"createdAt"`
+	UpdatedAt         time.Time  `json:"updated_at" firestore:"updatedAt"`
+}
+
+// IsActive reports whether the session can still be used at the given time
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// AuthTokens is returned by every sign in and refresh
+type AuthTokens struct {
+	// Token is the access token sent as "Authorization: Bearer"
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn is the lifetime of Token in seconds
+	ExpiresIn int `json:"expires_in"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Values of SnackVerdict.Status
+const (
+	SnackStatusHalal   = "Halal"
+	SnackStatusHaram   = "Haram"
+	SnackStatusUnknown = "Tidak Dapat Menentukan"
+)
+
+// SnackStatuses lists every allowed SnackVerdict.Status
+var SnackStatuses = []string{SnackStatusHalal, SnackStatusHaram, SnackStatusUnknown}
+
+// SnackVerdict is the halal analysis of a packaged product
+type SnackVerdict struct {
+	Status      string            `json:"Status"`
+	Reason      string            `json:"Reason"`
+	ProductName string            `json:"ProductName"`
+	Suggest     []SnackSuggestion `json:"Suggest"`
+}
+
+// SnackSuggestion is a halal alternative to a product
+type SnackSuggestion struct {
+	NamaSugestProduk string `json:"NamaSugestProduk"`
+}
+
+// Validate checks the status enum and the suggestion rules given to the model
+func (v *SnackVerdict) Validate() error {
+	if !containsString(SnackStatuses, v.Status) {
+		return fmt.Errorf("Status must be one of %q, got %q", SnackStatuses, v.Status)
+	}
+	if strings.TrimSpace(v.Reason) == "" {
+		return errors.New("Reason is required")
+	}
+	if v.Status == SnackStatusHalal && len(v.Suggest) > 0 {
+		return errors.New("Suggest must be empty when Status is Halal")
+	}
+	for i, suggestion := range v.Suggest {
+		if strings.TrimSpace(suggestion.NamaSugestProduk) == "" {
+			return fmt.Errorf("Suggest[%d].NamaSugestProduk is required", i)
+		}
+	}
+
+	if v.Suggest == nil {
+		v.Suggest = []SnackSuggestion{}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,21 +2,80 @@ package models
 
 import "time"
 
+// Values of User.Roles
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// SupportedLanguages lists the values of User.PreferredLanguage
+var SupportedLanguages = []string{"id", "en"}
+
+// Roles lists every role that can be granted
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// IsRole reports whether role is one of Roles
+func IsRole(role string) bool {
+	return containsString(Roles, role)
+}
+
 type User struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"` // Exclude password from JSON responses for security
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                 string             `json:"id" firestore:"id"`
+	Email              string             `json:"email" firestore:"email"`
+	Username           string             `json:"username" firestore:"username"`
+	Password           string             `json:"-" firestore:"password,omitempty"` // Exclude password from JSON responses for security
+	IsGoogleUser       bool               `json:"is_google_user" firestore:"isGoogleUser,omitempty"`
+	EmailVerified      bool               `json:"email_verified" firestore:"emailVerified"` // Google accounts are verified by Google
+	AvatarURL          string             `json:"avatar_url" firestore:"avatarUrl,omitempty"`
+	PreferredLanguage  string             `json:"preferred_language" firestore:"preferredLanguage,omitempty"` // One of SupportedLanguages
+	HomeCity           string             `json:"home_city" firestore:"homeCity,omitempty"`
+	DietaryPreferences DietaryPreferences `json:"dietary_preferences" firestore:"dietaryPreferences"`
+	FCMToken           string             `json:"-" firestore:"fcmToken,omitempty"` // Single token saved before FCMTokens existed, see DeviceTokens
+	FCMTokens          []string           `json:"-" firestore:"fcmTokens,omitempty"`
+	Roles              []string           `json:"roles" firestore:"roles,omitempty"` // Empty for users saved before roles existed, see RoleList
+	CreatedAt          time.Time          `json:"created_at" firestore:"CreatedAt,serverTimestamp"`
+	UpdatedAt          time.Time          `json:"updated_at" firestore:"UpdatedAt,serverTimestamp"`
 }
 
 type Profile struct {
-	ID           string    `json:"id"`
-	Email        string    `json:"email"`
-	Username     string    `json:"username"`
-	Password     string    `json:"-"` // Exclude password from JSON responses for security
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	IsGoogleUser bool      `json:"is_google_user"`
+	ID                 string             `json:"id"`
+	Email              string             `json:"email"`
+	Username           string             `json:"username"`
+	Password           string             `json:"-"` // Exclude password from JSON responses for security
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+	IsGoogleUser       bool               `json:"is_google_user"`
+	EmailVerified      bool               `json:"email_verified"`
+	HasPassword        bool               `json:"has_password"` // Google users can link a password to also log in with it
+	AvatarURL          string             `json:"avatar_url"`
+	PreferredLanguage  string             `json:"preferred_language"`
+	HomeCity           string             `json:"home_city"`
+	DietaryPreferences DietaryPreferences `json:"dietary_preferences"`
+	Roles              []string           `json:"roles"`
+}
+
+// RoleList returns the roles of the user, RoleUser for users saved before roles existed
+func (u *User) RoleList() []string {
+	if len(u.Roles) == 0 {
+		return []string{RoleUser}
+	}
+	return u.Roles
+}
+
+// HasRole reports whether the user was granted role
+func (u *User) HasRole(role string) bool {
+	return containsString(u.RoleList(), role)
+}
+
+// MaxDeviceTokens is how many push tokens a user keeps, registering more forgets the oldest
+const MaxDeviceTokens = 10
+
+// DeviceTokens returns the push tokens of the user, including the one saved before FCMTokens existed
+func (u *User) DeviceTokens() []string {
+	tokens := append([]string(nil), u.FCMTokens...)
+	if u.FCMToken != "" && !containsString(tokens, u.FCMToken) {
+		tokens = append([]string{u.FCMToken}, tokens...)
+	}
+	return tokens
 }
@@ -0,0 +1,66 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"firebase.google.com/go/messaging"
+)
+
+// fcmConcurrency bounds the requests in flight for one notification
+const fcmConcurrency = 8
+
+// FCMNotifier sends through Firebase Cloud Messaging. Every token gets its own request: the
+// multicast batch endpoint of this SDK version has been shut down by Google.
+type FCMNotifier struct {
+	Client *messaging.Client
+}
+
+// NewFCMNotifier creates a notifier sending with client
+func NewFCMNotifier(client *messaging.Client) *FCMNotifier {
+	return &FCMNotifier{Client: client}
+}
+
+func (n *FCMNotifier) Send(ctx context.Context, tokens []string, notification Notification) ([]string, error) {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		stale []string
+		errs  []error
+	)
+	slots := make(chan struct{}, fcmConcurrency)
+	for _, token := range tokens {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(token string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			_, err := n.Client.Send(ctx, &messaging.Message{
+				Token: token,
+				Notification: &messaging.Notification{
+					Title: notification.Title,
+					Body:  notification.Body,
+				},
+				Data: notification.Data,
+			})
+			if err == nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			// The payload is always well formed, so an invalid argument means a malformed token
+			if messaging.IsRegistrationTokenNotRegistered(err) || messaging.IsInvalidArgument(err) {
+				stale = append(stale, token)
+				return
+			}
+			errs = append(errs, err)
+		}(token)
+	}
+	wg.Wait()
+
+	// Stale tokens are not a failure, they are reported for removal
+	return stale, errors.Join(errs...)
+}
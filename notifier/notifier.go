@@ -0,0 +1,63 @@
+// Package notifier sends the push notifications of the API.
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	firebase "firebase.google.com/go"
+)
+
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+	DriverFCM  = "fcm"
+)
+
+// Notification is one push message
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// Data is delivered to the app along with the notification, such as the id of the screen to open
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// Notifier is implemented by every push backend
+type Notifier interface {
+	// Send delivers the notification to every device token. It returns the tokens the backend
+	// reported as no longer registered, so the caller can forget them.
+	Send(ctx context.Context, tokens []string, notification Notification) (stale []string, err error)
+}
+
+// Config selects and configures the backend
+type Config struct {
+	Driver string
+	// Path is the file the file driver appends notifications to
+	Path string
+	// App is the Firebase app the fcm driver sends with
+	App *firebase.App
+}
+
+// New builds the notifier described by cfg
+func New(ctx context.Context, cfg Config) (Notifier, error) {
+	switch cfg.Driver {
+	case "", DriverLog:
+		return NewRecordingNotifier(""), nil
+	case DriverFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("the file notifier needs a path")
+		}
+		return NewRecordingNotifier(cfg.Path), nil
+	case DriverFCM:
+		if cfg.App == nil {
+			return nil, fmt.Errorf("the fcm notifier needs Firebase to be initialized")
+		}
+		client, err := cfg.App.Messaging(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return NewFCMNotifier(client), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+)
+
+// Push is one notification recorded by RecordingNotifier
+type Push struct {
+	Tokens       []string     `json:"tokens"`
+	Notification Notification `json:"notification"`
+}
+
+// RecordingNotifier logs every notification, keeps it in memory and, when Path is set, appends it
+// to Path as a JSON line. It is meant for local development and tests, where ReadPushes reads them back.
+type RecordingNotifier struct {
+	Path   string
+	mu     sync.Mutex
+	pushes []Push
+}
+
+// NewRecordingNotifier creates a notifier writing to path, an empty path only logs and records
+func NewRecordingNotifier(path string) *RecordingNotifier {
+	return &RecordingNotifier{Path: path}
+}
+
+func (n *RecordingNotifier) Send(ctx context.Context, tokens []string, notification Notification) ([]string, error) {
+	log.Printf("[Notifier] %d device(s) | %s: %s %v", len(tokens), notification.Title, notification.Body, notification.Data)
+	push := Push{Tokens: append([]string(nil), tokens...), Notification: notification}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.pushes = append(n.pushes, push)
+	if n.Path == "" {
+		return nil, nil
+	}
+
+	line, err := json.Marshal(push)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(n.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return nil, err
+}
+
+// Pushes returns the notifications sent through n, oldest first
+func (n *RecordingNotifier) Pushes() []Push {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Push(nil), n.pushes...)
+}
+
+// ReadPushes returns every notification a RecordingNotifier appended to path, oldest first
+func ReadPushes(path string) ([]Push, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var pushes []Push
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var push Push
+		if err := json.Unmarshal(scanner.Bytes(), &push); err != nil {
+			return nil, err
+		}
+		pushes = append(pushes, push)
+	}
+	return pushes, scanner.Err()
+}
@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+)
+
+// BookmarkRepository stores the "bookmarks" subcollection of a user
+type BookmarkRepository interface {
+	List(ctx context.Context, userID string) ([]models.Bookmark, error)
+	// Get returns ErrNotFound when the bookmark does not exist
+	Get(ctx context.Context, userID, bookmarkID string) (*models.Bookmark, error)
+	// FindByRestaurantID returns ErrNotFound when the user has not bookmarked the restaurant
+	FindByRestaurantID(ctx context.Context, userID, restaurantID string) (*models.Bookmark, error)
+	// BookmarkedRestaurantIDs reports which of the given restaurants the user has bookmarked
+	BookmarkedRestaurantIDs(ctx context.Context, userID string, restaurantIDs []string) (map[string]bool, error)
+	// Create stores a new bookmark and writes the generated id into bookmark.ID
+	Create(ctx context.Context, userID string, bookmark *models.Bookmark) error
+	Delete(ctx context.Context, userID, bookmarkID string) error
+	// UserIDsByRestaurant returns the ids of the users who bookmarked the restaurant
+	UserIDsByRestaurant(ctx context.Context, restaurantID string) ([]string, error)
+	// DeleteAll deletes every bookmark of the user
+	DeleteAll(ctx context.Context, userID string) error
+}
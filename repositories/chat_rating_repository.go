@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sort"
+)
+
+// ChatRatingQuery filters chat ratings. Empty fields are ignored.
+type ChatRatingQuery struct {
+	UserID string
+	Rating string
+}
+
+// ChatRatingRepository stores documents of the "chat_ratings" collection
+type ChatRatingRepository interface {
+	// Save stores the rating under its ChatID, replacing an earlier rating of the same answer
+	// while keeping its CreatedAt
+	Save(ctx context.Context, rating *models.ChatRating) error
+	// Find returns the matching ratings, oldest first
+	Find(ctx context.Context, query ChatRatingQuery) ([]*models.ChatRating, error)
+	// DeleteAll deletes every rating of the user
+	DeleteAll(ctx context.Context, userID string) error
+}
+
+// sortChatRatings orders ratings by creation time, oldest first
+func sortChatRatings(ratings []*models.ChatRating) {
+	sort.Slice(ratings, func(i, j int) bool {
+		return ratings[i].CreatedAt.Before(ratings[j].CreatedAt)
+	})
+}
@@ -0,0 +1,15 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+)
+
+// EmailTokenRepository stores documents of the "email_tokens" collection
+type EmailTokenRepository interface {
+	// Create stores a new token under its ID, the hash of the token
+	Create(ctx context.Context, token *models.EmailToken) error
+	// Update reads the token, applies fn and writes the result back atomically.
+	// It returns ErrNotFound when the token does not exist.
+	Update(ctx context.Context, id string, fn func(token *models.EmailToken) error) (*models.EmailToken, error)
+}
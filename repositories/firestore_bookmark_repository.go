@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreBookmarkRepository struct {
+	FirestoreClient *firestore.Client
+}
+
+// NewFirestoreBookmarkRepository initializes the Firestore backed bookmark repository
+func NewFirestoreBookmarkRepository(client *firestore.Client) *FirestoreBookmarkRepository {
+	return &FirestoreBookmarkRepository{FirestoreClient: client}
+}
+
+func (r *FirestoreBookmarkRepository) collection(userID string) *firestore.CollectionRef {
+	return r.FirestoreClient.Collection("users").Doc(userID).Collection("bookmarks")
+}
+
+func (r *FirestoreBookmarkRepository) List(ctx context.Context, userID string) ([]models.Bookmark, error) {
+	iter := r.collection(userID).Documents(ctx)
+	defer iter.Stop()
+
+	var bookmarks []models.Bookmark
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var bookmark models.Bookmark
+		if err := doc.DataTo(&bookmark); err != nil {
+			return nil, err
+		}
+		bookmark.ID = doc.Ref.ID
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, nil
+}
+
+func (r *FirestoreBookmarkRepository) Get(ctx context.Context, userID, bookmarkID string) (*models.Bookmark, error) {
+	doc, err := r.collection(userID).Doc(bookmarkID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var bookmark models.Bookmark
+	if err := doc.DataTo(&bookmark); err != nil {
+		return nil, err
+	}
+	bookmark.ID = doc.Ref.ID
+	return &bookmark, nil
+}
+
+func (r *FirestoreBookmarkRepository) FindByRestaurantID(ctx context.Context, userID, restaurantID string) (*models.Bookmark, error) {
+	docs, err := r.collection(userID).Where("restaurantId", "==", restaurantID).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	var bookmark models.Bookmark
+	if err := docs[0].DataTo(&bookmark); err != nil {
+		return nil, err
+	}
+	bookmark.ID = docs[0].Ref.ID
+	return &bookmark, nil
+}
+
+func (r *FirestoreBookmarkRepository) BookmarkedRestaurantIDs(ctx context.Context, userID string, restaurantIDs []string) (map[string]bool, error) {
+	bookmarkedMap := make(map[string]bool)
+
+	for i := 0; i < len(restaurantIDs); i += inQueryBatchSize {
+		end := i + inQueryBatchSize
+		if end > len(restaurantIDs) {
+			end = len(restaurantIDs)
+		}
+
+		docs, err := r.collection(userID).Where("restaurantId", "in", restaurantIDs[i:end]).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			if restaurantID, ok := doc.Data()["restaurantId"].(string); ok {
+				bookmarkedMap[restaurantID] = true
+			}
+		}
+	}
+
+	return bookmarkedMap, nil
+}
+
+func (r *FirestoreBookmarkRepository) Create(ctx context.Context, userID string, bookmark *models.Bookmark) error {
+	docRef := r.collection(userID).NewDoc()
+	bookmark.ID = docRef.ID
+
+	_, err := docRef.Set(ctx, map[string]interface{}{
+		"id":           bookmark.ID,
+		"userId":       bookmark.UserID,
+		"restaurantId": bookmark.RestaurantID,
+		"createdAt":    bookmark.CreatedAt,
+	})
+	return err
+}
+
+func (r *FirestoreBookmarkRepository) Delete(ctx context.Context, userID, bookmarkID string) error {
+	_, err := r.collection(userID).Doc(bookmarkID).Delete(ctx)
+	return err
+}
+
+func (r *FirestoreBookmarkRepository) DeleteAll(ctx context.Context, userID string) error {
+	return deleteCollection(ctx, r.FirestoreClient, r.collection(userID), nil)
+}
+
+// UserIDsByRestaurant queries the "bookmarks" collection group, which needs the restaurantId
+// single-field index enabled for collection group scope
+func (r *FirestoreBookmarkRepository) UserIDsByRestaurant(ctx context.Context, restaurantID string) ([]string, error) {
+	docs, err := r.FirestoreClient.CollectionGroup("bookmarks").Where("restaurantId", "==", restaurantID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	userIDs := []string{}
+	for _, doc := range docs {
+		// users/{userID}/bookmarks/{bookmarkID}
+		userID := doc.Ref.Parent.Parent.ID
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
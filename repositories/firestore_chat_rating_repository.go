@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreChatRatingRepository struct {
+	FirestoreClient *firestore.Client
+}
+
+// NewFirestoreChatRatingRepository initializes the Firestore backed chat rating repository
+func NewFirestoreChatRatingRepository(client *firestore.Client) *FirestoreChatRatingRepository {
+	return &FirestoreChatRatingRepository{FirestoreClient: client}
+}
+
+func (r *FirestoreChatRatingRepository) collection() *firestore.CollectionRef {
+	return r.FirestoreClient.Collection("chat_ratings")
+}
+
+func (r *FirestoreChatRatingRepository) Save(ctx context.Context, rating *models.ChatRating) error {
+	ratingRef := r.collection().Doc(rating.ChatID)
+
+	return r.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		now := time.Now()
+		rating.ID = ratingRef.ID
+		rating.CreatedAt = now
+		rating.UpdatedAt = now
+
+		doc, err := tx.Get(ratingRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			existing, err := chatRatingFromDoc(doc)
+			if err != nil {
+				return err
+			}
+			rating.CreatedAt = existing.CreatedAt
+		}
+		return tx.Set(ratingRef, rating)
+	})
+}
+
+func (r *FirestoreChatRatingRepository) Find(ctx context.Context, query ChatRatingQuery) ([]*models.ChatRating, error) {
+	q := r.collection().Query
+	if query.UserID != "" {
+		q = q.Where("userId", "==", query.UserID)
+	}
+	if query.Rating != "" {
+		q = q.Where("rating", "==", query.Rating)
+	}
+
+	docs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	ratings := make([]*models.ChatRating, 0, len(docs))
+	for _, doc := range docs {
+		rating, err := chatRatingFromDoc(doc)
+		if err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, rating)
+	}
+	sortChatRatings(ratings)
+	return ratings, nil
+}
+
+func (r *FirestoreChatRatingRepository) DeleteAll(ctx context.Context, userID string) error {
+	refs := []*firestore.DocumentRef{}
+	docs, err := r.collection().Where("userId", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		refs = append(refs, doc.Ref)
+	}
+	return deleteDocuments(ctx, r.FirestoreClient, refs)
+}
+
+func chatRatingFromDoc(doc *firestore.DocumentSnapshot) (*models.ChatRating, error) {
+	var rating models.ChatRating
+	if err := doc.DataTo(&rating); err != nil {
+		return nil, err
+	}
+	rating.ID = doc.Ref.ID
+	return &rating, nil
+}
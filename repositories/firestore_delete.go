@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+)
+
+// deleteCollection deletes every document of the collection, along with the subcollections
+// returned by children for each document. Firestore never deletes subcollections on its own.
+func deleteCollection(ctx context.Context, client *firestore.Client, collection *firestore.CollectionRef, children func(doc *firestore.DocumentRef) []*firestore.CollectionRef) error {
+	refs, err := collection.DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	if children != nil {
+		for _, ref := range refs {
+			for _, child := range children(ref) {
+				if err := deleteCollection(ctx, client, child, nil); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return deleteDocuments(ctx, client, refs)
+}
+
+// deleteDocuments deletes the documents in one BulkWriter batch
+func deleteDocuments(ctx context.Context, client *firestore.Client, refs []*firestore.DocumentRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	writer := client.BulkWriter(ctx)
+	jobs := make([]*firestore.BulkWriterJob, 0, len(refs))
+	for _, ref := range refs {
+		job, err := writer.Delete(ref)
+		if err != nil {
+			writer.End()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	writer.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
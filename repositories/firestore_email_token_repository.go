@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreEmailTokenRepository struct {
+	FirestoreClient *firestore.Client
+}
+
+// NewFirestoreEmailTokenRepository initializes the Firestore backed email token repository
+func NewFirestoreEmailTokenRepository(client *firestore.Client) *FirestoreEmailTokenRepository {
+	return &FirestoreEmailTokenRepository{FirestoreClient: client}
+}
+
+func (r *FirestoreEmailTokenRepository) collection() *firestore.CollectionRef {
+	return r.FirestoreClient.Collection("email_tokens")
+}
+
+func (r *FirestoreEmailTokenRepository) Create(ctx context.Context, token *models.EmailToken) error {
+	token.CreatedAt = time.Now()
+	_, err := r.collection().Doc(token.ID).Create(ctx, token)
+	return err
+}
+
+func (r *FirestoreEmailTokenRepository) Update(ctx context.Context, id string, fn func(token *models.EmailToken) error) (*models.EmailToken, error) {
+	tokenRef := r.collection().Doc(id)
+
+	var updated *models.EmailToken
+	err := r.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(tokenRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		var token models.EmailToken
+		if err := doc.DataTo(&token); err != nil {
+			return err
+		}
+		token.ID = doc.Ref.ID
+		if err := fn(&token); err != nil {
+			return err
+		}
+
+		updated = &token
+		return tx.Set(tokenRef, &token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
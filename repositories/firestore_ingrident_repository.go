@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+type FirestoreIngridentRepository struct {
+	FirestoreClient *firestore.Client
+}
+
+// NewFirestoreIngridentRepository initializes the Firestore backed ingredient repository
+func NewFirestoreIngridentRepository(client *firestore.Client) *FirestoreIngridentRepository {
+	return &FirestoreIngridentRepository{FirestoreClient: client}
+}
+
+func (r *FirestoreIngridentRepository) collection() *firestore.CollectionRef {
+	return r.FirestoreClient.Collection("ingridients")
+}
+
+func (r *FirestoreIngridentRepository) Create(ctx context.Context, name string) (string, error) {
+	ingridentRef := r.collection().NewDoc()
+
+	_, err := ingridentRef.Set(ctx, map[string]interface{}{
+		"name": name,
+	})
+	if err != nil {
+		return "", err
+	}
+	return ingridentRef.ID, nil
+}
+
+func (r *FirestoreIngridentRepository) List(ctx context.Context) ([]*models.Ingrident, error) {
+	iter := r.collection().Documents(ctx)
+	defer iter.Stop()
+
+	var ingridents []*models.Ingrident
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var ingrident models.Ingrident
+		if err := doc.DataTo(&ingrident); err != nil {
+			return nil, err
+		}
+		ingridents = append(ingridents, &ingrident)
+	}
+	return ingridents, nil
+}
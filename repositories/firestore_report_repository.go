@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreReportRepository struct {
+	FirestoreClient *firestore.Client
+}
+
+// NewFirestoreReportRepository initializes the Firestore backed restaurant report repository
+func NewFirestoreReportRepository(client *firestore.Client) *FirestoreReportRepository {
+	return &FirestoreReportRepository{FirestoreClient: client}
+}
+
+func (r *FirestoreReportRepository) collection() *firestore.CollectionRef {
+	return r.FirestoreClient.Collection("restaurant_reports")
+}
+
+func (r *FirestoreReportRepository) Create(ctx context.Context, report *models.RestaurantReport) error {
+	docRef := r.collection().NewDoc()
+	report.ID = docRef.ID
+
+	now := time.Now()
+	report.CreatedAt = now
+	report.UpdatedAt = now
+
+	_, err := docRef.Set(ctx, report)
+	return err
+}
+
+func (r *FirestoreReportRepository) Get(ctx context.Context, id string) (*models.RestaurantReport, error) {
+	doc, err := r.collection().Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return reportFromDoc(doc)
+}
+
+func (r *FirestoreReportRepository) Find(ctx context.Context, query ReportQuery) ([]*models.RestaurantReport, error) {
+	q := r.collection().Query
+	if query.RestaurantID != "" {
+		q = q.Where("restaurantId", "==", query.RestaurantID)
+	}
+	if query.UserID != "" {
+		q = q.Where("userId", "==", query.UserID)
+	}
+	if query.Status != "" {
+		q = q.Where("status", "==", query.Status)
+	}
+	if query.Type != "" {
+		q = q.Where("type", "==", query.Type)
+	}
+
+	docs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*models.RestaurantReport, 0, len(docs))
+	for _, doc := range docs {
+		report, err := reportFromDoc(doc)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	sortReports(reports)
+	return reports, nil
+}
+
+func (r *FirestoreReportRepository) Update(ctx context.Context, id string, fn func(report *models.RestaurantReport) error) (*models.RestaurantReport, error) {
+	reportRef := r.collection().Doc(id)
+
+	var updated *models.RestaurantReport
+	err := r.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(reportRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		report, err := reportFromDoc(doc)
+		if err != nil {
+			return err
+		}
+		if err := fn(report); err != nil {
+			return err
+		}
+
+		report.UpdatedAt = time.Now()
+		updated = report
+		return tx.Set(reportRef, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func reportFromDoc(doc *firestore.DocumentSnapshot) (*models.RestaurantReport, error) {
+	var report models.RestaurantReport
+	if err := doc.DataTo(&report); err != nil {
+		return nil, err
+	}
+	report.ID = doc.Ref.ID
+	return &report, nil
+}
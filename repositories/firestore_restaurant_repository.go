@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// inQueryBatchSize is the number of values Firestore accepts in one "in" filter
+const inQueryBatchSize = 10
+
+type FirestoreRestaurantRepository struct {
+	FirestoreClient *firestore.Client
+}
+
+// NewFirestoreRestaurantRepository initializes the Firestore backed restaurant repository
+func NewFirestoreRestaurantRepository(client *firestore.Client) *FirestoreRestaurantRepository {
+	return &FirestoreRestaurantRepository{FirestoreClient: client}
+}
+
+func (r *FirestoreRestaurantRepository) collection() *firestore.CollectionRef {
+	return r.FirestoreClient.Collection("restaurants")
+}
+
+func (r *FirestoreRestaurantRepository) GetByID(ctx context.Context, id string) (*models.Restaurant, error) {
+	doc, err := r.collection().Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return restaurantFromDoc(doc)
+}
+
+func (r *FirestoreRestaurantRepository) GetByIDs(ctx context.Context, ids []string) ([]models.Restaurant, error) {
+	var restaurants []models.Restaurant
+
+	for i := 0; i < len(ids); i += inQueryBatchSize {
+		end := i + inQueryBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		docs, err := r.collection().Where("id", "in", ids[i:end]).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			restaurant, err := restaurantFromDoc(doc)
+			if err != nil {
+				return nil, err
+			}
+			restaurants = append(restaurants, *restaurant)
+		}
+	}
+
+	return restaurants, nil
+}
+
+func (r *FirestoreRestaurantRepository) Find(ctx context.Context, query RestaurantQuery) ([]models.Restaurant, error) {
+	q := r.collection().Query
+	if query.GeohashPrefix != "" {
+		q = q.Where("geohash", ">=", query.GeohashPrefix).
+			Where("geohash", "<=", query.GeohashPrefix+"~")
+	}
+	if query.Status != "" {
+		q = q.Where("status", "==", query.Status)
+	}
+	if query.Title != "" {
+		q = q.Where("title", "==", query.Title)
+	}
+	if query.Limit > 0 {
+		q = q.Limit(query.Limit)
+	}
+
+	iter := q.Documents(ctx)
+	defer iter.Stop()
+
+	var restaurants []models.Restaurant
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		restaurant, err := restaurantFromDoc(doc)
+		if err != nil {
+			return nil, err
+		}
+		restaurants = append(restaurants, *restaurant)
+	}
+
+	return restaurants, nil
+}
+
+func (r *FirestoreRestaurantRepository) Create(ctx context.Context, restaurant *models.Restaurant) error {
+	// Create a new document reference with an auto-generated ID
+	docRef := r.collection().NewDoc()
+	restaurant.ID = docRef.ID
+
+	now := time.Now()
+	restaurant.CreatedAt = now
+	restaurant.UpdatedAt = now
+
+	_, err := docRef.Set(ctx, restaurant)
+	return err
+}
+
+func (r *FirestoreRestaurantRepository) CreateMany(ctx context.Context, restaurants []*models.Restaurant) error {
+	if len(restaurants) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	batch := r.FirestoreClient.Batch()
+	for _, restaurant := range restaurants {
+		docRef := r.collection().NewDoc()
+		restaurant.ID = docRef.ID // Store Firestore document ID
+		restaurant.CreatedAt = now
+		restaurant.UpdatedAt = now
+		batch.Set(docRef, restaurant)
+	}
+
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+func (r *FirestoreRestaurantRepository) Update(ctx context.Context, id string, fn func(restaurant *models.Restaurant) error) (*models.Restaurant, error) {
+	restaurantRef := r.collection().Doc(id)
+
+	var updated *models.Restaurant
+	err := r.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(restaurantRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		restaurant, err := restaurantFromDoc(doc)
+		if err != nil {
+			return err
+		}
+		if err := fn(restaurant); err != nil {
+			return err
+		}
+
+		restaurant.UpdatedAt = time.Now()
+		updated = restaurant
+		return tx.Set(restaurantRef, restaurant)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func restaurantFromDoc(doc *firestore.DocumentSnapshot) (*models.Restaurant, error) {
+	var restaurant models.Restaurant
+	if err := doc.DataTo(&restaurant); err != nil {
+		return nil, err
+	}
+	restaurant.ID = doc.Ref.ID
+
+	// Documents written before the typed model spelled the field "updateAt"
+	if restaurant.UpdatedAt.IsZero() {
+		if legacy, ok := doc.Data()["updateAt"].(time.Time); ok {
+			restaurant.UpdatedAt = legacy
+		}
+	}
+	return &restaurant, nil
+}
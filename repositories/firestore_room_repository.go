@@ -0,0 +1,230 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreRoomRepository struct {
+	FirestoreClient *firestore.Client
+}
+
+// NewFirestoreRoomRepository initializes the Firestore backed room repository
+func NewFirestoreRoomRepository(client *firestore.Client) *FirestoreRoomRepository {
+	return &FirestoreRoomRepository{FirestoreClient: client}
+}
+
+func (r *FirestoreRoomRepository) rooms(userID string) *firestore.CollectionRef {
+	return r.FirestoreClient.Collection("users").Doc(userID).Collection("rooms")
+}
+
+func (r *FirestoreRoomRepository) chats(userID, roomID string) *firestore.CollectionRef {
+	return r.rooms(userID).Doc(roomID).Collection("chats")
+}
+
+func (r *FirestoreRoomRepository) CreateRoom(ctx context.Context, room *models.Room) error {
+	// Buat dokumen baru di Firestore (Firestore akan otomatis generate ID)
+	roomRef := r.rooms(room.UserID).NewDoc()
+	room.RoomID = roomRef.ID
+	room.LastActivityAt = room.ActivityTime()
+
+	_, err := roomRef.Set(ctx, room)
+	return err
+}
+
+func (r *FirestoreRoomRepository) ListRooms(ctx context.Context, userID string) ([]*models.Room, error) {
+	return roomsFromQuery(ctx, r.rooms(userID).Query)
+}
+
+func (r *FirestoreRoomRepository) ListRoomsPage(ctx context.Context, userID string, after *RoomCursor, limit int) ([]*models.Room, error) {
+	q := r.rooms(userID).OrderBy("LastActivityAt", firestore.Desc).OrderBy(firestore.DocumentID, firestore.Desc)
+	if after != nil {
+		q = q.StartAfter(after.At, after.ID)
+	}
+	return roomsFromQuery(ctx, q.Limit(limit))
+}
+
+func (r *FirestoreRoomRepository) ListAllRooms(ctx context.Context) ([]*models.Room, error) {
+	return roomsFromQuery(ctx, r.FirestoreClient.CollectionGroup("rooms").Query)
+}
+
+func roomsFromQuery(ctx context.Context, q firestore.Query) ([]*models.Room, error) {
+	roomDocs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var rooms []*models.Room
+	for _, doc := range roomDocs {
+		var room models.Room
+		if err := doc.DataTo(&room); err != nil {
+			return nil, err
+		}
+		room.RoomID = doc.Ref.ID
+		rooms = append(rooms, &room)
+	}
+	return rooms, nil
+}
+
+func (r *FirestoreRoomRepository) GetRoom(ctx context.Context, userID, roomID string) (*models.Room, error) {
+	roomDoc, err := r.rooms(userID).Doc(roomID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var room models.Room
+	if err := roomDoc.DataTo(&room); err != nil {
+		return nil, err
+	}
+	room.RoomID = roomDoc.Ref.ID
+	return &room, nil
+}
+
+func (r *FirestoreRoomRepository) UpdateRoom(ctx context.Context, userID, roomID string, fn func(room *models.Room) error) (*models.Room, error) {
+	roomRef := r.rooms(userID).Doc(roomID)
+
+	var updated *models.Room
+	err := r.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(roomRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		var room models.Room
+		if err := doc.DataTo(&room); err != nil {
+			return err
+		}
+		room.RoomID = doc.Ref.ID
+		if err := fn(&room); err != nil {
+			return err
+		}
+
+		updated = &room
+		return tx.Set(roomRef, room)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (r *FirestoreRoomRepository) DeleteRoom(ctx context.Context, userID, roomID string) error {
+	roomRef := r.rooms(userID).Doc(roomID)
+	if _, err := roomRef.Get(ctx); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	// Firestore keeps the chats subcollection when only the room document is deleted
+	if err := deleteCollection(ctx, r.FirestoreClient, roomRef.Collection("chats"), nil); err != nil {
+		return err
+	}
+	_, err := roomRef.Delete(ctx)
+	return err
+}
+
+func (r *FirestoreRoomRepository) ListChats(ctx context.Context, userID, roomID string) ([]models.Chat, error) {
+	chatsSnapshot, err := r.chats(userID, roomID).OrderBy("CreatedAt", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var chats []models.Chat
+	for _, chatDoc := range chatsSnapshot {
+		var chat models.Chat
+		if err := chatDoc.DataTo(&chat); err != nil {
+			return nil, err
+		}
+		chat.ChatID = chatDoc.Ref.ID
+		chats = append(chats, chat)
+	}
+	return chats, nil
+}
+
+func (r *FirestoreRoomRepository) CreateChat(ctx context.Context, userID string, chat *models.Chat) error {
+	roomRef := r.rooms(userID).Doc(chat.RoomID)
+	chatRef := roomRef.Collection("chats").NewDoc()
+	chat.ChatID = chatRef.ID
+
+	err := r.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := tx.Create(chatRef, chat); err != nil {
+			return err
+		}
+		// Update fails when the room does not exist, so no chat is left behind in a missing room
+		return tx.Update(roomRef, []firestore.Update{
+			{Path: "LastMessageAt", Value: chat.CreatedAt},
+			{Path: "LastActivityAt", Value: chatActivityTime(chat)},
+			{Path: "MessageCount", Value: firestore.Increment(1)},
+		})
+	})
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *FirestoreRoomRepository) GetChat(ctx context.Context, userID, roomID, chatID string) (*models.Chat, error) {
+	chatDoc, err := r.chats(userID, roomID).Doc(chatID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var chat models.Chat
+	if err := chatDoc.DataTo(&chat); err != nil {
+		return nil, err
+	}
+	chat.ChatID = chatDoc.Ref.ID
+	return &chat, nil
+}
+
+func (r *FirestoreRoomRepository) UpdateChat(ctx context.Context, userID, roomID, chatID string, fn func(chat *models.Chat) error) (*models.Chat, error) {
+	chatRef := r.chats(userID, roomID).Doc(chatID)
+
+	var updated *models.Chat
+	err := r.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(chatRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		var chat models.Chat
+		if err := doc.DataTo(&chat); err != nil {
+			return err
+		}
+		chat.ChatID = doc.Ref.ID
+		if err := fn(&chat); err != nil {
+			return err
+		}
+
+		updated = &chat
+		return tx.Set(chatRef, chat)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (r *FirestoreRoomRepository) DeleteAllRooms(ctx context.Context, userID string) error {
+	return deleteCollection(ctx, r.FirestoreClient, r.rooms(userID), func(room *firestore.DocumentRef) []*firestore.CollectionRef {
+		return []*firestore.CollectionRef{room.Collection("chats")}
+	})
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreScrapeJobRepository struct {
+	FirestoreClient *firestore.Client
+}
+
+// NewFirestoreScrapeJobRepository initializes the Firestore backed scrape job repository
+func NewFirestoreScrapeJobRepository(client *firestore.Client) *FirestoreScrapeJobRepository {
+	return &FirestoreScrapeJobRepository{FirestoreClient: client}
+}
+
+func (r *FirestoreScrapeJobRepository) collection() *firestore.CollectionRef {
+	return r.FirestoreClient.Collection("scrape_jobs")
+}
+
+func (r *FirestoreScrapeJobRepository) Create(ctx context.Context, job *models.ScrapeJob) error {
+	docRef := r.collection().NewDoc()
+	job.ID = docRef.ID
+
+	now := time.Now()
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	_, err := docRef.Set(ctx, job)
+	return err
+}
+
+func (r *FirestoreScrapeJobRepository) Get(ctx context.Context, id string) (*models.ScrapeJob, error) {
+	doc, err := r.collection().Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return scrapeJobFromDoc(doc)
+}
+
+func (r *FirestoreScrapeJobRepository) ListByStatus(ctx context.Context, jobStatus string) ([]*models.ScrapeJob, error) {
+	docs, err := r.collection().Where("status", "==", jobStatus).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*models.ScrapeJob, 0, len(docs))
+	for _, doc := range docs {
+		job, err := scrapeJobFromDoc(doc)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sortScrapeJobs(jobs)
+	return jobs, nil
+}
+
+func (r *FirestoreScrapeJobRepository) Update(ctx context.Context, id string, fn func(job *models.ScrapeJob) error) (*models.ScrapeJob, error) {
+	jobRef := r.collection().Doc(id)
+
+	var updated *models.ScrapeJob
+	err := r.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(jobRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		job, err := scrapeJobFromDoc(doc)
+		if err != nil {
+			return err
+		}
+		if err := fn(job); err != nil {
+			return err
+		}
+
+		job.UpdatedAt = time.Now()
+		updated = job
+		return tx.Set(jobRef, job)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func scrapeJobFromDoc(doc *firestore.DocumentSnapshot) (*models.ScrapeJob, error) {
+	var job models.ScrapeJob
+	if err := doc.DataTo(&job); err != nil {
+		return nil, err
+	}
+	job.ID = doc.Ref.ID
+	return &job, nil
+}
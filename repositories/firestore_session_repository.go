@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreSessionRepository struct {
+	FirestoreClient *firestore.Client
+}
+
+// NewFirestoreSessionRepository initializes the Firestore backed session repository
+func NewFirestoreSessionRepository(client *firestore.Client) *FirestoreSessionRepository {
+	return &FirestoreSessionRepository{FirestoreClient: client}
+}
+
+func (r *FirestoreSessionRepository) collection() *firestore.CollectionRef {
+	return r.FirestoreClient.Collection("sessions")
+}
+
+func (r *FirestoreSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	docRef := r.collection().NewDoc()
+	session.ID = docRef.ID
+
+	now := time.Now()
+	session.CreatedAt = now
+	session.UpdatedAt = now
+
+	_, err := docRef.Set(ctx, session)
+	return err
+}
+
+func (r *FirestoreSessionRepository) Get(ctx context.Context, id string) (*models.Session, error) {
+	doc, err := r.collection().Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return sessionFromDoc(doc)
+}
+
+func (r *FirestoreSessionRepository) ListByUser(ctx context.Context, userID string) ([]*models.Session, error) {
+	docs, err := r.collection().Where("userId", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*models.Session, 0, len(docs))
+	for _, doc := range docs {
+		session, err := sessionFromDoc(doc)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (r *FirestoreSessionRepository) Update(ctx context.Context, id string, fn func(session *models.Session) error) (*models.Session, error) {
+	sessionRef := r.collection().Doc(id)
+
+	var updated *models.Session
+	err := r.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(sessionRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		session, err := sessionFromDoc(doc)
+		if err != nil {
+			return err
+		}
+		if err := fn(session); err != nil {
+			return err
+		}
+
+		session.UpdatedAt = time.Now()
+		updated = session
+		return tx.Set(sessionRef, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func sessionFromDoc(doc *firestore.DocumentSnapshot) (*models.Session, error) {
+	var session models.Session
+	if err := doc.DataTo(&session); err != nil {
+		return nil, err
+	}
+	session.ID = doc.Ref.ID
+	return &session, nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreUserRepository struct {
+	FirestoreClient *firestore.Client
+}
+
+// NewFirestoreUserRepository initializes the Firestore backed user repository
+func NewFirestoreUserRepository(client *firestore.Client) *FirestoreUserRepository {
+	return &FirestoreUserRepository{FirestoreClient: client}
+}
+
+func (r *FirestoreUserRepository) collection() *firestore.CollectionRef {
+	return r.FirestoreClient.Collection("users")
+}
+
+func (r *FirestoreUserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	doc, err := r.collection().Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var user models.User
+	if err := doc.DataTo(&user); err != nil {
+		return nil, err
+	}
+	user.ID = doc.Ref.ID
+	return &user, nil
+}
+
+func (r *FirestoreUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	docs, err := r.collection().Where("email", "==", email).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	var user models.User
+	if err := docs[0].DataTo(&user); err != nil {
+		return nil, err
+	}
+	user.ID = docs[0].Ref.ID
+	return &user, nil
+}
+
+func (r *FirestoreUserRepository) Create(ctx context.Context, user *models.User) error {
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+
+	_, err := r.collection().Doc(user.ID).Set(ctx, user)
+	return err
+}
+
+func (r *FirestoreUserRepository) Update(ctx context.Context, id string, fn func(user *models.User) error) error {
+	userRef := r.collection().Doc(id)
+
+	return r.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(userRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		user.ID = doc.Ref.ID
+
+		if err := fn(&user); err != nil {
+			return err
+		}
+
+		// Zero value lets Firestore stamp the update time
+		user.UpdatedAt = time.Time{}
+		return tx.Set(userRef, &user)
+	})
+}
+
+func (r *FirestoreUserRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.collection().Doc(id).Delete(ctx)
+	return err
+}
@@ -0,0 +1,13 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+)
+
+// IngridentRepository stores documents of the "ingridients" collection
+type IngridentRepository interface {
+	// Create stores a new ingredient and returns its generated id
+	Create(ctx context.Context, name string) (string, error)
+	List(ctx context.Context) ([]*models.Ingrident, error)
+}
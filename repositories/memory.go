@@ -0,0 +1,8 @@
+package repositories
+
+import "github.com/google/uuid"
+
+// newMemoryID generates document IDs for the in-memory repositories
+func newMemoryID() string {
+	return uuid.NewString()
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sort"
+	"sync"
+)
+
+type MemoryBookmarkRepository struct {
+	mu        sync.RWMutex
+	bookmarks map[string]map[string]models.Bookmark // userID -> bookmarkID -> bookmark
+}
+
+// NewMemoryBookmarkRepository initializes an empty in-memory bookmark repository
+func NewMemoryBookmarkRepository() *MemoryBookmarkRepository {
+	return &MemoryBookmarkRepository{
+		bookmarks: make(map[string]map[string]models.Bookmark),
+	}
+}
+
+func (r *MemoryBookmarkRepository) List(ctx context.Context, userID string) ([]models.Bookmark, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var bookmarks []models.Bookmark
+	for _, bookmark := range r.bookmarks[userID] {
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	// Firestore lists documents ordered by id
+	sort.Slice(bookmarks, func(i, j int) bool {
+		return bookmarks[i].ID < bookmarks[j].ID
+	})
+	return bookmarks, nil
+}
+
+func (r *MemoryBookmarkRepository) Get(ctx context.Context, userID, bookmarkID string) (*models.Bookmark, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	bookmark, ok := r.bookmarks[userID][bookmarkID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &bookmark, nil
+}
+
+func (r *MemoryBookmarkRepository) FindByRestaurantID(ctx context.Context, userID, restaurantID string) (*models.Bookmark, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, bookmark := range r.bookmarks[userID] {
+		if bookmark.RestaurantID == restaurantID {
+			return &bookmark, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryBookmarkRepository) BookmarkedRestaurantIDs(ctx context.Context, userID string, restaurantIDs []string) (map[string]bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(restaurantIDs))
+	for _, id := range restaurantIDs {
+		wanted[id] = true
+	}
+
+	bookmarkedMap := make(map[string]bool)
+	for _, bookmark := range r.bookmarks[userID] {
+		if wanted[bookmark.RestaurantID] {
+			bookmarkedMap[bookmark.RestaurantID] = true
+		}
+	}
+	return bookmarkedMap, nil
+}
+
+func (r *MemoryBookmarkRepository) Create(ctx context.Context, userID string, bookmark *models.Bookmark) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bookmark.ID = newMemoryID()
+	if r.bookmarks[userID] == nil {
+		r.bookmarks[userID] = make(map[string]models.Bookmark)
+	}
+
+	stored := *bookmark
+	stored.Restaurant = nil
+	r.bookmarks[userID][bookmark.ID] = stored
+	return nil
+}
+
+func (r *MemoryBookmarkRepository) Delete(ctx context.Context, userID, bookmarkID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.bookmarks[userID], bookmarkID)
+	return nil
+}
+
+func (r *MemoryBookmarkRepository) DeleteAll(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.bookmarks, userID)
+	return nil
+}
+
+func (r *MemoryBookmarkRepository) UserIDsByRestaurant(ctx context.Context, restaurantID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	userIDs := []string{}
+	for userID, bookmarks := range r.bookmarks {
+		for _, bookmark := range bookmarks {
+			if bookmark.RestaurantID == restaurantID {
+				userIDs = append(userIDs, userID)
+				break
+			}
+		}
+	}
+	sort.Strings(userIDs)
+	return userIDs, nil
+}
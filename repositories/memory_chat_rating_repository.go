@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sync"
+	"time"
+)
+
+type MemoryChatRatingRepository struct {
+	mu      sync.RWMutex
+	ratings map[string]models.ChatRating
+}
+
+// NewMemoryChatRatingRepository initializes an empty in-memory chat rating repository
+func NewMemoryChatRatingRepository() *MemoryChatRatingRepository {
+	return &MemoryChatRatingRepository{
+		ratings: make(map[string]models.ChatRating),
+	}
+}
+
+func (r *MemoryChatRatingRepository) Save(ctx context.Context, rating *models.ChatRating) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	rating.ID = rating.ChatID
+	rating.CreatedAt = now
+	if existing, ok := r.ratings[rating.ID]; ok {
+		rating.CreatedAt = existing.CreatedAt
+	}
+	rating.UpdatedAt = now
+	r.ratings[rating.ID] = *rating
+	return nil
+}
+
+func (r *MemoryChatRatingRepository) Find(ctx context.Context, query ChatRatingQuery) ([]*models.ChatRating, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ratings := []*models.ChatRating{}
+	for _, rating := range r.ratings {
+		if query.UserID != "" && rating.UserID != query.UserID {
+			continue
+		}
+		if query.Rating != "" && rating.Rating != query.Rating {
+			continue
+		}
+		rating := rating
+		ratings = append(ratings, &rating)
+	}
+	sortChatRatings(ratings)
+	return ratings, nil
+}
+
+func (r *MemoryChatRatingRepository) DeleteAll(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, rating := range r.ratings {
+		if rating.UserID == userID {
+			delete(r.ratings, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sync"
+	"time"
+)
+
+type MemoryEmailTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]models.EmailToken
+}
+
+// NewMemoryEmailTokenRepository initializes an empty in-memory email token repository
+func NewMemoryEmailTokenRepository() *MemoryEmailTokenRepository {
+	return &MemoryEmailTokenRepository{
+		tokens: make(map[string]models.EmailToken),
+	}
+}
+
+func (r *MemoryEmailTokenRepository) Create(ctx context.Context, token *models.EmailToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token.CreatedAt = time.Now()
+	r.tokens[token.ID] = *token
+	return nil
+}
+
+func (r *MemoryEmailTokenRepository) Update(ctx context.Context, id string, fn func(token *models.EmailToken) error) (*models.EmailToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := fn(&token); err != nil {
+		return nil, err
+	}
+
+	token.ID = id
+	r.tokens[id] = token
+	return &token, nil
+}
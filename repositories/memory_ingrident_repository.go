@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sort"
+	"sync"
+)
+
+type MemoryIngridentRepository struct {
+	mu         sync.RWMutex
+	ingridents map[string]models.Ingrident
+}
+
+// NewMemoryIngridentRepository initializes an empty in-memory ingredient repository
+func NewMemoryIngridentRepository() *MemoryIngridentRepository {
+	return &MemoryIngridentRepository{
+		ingridents: make(map[string]models.Ingrident),
+	}
+}
+
+func (r *MemoryIngridentRepository) Create(ctx context.Context, name string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := newMemoryID()
+	r.ingridents[id] = models.Ingrident{Name: name}
+	return id, nil
+}
+
+func (r *MemoryIngridentRepository) List(ctx context.Context) ([]*models.Ingrident, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.ingridents))
+	for id := range r.ingridents {
+		ids = append(ids, id)
+	}
+	// Firestore lists documents ordered by id
+	sort.Strings(ids)
+
+	var ingridents []*models.Ingrident
+	for _, id := range ids {
+		ingrident := r.ingridents[id]
+		ingridents = append(ingridents, &ingrident)
+	}
+	return ingridents, nil
+}
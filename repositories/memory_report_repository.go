@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sync"
+	"time"
+)
+
+type MemoryReportRepository struct {
+	mu      sync.RWMutex
+	reports map[string]models.RestaurantReport
+}
+
+// NewMemoryReportRepository initializes an empty in-memory restaurant report repository
+func NewMemoryReportRepository() *MemoryReportRepository {
+	return &MemoryReportRepository{
+		reports: make(map[string]models.RestaurantReport),
+	}
+}
+
+func (r *MemoryReportRepository) Create(ctx context.Context, report *models.RestaurantReport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report.ID = newMemoryID()
+	now := time.Now()
+	report.CreatedAt = now
+	report.UpdatedAt = now
+	r.reports[report.ID] = *report
+	return nil
+}
+
+func (r *MemoryReportRepository) Get(ctx context.Context, id string) (*models.RestaurantReport, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	report, ok := r.reports[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &report, nil
+}
+
+func (r *MemoryReportRepository) Find(ctx context.Context, query ReportQuery) ([]*models.RestaurantReport, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reports := []*models.RestaurantReport{}
+	for _, report := range r.reports {
+		if query.RestaurantID != "" && report.RestaurantID != query.RestaurantID {
+			continue
+		}
+		if query.UserID != "" && report.UserID != query.UserID {
+			continue
+		}
+		if query.Status != "" && report.Status != query.Status {
+			continue
+		}
+		if query.Type != "" && report.Type != query.Type {
+			continue
+		}
+		report := report
+		reports = append(reports, &report)
+	}
+	sortReports(reports)
+	return reports, nil
+}
+
+func (r *MemoryReportRepository) Update(ctx context.Context, id string, fn func(report *models.RestaurantReport) error) (*models.RestaurantReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report, ok := r.reports[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := fn(&report); err != nil {
+		return nil, err
+	}
+
+	report.ID = id
+	report.UpdatedAt = time.Now()
+	r.reports[id] = report
+	return &report, nil
+}
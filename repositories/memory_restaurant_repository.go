@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type MemoryRestaurantRepository struct {
+	mu          sync.RWMutex
+	restaurants map[string]models.Restaurant
+}
+
+// NewMemoryRestaurantRepository initializes an empty in-memory restaurant repository
+func NewMemoryRestaurantRepository() *MemoryRestaurantRepository {
+	return &MemoryRestaurantRepository{
+		restaurants: make(map[string]models.Restaurant),
+	}
+}
+
+func (r *MemoryRestaurantRepository) GetByID(ctx context.Context, id string) (*models.Restaurant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	restaurant, ok := r.restaurants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &restaurant, nil
+}
+
+func (r *MemoryRestaurantRepository) GetByIDs(ctx context.Context, ids []string) ([]models.Restaurant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var restaurants []models.Restaurant
+	for _, id := range ids {
+		if restaurant, ok := r.restaurants[id]; ok {
+			restaurants = append(restaurants, restaurant)
+		}
+	}
+	return restaurants, nil
+}
+
+func (r *MemoryRestaurantRepository) Find(ctx context.Context, query RestaurantQuery) ([]models.Restaurant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var restaurants []models.Restaurant
+	for _, restaurant := range r.restaurants {
+		if query.GeohashPrefix != "" && !strings.HasPrefix(restaurant.Geohash, query.GeohashPrefix) {
+			continue
+		}
+		if query.Status != "" && restaurant.Status != query.Status {
+			continue
+		}
+		if query.Title != "" && restaurant.Title != query.Title {
+			continue
+		}
+		restaurants = append(restaurants, restaurant)
+	}
+
+	// Firestore returns range queries ordered by the range field
+	sort.Slice(restaurants, func(i, j int) bool {
+		if restaurants[i].Geohash != restaurants[j].Geohash {
+			return restaurants[i].Geohash < restaurants[j].Geohash
+		}
+		return restaurants[i].ID < restaurants[j].ID
+	})
+
+	if query.Limit > 0 && len(restaurants) > query.Limit {
+		restaurants = restaurants[:query.Limit]
+	}
+	return restaurants, nil
+}
+
+func (r *MemoryRestaurantRepository) Create(ctx context.Context, restaurant *models.Restaurant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.insert(restaurant, time.Now())
+	return nil
+}
+
+func (r *MemoryRestaurantRepository) CreateMany(ctx context.Context, restaurants []*models.Restaurant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, restaurant := range restaurants {
+		r.insert(restaurant, now)
+	}
+	return nil
+}
+
+// insert stores a new restaurant, the caller holds the lock
+func (r *MemoryRestaurantRepository) insert(restaurant *models.Restaurant, now time.Time) {
+	restaurant.ID = newMemoryID()
+	restaurant.CreatedAt = now
+	restaurant.UpdatedAt = now
+	r.restaurants[restaurant.ID] = *restaurant
+}
+
+func (r *MemoryRestaurantRepository) Update(ctx context.Context, id string, fn func(restaurant *models.Restaurant) error) (*models.Restaurant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restaurant, ok := r.restaurants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := fn(&restaurant); err != nil {
+		return nil, err
+	}
+
+	restaurant.UpdatedAt = time.Now()
+	r.restaurants[id] = restaurant
+	return &restaurant, nil
+}
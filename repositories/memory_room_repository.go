@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sort"
+	"sync"
+)
+
+type MemoryRoomRepository struct {
+	mu    sync.RWMutex
+	rooms map[string]map[string]models.Room // userID -> roomID -> room
+	chats map[string][]models.Chat          // userID/roomID -> chats in insertion order
+}
+
+// NewMemoryRoomRepository initializes an empty in-memory room repository
+func NewMemoryRoomRepository() *MemoryRoomRepository {
+	return &MemoryRoomRepository{
+		rooms: make(map[string]map[string]models.Room),
+		chats: make(map[string][]models.Chat),
+	}
+}
+
+func (r *MemoryRoomRepository) CreateRoom(ctx context.Context, room *models.Room) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room.RoomID = newMemoryID()
+	room.LastActivityAt = room.ActivityTime()
+	if r.rooms[room.UserID] == nil {
+		r.rooms[room.UserID] = make(map[string]models.Room)
+	}
+	r.rooms[room.UserID][room.RoomID] = *room
+	return nil
+}
+
+func (r *MemoryRoomRepository) ListRooms(ctx context.Context, userID string) ([]*models.Room, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var rooms []*models.Room
+	for _, room := range r.rooms[userID] {
+		room := room
+		rooms = append(rooms, &room)
+	}
+
+	// Firestore lists documents ordered by id
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].RoomID < rooms[j].RoomID
+	})
+	return rooms, nil
+}
+
+func (r *MemoryRoomRepository) ListRoomsPage(ctx context.Context, userID string, after *RoomCursor, limit int) ([]*models.Room, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Firestore leaves out the documents missing an ordered field
+	before := func(a, b *models.Room) bool {
+		if !a.LastActivityAt.Equal(b.LastActivityAt) {
+			return a.LastActivityAt.After(b.LastActivityAt)
+		}
+		return a.RoomID > b.RoomID
+	}
+	var rooms []*models.Room
+	for _, room := range r.rooms[userID] {
+		room := room
+		if room.LastActivityAt.IsZero() {
+			continue
+		}
+		if after != nil && !before(&models.Room{RoomID: after.ID, LastActivityAt: after.At}, &room) {
+			continue
+		}
+		rooms = append(rooms, &room)
+	}
+
+	sort.Slice(rooms, func(i, j int) bool {
+		return before(rooms[i], rooms[j])
+	})
+	if len(rooms) > limit {
+		rooms = rooms[:limit]
+	}
+	return rooms, nil
+}
+
+func (r *MemoryRoomRepository) ListAllRooms(ctx context.Context) ([]*models.Room, error) {
+	r.mu.RLock()
+	userIDs := make([]string, 0, len(r.rooms))
+	for userID := range r.rooms {
+		userIDs = append(userIDs, userID)
+	}
+	r.mu.RUnlock()
+
+	var rooms []*models.Room
+	for _, userID := range userIDs {
+		userRooms, _ := r.ListRooms(ctx, userID)
+		rooms = append(rooms, userRooms...)
+	}
+	return rooms, nil
+}
+
+func (r *MemoryRoomRepository) GetRoom(ctx context.Context, userID, roomID string) (*models.Room, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	room, ok := r.rooms[userID][roomID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &room, nil
+}
+
+func (r *MemoryRoomRepository) UpdateRoom(ctx context.Context, userID, roomID string, fn func(room *models.Room) error) (*models.Room, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[userID][roomID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := fn(&room); err != nil {
+		return nil, err
+	}
+	r.rooms[userID][roomID] = room
+	return &room, nil
+}
+
+func (r *MemoryRoomRepository) DeleteRoom(ctx context.Context, userID, roomID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rooms[userID][roomID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.rooms[userID], roomID)
+	delete(r.chats, chatKey(userID, roomID))
+	return nil
+}
+
+func (r *MemoryRoomRepository) ListChats(ctx context.Context, userID, roomID string) ([]models.Chat, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	chats := append([]models.Chat(nil), r.chats[chatKey(userID, roomID)]...)
+	sort.SliceStable(chats, func(i, j int) bool {
+		return chats[i].CreatedAt < chats[j].CreatedAt
+	})
+	return chats, nil
+}
+
+func (r *MemoryRoomRepository) CreateChat(ctx context.Context, userID string, chat *models.Chat) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[userID][chat.RoomID]
+	if !ok {
+		return ErrNotFound
+	}
+	room.LastMessageAt = chat.CreatedAt
+	room.LastActivityAt = chatActivityTime(chat)
+	room.MessageCount++
+	r.rooms[userID][chat.RoomID] = room
+
+	chat.ChatID = newMemoryID()
+	key := chatKey(userID, chat.RoomID)
+	r.chats[key] = append(r.chats[key], *chat)
+	return nil
+}
+
+func (r *MemoryRoomRepository) GetChat(ctx context.Context, userID, roomID, chatID string) (*models.Chat, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, chat := range r.chats[chatKey(userID, roomID)] {
+		if chat.ChatID == chatID {
+			return &chat, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryRoomRepository) UpdateChat(ctx context.Context, userID, roomID, chatID string, fn func(chat *models.Chat) error) (*models.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chats := r.chats[chatKey(userID, roomID)]
+	for i := range chats {
+		if chats[i].ChatID != chatID {
+			continue
+		}
+		chat := chats[i]
+		if err := fn(&chat); err != nil {
+			return nil, err
+		}
+		chats[i] = chat
+		return &chat, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryRoomRepository) DeleteAllRooms(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for roomID := range r.rooms[userID] {
+		delete(r.chats, chatKey(userID, roomID))
+	}
+	delete(r.rooms, userID)
+	return nil
+}
+
+func chatKey(userID, roomID string) string {
+	return userID + "/" + roomID
+}
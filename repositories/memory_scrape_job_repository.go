@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sync"
+	"time"
+)
+
+type MemoryScrapeJobRepository struct {
+	mu   sync.RWMutex
+	jobs map[string]models.ScrapeJob
+}
+
+// NewMemoryScrapeJobRepository initializes an empty in-memory scrape job repository
+func NewMemoryScrapeJobRepository() *MemoryScrapeJobRepository {
+	return &MemoryScrapeJobRepository{
+		jobs: make(map[string]models.ScrapeJob),
+	}
+}
+
+func (r *MemoryScrapeJobRepository) Create(ctx context.Context, job *models.ScrapeJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job.ID = newMemoryID()
+	now := time.Now()
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	r.jobs[job.ID] = copyScrapeJob(*job)
+	return nil
+}
+
+func (r *MemoryScrapeJobRepository) Get(ctx context.Context, id string) (*models.ScrapeJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	job = copyScrapeJob(job)
+	return &job, nil
+}
+
+func (r *MemoryScrapeJobRepository) ListByStatus(ctx context.Context, status string) ([]*models.ScrapeJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var jobs []*models.ScrapeJob
+	for _, job := range r.jobs {
+		if job.Status == status {
+			job = copyScrapeJob(job)
+			jobs = append(jobs, &job)
+		}
+	}
+	sortScrapeJobs(jobs)
+	return jobs, nil
+}
+
+func (r *MemoryScrapeJobRepository) Update(ctx context.Context, id string, fn func(job *models.ScrapeJob) error) (*models.ScrapeJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	job = copyScrapeJob(job)
+	if err := fn(&job); err != nil {
+		return nil, err
+	}
+
+	job.ID = id
+	job.UpdatedAt = time.Now()
+	r.jobs[id] = copyScrapeJob(job)
+	return &job, nil
+}
+
+// copyScrapeJob detaches the places slice so callers cannot mutate the stored job
+func copyScrapeJob(job models.ScrapeJob) models.ScrapeJob {
+	places := make([]models.ScrapeJobPlace, len(job.Places))
+	copy(places, job.Places)
+	job.Places = places
+	return job
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sync"
+	"time"
+)
+
+type MemorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[string]models.Session
+}
+
+// NewMemorySessionRepository initializes an empty in-memory session repository
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{
+		sessions: make(map[string]models.Session),
+	}
+}
+
+func (r *MemorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session.ID = newMemoryID()
+	now := time.Now()
+	session.CreatedAt = now
+	session.UpdatedAt = now
+	r.sessions[session.ID] = *session
+	return nil
+}
+
+func (r *MemorySessionRepository) Get(ctx context.Context, id string) (*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+func (r *MemorySessionRepository) ListByUser(ctx context.Context, userID string) ([]*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var sessions []*models.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			session := session
+			sessions = append(sessions, &session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *MemorySessionRepository) Update(ctx context.Context, id string, fn func(session *models.Session) error) (*models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := fn(&session); err != nil {
+		return nil, err
+	}
+
+	session.ID = id
+	session.UpdatedAt = time.Now()
+	r.sessions[id] = session
+	return &session, nil
+}
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sort"
+)
+
+// ReportQuery filters restaurant reports. Empty fields are ignored.
+type ReportQuery struct {
+	RestaurantID string
+	UserID       string
+	Status       string
+	Type         string
+}
+
+// ReportRepository stores documents of the "restaurant_reports" collection
+type ReportRepository interface {
+	// Create stores a new report and sets its ID
+	Create(ctx context.Context, report *models.RestaurantReport) error
+	// Get returns ErrNotFound when the report does not exist
+	Get(ctx context.Context, id string) (*models.RestaurantReport, error)
+	// Find returns the matching reports, oldest first
+	Find(ctx context.Context, query ReportQuery) ([]*models.RestaurantReport, error)
+	// Update reads the report, applies fn and writes the result back atomically
+	Update(ctx context.Context, id string, fn func(report *models.RestaurantReport) error) (*models.RestaurantReport, error)
+}
+
+// sortReports orders reports by creation time, oldest first
+func sortReports(reports []*models.RestaurantReport) {
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].CreatedAt.Before(reports[j].CreatedAt)
+	})
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"HalalMate/config/database"
+	"errors"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+)
+
+// ErrNotFound is returned by every repository when the requested document does not exist
+var ErrNotFound = errors.New("document not found")
+
+// Store groups every repository used by the services
+type Store struct {
+	Restaurants RestaurantRepository
+	Users       UserRepository
+	Rooms       RoomRepository
+	Bookmarks   BookmarkRepository
+	Ingridents  IngridentRepository
+	ScrapeJobs  ScrapeJobRepository
+	Reports     ReportRepository
+	ChatRatings ChatRatingRepository
+	Sessions    SessionRepository
+	EmailTokens EmailTokenRepository
+}
+
+// NewFirestoreStore builds a Store backed by Firestore
+func NewFirestoreStore(client *firestore.Client) *Store {
+	return &Store{
+		Restaurants: NewFirestoreRestaurantRepository(client),
+		Users:       NewFirestoreUserRepository(client),
+		Rooms:       NewFirestoreRoomRepository(client),
+		Bookmarks:   NewFirestoreBookmarkRepository(client),
+		Ingridents:  NewFirestoreIngridentRepository(client),
+		ScrapeJobs:  NewFirestoreScrapeJobRepository(client),
+		Reports:     NewFirestoreReportRepository(client),
+		ChatRatings: NewFirestoreChatRatingRepository(client),
+		Sessions:    NewFirestoreSessionRepository(client),
+		EmailTokens: NewFirestoreEmailTokenRepository(client),
+	}
+}
+
+// NewMemoryStore builds a Store that keeps everything in memory (offline runs and tests)
+func NewMemoryStore() *Store {
+	return &Store{
+		Restaurants: NewMemoryRestaurantRepository(),
+		Users:       NewMemoryUserRepository(),
+		Rooms:       NewMemoryRoomRepository(),
+		Bookmarks:   NewMemoryBookmarkRepository(),
+		Ingridents:  NewMemoryIngridentRepository(),
+		ScrapeJobs:  NewMemoryScrapeJobRepository(),
+		Reports:     NewMemoryReportRepository(),
+		ChatRatings: NewMemoryChatRatingRepository(),
+		Sessions:    NewMemorySessionRepository(),
+		EmailTokens: NewMemoryEmailTokenRepository(),
+	}
+}
+
+var (
+	defaultStore   *Store
+	defaultStoreMu sync.Mutex
+)
+
+// SetStore replaces the Store returned by GetStore
+func SetStore(store *Store) {
+	defaultStoreMu.Lock()
+	defer defaultStoreMu.Unlock()
+	defaultStore = store
+}
+
+// GetStore returns the Store used by the default service constructors.
+// When none was set it falls back to the global Firestore client.
+func GetStore() *Store {
+	defaultStoreMu.Lock()
+	defer defaultStoreMu.Unlock()
+	if defaultStore == nil {
+		defaultStore = NewFirestoreStore(database.GetFirestoreClient())
+	}
+	return defaultStore
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+)
+
+// RestaurantQuery filters restaurants. Empty fields are ignored.
+type RestaurantQuery struct {
+	GeohashPrefix string
+	Status        string
+	Title         string
+	Limit         int
+}
+
+// RestaurantRepository stores documents of the "restaurants" collection
+type RestaurantRepository interface {
+	// GetByID returns ErrNotFound when the restaurant does not exist
+	GetByID(ctx context.Context, id string) (*models.Restaurant, error)
+	GetByIDs(ctx context.Context, ids []string) ([]models.Restaurant, error)
+	Find(ctx context.Context, query RestaurantQuery) ([]models.Restaurant, error)
+	// Create stores a new restaurant and writes the generated id into restaurant.ID
+	Create(ctx context.Context, restaurant *models.Restaurant) error
+	// CreateMany stores all restaurants in a single batch
+	CreateMany(ctx context.Context, restaurants []*models.Restaurant) error
+	// Update applies fn to the stored restaurant and saves the result, returns ErrNotFound when it does not exist
+	Update(ctx context.Context, id string, fn func(restaurant *models.Restaurant) error) (*models.Restaurant, error)
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"time"
+)
+
+// RoomCursor marks the last room of a page by its LastActivityAt and id
+type RoomCursor struct {
+	At time.Time
+	ID string
+}
+
+// RoomRepository stores the "rooms" subcollection of a user and the "chats" inside each room
+type RoomRepository interface {
+	// CreateRoom stores a new room, writes the generated id into room.RoomID and sets its LastActivityAt
+	CreateRoom(ctx context.Context, room *models.Room) error
+	ListRooms(ctx context.Context, userID string) ([]*models.Room, error)
+	// ListRoomsPage returns at most limit rooms of the user after the cursor, ordered by
+	// LastActivityAt descending then by id descending
+	ListRoomsPage(ctx context.Context, userID string, after *RoomCursor, limit int) ([]*models.Room, error)
+	// ListAllRooms returns the rooms of every user
+	ListAllRooms(ctx context.Context) ([]*models.Room, error)
+	// GetRoom returns ErrNotFound when the room does not exist
+	GetRoom(ctx context.Context, userID, roomID string) (*models.Room, error)
+	// UpdateRoom applies fn to the stored room atomically and returns the updated room.
+	// It returns ErrNotFound when the room does not exist, errors from fn are returned as is.
+	UpdateRoom(ctx context.Context, userID, roomID string, fn func(room *models.Room) error) (*models.Room, error)
+	// DeleteRoom deletes the room along with its chats, it returns ErrNotFound when the room does not exist
+	DeleteRoom(ctx context.Context, userID, roomID string) error
+	// ListChats returns the chats of a room ordered by CreatedAt ascending
+	ListChats(ctx context.Context, userID, roomID string) ([]models.Chat, error)
+	// CreateChat stores a new chat, writes the generated id into chat.ChatID and bumps the
+	// LastMessageAt, LastActivityAt and MessageCount of its room. It returns ErrNotFound when the room does not exist.
+	CreateChat(ctx context.Context, userID string, chat *models.Chat) error
+	// GetChat returns ErrNotFound when the chat does not exist
+	GetChat(ctx context.Context, userID, roomID, chatID string) (*models.Chat, error)
+	// UpdateChat applies fn to the stored chat atomically and returns the updated chat.
+	// It returns ErrNotFound when the chat does not exist, errors from fn are returned as is.
+	UpdateChat(ctx context.Context, userID, roomID, chatID string, fn func(chat *models.Chat) error) (*models.Chat, error)
+	// DeleteAllRooms deletes every room of the user along with their chats
+	DeleteAllRooms(ctx context.Context, userID string) error
+}
+
+// chatActivityTime is the time a new chat bumps its room to, now when its CreatedAt does not parse
+func chatActivityTime(chat *models.Chat) time.Time {
+	at, err := time.Parse(time.RFC3339Nano, chat.CreatedAt)
+	if err != nil {
+		return time.Now()
+	}
+	return at
+}
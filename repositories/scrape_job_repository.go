@@ -0,0 +1,26 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+	"sort"
+)
+
+// ScrapeJobRepository stores documents of the "scrape_jobs" collection
+type ScrapeJobRepository interface {
+	// Create stores a new job and sets its ID
+	Create(ctx context.Context, job *models.ScrapeJob) error
+	// Get returns ErrNotFound when the job does not exist
+	Get(ctx context.Context, id string) (*models.ScrapeJob, error)
+	// ListByStatus returns the jobs in the given state, oldest first
+	ListByStatus(ctx context.Context, status string) ([]*models.ScrapeJob, error)
+	// Update reads the job, applies fn and writes the result back atomically
+	Update(ctx context.Context, id string, fn func(job *models.ScrapeJob) error) (*models.ScrapeJob, error)
+}
+
+// sortScrapeJobs orders jobs by creation time, oldest first
+func sortScrapeJobs(jobs []*models.ScrapeJob) {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+}
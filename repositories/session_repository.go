@@ -0,0 +1,18 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+)
+
+// SessionRepository stores documents of the "sessions" collection
+type SessionRepository interface {
+	// Create stores a new session and sets its ID
+	Create(ctx context.Context, session *models.Session) error
+	// Get returns ErrNotFound when the session does not exist
+	Get(ctx context.Context, id string) (*models.Session, error)
+	// ListByUser returns every session of the user, revoked ones included
+	ListByUser(ctx context.Context, userID string) ([]*models.Session, error)
+	// Update reads the session, applies fn and writes the result back atomically
+	Update(ctx context.Context, id string, fn func(session *models.Session) error) (*models.Session, error)
+}
@@ -0,0 +1,19 @@
+package repositories
+
+import (
+	"HalalMate/models"
+	"context"
+)
+
+// UserRepository stores documents of the "users" collection
+type UserRepository interface {
+	// GetByID returns ErrNotFound when the user does not exist
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	// GetByEmail returns ErrNotFound when no user has this email
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	// Update reads the user, applies fn and writes the result back atomically
+	Update(ctx context.Context, id string, fn func(user *models.User) error) error
+	// Delete removes the user document, its subcollections are left to their own repositories
+	Delete(ctx context.Context, id string) error
+}
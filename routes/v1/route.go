@@ -3,14 +3,15 @@ package route
 import (
 	"HalalMate/controllers"
 	"HalalMate/handlers"
+	"HalalMate/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// RegisterRoutes initializes all routes
-func RegisterRoutes(router *gin.Engine) {
+// RegisterRoutes initializes all routes, the scraper ones on the given scrape job service
+func RegisterRoutes(router *gin.Engine, scrapeJobService *services.ScrapeJobService) {
 	authHandler := controllers.NewAuthController()
-	scrapHandler := controllers.NewScrapController()
+	scrapHandler := controllers.NewScrapController(scrapeJobService)
 	restaurantHandler := controllers.NewRestaurantController()
 	chatHandler := controllers.NewChatController()
 	roomHandler := controllers.NewRoomController()
@@ -18,6 +19,7 @@ func RegisterRoutes(router *gin.Engine) {
 	bookmarkHandler := controllers.NewBookmarkController()
 	snackHandler := controllers.NewSnackController()
 	ingridientHandler := controllers.NewIngridientController()
+	reportHandler := controllers.NewReportController()
 
 	// Register the routes
 	v1Routes := router.Group("/v1")
@@ -31,5 +33,6 @@ func RegisterRoutes(router *gin.Engine) {
 		handlers.RegisterBookmarkRoute(v1Routes, bookmarkHandler)
 		handlers.RegisterSnackRoutes(v1Routes, snackHandler)
 		handlers.RegisterIngridentsRoutes(v1Routes, ingridientHandler)
+		handlers.RegisterReportRoutes(v1Routes, reportHandler)
 	}
 }
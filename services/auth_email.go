@@ -0,0 +1,212 @@
+package services
+
+import (
+	"HalalMate/config/environment"
+	"HalalMate/mailer"
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"HalalMate/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	verifyEmailTokenTTL   = 24 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+	minPasswordLength     = 8
+)
+
+// newMailer builds the mailer from the MAILER_* and SMTP_* environment, logging messages when it is misconfigured
+func newMailer() mailer.Mailer {
+	m, err := mailer.New(mailer.Config{
+		Driver:       environment.GetMailerDriver(),
+		From:         environment.GetMailFrom(),
+		Path:         environment.GetMailerFile(),
+		SMTPHost:     environment.GetSMTPHost(),
+		SMTPPort:     environment.GetSMTPPort(),
+		SMTPUsername: environment.GetSMTPUsername(),
+		SMTPPassword: environment.GetSMTPPassword(),
+	})
+	if err != nil {
+		log.Printf("[Mailer] %v, emails will only be logged", err)
+		return mailer.NewFileMailer("")
+	}
+	return m
+}
+
+// emailLink returns the page of the app handling token, or only the token when APP_BASE_URL is not set
+func emailLink(page, token string) string {
+	baseURL := environment.GetAppBaseURL()
+	if baseURL == "" {
+		return "Token: " + token
+	}
+	return fmt.Sprintf("%s/%s?token=%s", strings.TrimRight(baseURL, "/"), page, url.QueryEscape(token))
+}
+
+// sendEmailToken stores a new single-use token for the user and mails it
+func (s *AuthService) sendEmailToken(ctx context.Context, user *models.User, purpose string, ttl time.Duration, subject, body string) error {
+	secret, hash, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	token := &models.EmailToken{
+		ID:        hash,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.EmailTokens.Create(ctx, token); err != nil {
+		return err
+	}
+
+	page := "verify-email"
+	if purpose == models.EmailTokenResetPassword {
+		page = "reset-password"
+	}
+	return s.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf("Hi %s,\n\n%s\n\n%s\n\nThis link expires in %s.", user.Username, body, emailLink(page, secret), ttl),
+	})
+}
+
+// sendVerificationEmail mails a verification token
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	return s.sendEmailToken(ctx, user, models.EmailTokenVerifyEmail, verifyEmailTokenTTL,
+		"Verify your HalalMate email", "Please confirm this is your email address by opening the link below.")
+}
+
+// redeemEmailToken marks the token as used and returns it, a token is only accepted once and before it expires
+func (s *AuthService) redeemEmailToken(ctx context.Context, secret, purpose string) (*models.EmailToken, error) {
+	invalid := utils.NewCustomError(http.StatusBadRequest, "Invalid or expired token")
+	if secret == "" {
+		return nil, invalid
+	}
+
+	token, err := s.EmailTokens.Update(ctx, hashSecretToken(secret), func(token *models.EmailToken) error {
+		if token.Purpose != purpose || !token.IsUsable(time.Now()) {
+			return repositories.ErrNotFound
+		}
+		now := time.Now()
+		token.UsedAt = &now
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, invalid
+		}
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to check token")
+	}
+	return token, nil
+}
+
+// ResendVerification mails a new verification token to the user
+func (s *AuthService) ResendVerification(ctx context.Context, userID string) error {
+	user, err := s.Users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return utils.NewCustomError(http.StatusNotFound, "User not found")
+		}
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to get user")
+	}
+	if user.EmailVerified {
+		return utils.NewCustomError(http.StatusConflict, "Email is already verified")
+	}
+
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("[ERROR] Failed to send verification email to %s: %v", user.Email, err)
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to send verification email")
+	}
+	return nil
+}
+
+// VerifyEmail redeems a verification token
+func (s *AuthService) VerifyEmail(ctx context.Context, secret string) error {
+	token, err := s.redeemEmailToken(ctx, secret, models.EmailTokenVerifyEmail)
+	if err != nil {
+		return err
+	}
+
+	err = s.Users.Update(ctx, token.UserID, func(user *models.User) error {
+		// The address may have changed since the token was sent
+		if user.Email != token.Email {
+			return repositories.ErrNotFound
+		}
+		user.EmailVerified = true
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return utils.NewCustomError(http.StatusBadRequest, "Invalid or expired token")
+		}
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to verify email")
+	}
+	return nil
+}
+
+// ForgotPassword mails a password reset token. It succeeds for unknown emails too,
+// so the endpoint does not reveal which emails have an account.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.Users.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNotFound) {
+			log.Printf("[ERROR] Failed to look up %s for a password reset: %v", email, err)
+		}
+		return nil
+	}
+	if user.Password == "" {
+		log.Printf("[WARNING] Password reset requested for Google user %s without a password", email)
+		return nil
+	}
+
+	err = s.sendEmailToken(ctx, user, models.EmailTokenResetPassword, resetPasswordTokenTTL,
+		"Reset your HalalMate password", "Someone asked to reset your password. If it was you, open the link below to choose a new one, otherwise ignore this email.")
+	if err != nil {
+		log.Printf("[ERROR] Failed to send password reset email to %s: %v", email, err)
+	}
+	return nil
+}
+
+// ResetPassword redeems a reset token, sets the new password and signs every device out
+func (s *AuthService) ResetPassword(ctx context.Context, secret, password string) error {
+	if len(password) < minPasswordLength {
+		return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to reset password")
+	}
+
+	token, err := s.redeemEmailToken(ctx, secret, models.EmailTokenResetPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.Users.Update(ctx, token.UserID, func(user *models.User) error {
+		user.Password = string(hashedPassword)
+		// Receiving the email proves the address
+		user.EmailVerified = true
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return utils.NewCustomError(http.StatusBadRequest, "Invalid or expired token")
+		}
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to reset password")
+	}
+
+	if err := s.Sessions.RevokeAll(ctx, token.UserID); err != nil {
+		log.Printf("[ERROR] Failed to revoke the sessions of %s after a password reset: %v", token.UserID, err)
+	}
+	return nil
+}
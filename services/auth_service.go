@@ -1,32 +1,48 @@
 package services
 
 import (
-	"HalalMate/config/database"
 	"HalalMate/config/environment"
+	"HalalMate/mailer"
+	"HalalMate/models"
+	"HalalMate/repositories"
 	"HalalMate/utils"
 	"context"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"log"
 	"math/big"
 	"net/http"
 	"strings"
 
-	"cloud.google.com/go/firestore"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/api/idtoken"
 )
 
-// AuthService provides authentication functions using Firestore
+// maxFCMTokenLength bounds the size of a push token, real ones are a few hundred characters
+const maxFCMTokenLength = 4096
+
+// AuthService provides authentication functions on top of the user repository
 type AuthService struct {
-	FirestoreClient *firestore.Client
+	Users       repositories.UserRepository
+	EmailTokens repositories.EmailTokenRepository
+	Sessions    *SessionService
+	Mailer      mailer.Mailer
 }
 
-// NewAuthService initializes AuthService with Firestore client
+// NewAuthService initializes AuthService with the default store
 func NewAuthService() *AuthService {
+	return NewAuthServiceWithStore(repositories.GetStore())
+}
+
+// NewAuthServiceWithStore initializes AuthService with the given repositories
+func NewAuthServiceWithStore(store *repositories.Store) *AuthService {
 	return &AuthService{
-		FirestoreClient: database.GetFirestoreClient(),
+		Users:       store.Users,
+		EmailTokens: store.EmailTokens,
+		Sessions:    NewSessionServiceWithStore(store),
+		Mailer:      newMailer(),
 	}
 }
 
@@ -38,121 +54,125 @@ func generateUUID() (string, error) {
 	return newUUID.String(), nil
 }
 
-// Register creates a new user in Firestore
-func (s *AuthService) Register(email, username, password string) (*firestore.DocumentRef, string, error) {
+// Register creates a new user in Firestore and signs it in on deviceID
+func (s *AuthService) Register(email, username, password, deviceID string) (*models.User, *models.AuthTokens, error) {
 	ctx := context.Background()
 
+	if len(password) < minPasswordLength {
+		return nil, nil, utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+	}
+
 	// Check if email already exists
-	query := s.FirestoreClient.Collection("users").Where("email", "==", email).Limit(1)
-	docs, err := query.Documents(ctx).GetAll()
-	if err != nil {
-		return nil, "", utils.NewCustomError(http.StatusInternalServerError, "internal server error")
+	_, err := s.Users.GetByEmail(ctx, email)
+	if err == nil {
+		return nil, nil, utils.NewCustomError(http.StatusConflict, "email already exists")
 	}
-	if len(docs) > 0 {
-		return nil, "", utils.NewCustomError(http.StatusConflict, "email already exists")
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil, utils.NewCustomError(http.StatusInternalServerError, "internal server error")
 	}
 
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	userID, err := generateUUID()
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	// Create user in Firestore
-	userRef := s.FirestoreClient.Collection("users").Doc(userID)
-	_, err = userRef.Set(ctx, map[string]interface{}{
-		"id":        userRef.ID,
-		"email":     email,
-		"username":  username,
-		"password":  string(hashedPassword),
-		"CreatedAt": firestore.ServerTimestamp,
-		"UpdatedAt": firestore.ServerTimestamp,
-	})
-	if err != nil {
-		return nil, "", err
+	user := &models.User{
+		ID:       userID,
+		Email:    email,
+		Username: username,
+		Password: string(hashedPassword),
+		Roles:    []string{models.RoleUser},
+	}
+	if err := s.Users.Create(ctx, user); err != nil {
+		return nil, nil, err
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(userRef.ID)
+	// The account is usable right away, a failed email can be sent again from /auth/resend-verification
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("[ERROR] Failed to send verification email to %s: %v", user.Email, err)
+	}
+
+	// Generate JWT and refresh tokens
+	tokens, err := s.Sessions.StartSession(ctx, user, deviceID)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return userRef, token, nil
+	return user, tokens, nil
 }
 
 
-func (s *AuthService) Login(email string, password string) (string, error) {
+func (s *AuthService) Login(email string, password string, deviceID string) (*models.AuthTokens, error) {
 	log.Printf("[DEBUG] Searching for user: %s", email)
 
 	// Query Firestore for user by email
-	query := s.FirestoreClient.Collection("users").Where("email", "==", email).Limit(1)
-	docs, err := query.Documents(context.Background()).GetAll()
+	user, err := s.Users.GetByEmail(context.Background(), email)
+	if errors.Is(err, repositories.ErrNotFound) {
+		log.Println("[WARNING] User not found:", email)
+		return nil, errors.New("invalid email or password")
+	}
 	if err != nil {
 		log.Println("[ERROR] Firestore query failed:", err)
-		return "", errors.New("internal server error")
-	}
-	if len(docs) == 0 {
-		log.Println("[WARNING] User not found:", email)
-		return "", errors.New("invalid email or password")
+		return nil, errors.New("internal server error")
 	}
 
-	// Get user document
-	docSnap := docs[0]
-	userData := docSnap.Data()
+	log.Printf("[DEBUG] User found: %s", user.ID)
 
-	log.Printf("[DEBUG] User found: %+v", userData)
-
-	// ✅ Check if user is a Google User
-	if isGoogleUser, exists := userData["isGoogleUser"].(bool); exists && isGoogleUser {
+	// ✅ Check if user is a Google User without a linked password
+	if user.IsGoogleUser && user.Password == "" {
 		log.Println("[WARNING] User attempted to login with password but is a Google user:", email)
-		return "", errors.New("you have previously signed in with Google, please log in using Google")
+		return nil, errors.New("you have previously signed in with Google, please log in using Google")
 	}
 
 	// ✅ Extract stored password
-	storedPassword, ok := userData["password"].(string)
-	if !ok || storedPassword == "" {
+	if user.Password == "" {
 		log.Println("[ERROR] Password field missing in Firestore document")
-		return "", errors.New("password not found")
+		return nil, errors.New("password not found")
 	}
 
 	// ✅ Compare hashed password
-	err = bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(password))
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
 		log.Println("[WARNING] Password mismatch for user:", email)
-		return "", errors.New("invalid password")
+		return nil, errors.New("invalid password")
 	}
 
-	// ✅ Generate JWT token
-	token, err := utils.GenerateToken(userData["id"].(string))
+	// ✅ Generate JWT and refresh tokens
+	tokens, err := s.Sessions.StartSession(context.Background(), user, deviceID)
 	if err != nil {
 		log.Println("[ERROR] JWT token generation failed:", err)
-		return "", err
+		return nil, err
 	}
 
 	log.Println("[INFO] Login successful, token generated")
-	return token, nil
+	return tokens, nil
 }
 
-func (s *AuthService) VerifyGoogleIDToken(idToken string) (string, error) {
+func (s *AuthService) VerifyGoogleIDToken(idToken, deviceID string) (*models.AuthTokens, error) {
 	ctx := context.Background()
 	audience := environment.GetWebClientId()
 
 	// ✅ Validate Google ID Token
 	payload, err := idtoken.Validate(ctx, idToken, audience)
 	if err != nil {
-		return "", errors.New("invalid ID token")
+		return nil, errors.New("invalid ID token")
 	}
 
 	// ✅ Extract email
 	email, ok := payload.Claims["email"].(string)
 	if !ok {
-		return "", errors.New("email not found in token")
+		return nil, errors.New("email not found in token")
+	}
+	// Only an address Google checked may take over an account registered with it
+	if verified, _ := payload.Claims["email_verified"].(bool); !verified {
+		return nil, errors.New("email not verified by Google")
 	}
 
 	// ✅ Extract display name
@@ -162,62 +182,110 @@ func (s *AuthService) VerifyGoogleIDToken(idToken string) (string, error) {
 	}
 
 	// ✅ Check if user already exists in Firestore
-	query := s.FirestoreClient.Collection("users").Where("email", "==", email).Limit(1)
-	docs, err := query.Documents(ctx).GetAll()
-	if err != nil {
-		return "", errors.New("internal server error")
+	existing, err := s.Users.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return nil, errors.New("internal server error")
 	}
 
-	// ✅ If user exists, return JWT
-	if len(docs) > 0 {
-		userData := docs[0].Data()
-		tokenJWT, err := utils.GenerateToken(userData["id"].(string))
+	// ✅ If user exists, link Google to it and return JWT
+	if existing != nil {
+		linked, err := s.linkGoogleAccount(ctx, existing)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		return tokenJWT, nil
+		return s.Sessions.StartSession(ctx, linked, deviceID)
 	}
 
 	// ✅ New User: Generate UUID
 	userID, err := generateUUID()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// ✅ Store new Google user in Firestore
-	userRef := s.FirestoreClient.Collection("users").Doc(userID)
-	_, err = userRef.Set(ctx, map[string]interface{}{
-		"id":           userRef.ID,
-		"email":        email,
-		"username":     name,
-		"isGoogleUser": true, // Mark as Google user
-		"CreatedAt":    firestore.ServerTimestamp,
-		"UpdatedAt":    firestore.ServerTimestamp,
-	})
-	if err != nil {
-		return "", err
+	user := &models.User{
+		ID:            userID,
+		Email:         email,
+		Username:      name,
+		IsGoogleUser:  true, // Mark as Google user
+		EmailVerified: true,
+		Roles:         []string{models.RoleUser},
+	}
+	if err := s.Users.Create(ctx, user); err != nil {
+		return nil, err
 	}
 
-	// ✅ Generate JWT token
-	tokenJWT, err := utils.GenerateToken(userRef.ID)
+	// ✅ Generate JWT and refresh tokens
+	tokens, err := s.Sessions.StartSession(ctx, user, deviceID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	log.Printf("[INFO] New Google user registered: %s", email)
-	return tokenJWT, nil
+	return tokens, nil
 }
 
-// store fcm service
+// linkGoogleAccount marks an existing account as signed in with Google. A password set on an
+// unverified account may belong to whoever registered the address first, so it is dropped and
+// the sessions opened with it are revoked before the owner of the address gets in.
+func (s *AuthService) linkGoogleAccount(ctx context.Context, existing *models.User) (*models.User, error) {
+	var linked models.User
+	claimed := false
+	err := s.Users.Update(ctx, existing.ID, func(user *models.User) error {
+		claimed = !user.EmailVerified && user.Password != ""
+		if claimed {
+			user.Password = ""
+		}
+		user.IsGoogleUser = true
+		user.EmailVerified = true
+		linked = *user
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("internal server error")
+	}
+
+	if claimed {
+		if err := s.Sessions.RevokeAll(ctx, existing.ID); err != nil {
+			return nil, errors.New("internal server error")
+		}
+		log.Printf("[WARNING] Dropped the password of unverified account %s when linking Google", existing.Email)
+	}
+	return &linked, nil
+}
+
+// StoreFCMToken registers a push token for one more device of the user
 func (s *AuthService) StoreFCMToken(userID, fcmToken string) error {
+	fcmToken = strings.TrimSpace(fcmToken)
+	if fcmToken == "" || len(fcmToken) > maxFCMTokenLength {
+		return utils.NewCustomError(http.StatusBadRequest, "fcm_token is required")
+	}
+
 	// Update user document with FCM token
-	userRef := s.FirestoreClient.Collection("users").Doc(userID)
-	_, err := userRef.Set(context.Background(), map[string]interface{}{
-		"fcmToken": fcmToken,
-	}, firestore.MergeAll)
+	err := s.Users.Update(context.Background(), userID, func(user *models.User) error {
+		addDeviceToken(user, fcmToken)
+		return nil
+	})
 	if err != nil {
-		return err
+		if errors.Is(err, repositories.ErrNotFound) {
+			return utils.NewCustomError(http.StatusNotFound, "User not found")
+		}
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to store FCM token")
+	}
+	return nil
+}
 
+// RemoveFCMToken stops the pushes to a device, such as when the user logs out of it
+func (s *AuthService) RemoveFCMToken(userID, fcmToken string) error {
+	err := s.Users.Update(context.Background(), userID, func(user *models.User) error {
+		removeDeviceTokens(user, strings.TrimSpace(fcmToken))
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return utils.NewCustomError(http.StatusNotFound, "User not found")
+		}
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to remove FCM token")
 	}
 	return nil
 }
@@ -235,3 +303,13 @@ func GenerateRandomPassword(length int) (string, error) {
 	}
 	return password.String(), nil
 }
+
+// Refresh exchanges a refresh token for new tokens
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*models.AuthTokens, error) {
+	return s.Sessions.Refresh(ctx, refreshToken)
+}
+
+// Logout revokes the session of the token, or every session of the user
+func (s *AuthService) Logout(ctx context.Context, userID, sessionID string, allDevices bool) error {
+	return s.Sessions.Logout(ctx, userID, sessionID, allDevices)
+}
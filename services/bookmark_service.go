@@ -1,29 +1,30 @@
 package services
 
 import (
-	"HalalMate/config/database"
 	"HalalMate/models"
+	"HalalMate/repositories"
 	"HalalMate/utils"
 	"context"
+	"errors"
 	"log"
 	"net/http"
-
-	"cloud.google.com/go/firestore"
-	"google.golang.org/api/iterator"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 type BookmarkService struct {
-	FirestoreClient   *firestore.Client
+	Bookmarks         repositories.BookmarkRepository
 	RestaurantService *RestaurantService
 }
 
 // NewBookmarkService initializes a new BookmarkService
 func NewBookmarkService() *BookmarkService {
+	return NewBookmarkServiceWithStore(repositories.GetStore())
+}
+
+// NewBookmarkServiceWithStore initializes BookmarkService with the given repositories
+func NewBookmarkServiceWithStore(store *repositories.Store) *BookmarkService {
 	return &BookmarkService{
-		FirestoreClient:   database.GetFirestoreClient(),
-		RestaurantService: NewRestaurantService(),
+		Bookmarks:         store.Bookmarks,
+		RestaurantService: NewRestaurantServiceWithStore(store),
 	}
 }
 
@@ -31,29 +32,15 @@ func NewBookmarkService() *BookmarkService {
 func (b *BookmarkService) GetAllBookmarks(ctx context.Context, userID string, latitude, longitude float64) ([]models.Bookmark, error) {
 	log.Printf("Fetching bookmarks for user: %s", userID)
 
-	iter := b.FirestoreClient.Collection("users").Doc(userID).Collection("bookmarks").Documents(ctx)
-	var bookmarks []models.Bookmark
+	bookmarks, err := b.Bookmarks.List(ctx, userID)
+	if err != nil {
+		log.Printf("Error fetching bookmarks: %v", err)
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to fetch bookmarks")
+	}
 	var restaurantIDs []string
 
-	// First loop: Fetch bookmarks and collect restaurant IDs
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			log.Printf("Error fetching bookmarks: %v", err)
-			return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to fetch bookmarks")
-		}
-
-		var bookmark models.Bookmark
-		if err := doc.DataTo(&bookmark); err != nil {
-			log.Printf("Error parsing bookmark data: %v", err)
-			return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to parse bookmark data")
-		}
-		bookmark.ID = doc.Ref.ID
-		bookmarks = append(bookmarks, bookmark)
-
+	// First loop: collect restaurant IDs
+	for _, bookmark := range bookmarks {
 		log.Printf("Fetched bookmark: %+v", bookmark)
 
 		// Collect restaurant ID if not empty
@@ -65,7 +52,7 @@ func (b *BookmarkService) GetAllBookmarks(ctx context.Context, userID string, la
 	log.Printf("Collected Restaurant IDs: %v", restaurantIDs)
 
 	// Fetch all restaurants in one query
-	restaurantsMap := make(map[string]map[string]interface{})
+	restaurantsMap := make(map[string]*models.Restaurant)
 	if len(restaurantIDs) > 0 {
 		log.Println("Fetching restaurant details for collected IDs...")
 
@@ -76,11 +63,9 @@ func (b *BookmarkService) GetAllBookmarks(ctx context.Context, userID string, la
 		}
 
 		// Convert list to map for quick lookup
-		for _, restaurant := range restaurants {
-			if id, ok := restaurant["id"].(string); ok {
-				restaurantsMap[id] = restaurant
-				log.Printf("Mapped restaurant: %s -> %+v", id, restaurant)
-			}
+		for i := range restaurants {
+			restaurantsMap[restaurants[i].ID] = &restaurants[i]
+			log.Printf("Mapped restaurant: %s -> %+v", restaurants[i].ID, restaurants[i])
 		}
 	} else {
 		log.Println("No restaurant IDs found, skipping restaurant lookup.")
@@ -104,59 +89,35 @@ func (b *BookmarkService) GetAllBookmarks(ctx context.Context, userID string, la
 
 // PostBookmark adds a new bookmark for a user
 func (b *BookmarkService) PostBookmark(ctx context.Context, userID string, bookmark models.Bookmark) (*models.Bookmark, error) {
-	restaurantService := NewRestaurantService()
-	_, err := restaurantService.GetRestaurantByID(ctx, bookmark.RestaurantID)
+	_, err := b.RestaurantService.GetRestaurantByID(ctx, bookmark.RestaurantID)
 	if err != nil {
 		return nil, utils.NewCustomError(http.StatusNotFound, "Restaurant not found")
 	}
 
-	iter := b.FirestoreClient.Collection("users").Doc(userID).Collection("bookmarks").
-		Where("restaurantId", "==", bookmark.RestaurantID).Limit(1).Documents(ctx)
-
-	_, err = iter.Next()
+	_, err = b.Bookmarks.FindByRestaurantID(ctx, userID, bookmark.RestaurantID)
 	if err == nil {
 		return nil, utils.NewCustomError(http.StatusConflict, "Bookmark already exists")
-	} else if err != iterator.Done {
+	} else if !errors.Is(err, repositories.ErrNotFound) {
 		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to check existing bookmarks")
 	}
 
-	docRef, _, err := b.FirestoreClient.Collection("users").Doc(userID).Collection("bookmarks").Add(ctx, bookmark)
-	if err != nil {
+	if err := b.Bookmarks.Create(ctx, userID, &bookmark); err != nil {
 		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to add bookmark")
 	}
 
-	bookmark.ID = docRef.ID
-	_, err = docRef.Set(ctx, map[string]interface{}{
-		"id":           bookmark.ID,
-		"userId":       bookmark.UserID,
-		"restaurantId": bookmark.RestaurantID,
-		"createdAt":    bookmark.CreatedAt,
-	}, firestore.MergeAll)
-
-	if err != nil {
-		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to update bookmark with ID")
-	}
-
 	return &bookmark, nil
 }
 
 // GetBookmarkByID retrieves a single bookmark by its ID
 func (b *BookmarkService) GetBookmarkByID(ctx context.Context, userID string, bookmarkID string) (*models.Bookmark, error) {
-	doc, err := b.FirestoreClient.Collection("users").Doc(userID).Collection("bookmarks").Doc(bookmarkID).Get(ctx)
+	bookmark, err := b.Bookmarks.Get(ctx, userID, bookmarkID)
 	if err != nil {
-		if status.Code(err) == codes.NotFound {
+		if errors.Is(err, repositories.ErrNotFound) {
 			return nil, utils.NewCustomError(http.StatusNotFound, "Bookmark not found")
 		}
 		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to fetch bookmark data")
 	}
 
-	var bookmark models.Bookmark
-	if err := doc.DataTo(&bookmark); err != nil {
-		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to parse bookmark data")
-	}
-
-	bookmark.ID = doc.Ref.ID
-
 	// Fetch associated restaurant details
 	restaurant, err := b.RestaurantService.GetRestaurantByID(ctx, bookmark.RestaurantID)
 	if err != nil {
@@ -170,30 +131,23 @@ func (b *BookmarkService) GetBookmarkByID(ctx context.Context, userID string, bo
 		bookmark.Restaurant = restaurant
 	}
 
-	return &bookmark, nil
+	return bookmark, nil
 }
 
 // DeleteBookmark removes a bookmark by restaurantId
 func (b *BookmarkService) DeleteBookmark(ctx context.Context, userID string, restaurantID string) error {
-	collectionRef := b.FirestoreClient.Collection("users").Doc(userID).Collection("bookmarks")
-
 	// Search for the bookmark with the given restaurantId
-	query := collectionRef.Where("restaurantId", "==", restaurantID).Limit(1)
-	docs, err := query.Documents(ctx).GetAll()
+	bookmark, err := b.Bookmarks.FindByRestaurantID(ctx, userID, restaurantID)
 	if err != nil {
+		// If no bookmark found, return error
+		if errors.Is(err, repositories.ErrNotFound) {
+			return utils.NewCustomError(http.StatusNotFound, "Bookmark not found")
+		}
 		return utils.NewCustomError(http.StatusInternalServerError, "Failed to query bookmarks")
 	}
 
-	// If no bookmark found, return error
-	if len(docs) == 0 {
-		return utils.NewCustomError(http.StatusNotFound, "Bookmark not found")
-	}
-
-	// Get the first document (since we used Limit(1))
-	docRef := docs[0].Ref
-
 	// Delete the bookmark
-	_, err = docRef.Delete(ctx)
+	err = b.Bookmarks.Delete(ctx, userID, bookmark.ID)
 	if err != nil {
 		return utils.NewCustomError(http.StatusInternalServerError, "Failed to delete bookmark")
 	}
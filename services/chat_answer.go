@@ -0,0 +1,483 @@
+package services
+
+import (
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"HalalMate/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// continueAnswerPrompt asks the model to finish an answer whose stream was cut
+const continueAnswerPrompt = "Your previous answer was cut off. Continue it exactly where it stopped, without repeating what you already wrote."
+
+const (
+	// answerSaveInterval is how often a streaming answer saves its progress and renews its lease
+	answerSaveInterval = 2 * time.Second
+	// answerStreamLease is how long an answer stays with its stream without a save, such as after a crash
+	answerStreamLease = 10 * time.Second
+	// answerResumeWait bounds how long a reconnecting client waits for the previous stream to stop
+	answerResumeWait = 5 * time.Second
+	answerResumePoll = 200 * time.Millisecond
+)
+
+var (
+	// errAnswerStreaming is returned while another stream holds the lease of an answer
+	errAnswerStreaming = errors.New("answer is streaming")
+	// errInvalidAnswerCursor is returned for a cursor past what the answer holds
+	errInvalidAnswerCursor = errors.New("cursor past the answer")
+	// errAnswerLeaseLost stops a stream whose answer was taken over by another one
+	errAnswerLeaseLost = errors.New("answer taken over by another stream")
+)
+
+func newAnswerLease() *time.Time {
+	until := time.Now().Add(answerStreamLease)
+	return &until
+}
+
+// leaseAnswer leases the answer to a new stream, identified by the token it saves with
+func leaseAnswer(answer *models.Chat) error {
+	token, err := generateUUID()
+	if err != nil {
+		return err
+	}
+	answer.StreamLeaseUntil = newAnswerLease()
+	answer.StreamToken = token
+	return nil
+}
+
+// isAnswerStreaming reports whether a stream still holds the lease of the answer
+func isAnswerStreaming(answer *models.Chat) bool {
+	return answer.StreamLeaseUntil != nil && answer.StreamLeaseUntil.After(time.Now())
+}
+
+// AnswerCursor is the position of a client in a streamed answer: the bytes of prose and the
+// number of cards it received. Its ID is sent as the SSE id of every event.
+type AnswerCursor struct {
+	ChatID string
+	Text   int
+	Cards  int
+}
+
+// ID encodes the cursor as "<chat id>:<text>:<cards>"
+func (c AnswerCursor) ID() string {
+	return fmt.Sprintf("%s:%d:%d", c.ChatID, c.Text, c.Cards)
+}
+
+// Advance moves the cursor past event
+func (c *AnswerCursor) Advance(event ChatEvent) {
+	c.Text += len(event.Text)
+	if event.Card != nil {
+		c.Cards++
+	}
+}
+
+func parseAnswerCursor(id string) (AnswerCursor, error) {
+	parts := strings.Split(strings.TrimSpace(id), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return AnswerCursor{}, errors.New("malformed event id")
+	}
+	text, err := strconv.Atoi(parts[1])
+	if err != nil || text < 0 {
+		return AnswerCursor{}, errors.New("malformed event id")
+	}
+	cards, err := strconv.Atoi(parts[2])
+	if err != nil || cards < 0 {
+		return AnswerCursor{}, errors.New("malformed event id")
+	}
+	return AnswerCursor{ChatID: parts[0], Text: text, Cards: cards}, nil
+}
+
+// StartAnswer stores the empty HocaAI answer that the stream fills, so its id can be sent with every event.
+// The answer is leased to the stream that follows.
+func (s *ChatService) StartAnswer(ctx context.Context, userId, roomId string) (*models.Chat, error) {
+	complete := false
+	answer := &models.Chat{
+		RoomID:    roomId,
+		UserID:    hocaUserID,
+		CreatedAt: time.Now().Format(time.RFC3339Nano),
+		Complete:  &complete,
+	}
+	if err := leaseAnswer(answer); err != nil {
+		return nil, err
+	}
+	if err := s.Rooms.CreateChat(ctx, userId, answer); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, utils.NewCustomError(http.StatusNotFound, "Room not found")
+		}
+		return nil, err
+	}
+	return answer, nil
+}
+
+// ResumeAnswer loads the answer a reconnecting client was receiving and returns the cursor
+// of its Last-Event-ID with the events it missed. The stream the client lost saves what it
+// sent when it stops, so ResumeAnswer waits for it before taking over the lease of the answer.
+func (s *ChatService) ResumeAnswer(ctx context.Context, userId, roomId, lastEventID string) (*models.Chat, AnswerCursor, []ChatEvent, error) {
+	invalid := utils.NewCustomError(http.StatusBadRequest, "Invalid Last-Event-ID")
+
+	cursor, err := parseAnswerCursor(lastEventID)
+	if err != nil {
+		return nil, AnswerCursor{}, nil, invalid
+	}
+
+	var answer *models.Chat
+	deadline := time.Now().Add(answerResumeWait)
+	for {
+		answer, err = s.claimAnswer(ctx, userId, roomId, cursor)
+		if !errors.Is(err, errAnswerStreaming) {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, AnswerCursor{}, nil, utils.NewCustomError(http.StatusConflict, "The answer is still streaming")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, AnswerCursor{}, nil, ctx.Err()
+		case <-time.After(answerResumePoll):
+		}
+	}
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		return nil, AnswerCursor{}, nil, utils.NewCustomError(http.StatusNotFound, "Chat not found")
+	case errors.Is(err, errInvalidAnswerCursor):
+		return nil, AnswerCursor{}, nil, invalid
+	case err != nil:
+		return nil, AnswerCursor{}, nil, err
+	}
+
+	// Prose and cards were interleaved, the missed ones are replayed prose first
+	var missed []ChatEvent
+	if cursor.Text < len(answer.Chat) {
+		missed = append(missed, ChatEvent{Text: answer.Chat[cursor.Text:]})
+	}
+	for i := cursor.Cards; i < len(answer.Recommendations); i++ {
+		missed = append(missed, ChatEvent{Card: &answer.Recommendations[i]})
+	}
+	return answer, cursor, missed, nil
+}
+
+// claimAnswer leases an unfinished answer to a continuation from cursor. It fails with errAnswerStreaming
+// while another stream holds the lease, and with errInvalidAnswerCursor when the cursor is past the answer.
+func (s *ChatService) claimAnswer(ctx context.Context, userId, roomId string, cursor AnswerCursor) (*models.Chat, error) {
+	return s.Rooms.UpdateChat(ctx, userId, roomId, cursor.ChatID, func(chat *models.Chat) error {
+		if chat.UserID != hocaUserID {
+			return errInvalidAnswerCursor
+		}
+		if isAnswerStreaming(chat) {
+			return errAnswerStreaming
+		}
+		if cursor.Text > len(chat.Chat) || cursor.Cards > len(chat.Recommendations) {
+			return errInvalidAnswerCursor
+		}
+		if !IsAnswerComplete(chat) {
+			return leaseAnswer(chat)
+		}
+		return nil
+	})
+}
+
+// SaveAnswer stores the prose and cards streamed so far and releases the lease of the answer,
+// complete tells whether the stream ended normally. It fails with errAnswerLeaseLost when the
+// StreamToken of answer no longer holds the lease.
+func (s *ChatService) SaveAnswer(ctx context.Context, userId string, answer *models.Chat, complete bool) error {
+	_, err := s.Rooms.UpdateChat(ctx, userId, answer.RoomID, answer.ChatID, func(chat *models.Chat) error {
+		if chat.StreamToken != answer.StreamToken {
+			return errAnswerLeaseLost
+		}
+		chat.Chat = answer.Chat
+		chat.Recommendations = answer.Recommendations
+		chat.Complete = &complete
+		chat.StreamLeaseUntil = nil
+		chat.StreamToken = ""
+		return nil
+	})
+	return err
+}
+
+// saveAnswerProgress stores the prose and cards streamed so far and renews the lease of the answer,
+// it fails with errAnswerLeaseLost like SaveAnswer
+func (s *ChatService) saveAnswerProgress(ctx context.Context, userId string, answer *models.Chat) error {
+	_, err := s.Rooms.UpdateChat(ctx, userId, answer.RoomID, answer.ChatID, func(chat *models.Chat) error {
+		if chat.StreamToken != answer.StreamToken {
+			return errAnswerLeaseLost
+		}
+		chat.Chat = answer.Chat
+		chat.Recommendations = answer.Recommendations
+		chat.StreamLeaseUntil = newAnswerLease()
+		return nil
+	})
+	return err
+}
+
+// AnswerStream sends a streamed answer to its clients, over SSE or WebSockets
+type AnswerStream interface {
+	// Event sends a piece of the answer, cursor is already past it
+	Event(cursor AnswerCursor, event ChatEvent)
+	// RoomTitled sends the title generated for a room created without one
+	RoomTitled(room *models.Room)
+	// Done ends the answer, complete is false when its stream was cut
+	Done(cursor AnswerCursor, answer *models.Chat, complete bool)
+	// Failed reports an answer that could not be saved
+	Failed(err error)
+}
+
+// AnswerRequest is an answer for StreamAnswer to stream
+type AnswerRequest struct {
+	UserID   string
+	Prompt   string
+	Location models.GeoLocation
+	Answer   *models.Chat
+	// Cursor is the position of the client in the answer, Missed the events it still has to receive
+	Cursor        AnswerCursor
+	Missed        []ChatEvent
+	GenerateTitle bool
+}
+
+// StreamAnswer replays the missed events and streams the rest of the answer to out. The progress
+// is saved as it streams, and the answer once the model is done, or with what was streamed so far
+// when ctx is cancelled. The caller must hold the lease of the answer, StreamAnswer releases it.
+// A stream whose answer was taken over stops without saving or ending its clients' stream.
+func (s *ChatService) StreamAnswer(ctx context.Context, req AnswerRequest, out AnswerStream) {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	answer, cursor := req.Answer, req.Cursor
+	send := func(event ChatEvent) {
+		cursor.Advance(event)
+		out.Event(cursor, event)
+	}
+
+	for _, event := range req.Missed {
+		send(event)
+	}
+	if IsAnswerComplete(answer) {
+		out.Done(cursor, answer, true)
+		return
+	}
+
+	// Rooms created without a title are named after their first prompt while the answer streams
+	var titledRoomChan chan *models.Room
+	if req.GenerateTitle {
+		titledRoomChan = make(chan *models.Room, 1)
+		go func() {
+			room, err := s.RoomService.GenerateTitle(ctx, req.UserID, answer.RoomID, req.Prompt)
+			if err != nil {
+				log.Println("Failed to generate room title:", err)
+			}
+			titledRoomChan <- room
+		}()
+	}
+
+	eventChan := make(chan ChatEvent)
+	doneChan := make(chan bool)
+	go s.StreamRecommendations(ctx, eventChan, doneChan, req.Location, req.Prompt, req.UserID, answer.RoomID, *answer)
+
+	saveTicker := time.NewTicker(answerSaveInterval)
+	defer saveTicker.Stop()
+
+	for {
+		select {
+		case <-saveTicker.C:
+			// A client reconnecting elsewhere resumes from the saved progress
+			err := s.saveAnswerProgress(ctx, req.UserID, answer)
+			if errors.Is(err, errAnswerLeaseLost) {
+				log.Printf("Answer %s was taken over by another stream, stopping", answer.ChatID)
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Println("Failed to save answer progress:", err)
+			}
+
+		case event, ok := <-eventChan:
+			if !ok {
+				eventChan = nil // Channel closed, stop reading
+				continue
+			}
+			answer.Chat += event.Text
+			if event.Card != nil {
+				answer.Recommendations = append(answer.Recommendations, *event.Card)
+			}
+			send(event)
+
+		case <-ctx.Done():
+			// Keep what was streamed so far, it can be resumed from the cursor of the client
+			err := s.SaveAnswer(context.WithoutCancel(ctx), req.UserID, answer, false)
+			if errors.Is(err, errAnswerLeaseLost) {
+				log.Printf("Answer %s was taken over by another stream, its cut is not saved", answer.ChatID)
+				return
+			}
+			if err != nil {
+				log.Println("Failed to save partial answer:", err)
+			}
+			out.Done(cursor, answer, false)
+			return
+
+		case complete, ok := <-doneChan:
+			if !ok {
+				// The stream gave up because ctx was cancelled, handled above
+				doneChan = nil
+				continue
+			}
+
+			err := s.SaveAnswer(ctx, req.UserID, answer, complete)
+			if errors.Is(err, errAnswerLeaseLost) {
+				log.Printf("Answer %s was taken over by another stream, its end is not saved", answer.ChatID)
+				return
+			}
+			if err != nil {
+				log.Println("Failed to save answer:", err)
+				out.Failed(err)
+				return
+			}
+			if titledRoomChan != nil {
+				if room := <-titledRoomChan; room != nil {
+					out.RoomTitled(room)
+				}
+			}
+			out.Done(cursor, answer, complete)
+			return
+		}
+	}
+}
+
+// IsAnswerComplete reports whether the stream of a HocaAI answer ended normally, answers saved before
+// the flag existed are complete
+func IsAnswerComplete(answer *models.Chat) bool {
+	return answer.Complete == nil || *answer.Complete
+}
+
+// RegenerateAnswer clears the last HocaAI answer of the room so it can be streamed again, and
+// returns it with the prompt it answers. A prompt left without an answer gets a new one.
+func (s *ChatService) RegenerateAnswer(ctx context.Context, userId, roomId string) (string, *models.Chat, error) {
+	room, err := s.RoomService.GetRoomByID(ctx, userId, roomId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	chats := room.Chats
+	n := len(chats)
+	if n == 0 {
+		return "", nil, utils.NewCustomError(http.StatusConflict, "There is no answer to regenerate")
+	}
+	if chats[n-1].UserID != hocaUserID {
+		answer, err := s.StartAnswer(ctx, userId, roomId)
+		return strings.TrimSpace(chats[n-1].Chat), answer, err
+	}
+	if n < 2 || chats[n-2].UserID == hocaUserID {
+		return "", nil, utils.NewCustomError(http.StatusConflict, "There is no answer to regenerate")
+	}
+
+	// The answer is replaced in place, its ratings keep a copy of the old one
+	complete := false
+	answer, err := s.Rooms.UpdateChat(ctx, userId, roomId, chats[n-1].ChatID, func(chat *models.Chat) error {
+		if isAnswerStreaming(chat) {
+			return errAnswerStreaming
+		}
+		if err := leaseAnswer(chat); err != nil {
+			return err
+		}
+		chat.Chat = ""
+		chat.Recommendations = nil
+		chat.Rating = ""
+		chat.Complete = &complete
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return "", nil, utils.NewCustomError(http.StatusNotFound, "Chat not found")
+		}
+		if errors.Is(err, errAnswerStreaming) {
+			return "", nil, utils.NewCustomError(http.StatusConflict, "The answer is still streaming")
+		}
+		return "", nil, err
+	}
+	return strings.TrimSpace(chats[n-2].Chat), answer, nil
+}
+
+// BranchChat edits a prompt of the room into a new room. The new room starts with a copy of the
+// chats before the prompt followed by the edited prompt, and returns with the answer to stream.
+// The original room is left unchanged.
+func (s *ChatService) BranchChat(ctx context.Context, userId, roomId, chatId, prompt string) (*models.Room, *models.Chat, error) {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return nil, nil, utils.NewCustomError(http.StatusBadRequest, "Prompt is required")
+	}
+
+	source, err := s.RoomService.GetRoomByID(ctx, userId, roomId)
+	if err != nil {
+		return nil, nil, err
+	}
+	edited := -1
+	for i, chat := range source.Chats {
+		if chat.ChatID == chatId {
+			edited = i
+			break
+		}
+	}
+	if edited < 0 {
+		return nil, nil, utils.NewCustomError(http.StatusNotFound, "Chat not found")
+	}
+	if source.Chats[edited].UserID == hocaUserID {
+		return nil, nil, utils.NewCustomError(http.StatusBadRequest, "Only prompts can be edited")
+	}
+
+	room := &models.Room{
+		UserID:             userId,
+		RoomTitle:          source.Room.RoomTitle,
+		AutoTitle:          source.Room.AutoTitle,
+		CreatedAt:          time.Now().Format(time.RFC3339Nano),
+		BranchedFromRoomID: roomId,
+		BranchedFromChatID: chatId,
+	}
+	if err := s.Rooms.CreateRoom(ctx, room); err != nil {
+		return nil, nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to create room chat")
+	}
+
+	// Copies get new ids, the summary follows the copy of the chat it ends at
+	summarizedChatID := ""
+	for _, chat := range source.Chats[:edited] {
+		copied := chat
+		copied.RoomID = room.RoomID
+		copied.Rating = ""
+		copied.StreamLeaseUntil = nil
+		copied.StreamToken = ""
+		if err := s.Rooms.CreateChat(ctx, userId, &copied); err != nil {
+			return nil, nil, err
+		}
+		if chat.ChatID == source.Room.SummarizedChatID {
+			summarizedChatID = copied.ChatID
+		}
+	}
+	if summarizedChatID != "" {
+		updated, err := s.Rooms.UpdateRoom(ctx, userId, room.RoomID, func(stored *models.Room) error {
+			stored.Summary = source.Room.Summary
+			stored.SummarizedChatID = summarizedChatID
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		room = updated
+	}
+
+	if _, err := s.SaveChat(ctx, userId, prompt, room.RoomID, false, nil); err != nil {
+		return nil, nil, err
+	}
+	answer, err := s.StartAnswer(ctx, userId, room.RoomID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Reload the room for its message count and activity
+	if stored, err := s.Rooms.GetRoom(ctx, userId, room.RoomID); err == nil {
+		room = stored
+	}
+	return room, answer, nil
+}
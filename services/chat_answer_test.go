@@ -0,0 +1,218 @@
+package services
+
+import (
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"HalalMate/utils"
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAnswerCursor(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    AnswerCursor
+		wantErr bool
+	}{
+		{id: "chat-1:0:0", want: AnswerCursor{ChatID: "chat-1"}},
+		{id: " chat-1:42:3 ", want: AnswerCursor{ChatID: "chat-1", Text: 42, Cards: 3}},
+		{id: "", wantErr: true},
+		{id: "chat-1", wantErr: true},
+		{id: "chat-1:4", wantErr: true},
+		{id: ":4:1", wantErr: true},
+		{id: "chat-1:-1:0", wantErr: true},
+		{id: "chat-1:4:-2", wantErr: true},
+		{id: "chat-1:four:0", wantErr: true},
+		{id: "chat-1:4:1:9", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := parseAnswerCursor(tt.id)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseAnswerCursor(%q) = %+v, want an error", tt.id, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAnswerCursor(%q): %v", tt.id, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseAnswerCursor(%q) = %+v, want %+v", tt.id, got, tt.want)
+			}
+			if back, err := parseAnswerCursor(got.ID()); err != nil || back != got {
+				t.Errorf("ID() = %q parses back to %+v, %v", got.ID(), back, err)
+			}
+		})
+	}
+}
+
+// newResumeTest stores a room with a partial HocaAI answer for ResumeAnswer
+func newResumeTest(t *testing.T, lease *time.Time) (*ChatService, *models.Chat) {
+	t.Helper()
+	ctx := context.Background()
+	rooms := repositories.NewMemoryRoomRepository()
+
+	room := &models.Room{UserID: "user-1", RoomTitle: "Makan siang", CreatedAt: time.Now().Format(time.RFC3339Nano)}
+	if err := rooms.CreateRoom(ctx, room); err != nil {
+		t.Fatal(err)
+	}
+	complete := false
+	answer := &models.Chat{
+		RoomID:    room.RoomID,
+		UserID:    hocaUserID,
+		Chat:      "Coba Sate Pak Haji, ",
+		CreatedAt: time.Now().Format(time.RFC3339Nano),
+		Recommendations: []models.RecommendationCard{
+			{RestaurantID: "sate", Name: "Sate Pak Haji"},
+			{RestaurantID: "soto", Name: "Soto Betawi"},
+		},
+		Complete:         &complete,
+		StreamLeaseUntil: lease,
+	}
+	if err := rooms.CreateChat(ctx, "user-1", answer); err != nil {
+		t.Fatal(err)
+	}
+	return &ChatService{Rooms: rooms}, answer
+}
+
+func expectErrorStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var customErr *utils.CustomError
+	if !errors.As(err, &customErr) || customErr.StatusCode != status {
+		t.Fatalf("got %v, want status %d", err, status)
+	}
+}
+
+func TestResumeAnswer(t *testing.T) {
+	ctx := context.Background()
+	service, answer := newResumeTest(t, nil)
+
+	resumed, cursor, missed, err := service.ResumeAnswer(ctx, "user-1", answer.RoomID, AnswerCursor{ChatID: answer.ChatID, Text: 5, Cards: 1}.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.ChatID != answer.ChatID || cursor.Text != 5 || cursor.Cards != 1 {
+		t.Errorf("resumed %s at %+v", resumed.ChatID, cursor)
+	}
+	want := []ChatEvent{{Text: "Sate Pak Haji, "}, {Card: &answer.Recommendations[1]}}
+	if !reflect.DeepEqual(missed, want) {
+		t.Errorf("missed = %+v, want %+v", missed, want)
+	}
+
+	// The continuation holds the answer until it saves it
+	stored, err := service.Rooms.GetChat(ctx, "user-1", answer.RoomID, answer.ChatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isAnswerStreaming(stored) {
+		t.Errorf("answer not leased to the continuation")
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := service.ResumeAnswer(waitCtx, "user-1", answer.RoomID, cursor.ID()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second continuation: got %v, want it waiting for the first", err)
+	}
+}
+
+func TestResumeAnswerErrors(t *testing.T) {
+	ctx := context.Background()
+	service, answer := newResumeTest(t, nil)
+
+	tests := []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{"malformed id", "nope", http.StatusBadRequest},
+		{"text past the answer", AnswerCursor{ChatID: answer.ChatID, Text: len(answer.Chat) + 1}.ID(), http.StatusBadRequest},
+		{"cards past the answer", AnswerCursor{ChatID: answer.ChatID, Cards: 3}.ID(), http.StatusBadRequest},
+		{"missing chat", AnswerCursor{ChatID: "missing"}.ID(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := service.ResumeAnswer(ctx, "user-1", answer.RoomID, tt.id)
+			expectErrorStatus(t, err, tt.status)
+		})
+	}
+}
+
+func TestResumeAnswerWaitsForTheLease(t *testing.T) {
+	ctx := context.Background()
+	lease := time.Now().Add(time.Minute)
+	service, answer := newResumeTest(t, &lease)
+
+	// The cursor is past the stored text until the stream that lost its client saves what it sent
+	cursor := AnswerCursor{ChatID: answer.ChatID, Text: len(answer.Chat) + len("lalu"), Cards: 2}
+	go func() {
+		time.Sleep(2 * answerResumePoll)
+		saved := *answer
+		saved.Chat += "lalu"
+		if err := service.SaveAnswer(ctx, "user-1", &saved, false); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	_, resumed, missed, err := service.ResumeAnswer(ctx, "user-1", answer.RoomID, cursor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed != cursor || len(missed) != 0 {
+		t.Errorf("resumed at %+v with %+v missed, want %+v and nothing missed", resumed, missed, cursor)
+	}
+}
+
+func TestResumeAnswerTakesAnExpiredLease(t *testing.T) {
+	lease := time.Now().Add(-time.Second)
+	service, answer := newResumeTest(t, &lease)
+
+	if _, _, _, err := service.ResumeAnswer(context.Background(), "user-1", answer.RoomID, AnswerCursor{ChatID: answer.ChatID}.ID()); err != nil {
+		t.Fatalf("a crashed stream keeps the answer: %v", err)
+	}
+}
+
+func TestSaveAnswerAfterTakeover(t *testing.T) {
+	ctx := context.Background()
+	lease := time.Now().Add(-time.Second)
+	service, answer := newResumeTest(t, &lease)
+
+	// The stream holding the expired lease stalled, a reconnecting client took the answer over
+	stalled := *answer
+	resumed, _, _, err := service.ResumeAnswer(ctx, "user-1", answer.RoomID, AnswerCursor{ChatID: answer.ChatID}.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.StreamToken == "" || resumed.StreamToken == stalled.StreamToken {
+		t.Fatalf("continuation got token %q", resumed.StreamToken)
+	}
+
+	stalled.Chat += "basi"
+	if err := service.saveAnswerProgress(ctx, "user-1", &stalled); !errors.Is(err, errAnswerLeaseLost) {
+		t.Errorf("progress of the stalled stream: got %v, want errAnswerLeaseLost", err)
+	}
+	if err := service.SaveAnswer(ctx, "user-1", &stalled, false); !errors.Is(err, errAnswerLeaseLost) {
+		t.Errorf("final save of the stalled stream: got %v, want errAnswerLeaseLost", err)
+	}
+	stored, err := service.Rooms.GetChat(ctx, "user-1", answer.RoomID, answer.ChatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Chat != answer.Chat || !isAnswerStreaming(stored) || stored.StreamToken != resumed.StreamToken {
+		t.Errorf("the stalled stream changed the resumed answer: %+v", stored)
+	}
+
+	// The continuation still saves and releases the answer
+	resumed.Chat += "lalu Soto Betawi."
+	if err := service.SaveAnswer(ctx, "user-1", resumed, true); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = service.Rooms.GetChat(ctx, "user-1", answer.RoomID, answer.ChatID)
+	if err != nil || stored.Chat != resumed.Chat || isAnswerStreaming(stored) || stored.StreamToken != "" {
+		t.Errorf("continuation not saved: %+v %v", stored, err)
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"HalalMate/config/environment"
+	"HalalMate/llm"
+	"HalalMate/models"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultChatHistoryTokens = 3000
+	// messageTokenOverhead approximates the tokens the API adds around each message
+	messageTokenOverhead = 4
+	// hocaUserID is the UserID of the chats written by HocaAI
+	hocaUserID = "HocaAI"
+)
+
+// errSummaryOutdated skips storing a summary when another answer updated it meanwhile
+var errSummaryOutdated = errors.New("room summary changed")
+
+// chatHistoryTokens is the budget of the chats sent verbatim, read from CHAT_HISTORY_TOKENS
+func chatHistoryTokens() int {
+	if value := environment.GetChatHistoryTokens(); value != "" {
+		if tokens, err := strconv.Atoi(value); err == nil && tokens > 0 {
+			return tokens
+		}
+		log.Printf("Invalid CHAT_HISTORY_TOKENS %q, using %d", value, defaultChatHistoryTokens)
+	}
+	return defaultChatHistoryTokens
+}
+
+// estimateTokens approximates the tokens of a message, about 4 characters each
+func estimateTokens(message llm.Message) int {
+	return utf8.RuneCountInString(message.Content)/4 + messageTokenOverhead
+}
+
+// chatMessage converts a stored chat into a role tagged message. The recommendation markers
+// removed while streaming are put back so the model knows which restaurants it recommended.
+func chatMessage(chat models.Chat) llm.Message {
+	if chat.UserID != hocaUserID {
+		return llm.Message{Role: llm.RoleUser, Content: chat.Chat}
+	}
+
+	var content strings.Builder
+	content.WriteString(chat.Chat)
+	for _, card := range chat.Recommendations {
+		content.WriteString("\n" + recommendationMarkerOpen + recommendationMarkerPrefix + card.RestaurantID + "|")
+		for i, item := range card.MenuHighlights {
+			if i > 0 {
+				content.WriteString(";")
+			}
+			content.WriteString(item.Name)
+		}
+		content.WriteString(recommendationMarkerClose)
+	}
+	return llm.Message{Role: llm.RoleAssistant, Content: content.String()}
+}
+
+// conversationHistory returns the summary of the earlier conversation and the recent chats of
+// the room as messages, oldest first, leaving out the answer being written. Recent chats are kept
+// verbatim while they fit the token budget. Once they overflow, the oldest are folded into the
+// summary stored on the room, keeping half of the budget so the summary is not regenerated on
+// every answer.
+func (s *ChatService) conversationHistory(ctx context.Context, userId string, room *models.RoomWithChat, prompt, answerID string) (string, []llm.Message) {
+	chats := make([]models.Chat, 0, len(room.Chats))
+	for _, chat := range room.Chats {
+		// Answers stopped before their first word have nothing to tell the model
+		empty := chat.UserID == hocaUserID && chat.Chat == "" && len(chat.Recommendations) == 0
+		if !empty && (answerID == "" || chat.ChatID != answerID) {
+			chats = append(chats, chat)
+		}
+	}
+	// The prompt was saved before answering, it is sent as the last message instead
+	if n := len(chats); n > 0 && chats[n-1].UserID != hocaUserID && strings.TrimSpace(chats[n-1].Chat) == prompt {
+		chats = chats[:n-1]
+	}
+
+	// Chats after the summarized one are not covered by the summary yet
+	summary := room.Room.Summary
+	start := 0
+	if room.Room.SummarizedChatID != "" {
+		summary = ""
+		for i, chat := range chats {
+			if chat.ChatID == room.Room.SummarizedChatID {
+				summary, start = room.Room.Summary, i+1
+				break
+			}
+		}
+	}
+
+	messages := make([]llm.Message, 0, len(chats)-start)
+	for _, chat := range chats[start:] {
+		messages = append(messages, chatMessage(chat))
+	}
+
+	budget := chatHistoryTokens()
+	if tokensOf(messages) <= budget {
+		return summary, messages
+	}
+
+	// Keep the newest messages within half of the budget and summarize the ones before
+	keep := len(messages)
+	for used := 0; keep > 0; keep-- {
+		used += estimateTokens(messages[keep-1])
+		if used > budget/2 {
+			break
+		}
+	}
+	folded, recent := messages[:keep], messages[keep:]
+
+	newSummary, err := s.OpenAIService.SummarizeConversation(ctx, summary, folded)
+	if err != nil || newSummary == "" {
+		log.Printf("[Chat] Failed to summarize room %s, sending the last messages only: %v", room.Room.RoomID, err)
+		return summary, recent
+	}
+
+	summarizedChatID := chats[start+keep-1].ChatID
+	_, err = s.Rooms.UpdateRoom(ctx, userId, room.Room.RoomID, func(stored *models.Room) error {
+		if stored.SummarizedChatID != room.Room.SummarizedChatID {
+			return errSummaryOutdated
+		}
+		stored.Summary = newSummary
+		stored.SummarizedChatID = summarizedChatID
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSummaryOutdated) {
+		log.Printf("[Chat] Failed to store the summary of room %s: %v", room.Room.RoomID, err)
+	}
+	return newSummary, recent
+}
+
+func tokensOf(messages []llm.Message) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += estimateTokens(message)
+	}
+	return tokens
+}
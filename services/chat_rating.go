@@ -0,0 +1,89 @@
+package services
+
+import (
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"HalalMate/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const maxChatRatingReasonLength = 1000
+
+// RateChat stores the rating of a HocaAI answer with a copy of the prompt and the answer,
+// rating the same answer again replaces the earlier rating
+func (s *ChatService) RateChat(ctx context.Context, userId, roomId, chatId, rating, reason string) (*models.ChatRating, error) {
+	reason = strings.TrimSpace(reason)
+	if rating != models.ChatRatingUp && rating != models.ChatRatingDown {
+		return nil, utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("rating must be one of %s, %s", models.ChatRatingUp, models.ChatRatingDown))
+	}
+	if len(reason) > maxChatRatingReasonLength {
+		return nil, utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("reason must be at most %d characters", maxChatRatingReasonLength))
+	}
+
+	chats, err := s.Rooms.ListChats(ctx, userId, roomId)
+	if err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get chats")
+	}
+	answer := -1
+	for i, chat := range chats {
+		if chat.ChatID == chatId {
+			answer = i
+			break
+		}
+	}
+	if answer < 0 {
+		return nil, utils.NewCustomError(http.StatusNotFound, "Chat not found")
+	}
+	if chats[answer].UserID != hocaUserID {
+		return nil, utils.NewCustomError(http.StatusBadRequest, "Only HocaAI answers can be rated")
+	}
+
+	// The prompt is the closest user chat before the answer
+	var prompt string
+	for i := answer - 1; i >= 0; i-- {
+		if chats[i].UserID != hocaUserID {
+			prompt = chats[i].Chat
+			break
+		}
+	}
+
+	stored := &models.ChatRating{
+		UserID:          userId,
+		RoomID:          roomId,
+		ChatID:          chatId,
+		Rating:          rating,
+		Reason:          reason,
+		Prompt:          prompt,
+		Answer:          chats[answer].Chat,
+		Recommendations: chats[answer].Recommendations,
+	}
+	if err := s.Ratings.Save(ctx, stored); err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to save rating")
+	}
+
+	_, err = s.Rooms.UpdateChat(ctx, userId, roomId, chatId, func(chat *models.Chat) error {
+		chat.Rating = rating
+		return nil
+	})
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to save rating")
+	}
+	return stored, nil
+}
+
+// ListRatings returns the ratings of every user, oldest first, for building evaluation datasets
+func (s *ChatService) ListRatings(ctx context.Context, rating string) ([]*models.ChatRating, error) {
+	if rating != "" && rating != models.ChatRatingUp && rating != models.ChatRatingDown {
+		return nil, utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("rating must be one of %s, %s", models.ChatRatingUp, models.ChatRatingDown))
+	}
+
+	ratings, err := s.Ratings.Find(ctx, repositories.ChatRatingQuery{Rating: rating})
+	if err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get ratings")
+	}
+	return ratings, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"HalalMate/models"
+	"strings"
+)
+
+const (
+	// recommendationMarkerOpen and recommendationMarkerClose wrap "restaurant:<id>|<menu item>;<menu item>"
+	// in the answer of the model, the markers are replaced by recommendation cards
+	recommendationMarkerOpen   = "[["
+	recommendationMarkerClose  = "]]"
+	recommendationMarkerPrefix = "restaurant:"
+	// maxRecommendationMarkerLength stops holding back text for a marker that is never closed
+	maxRecommendationMarkerLength = 512
+	maxMenuHighlights             = 3
+)
+
+// ChatEvent is one piece of a streamed HocaAI answer: prose, a recommended restaurant,
+// or the name of a tool HocaAI is running
+type ChatEvent struct {
+	Text string
+	Card *models.RecommendationCard
+	Tool string
+}
+
+// recommendationParser splits the streamed answer into prose and recommendation cards.
+// Markers may be cut across deltas, so text that could start one is held back until it is complete.
+type recommendationParser struct {
+	// lookup returns the restaurant of an id, or nil when the model made it up
+	lookup  func(id string) *models.Restaurant
+	seen    map[string]bool
+	pending string
+}
+
+func newRecommendationParser(lookup func(id string) *models.Restaurant) *recommendationParser {
+	return &recommendationParser{
+		lookup: lookup,
+		seen:   make(map[string]bool),
+	}
+}
+
+// Feed consumes a delta and returns the events that are complete
+func (p *recommendationParser) Feed(delta string) []ChatEvent {
+	buf := p.pending + delta
+	p.pending = ""
+
+	var events []ChatEvent
+	emitText := func(text string) {
+		if text != "" {
+			events = append(events, ChatEvent{Text: text})
+		}
+	}
+
+	for buf != "" {
+		start := strings.Index(buf, recommendationMarkerOpen)
+		if start < 0 {
+			// A trailing "[" may be the first half of a marker
+			if strings.HasSuffix(buf, recommendationMarkerOpen[:1]) {
+				emitText(buf[:len(buf)-1])
+				p.pending = buf[len(buf)-1:]
+			} else {
+				emitText(buf)
+			}
+			break
+		}
+		emitText(buf[:start])
+		buf = buf[start:]
+
+		end := strings.Index(buf, recommendationMarkerClose)
+		if end < 0 {
+			if len(buf) > maxRecommendationMarkerLength {
+				emitText(buf)
+			} else {
+				p.pending = buf
+			}
+			break
+		}
+
+		marker := buf[len(recommendationMarkerOpen):end]
+		buf = buf[end+len(recommendationMarkerClose):]
+		if !strings.HasPrefix(strings.TrimSpace(marker), recommendationMarkerPrefix) {
+			emitText(recommendationMarkerOpen + marker + recommendationMarkerClose)
+			continue
+		}
+		// Unknown ids come from the model making a restaurant up, they are dropped
+		if card := p.card(marker); card != nil {
+			events = append(events, ChatEvent{Card: card})
+		}
+	}
+	return events
+}
+
+// Flush returns the text still held back once the stream ended
+func (p *recommendationParser) Flush() []ChatEvent {
+	pending := p.pending
+	p.pending = ""
+	if pending == "" {
+		return nil
+	}
+	return []ChatEvent{{Text: pending}}
+}
+
+// card resolves a marker against the restaurants data, each restaurant is carded once
+func (p *recommendationParser) card(marker string) *models.RecommendationCard {
+	id, items, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(marker), recommendationMarkerPrefix), "|")
+	id = strings.TrimSpace(id)
+
+	if id == "" || p.seen[id] {
+		return nil
+	}
+	restaurant := p.lookup(id)
+	if restaurant == nil {
+		return nil
+	}
+	p.seen[id] = true
+
+	return &models.RecommendationCard{
+		RestaurantID:   restaurant.ID,
+		Name:           restaurant.Title,
+		DistanceKm:     restaurant.Distance,
+		ImageURL:       restaurant.ImageURL,
+		MenuHighlights: menuHighlights(restaurant, strings.Split(items, ";")),
+	}
+}
+
+// menuHighlights keeps the picked items found in the menu, falling back to the first items of the menu
+func menuHighlights(restaurant *models.Restaurant, picked []string) []models.MenuList {
+	var menu []models.MenuList
+	for _, section := range restaurant.Menu {
+		menu = append(menu, section.MenuList...)
+	}
+
+	highlights := []models.MenuList{}
+	for _, name := range picked {
+		name = strings.TrimSpace(name)
+		if name == "" || len(highlights) == maxMenuHighlights {
+			continue
+		}
+		for _, item := range menu {
+			if strings.EqualFold(strings.TrimSpace(item.Name), name) && !containsMenuItem(highlights, item.Name) {
+				highlights = append(highlights, item)
+				break
+			}
+		}
+	}
+
+	if len(highlights) == 0 {
+		for _, item := range menu {
+			if len(highlights) == maxMenuHighlights {
+				break
+			}
+			if strings.TrimSpace(item.Name) != "" {
+				highlights = append(highlights, item)
+			}
+		}
+	}
+	return highlights
+}
+
+func containsMenuItem(items []models.MenuList, name string) bool {
+	for _, item := range items {
+		if item.Name == name {
+			return true
+		}
+	}
+	return false
+}
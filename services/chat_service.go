@@ -1,195 +1,269 @@
 package services
 
 import (
-	"HalalMate/config/database"
+	"HalalMate/llm"
 	"HalalMate/models"
-	"bufio"
+	"HalalMate/repositories"
+	"HalalMate/utils"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"strings"
 	"time"
-
-	"cloud.google.com/go/firestore"
 )
 
 type ChatService struct {
 	RestaurantService *RestaurantService
 	RoomService       *RoomService
+	BookmarkService   *BookmarkService
+	IngridentService  *IngridentService
 	OpenAIService     *OpenAIService
-	FirestoreClient   *firestore.Client
+	Rooms             repositories.RoomRepository
+	Ratings           repositories.ChatRatingRepository
+	Users             repositories.UserRepository
 }
 
 // NewRecomendationService initializes RecomendationService with RestaurantService and OpenAIService
 func NewChatService() *ChatService {
+	return NewChatServiceWithStore(repositories.GetStore())
+}
+
+// NewChatServiceWithStore initializes ChatService with the given repositories
+func NewChatServiceWithStore(store *repositories.Store) *ChatService {
+	openAIService := NewOpenAIService()
+	roomService := NewRoomServiceWithStore(store)
+	roomService.OpenAIService = openAIService
+
 	return &ChatService{
-		RestaurantService: NewRestaurantService(),
-		RoomService:       NewRoomService(),
-		OpenAIService:     NewOpenAIService(),
-		FirestoreClient:   database.GetFirestoreClient(),
+		RestaurantService: NewRestaurantServiceWithStore(store),
+		RoomService:       roomService,
+		BookmarkService:   NewBookmarkServiceWithStore(store),
+		IngridentService:  NewIngridentServiceWithStore(store),
+		OpenAIService:     openAIService,
+		Rooms:             store.Rooms,
+		Ratings:           store.ChatRatings,
+		Users:             store.Users,
 	}
 }
 
+// maxToolRounds bounds the completions of one answer, the last one may not call tools
+const maxToolRounds = 5
+
 //model user prompt system prompt
 
+// StreamRecommendations answers the prompt, letting the model call hocaTools until it writes
+// its final answer. Prose and recommendation cards are sent on eventChan as they stream, doneChan
+// tells whether the answer was finished. The answer started by StartAnswer is continued when it
+// already holds a partial reply. Streaming stops when ctx is cancelled.
 func (s *ChatService) StreamRecommendations(
 	ctx context.Context,
-	recommendationChan chan<- string,
+	eventChan chan<- ChatEvent,
 	doneChan chan<- bool,
 	location models.GeoLocation,
 	prompt string,
 	userId string,
 	roomId string,
+	answer models.Chat,
 ) {
-	defer close(recommendationChan)
+	defer close(eventChan)
 	defer close(doneChan)
 
-	// Fetch nearby restaurants
-	restaurants, err := s.RestaurantService.GetAllRestaurantByLocation(ctx, location.Latitude, location.Longitude, userId)
-	if err != nil {
-		log.Println("Error fetching restaurants:", err)
-		doneChan <- true
-		return
+	// The reader stops receiving once the client is gone, so every send gives up with ctx
+	emit := func(event ChatEvent) bool {
+		select {
+		case eventChan <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	done := func(complete bool) {
+		select {
+		case doneChan <- complete:
+		case <-ctx.Done():
+		}
 	}
 
-	// Limit restaurants to prevent token overflow (keep only closest 10)
-	if len(restaurants) > 10 {
-		restaurants = restaurants[:10]
-		log.Printf("Limited restaurants from %d to 10 to prevent token overflow", len(restaurants))
+	prefs, err := dietaryPreferences(ctx, s.Users, userId)
+	if err != nil {
+		log.Println("Error fetching dietary preferences:", err)
+		done(false)
+		return
 	}
 
 	//get history chat before
 	rooms, err := s.RoomService.GetRoomByID(ctx, userId, roomId)
 	if err != nil {
 		log.Println("Error fetching chats:", err)
-		doneChan <- true
+		done(false)
 		return
 	}
 
-	// Format chat history (limit to last 5 messages to reduce tokens)
-	var chatHistory strings.Builder
-	chatCount := 0
-	maxChats := 5
-
-	for i := len(rooms.Chats) - 1; i >= 0 && chatCount < maxChats; i-- {
-		chat := rooms.Chats[i]
-		chatHistory.WriteString(fmt.Sprintf(
-			"**User %s** (%s):\n%s\n\n",
-			chat.UserID, chat.CreatedAt, chat.Chat,
-		))
-		chatCount++
-	}
-
-	// Convert restaurant data to JSON
-	restaurantsJSON, err := json.Marshal(restaurants)
-	if err != nil {
-		log.Println("Error marshaling restaurants:", err)
-		doneChan <- true
-		return
+	// Recent chats go verbatim as messages, older ones through the summary of the room
+	summary, history := s.conversationHistory(ctx, userId, rooms, prompt, answer.ChatID)
+	var earlierConversation string
+	if summary != "" {
+		earlierConversation = "### 🧠 Earlier in this conversation:\n\n" + summary + "\n\n"
 	}
 
 	// System prompt for AI
 	systemPrompt := fmt.Sprintf(
-		"Generate restaurant recommendations based on the user's request using the provided restaurant data.\n\n"+
-			"### 🗣 Chat History:\n\n%s\n\n"+
+		"You are Hoca, the HalalMate assistant recommending halal restaurants around the user.\n\n"+
+			"%s"+
+			"%s"+
+			"### 🔧 Tools:\n"+
+			"- Look restaurants up with the tools before answering, they search our database around the user's current location.\n"+
+			"- Use `%s` to find places by name, category, price, rating, distance or opening status, `%s` for the menu, halal status and reviews of one place, `%s` for the places the user saved and `%s` for questions about an ingredient.\n"+
+			"- Call the tools again with other filters when the results do not fit the request, and say so when nothing matches instead of recommending something else.\n\n"+
 			"### ⚡ Guidelines:\n"+
-			"- You are free to generate descriptive and engaging recommendations, but **any data retrieved from the database must be formatted in a markdown-like block**.\n"+
-			"- If a specific detail (e.g., menu, distance) **is missing in the database, do not guess or fabricate it**—simply omit it.\n"+
+			"- You are free to generate descriptive and engaging recommendations, but **only recommend restaurants returned by the tools**.\n"+
+			"- If a specific detail (e.g., menu, distance) **is missing from the tool results, do not guess or fabricate it**—simply omit it.\n"+
 			"- Ensure that responses remain **concise, structured, and within the given data limits**.\n\n"+
-			"###  Recommended Restaurants:\n\n%s\n\n"+
-			"### Formatting Rules for Database Data:\n"+
-			"- **Wrap all database-sourced restaurant details** inside a markdown-like fenced block:\n"+
-			"  ```md\n"+
-			"  **Name**: {{name}}\n"+
-			"  **ID**: {{id}}\n"+
-			"  **Distance**: {{distance}} km\n"+
-			"  **Image_URL: {{image_url}}\n"+
-			"  **Menu Highlights**: {{menu}}\n"+
-			"  ```\n"+
-			"- Feel free to add context or suggestions outside this block to make the response more engaging.\n"+
+			"### Referencing Restaurants:\n"+
+			"- The app shows each recommended restaurant as a card with its name, distance, photo and menu, so do **not** write these details out.\n"+
+			"- Reference each recommended restaurant once with a marker on its own line: `[[restaurant:{{id}}|{{menu item}};{{menu item}}]]`\n"+
+			"- Use the exact `id` of the restaurant and up to 3 menu item names copied exactly from its `menu`. Leave the part after `|` empty when you did not look at its menu.\n"+
 			"- Example output:\n"+
-			"  *Looking for the best sushi spot? Try this one!*\n\n"+
-			"  ```md\n"+
-			"  **Name**: Sushi Go\n"+
-			"  **ID**: 12345\n"+
-			"  **Distance**: 2.4 km\n"+
-			"  **Image_URL: https://lh5.googleusercontent.com/p/AF1QipMc47gMvdQbWmWO1R3p0jyAQLSRuf37GYegszai=w92-h92-k-no\n"+
-			"  **Menu Highlights**: Salmon Sashimi, Tuna Roll\n"+
-			"  ```\n\n"+
+			"  *Looking for the best sushi spot? Try this one, the salmon is a favourite!*\n"+
+			"  [[restaurant:12345|Salmon Sashimi;Tuna Roll]]\n\n"+
 			" **Reminder**: Do **not** exceed the provided data limits, and avoid making assumptions about missing details.",
-		chatHistory.String(),
-		string(restaurantsJSON),
+		earlierConversation,
+		dietaryPromptRules(prefs),
+		ToolSearchRestaurants, ToolGetRestaurant, ToolListBookmarks, ToolCheckIngredient,
 	)
 
-	// Start OpenAI streaming
-	stream, err := s.OpenAIService.ChatStream(ctx, systemPrompt, prompt)
-	if err != nil {
-		log.Println("Error starting OpenAI stream:", err)
-		doneChan <- true
-		return
+	tools := newChatTools(s, userId, location)
+	parser := newRecommendationParser(func(id string) *models.Restaurant {
+		restaurant, err := tools.Restaurant(ctx, id)
+		if err != nil {
+			log.Printf("Dropping the recommendation of %s: %v", id, err)
+			return nil
+		}
+		return restaurant
+	})
+
+	messages := make([]llm.Message, 0, len(history)+4)
+	messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: systemPrompt})
+	messages = append(messages, history...)
+	messages = append(messages, llm.Message{Role: llm.RoleUser, Content: prompt})
+
+	// A resumed answer goes on from the reply cut off, its cards are not sent twice
+	if answer.Chat != "" || len(answer.Recommendations) > 0 {
+		messages = append(messages,
+			chatMessage(answer),
+			llm.Message{Role: llm.RoleUser, Content: continueAnswerPrompt},
+		)
+		for _, card := range answer.Recommendations {
+			parser.seen[card.RestaurantID] = true
+		}
 	}
-	defer stream.Close()
 
-	// Read and process response line by line
-	reader := bufio.NewReader(stream)
-	for {
-		line, err := reader.ReadString('\n')
+	complete := true
+	for round := 0; round < maxToolRounds; round++ {
+		// The last round has no tools left, so the model has to answer with what it found
+		availableTools := hocaTools
+		if round == maxToolRounds-1 {
+			availableTools = nil
+		}
+
+		text, toolCalls, err := s.streamRound(ctx, messages, availableTools, parser, emit)
 		if err != nil {
-			break // End of stream
+			log.Println("Error streaming OpenAI answer:", err)
+			complete = false
+			break
+		}
+		if len(toolCalls) == 0 {
+			break
 		}
 
-		// OpenAI responses are prefixed with "data: "
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
-			if data == "[DONE]" {
-				break // End of stream
+		messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: text, ToolCalls: toolCalls})
+		for _, call := range toolCalls {
+			if !emit(ChatEvent{Tool: call.Name}) {
+				return
 			}
+			messages = append(messages, llm.Message{
+				Role:       llm.RoleTool,
+				ToolCallID: call.ID,
+				Content:    tools.Call(ctx, call),
+			})
+		}
+	}
+	for _, event := range parser.Flush() {
+		if !emit(event) {
+			return
+		}
+	}
+
+	// Signal completion
+	done(complete)
+}
 
-			// Parse OpenAI JSON response
-			var parsed map[string]interface{}
-			if err := json.Unmarshal([]byte(data), &parsed); err != nil {
-				log.Println("Error parsing streaming data:", err)
-				continue
+// streamRound streams one completion through emit and returns its text and the tools it asked to call
+func (s *ChatService) streamRound(
+	ctx context.Context,
+	messages []llm.Message,
+	tools []llm.Tool,
+	parser *recommendationParser,
+	emit func(ChatEvent) bool,
+) (string, []llm.ToolCall, error) {
+	stream, err := s.OpenAIService.ChatStreamWithTools(ctx, messages, tools)
+	if err != nil {
+		return "", nil, err
+	}
+	defer stream.Close()
+
+	// Read content deltas until the answer is complete, turning restaurant markers into cards
+	var text strings.Builder
+	for {
+		content, err := stream.Recv()
+		if err != nil {
+			if err != io.EOF {
+				return text.String(), nil, err
 			}
+			break // End of stream
+		}
 
-			// Extract content from OpenAI response
-			if choices, ok := parsed["choices"].([]interface{}); ok && len(choices) > 0 {
-				if delta, ok := choices[0].(map[string]interface{})["delta"].(map[string]interface{}); ok {
-					if content, ok := delta["content"].(string); ok {
-						// Send extracted recommendation content
-						recommendationChan <- content
-					}
-				}
+		text.WriteString(content)
+		for _, event := range parser.Feed(content) {
+			if !emit(event) {
+				return text.String(), nil, ctx.Err()
 			}
 		}
 	}
 
-	// Signal completion
-	doneChan <- true
+	if toolStream, ok := stream.(llm.ToolCallStream); ok {
+		return text.String(), toolStream.ToolCalls(), nil
+	}
+	return text.String(), nil, nil
 }
 
 // save chat into firebase
-func (s *ChatService) SaveChat(ctx context.Context, userId string, prompt string, roomId string, hocaAI bool) error {
+func (s *ChatService) SaveChat(ctx context.Context, userId string, prompt string, roomId string, hocaAI bool, recommendations []models.RecommendationCard) (*models.Chat, error) {
 
 	var chatData models.Chat
 
 	chatData.RoomID = roomId
 	chatData.Chat = prompt
-	chatData.CreatedAt = time.Now().Format(time.RFC3339)
+	chatData.Recommendations = recommendations
+	// Sub-second precision keeps a prompt and its answer apart in the chat order and the room activity
+	chatData.CreatedAt = time.Now().Format(time.RFC3339Nano)
 
 	if hocaAI {
-		chatData.UserID = "HocaAI"
+		chatData.UserID = hocaUserID
 	} else {
 		chatData.UserID = userId
 	}
 
-	chatRef := s.FirestoreClient.Collection("users").Doc(userId).Collection("rooms").Doc(roomId).Collection("chats").NewDoc()
-	_, err := chatRef.Set(ctx, chatData)
-	if err != nil {
-		return err
+	if err := s.Rooms.CreateChat(ctx, userId, &chatData); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, utils.NewCustomError(http.StatusNotFound, "Room not found")
+		}
+		return nil, err
 	}
-	return nil
+	return &chatData, nil
 }
 
 //save room into firebase
@@ -197,49 +271,23 @@ func (s *ChatService) SaveChat(ctx context.Context, userId string, prompt string
 func (s *ChatService) SaveRoom(ctx context.Context, userId string) (*models.Room, error) {
 
 	var room models.Room
-	// Buat dokumen baru di Firestore (Firestore akan otomatis generate ID)
-	roomRef := s.FirestoreClient.Collection("users").Doc(userId).Collection("rooms").NewDoc()
 
 	// Gunakan waktu sekarang jika CreatedAt tidak diset
 	room.CreatedAt = time.Now().Format(time.RFC3339) // Format waktu standar
 
 	room.UserID = userId
 
-	// Simpan data ke Firestore
-	_, err := roomRef.Set(ctx, room)
-	if err != nil {
+	// Simpan data ke Firestore (Firestore akan otomatis generate ID)
+	if err := s.Rooms.CreateRoom(ctx, &room); err != nil {
 		return nil, err
 	}
 
 	// Kembalikan objek Room dengan RoomID yang di-generate Firestore
-	return &models.Room{
-		RoomID:    roomRef.ID, // Firestore-generated ID
-		UserID:    room.UserID,
-		CreatedAt: room.CreatedAt,
-	}, nil
+	return &room, nil
 }
 
 //get all room chat
 
 func (s *ChatService) GetRooms(ctx context.Context, userId string) ([]*models.Room, error) {
-	var rooms []*models.Room
-
-	// Query rooms collection
-	roomDocs, err := s.FirestoreClient.Collection("users").Doc(userId).Collection("rooms").Documents(ctx).GetAll()
-	if err != nil {
-		return nil, err
-	}
-
-	// Iterate over room documents
-	for _, doc := range roomDocs {
-		var room models.Room
-		if err := doc.DataTo(&room); err != nil {
-			return nil, err
-		}
-
-		// Append room to list
-		rooms = append(rooms, &room)
-	}
-
-	return rooms, nil
+	return s.Rooms.ListRooms(ctx, userId)
 }
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"HalalMate/llm"
+	"HalalMate/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Names of the tools HocaAI can call
+const (
+	ToolSearchRestaurants = "search_restaurants"
+	ToolGetRestaurant     = "get_restaurant"
+	ToolListBookmarks     = "list_bookmarks"
+	ToolCheckIngredient   = "check_ingredient"
+)
+
+const (
+	defaultToolSearchLimit = 5
+	maxToolSearchLimit     = 10
+	maxToolMenuItems       = 40
+	maxToolReviews         = 3
+)
+
+// hocaTools are the functions HocaAI can call, they run against our own services
+var hocaTools = []llm.Tool{
+	{
+		Name:        ToolSearchRestaurants,
+		Description: "Search the halal restaurants around the user. Every filter is optional. Results follow the dietary preferences of the user.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query":       map[string]interface{}{"type": "string", "description": "Part of the restaurant name, e.g. \"padang\" or \"ramen\""},
+				"category":    map[string]interface{}{"type": "string", "description": "Exact category as listed by Google Maps, e.g. \"Restoran Padang\""},
+				"price_range": map[string]interface{}{"type": "string", "description": "Price range as listed by Google Maps, e.g. \"Rp 1–25 rb\""},
+				"min_rating":  map[string]interface{}{"type": "number", "description": "Minimum rating from 0 to 5"},
+				"radius_km":   map[string]interface{}{"type": "number", "description": fmt.Sprintf("Search radius in km, at most %.0f", MaxSearchRadiusKm)},
+				"open_now":    map[string]interface{}{"type": "boolean", "description": "Only restaurants that were open when last checked"},
+				"sort": map[string]interface{}{
+					"type": "string",
+					"enum": []string{RestaurantSortDistance, RestaurantSortRating, RestaurantSortReviewCount},
+				},
+				"limit": map[string]interface{}{"type": "integer", "description": fmt.Sprintf("Number of results, at most %d", maxToolSearchLimit)},
+			},
+		},
+	},
+	{
+		Name:        ToolGetRestaurant,
+		Description: "Get the details of a restaurant: halal status and its reason, address, opening status, reviews and menu with prices.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"restaurant_id": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"restaurant_id"},
+		},
+	},
+	{
+		Name:        ToolListBookmarks,
+		Description: "List the restaurants the user bookmarked.",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	},
+	{
+		Name:        ToolCheckIngredient,
+		Description: "Check an ingredient against the HalalMate list of haram and doubtful ingredients.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"name"},
+		},
+	},
+}
+
+// toolRestaurant is the summary of a restaurant returned to the model
+type toolRestaurant struct {
+	ID            string   `json:"id"`
+	Title         string   `json:"title"`
+	Category      string   `json:"category,omitempty"`
+	PriceRange    string   `json:"price_range,omitempty"`
+	Rating        string   `json:"rating,omitempty"`
+	ReviewCount   string   `json:"review_count,omitempty"`
+	DistanceKm    *float64 `json:"distance_km,omitempty"`
+	OpeningStatus string   `json:"opening_status,omitempty"`
+	Address       string   `json:"address,omitempty"`
+}
+
+// toolRestaurantDetails adds what get_restaurant returns on top of the summary
+type toolRestaurantDetails struct {
+	toolRestaurant
+	Status      string            `json:"halal_status"`
+	HalalReason string            `json:"halal_reason,omitempty"`
+	Reviews     []string          `json:"reviews,omitempty"`
+	Menu        []models.MenuList `json:"menu,omitempty"`
+}
+
+// chatTools runs the tool calls of one answer for a user at a location. Every restaurant
+// returned to the model is remembered so its recommendation cards use the same data.
+type chatTools struct {
+	service     *ChatService
+	userID      string
+	location    models.GeoLocation
+	restaurants map[string]*models.Restaurant
+}
+
+func newChatTools(service *ChatService, userID string, location models.GeoLocation) *chatTools {
+	return &chatTools{
+		service:     service,
+		userID:      userID,
+		location:    location,
+		restaurants: make(map[string]*models.Restaurant),
+	}
+}
+
+// Call runs a tool call and returns its JSON result, failures are reported to the model as {"error": ...}
+func (t *chatTools) Call(ctx context.Context, call llm.ToolCall) string {
+	result, err := t.call(ctx, call)
+	if err != nil {
+		log.Printf("[Chat] Tool %s failed: %v", call.Name, err)
+		result = map[string]string{"error": err.Error()}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return `{"error":"failed to encode the result"}`
+	}
+	return string(data)
+}
+
+func (t *chatTools) call(ctx context.Context, call llm.ToolCall) (interface{}, error) {
+	arguments := strings.TrimSpace(call.Arguments)
+	if arguments == "" {
+		arguments = "{}"
+	}
+
+	switch call.Name {
+	case ToolSearchRestaurants:
+		var args struct {
+			Query      string  `json:"query"`
+			Category   string  `json:"category"`
+			PriceRange string  `json:"price_range"`
+			MinRating  float64 `json:"min_rating"`
+			RadiusKm   float64 `json:"radius_km"`
+			OpenNow    bool    `json:"open_now"`
+			Sort       string  `json:"sort"`
+			Limit      int     `json:"limit"`
+		}
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %v", err)
+		}
+		return t.searchRestaurants(ctx, RestaurantSearch{
+			Latitude:   t.location.Latitude,
+			Longitude:  t.location.Longitude,
+			RadiusKm:   args.RadiusKm,
+			Category:   strings.TrimSpace(args.Category),
+			MinRating:  args.MinRating,
+			PriceRange: strings.TrimSpace(args.PriceRange),
+			OpenNow:    args.OpenNow,
+			Query:      strings.TrimSpace(args.Query),
+			Sort:       args.Sort,
+			Limit:      args.Limit,
+		})
+
+	case ToolGetRestaurant:
+		var args struct {
+			RestaurantID string `json:"restaurant_id"`
+		}
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %v", err)
+		}
+		restaurant, err := t.Restaurant(ctx, strings.TrimSpace(args.RestaurantID))
+		if err != nil {
+			return nil, err
+		}
+		return newToolRestaurantDetails(restaurant), nil
+
+	case ToolListBookmarks:
+		bookmarks, err := t.service.BookmarkService.GetAllBookmarks(ctx, t.userID, t.location.Latitude, t.location.Longitude)
+		if err != nil {
+			return nil, err
+		}
+		results := []toolRestaurant{}
+		for _, bookmark := range bookmarks {
+			if bookmark.Restaurant == nil {
+				continue
+			}
+			// Only the ones that may be recommended are remembered for the cards
+			if recommendable(bookmark.Restaurant) {
+				t.restaurants[bookmark.Restaurant.ID] = bookmark.Restaurant
+			}
+			results = append(results, newToolRestaurant(bookmark.Restaurant))
+		}
+		return map[string]interface{}{"bookmarks": results}, nil
+
+	case ToolCheckIngredient:
+		var args struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %v", err)
+		}
+		return t.checkIngredient(ctx, args.Name)
+	}
+	return nil, fmt.Errorf("unknown tool %q", call.Name)
+}
+
+func (t *chatTools) searchRestaurants(ctx context.Context, search RestaurantSearch) (interface{}, error) {
+	if search.Limit <= 0 {
+		search.Limit = defaultToolSearchLimit
+	}
+	if search.Limit > maxToolSearchLimit {
+		search.Limit = maxToolSearchLimit
+	}
+
+	page, err := t.service.RestaurantService.SearchRestaurants(ctx, t.userID, search)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]toolRestaurant, 0, len(page.Restaurants))
+	for i := range page.Restaurants {
+		restaurant := &page.Restaurants[i]
+		t.restaurants[restaurant.ID] = restaurant
+		results = append(results, newToolRestaurant(restaurant))
+	}
+	return map[string]interface{}{"restaurants": results}, nil
+}
+
+func (t *chatTools) checkIngredient(ctx context.Context, name string) (interface{}, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	ingridients, err := t.service.IngridentService.GetAllIngridients(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lowered := strings.ToLower(name)
+	matches := []string{}
+	for _, ingridient := range ingridients {
+		listed := strings.ToLower(strings.TrimSpace(ingridient.Name))
+		if listed != "" && (strings.Contains(listed, lowered) || strings.Contains(lowered, listed)) {
+			matches = append(matches, ingridient.Name)
+		}
+	}
+
+	result := map[string]interface{}{
+		"name":    name,
+		"listed":  len(matches) > 0,
+		"matches": matches,
+	}
+	if len(matches) == 0 {
+		result["note"] = "Not in the list, this alone does not prove the ingredient is halal."
+	}
+	return result, nil
+}
+
+// Restaurant returns a restaurant the model may recommend: one returned by a tool, or a halal
+// restaurant still open, with its distance to the user
+func (t *chatTools) Restaurant(ctx context.Context, id string) (*models.Restaurant, error) {
+	if restaurant, ok := t.restaurants[id]; ok {
+		return restaurant, nil
+	}
+	if id == "" {
+		return nil, fmt.Errorf("restaurant_id is required")
+	}
+
+	restaurant, err := t.service.RestaurantService.GetRestaurantByIdAndLocation(ctx, id, t.location.Latitude, t.location.Longitude, t.userID)
+	if err != nil {
+		return nil, err
+	}
+	if !recommendable(restaurant) {
+		return nil, fmt.Errorf("restaurant %s is not a halal restaurant", id)
+	}
+	t.restaurants[id] = restaurant
+	return restaurant, nil
+}
+
+// recommendable reports whether a restaurant is halal and still open
+func recommendable(restaurant *models.Restaurant) bool {
+	return restaurant.Status == models.RestaurantStatusHalal && !restaurant.Closed
+}
+
+func newToolRestaurant(restaurant *models.Restaurant) toolRestaurant {
+	return toolRestaurant{
+		ID:            restaurant.ID,
+		Title:         restaurant.Title,
+		Category:      restaurant.Category,
+		PriceRange:    restaurant.PriceRange,
+		Rating:        restaurant.Rating,
+		ReviewCount:   restaurant.ReviewCount,
+		DistanceKm:    restaurant.Distance,
+		OpeningStatus: restaurant.OpeningStatus,
+		Address:       restaurant.Address,
+	}
+}
+
+func newToolRestaurantDetails(restaurant *models.Restaurant) toolRestaurantDetails {
+	details := toolRestaurantDetails{
+		toolRestaurant: newToolRestaurant(restaurant),
+		Status:         restaurant.Status,
+	}
+	if restaurant.Verdict != nil {
+		details.HalalReason = restaurant.Verdict.Reason
+	}
+
+	details.Reviews = restaurant.Reviews
+	if len(details.Reviews) > maxToolReviews {
+		details.Reviews = details.Reviews[:maxToolReviews]
+	}
+	for _, section := range restaurant.Menu {
+		for _, item := range section.MenuList {
+			if len(details.Menu) == maxToolMenuItems {
+				return details
+			}
+			details.Menu = append(details.Menu, item)
+		}
+	}
+	return details
+}
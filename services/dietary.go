@@ -0,0 +1,130 @@
+package services
+
+import (
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// syubhatConfidence is the verdict confidence below which AvoidSyubhat drops a halal restaurant
+const syubhatConfidence = 0.8
+
+// seafoodKeywords name seafood other than fish, in Indonesian and English
+var seafoodKeywords = []string{
+	"seafood", "makanan laut", "udang", "kepiting", "rajungan", "cumi", "sotong", "gurita", "kerang", "tiram", "lobster",
+	"shrimp", "prawn", "crab", "squid", "calamari", "octopus", "clam", "oyster", "mussel", "scallop",
+}
+
+// alcoholFlavouringKeywords name cooking wines and flavourings made with alcohol
+var alcoholFlavouringKeywords = []string{
+	"mirin", "angciu", "ang ciu", "arak", "sake", "rum", "wine", "beer", "bir", "brandy", "liqueur",
+	"vanilla extract", "ekstrak vanila",
+}
+
+// dietaryPreferences returns the preferences of the user, the default standard when the user has no document
+func dietaryPreferences(ctx context.Context, users repositories.UserRepository, userID string) (models.DietaryPreferences, error) {
+	if userID == "" {
+		return models.DietaryPreferences{}, nil
+	}
+	user, err := users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return models.DietaryPreferences{}, nil
+		}
+		return models.DietaryPreferences{}, err
+	}
+	return user.DietaryPreferences, nil
+}
+
+// matchesDietaryPreferences reports whether a halal restaurant meets the standard of the user
+func matchesDietaryPreferences(restaurant *models.Restaurant, prefs models.DietaryPreferences) bool {
+	verdict := restaurant.Verdict
+	if prefs.CertifiedOnly && (verdict == nil || verdict.Source != models.VerdictSourceCertificate) {
+		return false
+	}
+	if prefs.AvoidSyubhat && (verdict == nil || verdict.Confidence < syubhatConfidence || len(verdict.OffendingItems) > 0) {
+		return false
+	}
+	if prefs.AvoidSeafood && containsKeyword(restaurant.Title+"\n"+restaurant.Category+"\n"+restaurantMenuText(restaurant), seafoodKeywords) {
+		return false
+	}
+	if prefs.AvoidAlcoholFlavouring && containsKeyword(restaurantMenuText(restaurant), alcoholFlavouringKeywords) {
+		return false
+	}
+	return true
+}
+
+func restaurantMenuText(restaurant *models.Restaurant) string {
+	var text strings.Builder
+	for _, menu := range restaurant.Menu {
+		text.WriteString(menu.SubMenu)
+		text.WriteString("\n")
+		for _, item := range menu.MenuList {
+			text.WriteString(item.Name)
+			text.WriteString("\n")
+		}
+	}
+	if restaurant.Verdict != nil {
+		text.WriteString(strings.Join(restaurant.Verdict.OffendingItems, "\n"))
+	}
+	return text.String()
+}
+
+// containsKeyword reports whether text contains one of the keywords as whole words, so "rum" does not match "rumah"
+func containsKeyword(text string, keywords []string) bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	padded := " " + strings.Join(words, " ") + " "
+	for _, keyword := range keywords {
+		if strings.Contains(padded, " "+keyword+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// dietaryPromptRules tells the recommendation model about the standard of the user, empty for the default standard
+func dietaryPromptRules(prefs models.DietaryPreferences) string {
+	var rules []string
+	if prefs.CertifiedOnly {
+		rules = append(rules, "- The user only eats at restaurants with a halal certificate, never present a restaurant as certified unless its verdict source is \"certificate\".")
+	}
+	if prefs.AvoidSyubhat {
+		rules = append(rules, "- The user avoids syubhat (doubtful) food, do not suggest dishes whose halal status is unclear.")
+	}
+	if prefs.AvoidSeafood {
+		rules = append(rules, "- The user does not eat seafood other than fish (shrimp, crab, squid, shellfish), do not suggest such dishes.")
+	}
+	if prefs.AvoidAlcoholFlavouring {
+		rules = append(rules, "- The user avoids dishes cooked with alcohol-based flavourings such as mirin, angciu or rum essence, do not suggest them.")
+	}
+	if len(rules) == 0 {
+		return ""
+	}
+	return "### 🥗 User's Dietary Standard:\n" + strings.Join(rules, "\n") + "\n\n"
+}
+
+// SnackDietaryRules tells the snack verdict model about the standard of the user, empty for the default standard
+func SnackDietaryRules(prefs models.DietaryPreferences) string {
+	var rules []string
+	if prefs.CertifiedOnly {
+		rules = append(rules, `- Pengguna hanya menerima produk bersertifikat halal (MUI, BPJPH, JAKIM, dll). Jika tidak ada bukti sertifikat halal, jangan beri "Halal", gunakan "Tidak Dapat Menentukan".`)
+	}
+	if prefs.AvoidSyubhat {
+		rules = append(rules, `- Pengguna menghindari bahan syubhat. Bahan yang sumbernya meragukan (gelatin, emulsifier, enzim, E-codes tanpa keterangan) dianggap "Haram".`)
+	}
+	if prefs.AvoidSeafood {
+		rules = append(rules, `- Pengguna tidak mengonsumsi hewan laut selain ikan (udang, kepiting, cumi, kerang). Produk yang mengandungnya dianggap "Haram" dan sebutkan alasannya.`)
+	}
+	if prefs.AvoidAlcoholFlavouring {
+		rules = append(rules, `- Pengguna menghindari perisa yang dibuat dengan alkohol (ekstrak vanila, perisa rum, mirin, angciu). Produk yang mengandungnya dianggap "Haram".`)
+	}
+	if len(rules) == 0 {
+		return ""
+	}
+	return "\n\nStandar kehalalan pengguna (wajib diikuti, lebih ketat dari aturan di atas):\n" + strings.Join(rules, "\n")
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"math"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+// maxGeohashPrecision bounds the cell size to roughly 150 m, smaller cells only add queries
+const maxGeohashPrecision = 7
+
+// geohashPrecisionFor returns the longest geohash precision whose cell around the point
+// is at least radiusKm wide and high, so the cell and its 8 neighbours contain the circle
+func geohashPrecisionFor(latitude, longitude, radiusKm float64) uint {
+	for precision := uint(maxGeohashPrecision); precision > 1; precision-- {
+		box := geohash.BoundingBox(geohash.EncodeWithPrecision(latitude, longitude, precision))
+		centerLat, centerLng := box.Center()
+
+		width := haversine(centerLat, box.MinLng, centerLat, box.MaxLng)
+		height := haversine(box.MinLat, centerLng, box.MaxLat, centerLng)
+		if math.Min(width, height) >= radiusKm {
+			return precision
+		}
+	}
+	return 1
+}
+
+// geohashCover returns the geohash prefixes whose cells intersect the circle of radiusKm
+// around the point: the cell of the point plus those of its 8 neighbours that reach the circle
+func geohashCover(latitude, longitude, radiusKm float64) []string {
+	center := geohash.EncodeWithPrecision(latitude, longitude, geohashPrecisionFor(latitude, longitude, radiusKm))
+
+	cells := []string{center}
+	seen := map[string]bool{center: true}
+	for _, neighbour := range geohash.Neighbors(center) {
+		// Near the poles several directions can wrap to the same cell
+		if seen[neighbour] {
+			continue
+		}
+		seen[neighbour] = true
+
+		if distanceToBox(latitude, longitude, geohash.BoundingBox(neighbour)) <= radiusKm {
+			cells = append(cells, neighbour)
+		}
+	}
+	return cells
+}
+
+// distanceToBox returns the distance in km from the point to the closest point of the box
+func distanceToBox(latitude, longitude float64, box geohash.Box) float64 {
+	closestLat := math.Max(box.MinLat, math.Min(latitude, box.MaxLat))
+	closestLng := longitude
+	if longitude < box.MinLng || longitude > box.MaxLng {
+		// A box across the antimeridian is closest through it, not around the globe
+		closestLng = box.MinLng
+		if longitudeGap(longitude, box.MaxLng) < longitudeGap(longitude, box.MinLng) {
+			closestLng = box.MaxLng
+		}
+	}
+	return haversine(latitude, longitude, closestLat, closestLng)
+}
+
+// longitudeGap returns the angle in degrees between two longitudes, the short way around
+func longitudeGap(a, b float64) float64 {
+	gap := math.Abs(a - b)
+	return math.Min(gap, 360-gap)
+}
@@ -1,21 +1,23 @@
 package services
 
 import (
-	"HalalMate/config/database"
 	"HalalMate/models"
+	"HalalMate/repositories"
 	"context"
-
-	"cloud.google.com/go/firestore"
-	"google.golang.org/api/iterator"
 )
 
 type IngridentService struct {
-	FirestoreClient *firestore.Client
+	Ingridents repositories.IngridentRepository
 }
 
 func NewIngridentService() *IngridentService {
+	return NewIngridentServiceWithStore(repositories.GetStore())
+}
+
+// NewIngridentServiceWithStore initializes IngridentService with the given repositories
+func NewIngridentServiceWithStore(store *repositories.Store) *IngridentService {
 	return &IngridentService{
-		FirestoreClient: database.GetFirestoreClient(),
+		Ingridents: store.Ingridents,
 	}
 }
 
@@ -23,17 +25,12 @@ func NewIngridentService() *IngridentService {
 
 func (s *IngridentService) SaveIngrident(ctx context.Context, name string) (*string, error) {
 
-	// Buat dokumen baru di Firestore (Firestore akan otomatis generate ID)
-	ingridentRef := s.FirestoreClient.Collection("ingridients").NewDoc()
-
-	// Simpan data room ke dalam dokumen
-	_, err := ingridentRef.Set(ctx, map[string]interface{}{
-		"name": name,
-	})
+	// Buat dokumen baru (akan otomatis generate ID)
+	id, err := s.Ingridents.Create(ctx, name)
 	if err != nil {
 		return nil, err
 	}
-	return &ingridentRef.ID, nil
+	return &id, nil
 
 }
 
@@ -41,27 +38,5 @@ func (s *IngridentService) SaveIngrident(ctx context.Context, name string) (*str
 
 // Correct GetAllIngridients method
 func (s *IngridentService) GetAllIngridients(ctx context.Context) ([]*models.Ingrident, error) {
-	iter := s.FirestoreClient.Collection("ingridients").Documents(ctx)
-	defer iter.Stop()
-
-	var ingridents []*models.Ingrident
-
-	for {
-		doc, err := iter.Next()
-		if err != nil {
-			if err == iterator.Done {
-				break
-			}
-			return nil, err
-		}
-
-		var ingrident models.Ingrident
-		err = doc.DataTo(&ingrident)
-		if err != nil {
-			return nil, err
-		}
-		ingridents = append(ingridents, &ingrident)
-	}
-
-	return ingridents, nil
+	return s.Ingridents.List(ctx)
 }
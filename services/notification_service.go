@@ -0,0 +1,177 @@
+package services
+
+import (
+	"HalalMate/config/database"
+	"HalalMate/config/environment"
+	"HalalMate/models"
+	"HalalMate/notifier"
+	"HalalMate/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+)
+
+// Values of the "type" data key of the notifications, the app routes on it
+const (
+	NotificationRestaurantStatus = "restaurant_status"
+	NotificationScrapeJob        = "scrape_job"
+)
+
+// NotificationService sends push notifications to the devices registered by a user
+type NotificationService struct {
+	Users     repositories.UserRepository
+	Bookmarks repositories.BookmarkRepository
+	Notifier  notifier.Notifier
+}
+
+// NewNotificationService initializes NotificationService with the default store
+func NewNotificationService() *NotificationService {
+	return NewNotificationServiceWithStore(repositories.GetStore())
+}
+
+// NewNotificationServiceWithStore initializes NotificationService with the given repositories
+func NewNotificationServiceWithStore(store *repositories.Store) *NotificationService {
+	return &NotificationService{
+		Users:     store.Users,
+		Bookmarks: store.Bookmarks,
+		Notifier:  newNotifier(),
+	}
+}
+
+// newNotifier builds the notifier from the NOTIFIER_* environment, logging notifications when it is misconfigured
+func newNotifier() notifier.Notifier {
+	n, err := notifier.New(context.Background(), notifier.Config{
+		Driver: environment.GetNotifierDriver(),
+		Path:   environment.GetNotifierFile(),
+		App:    database.FirebaseApp,
+	})
+	if err != nil {
+		log.Printf("[Notifier] %v, notifications will only be logged", err)
+		return notifier.NewRecordingNotifier("")
+	}
+	return n
+}
+
+// NotifyUser sends the notification to every device of the user and forgets the tokens the backend rejected.
+// Users without a device are skipped.
+func (s *NotificationService) NotifyUser(ctx context.Context, user *models.User, notification notifier.Notification) error {
+	tokens := user.DeviceTokens()
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	stale, err := s.Notifier.Send(ctx, tokens, notification)
+	if len(stale) > 0 {
+		log.Printf("[Notifier] Forgetting %d stale device token(s) of %s", len(stale), user.ID)
+		updateErr := s.Users.Update(ctx, user.ID, func(user *models.User) error {
+			removeDeviceTokens(user, stale...)
+			return nil
+		})
+		if updateErr != nil && !errors.Is(updateErr, repositories.ErrNotFound) {
+			log.Printf("[ERROR] Failed to forget the stale device tokens of %s: %v", user.ID, updateErr)
+		}
+	}
+	return err
+}
+
+// NotifyRestaurantStatusChanged tells the users who bookmarked the restaurant about its new halal status.
+// Failures are logged, a missed notification never fails the status change.
+func (s *NotificationService) NotifyRestaurantStatusChanged(ctx context.Context, restaurant *models.Restaurant, previousStatus string) {
+	userIDs, err := s.Bookmarks.UserIDsByRestaurant(ctx, restaurant.ID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to find the users who bookmarked %s: %v", restaurant.ID, err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		user, err := s.Users.GetByID(ctx, userID)
+		if err != nil {
+			if !errors.Is(err, repositories.ErrNotFound) {
+				log.Printf("[ERROR] Failed to get user %s: %v", userID, err)
+			}
+			continue
+		}
+
+		title, body := "Status halal berubah", fmt.Sprintf("%s sekarang berstatus %s.", restaurant.Title, restaurant.Status)
+		if user.PreferredLanguage == "en" {
+			title, body = "Halal status changed", fmt.Sprintf("%s is now %s.", restaurant.Title, restaurant.Status)
+		}
+		err = s.NotifyUser(ctx, user, notifier.Notification{
+			Title: title,
+			Body:  body,
+			Data: map[string]string{
+				"type":            NotificationRestaurantStatus,
+				"restaurant_id":   restaurant.ID,
+				"status":          restaurant.Status,
+				"previous_status": previousStatus,
+			},
+		})
+		if err != nil {
+			log.Printf("[ERROR] Failed to notify %s about restaurant %s: %v", userID, restaurant.ID, err)
+		}
+	}
+}
+
+// NotifyScrapeJobFinished tells the user who queued the job whether it succeeded or failed. Failures are logged.
+func (s *NotificationService) NotifyScrapeJobFinished(ctx context.Context, job *models.ScrapeJob) {
+	if job.CreatedBy == "" {
+		return
+	}
+	user, err := s.Users.GetByID(ctx, job.CreatedBy)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNotFound) {
+			log.Printf("[ERROR] Failed to get user %s: %v", job.CreatedBy, err)
+		}
+		return
+	}
+
+	title, body := scrapeJobMessage(job, user.PreferredLanguage)
+	err = s.NotifyUser(ctx, user, notifier.Notification{
+		Title: title,
+		Body:  body,
+		Data: map[string]string{
+			"type":   NotificationScrapeJob,
+			"job_id": job.ID,
+			"status": job.Status,
+		},
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to notify %s about scrape job %s: %v", job.CreatedBy, job.ID, err)
+	}
+}
+
+func scrapeJobMessage(job *models.ScrapeJob, language string) (string, string) {
+	failed := job.Status == models.ScrapeJobFailed
+	if language == "en" {
+		if failed {
+			return "Scrape job failed", fmt.Sprintf("The search for %q failed: %s", job.Keyword, job.Error)
+		}
+		return "Scrape job finished", fmt.Sprintf("The search for %q found %d places.", job.Keyword, len(job.Places))
+	}
+	if failed {
+		return "Scraping gagal", fmt.Sprintf("Pencarian %q gagal: %s", job.Keyword, job.Error)
+	}
+	return "Scraping selesai", fmt.Sprintf("Pencarian %q menemukan %d tempat.", job.Keyword, len(job.Places))
+}
+
+// addDeviceToken registers token as the newest device of the user, forgetting the oldest past models.MaxDeviceTokens
+func addDeviceToken(user *models.User, token string) {
+	tokens := user.DeviceTokens()
+	tokens = slices.DeleteFunc(tokens, func(t string) bool { return t == token })
+	tokens = append(tokens, token)
+	if len(tokens) > models.MaxDeviceTokens {
+		tokens = tokens[len(tokens)-models.MaxDeviceTokens:]
+	}
+	user.FCMTokens = tokens
+	user.FCMToken = ""
+}
+
+// removeDeviceTokens forgets the given tokens of the user
+func removeDeviceTokens(user *models.User, tokens ...string) {
+	user.FCMTokens = slices.DeleteFunc(user.DeviceTokens(), func(t string) bool {
+		return slices.Contains(tokens, t)
+	})
+	user.FCMToken = ""
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"HalalMate/llm"
+	"HalalMate/models"
+)
+
+// maxStructuredRepairs is how many times an invalid structured answer is sent back to the model
+const maxStructuredRepairs = 2
+
+// snackVerdictSchema mirrors models.SnackVerdict
+var snackVerdictSchema = &llm.JSONSchema{
+	Name:   "snack_verdict",
+	Strict: true,
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"Status":      map[string]interface{}{"type": "string", "enum": models.SnackStatuses},
+			"Reason":      map[string]interface{}{"type": "string"},
+			"ProductName": map[string]interface{}{"type": "string"},
+			"Suggest": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"NamaSugestProduk": map[string]interface{}{"type": "string"},
+					},
+					"required":             []string{"NamaSugestProduk"},
+					"additionalProperties": false,
+				},
+			},
+		},
+		"required":             []string{"Status", "Reason", "ProductName", "Suggest"},
+		"additionalProperties": false,
+	},
+}
+
+// menuAnalysisSchema mirrors models.AIResponsAnalyzeMenu
+var menuAnalysisSchema = &llm.JSONSchema{
+	Name:   "menu_analysis",
+	Strict: true,
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"halal_status": map[string]interface{}{"type": "string", "enum": models.MenuStatuses},
+			"confidence":   map[string]interface{}{"type": "number"},
+			"haram_items":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"menu": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"sub_menu": map[string]interface{}{"type": "string"},
+						"menu_list": map[string]interface{}{
+							"type": "array",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"name":  map[string]interface{}{"type": "string"},
+									"price": map[string]interface{}{"type": "integer"},
+								},
+								"required":             []string{"name", "price"},
+								"additionalProperties": false,
+							},
+						},
+					},
+					"required":             []string{"sub_menu", "menu_list"},
+					"additionalProperties": false,
+				},
+			},
+		},
+		"required":             []string{"halal_status", "menu", "confidence", "haram_items"},
+		"additionalProperties": false,
+	},
+}
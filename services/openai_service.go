@@ -2,30 +2,110 @@ package services
 
 import (
 	"HalalMate/config/environment"
+	"HalalMate/llm"
 	"HalalMate/models"
-	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// OpenAIService handles image processing with OpenAI API
+// LLMCallSite is the provider and tuning used by one OpenAIService method
+type LLMCallSite struct {
+	Provider    llm.LLMProvider
+	Model       string
+	Temperature *float64
+	Timeout     time.Duration
+}
+
+func (c LLMCallSite) request(messages ...llm.Message) llm.Request {
+	return llm.Request{
+		Model:       c.Model,
+		Messages:    messages,
+		Temperature: c.Temperature,
+		Timeout:     c.Timeout,
+	}
+}
+
+// OpenAIService handles the LLM calls of the app. Each call site can use its own provider and model.
 type OpenAIService struct {
-	APIKey string
+	Menu   LLMCallSite // AnalyzeImages
+	Stream LLMCallSite // ChatStreamWithTools
+	Text   LLMCallSite // Chat
+	Vision LLMCallSite // ChatWithVision, ChatWithVisionAndData
 }
 
-// NewOpenAIService creates a new instance of OpenAIService
+// NewOpenAIService creates a new instance of OpenAIService configured from the LLM_* environment
 func NewOpenAIService() *OpenAIService {
 	return &OpenAIService{
-		APIKey: environment.GetOpenAIKey(),
+		Menu:   newLLMCallSite("MENU"),
+		Stream: newLLMCallSite("STREAM"),
+		Text:   newLLMCallSite("TEXT"),
+		Vision: newLLMCallSite("VISION"),
+	}
+}
+
+// NewOpenAIServiceWithProvider routes every call site to the same provider
+func NewOpenAIServiceWithProvider(provider llm.LLMProvider) *OpenAIService {
+	site := LLMCallSite{Provider: provider, Model: llm.DefaultOpenAIModel}
+	return &OpenAIService{Menu: site, Stream: site, Text: site, Vision: site}
+}
+
+// newLLMCallSite reads LLM_<SITE>_<KEY>, falling back to LLM_<KEY> and then to OpenAI defaults
+func newLLMCallSite(site string) LLMCallSite {
+	cfg := llm.Config{
+		Provider: environment.GetLLMSetting(site, "PROVIDER"),
+		BaseURL:  environment.GetLLMSetting(site, "BASE_URL"),
+		APIKey:   environment.GetLLMSetting(site, "API_KEY"),
+		Model:    environment.GetLLMSetting(site, "MODEL"),
+	}
+	if cfg.Provider == "" || cfg.Provider == llm.ProviderOpenAI {
+		cfg.Provider = llm.ProviderOpenAI
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = environment.GetOpenAIBaseURL()
+		}
+		if cfg.APIKey == "" {
+			cfg.APIKey = environment.GetOpenAIKey()
+		}
+	}
+	if cfg.Model == "" {
+		cfg.Model = llm.DefaultOpenAIModel
+	}
+	if value := environment.GetLLMSetting(site, "TEMPERATURE"); value != "" {
+		temperature, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Printf("[LLM] Ignoring invalid temperature %q for %s: %v", value, site, err)
+		} else {
+			cfg.Temperature = &temperature
+		}
+	}
+	if value := environment.GetLLMSetting(site, "TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("[LLM] Ignoring invalid timeout %q for %s: %v", value, site, err)
+		} else {
+			cfg.Timeout = timeout
+		}
+	}
+
+	provider, err := llm.NewProvider(cfg)
+	if err != nil {
+		log.Printf("[LLM] %v for %s, falling back to OpenAI", err, site)
+		provider = llm.NewOpenAIProvider(environment.GetOpenAIKey(), environment.GetOpenAIBaseURL())
+	}
+
+	return LLMCallSite{
+		Provider:    provider,
+		Model:       cfg.Model,
+		Temperature: cfg.Temperature,
+		Timeout:     cfg.Timeout,
 	}
 }
 
@@ -87,99 +167,27 @@ func (s *OpenAIService) AnalyzeImages(ctx context.Context, imageUrls []string) (
 
 	prompt := "Analyze these images and return a structured JSON output of the menu. Generate submenu categories dynamically. Each menu item should include a name and estimated price. If any item is unclear, return 'N/A'."
 
-	var content []map[string]interface{}
-	content = append(content, map[string]interface{}{"type": "text", "text": prompt})
-	for _, img := range encodedImages {
-		content = append(content, map[string]interface{}{"type": "image_url", "image_url": map[string]string{"url": img}})
-	}
-
-	url := "https://api.openai.com/v1/chat/completions"
-	payload := map[string]interface{}{
-		"model": "gpt-4o",
-		"messages": []map[string]interface{}{
-			{
-				"role": "system",
-				"content": `You are an AI assistant that analyzes images of food menus and returns a structured JSON output. Your response must follow this format:
-
-{
-  "halal_status": "halal", // or "haram"
-  "menu": [
-    {
-      "sub_menu": "Generated category based on analysis",
-      "menu_list": [
-        { "name": "Dish name or 'N/A' if unclear", "price": 0 }
-      ]
-    }
-  ]
-}
-
-Rules:
-1. Extract menu items and group them into relevant submenu categories like 'Makanan Berat', 'Minuman Dingin', etc.
-2. Convert all price formats into integer values in Indonesian Rupiah (IDR). Examples:
-   - '5K' ➝ 5000
-   - 'IDR 2K' ➝ 2000
-   - 'Rp 10.500' ➝ 10500
-3. If price is unclear or missing, return 0.
-4. Determine halal_status based on whether any item likely contains haram ingredients (e.g., pork, bacon, lard, alcohol).
-   - If any haram food is found, set "halal_status": "haram".
-   - Otherwise, set "halal_status": "halal".
-5. Do not include any explanation outside the JSON response.`,
-			},
-			{
-				"role":    "user",
-				"content": content,
-			},
+	req := s.Menu.request(
+		llm.Message{
+			Role:    llm.RoleSystem,
+			Content: menuAnalysisPrompt,
 		},
-	}
-
-	jsonData, _ := json.Marshal(payload)
-	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	req.Header.Set("Authorization", "Bearer "+s.APIKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// ✅ FIX: Check if API response is valid
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	body, _ := io.ReadAll(resp.Body)
-	// fmt.Println("Raw API Response:", string(body)) // ✅ Debugging
-	logger.Printf("Raw API Response: %s", string(body))
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %w", err)
-	}
-
-	if len(result.Choices) == 0 {
-		return nil, errors.New("no valid response received")
-	}
-
-	fmt.Println("Response Content:", result.Choices[0].Message.Content) // ✅ Debugging
-
-	cleanedJSON := cleanJSONResponse(result.Choices[0].Message.Content)
-
-	fmt.Println("Cleaned JSON:", cleanedJSON) // Debugging
+		llm.Message{
+			Role:    llm.RoleUser,
+			Content: prompt,
+			Images:  encodedImages,
+		},
+	)
+	req.ResponseFormat = menuAnalysisSchema
 
 	var aiResponse models.AIResponsAnalyzeMenu
-	if err := json.Unmarshal([]byte(cleanedJSON), &aiResponse); err != nil {
-		fmt.Println("Error:", err)
+	if err := llm.CompleteStructured(ctx, s.Menu.Provider.CompleteVision, req, &aiResponse, maxStructuredRepairs); err != nil {
+		logger.Printf("Menu analysis failed: %v", err)
 		return nil, err
 	}
+	if aiResponse.HaramItems == nil {
+		aiResponse.HaramItems = []string{}
+	}
 
 	// Print the parsed data
 	fmt.Println("Halal Status:", aiResponse.HalalStatus)
@@ -193,395 +201,114 @@ Rules:
 	return &aiResponse, nil
 }
 
-func cleanJSONResponse(response string) string {
-	// Remove markdown code block markers like ```json and ```
-	re := regexp.MustCompile("(?s)```(?:json)?(.*?)```")
-	cleaned := re.ReplaceAllString(response, "$1")
-
-	// Trim unnecessary whitespace
-	return strings.TrimSpace(cleaned)
+// ChatStreamWithTools streams an answer to the conversation, the model may call tools instead of answering (caller must close the stream)
+func (s *OpenAIService) ChatStreamWithTools(ctx context.Context, messages []llm.Message, tools []llm.Tool) (llm.CompletionStream, error) {
+	req := s.Stream.request(messages...)
+	req.Tools = tools
+	return s.Stream.Provider.CompleteStream(ctx, req)
 }
 
-// ChatStream sends a request to OpenAI's API and returns a streaming response
-func (s *OpenAIService) ChatStream(ctx context.Context, systemPrompt string, userPrompt string) (io.ReadCloser, error) {
-	url := "https://api.openai.com/v1/chat/completions"
-	payload := map[string]interface{}{
-		"model": "gpt-4o",
-		"messages": []map[string]interface{}{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": userPrompt},
-		},
-		"stream": true, // Enable streaming mode
+// SummarizeConversation asks the text call site to fold messages into the summary of the earlier conversation
+func (s *OpenAIService) SummarizeConversation(ctx context.Context, summary string, messages []llm.Message) (string, error) {
+	var transcript strings.Builder
+	if summary != "" {
+		transcript.WriteString("Current summary:\n" + summary + "\n\n")
 	}
-
-	jsonData, _ := json.Marshal(payload)
-	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	req.Header.Set("Authorization", "Bearer "+s.APIKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
+	transcript.WriteString("New messages:\n")
+	for _, message := range messages {
+		transcript.WriteString(message.Role + ": " + message.Content + "\n")
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		log.Println("OpenAI API error response:", string(body))
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	resp, err := s.Text.Provider.Complete(ctx, s.Text.request(
+		llm.Message{Role: llm.RoleSystem, Content: conversationSummaryPrompt},
+		llm.Message{Role: llm.RoleUser, Content: transcript.String()},
+	))
+	if err != nil {
+		return "", err
 	}
-
-	// Return the response stream (caller must close it)
-	return resp.Body, nil
+	return strings.TrimSpace(resp.Content), nil
 }
 
-// Chat sends a request to OpenAI's API and returns a non-streaming response
-func (s *OpenAIService) Chat(ctx context.Context, systemPrompt string, userPrompt string) (map[string]interface{}, error) {
-	url := "https://api.openai.com/v1/chat/completions"
-	payload := map[string]interface{}{
-		"model": "gpt-4o",
-		"messages": []map[string]interface{}{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": userPrompt},
-		},
-		// No "stream": true
-	}
-
-	jsonData, _ := json.Marshal(payload)
-	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	req.Header.Set("Authorization", "Bearer "+s.APIKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// RoomTitle asks the text call site for a short title summarizing the first prompt of a room
+func (s *OpenAIService) RoomTitle(ctx context.Context, prompt string) (string, error) {
+	resp, err := s.Text.Provider.Complete(ctx, s.Text.request(
+		llm.Message{Role: llm.RoleSystem, Content: roomTitlePrompt},
+		llm.Message{Role: llm.RoleUser, Content: prompt},
+	))
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Println("OpenAI API error response:", string(body))
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
-	}
-
-	if len(result.Choices) == 0 {
-		return nil, fmt.Errorf("no choices returned in response")
+		return "", err
 	}
+	return resp.Content, nil
+}
 
-	// Clean markdown if needed
-	cleaned := strings.TrimSpace(result.Choices[0].Message.Content)
-	if strings.HasPrefix(cleaned, "```json") {
-		cleaned = strings.TrimPrefix(cleaned, "```json")
-		cleaned = strings.TrimSuffix(cleaned, "```")
-		cleaned = strings.TrimSpace(cleaned)
-	}
+// SnackVerdict asks the text call site for a schema-validated halal verdict
+func (s *OpenAIService) SnackVerdict(ctx context.Context, systemPrompt string, userPrompt string) (*models.SnackVerdict, error) {
+	req := s.Text.request(
+		llm.Message{Role: llm.RoleSystem, Content: systemPrompt},
+		llm.Message{Role: llm.RoleUser, Content: userPrompt},
+	)
+	req.ResponseFormat = snackVerdictSchema
 
-	var parsed map[string]interface{}
-	if err := json.Unmarshal([]byte(cleaned), &parsed); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	var verdict models.SnackVerdict
+	if err := llm.CompleteStructured(ctx, s.Text.Provider.Complete, req, &verdict, maxStructuredRepairs); err != nil {
+		return nil, err
 	}
 
-	return parsed, nil
+	return &verdict, nil
 }
 
-func (s *OpenAIService) ChatWithVision(ctx context.Context, systemPrompt string, base64Images []string) (map[string]interface{}, error) {
-	url := "https://api.openai.com/v1/chat/completions"
-
-	var imageMessages []map[string]interface{}
-	for _, b64 := range base64Images {
-		imageMessages = append(imageMessages, map[string]interface{}{
-			"type": "image_url",
-			"image_url": map[string]interface{}{
-				"url": b64,
-			},
-		})
-	}
-
-	var userContent []interface{}
+// SnackVerdictFromImages analyzes the front (and optionally back) of a packaging
+func (s *OpenAIService) SnackVerdictFromImages(ctx context.Context, systemPrompt string, base64Images []string) (*models.SnackVerdict, error) {
+	var userMessage llm.Message
 
 	// Determine prompt based on image count
 	if len(base64Images) == 1 {
-		userContent = []interface{}{
-			map[string]string{
-				"type": "text",
-				"text": `Kamu adalah pakar analisis kehalalan makanan.
-
-Kamu diberikan **foto kemasan bagian depan** dari sebuah produk makanan. Gambar ini biasanya memuat **nama produk, brand/logo, dan visual tampilan kemasan**.
-
-Tugasmu:
-1. Identifikasi **nama produk** dan brand dari gambar depan.
-2. Berdasarkan informasi tersebut, **cari data komposisi bahan dari internet**.
-3. Analisis status kehalalan produk berdasarkan bahan-bahan tersebut. Fokus pada:
-   - Daging babi dan turunannya
-   - Alkohol atau bahan hasil fermentasi alkohol
-   - Gelatin, enzim, dan bahan hewani yang tidak jelas
-   - Bahan sintetis atau kimia yang diragukan (misalnya E-codes)
-4. Jika tidak bisa menemukan informasi bahan, jawab "Tidak Dapat Menentukan".
-
-Catatan penting:
-- Jangan hanya mengandalkan label halal pada kemasan.
-- Jika ada keraguan terhadap bahan, anggap sebagai "Tidak Dapat Menentukan".
-- Balasan **HARUS** dalam bentuk **JSON valid dan murni (tanpa markdown)**:
-
-{
-  "Status": "Halal" | "Haram" | "Tidak Dapat Menentukan",
-  "Reason": "Alasan singkat dan jelas",
-  "ProductName": "Nama produk",
-  "Suggest": [
-    {
-      "NamaSugestProduk": "Alternatif halal (jika produk haram)"
-    }
-  ]
-}
-
-Ketentuan tambahan:
-- Jika Status = "Halal", maka "Suggest": []
-- Jika Status = "Haram", berikan 1-3 alternatif halal yang tersedia di Indonesia
-- Jika Status = "Tidak Dapat Menentukan", maka "Suggest": []
-`,
-			},
-			imageMessages[0],
+		userMessage = llm.Message{
+			Role:    llm.RoleUser,
+			Content: snackFrontPrompt,
+			Images:  base64Images[:1],
 		}
-
 	} else if len(base64Images) >= 2 {
-		userContent = []interface{}{
-			map[string]string{
-				"type": "text",
-				"text": `Kamu adalah pakar analisis kehalalan makanan.
-
-Berikut dua gambar kemasan produk:
-1. Gambar depan: berisi nama dan tampilan produk.
-2. Gambar belakang: berisi daftar bahan, komposisi, dan informasi gizi.
-
-Tugasmu:
-- Identifikasi nama produk dari gambar depan.
-- Ambil semua informasi bahan dari gambar belakang.
-- Analisis kehalalan produk berdasarkan bahan-bahan tersebut. Fokus pada:
-  - Daging babi dan turunannya
-  - Alkohol atau bahan hasil fermentasi alkohol
-  - Gelatin, enzim, dan bahan hewani yang tidak jelas
-  - Bahan sintetis atau kimia yang diragukan (misalnya E-codes)
-
-Aturan penting:
-- Label halal hanya jadi pendukung, bukan bukti utama.
-- Jika gambar tidak cukup jelas untuk menentukan, jawab "Tidak Dapat Menentukan".
-- Balas HANYA dalam format JSON valid dan murni (tidak ada markdown atau penjelasan lain):
-
-{
-  "Status": "Halal" | "Haram" | "Tidak Dapat Menentukan",
-  "Reason": "Alasan singkat dan jelas",
-  "ProductName": "Nama produk",
-  "Suggest": [
-    {
-      "NamaSugestProduk": "Alternatif halal (jika produk haram)"
-    }
-  ]
-}
-
-Catatan:
-- Jika status = "Halal", maka "Suggest": []
-- Jika status = "Haram", beri 1-3 alternatif halal yang tersedia di Indonesia
-- Jika "Tidak Dapat Menentukan", maka "Suggest": []`,
-			},
-			imageMessages[0], // Gambar depan kemasan
-			imageMessages[1], // Gambar belakang kemasan
+		userMessage = llm.Message{
+			Role:    llm.RoleUser,
+			Content: snackFrontBackPrompt,
+			Images:  base64Images[:2], // Gambar depan dan belakang kemasan
 		}
-
 	} else {
 		return nil, fmt.Errorf("no images provided")
 	}
 
-	messages := []map[string]interface{}{
-		{
-			"role":    "system",
-			"content": systemPrompt,
-		},
-		{
-			"role":    "user",
-			"content": userContent,
-		},
-	}
-
-	payload := map[string]interface{}{
-		"model":    "gpt-4o", // or "gpt-4-vision-preview"
-		"messages": messages,
-	}
-
-	jsonData, _ := json.Marshal(payload)
-	fmt.Println("Payload:", string(jsonData))
-
-	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	req.Header.Set("Authorization", "Bearer "+s.APIKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println("Error sending request:", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		fmt.Println("API Error Response:", string(body))
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Println("Raw API Response:", string(body))
-
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
-	}
-
-	if len(result.Choices) == 0 {
-		return nil, fmt.Errorf("no choices returned in response")
-	}
-
-	cleaned := strings.TrimSpace(result.Choices[0].Message.Content)
-	if strings.HasPrefix(cleaned, "```json") {
-		cleaned = strings.TrimPrefix(cleaned, "```json")
-		cleaned = strings.TrimSuffix(cleaned, "```")
-		cleaned = strings.TrimSpace(cleaned)
-	}
-
-	var parsed map[string]interface{}
-	if err := json.Unmarshal([]byte(cleaned), &parsed); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %w", err)
-	}
-
-	return parsed, nil
+	return s.snackVerdictWithVision(ctx, systemPrompt, userMessage)
 }
 
-func (s *OpenAIService) ChatWithVisionAndData(ctx context.Context, systemPrompt string, base64Images []string, userPrompt string) (map[string]interface{}, error) {
-	url := "https://api.openai.com/v1/chat/completions"
-
-	// Prepare image messages
-	var imageMessages []map[string]interface{}
-	for _, b64 := range base64Images {
-		imageMessages = append(imageMessages, map[string]interface{}{
-			"type": "image_url",
-			"image_url": map[string]interface{}{
-				"url": b64,
-			},
-		})
-	}
-
-	if len(imageMessages) == 0 {
+// SnackVerdictFromImagesAndData analyzes packaging images together with extra text such as barcode data
+func (s *OpenAIService) SnackVerdictFromImagesAndData(ctx context.Context, systemPrompt string, base64Images []string, userPrompt string) (*models.SnackVerdict, error) {
+	if len(base64Images) == 0 {
 		return nil, fmt.Errorf("no images provided")
 	}
 
-	// Construct user content with both text prompt + images
-	userContent := []interface{}{
-		map[string]interface{}{
-			"type": "text",
-			"text": userPrompt,
-		},
-	}
-	for _, img := range imageMessages {
-		userContent = append(userContent, img)
-	}
-
 	// Log prompts and image count
 	log.Println("[OpenAI] System Prompt:", systemPrompt)
 	log.Println("[OpenAI] User Prompt:", userPrompt)
 	log.Printf("[OpenAI] Sending %d image(s)\n", len(base64Images))
 
-	// Build messages payload
-	messages := []map[string]interface{}{
-		{
-			"role":    "system",
-			"content": systemPrompt,
-		},
-		{
-			"role":    "user",
-			"content": userContent,
-		},
-	}
-
-	payload := map[string]interface{}{
-		"model":    "gpt-4o",
-		"messages": messages,
-	}
-
-	jsonData, _ := json.Marshal(payload)
-	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	req.Header.Set("Authorization", "Bearer "+s.APIKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		log.Println("OpenAI API error response:", string(body))
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	log.Println("[OpenAI] Raw Response Body:", string(body))
-
-	// Parse response
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
-	}
-
-	if len(result.Choices) == 0 {
-		return nil, fmt.Errorf("no choices returned in response")
-	}
-
-	// Extract and clean content
-	cleaned := strings.TrimSpace(result.Choices[0].Message.Content)
-	if strings.HasPrefix(cleaned, "```json") {
-		cleaned = strings.TrimPrefix(cleaned, "```json")
-		cleaned = strings.TrimSuffix(cleaned, "```")
-		cleaned = strings.TrimSpace(cleaned)
-	}
+	return s.snackVerdictWithVision(ctx, systemPrompt, llm.Message{Role: llm.RoleUser, Content: userPrompt, Images: base64Images})
+}
 
-	log.Println("[OpenAI] Cleaned Response Content:", cleaned)
+func (s *OpenAIService) snackVerdictWithVision(ctx context.Context, systemPrompt string, userMessage llm.Message) (*models.SnackVerdict, error) {
+	req := s.Vision.request(
+		llm.Message{Role: llm.RoleSystem, Content: systemPrompt},
+		userMessage,
+	)
+	req.ResponseFormat = snackVerdictSchema
 
-	var parsed map[string]interface{}
-	if err := json.Unmarshal([]byte(cleaned), &parsed); err != nil {
-		log.Println("[OpenAI] JSON Unmarshal Error:", err)
-		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	var verdict models.SnackVerdict
+	if err := llm.CompleteStructured(ctx, s.Vision.Provider.CompleteVision, req, &verdict, maxStructuredRepairs); err != nil {
+		log.Println("[OpenAI] Snack verdict failed:", err)
+		return nil, err
 	}
 
-	log.Println("[OpenAI] Parsed Response JSON:", parsed)
+	log.Printf("[OpenAI] Snack verdict: %+v\n", verdict)
 
-	return parsed, nil
+	return &verdict, nil
 }
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"HalalMate/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxReportCommentLength = 1000
+	// maxReportMenuItemLength keeps menu items as short as the dish names scanned from menus
+	maxReportMenuItemLength = 100
+)
+
+// errReportAlreadyReviewed aborts the update of a report that left the queue in the meantime
+var errReportAlreadyReviewed = errors.New("report already reviewed")
+
+// ReportInput is what a user sends when reporting a restaurant
+type ReportInput struct {
+	Type            string
+	SuggestedStatus string
+	MenuItem        string
+	Comment         string
+	PhotoURL        string
+}
+
+// ReportService stores community reports in "restaurant_reports" and applies the accepted ones
+type ReportService struct {
+	Reports       repositories.ReportRepository
+	Restaurants   repositories.RestaurantRepository
+	Users         repositories.UserRepository
+	Notifications *NotificationService
+}
+
+// NewReportService initializes ReportService with the default store
+func NewReportService() *ReportService {
+	return NewReportServiceWithStore(repositories.GetStore())
+}
+
+// NewReportServiceWithStore initializes ReportService with the given repositories
+func NewReportServiceWithStore(store *repositories.Store) *ReportService {
+	return &ReportService{
+		Reports:       store.Reports,
+		Restaurants:   store.Restaurants,
+		Users:         store.Users,
+		Notifications: NewNotificationServiceWithStore(store),
+	}
+}
+
+// CreateReport queues a report for moderation. Only users with a verified email can report, and only
+// once per type and restaurant while the report is pending.
+func (s *ReportService) CreateReport(ctx context.Context, userID, restaurantID string, input ReportInput) (*models.RestaurantReport, error) {
+	if err := validateReportInput(&input); err != nil {
+		return nil, err
+	}
+
+	user, err := s.Users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, utils.NewCustomError(http.StatusNotFound, "User not found")
+		}
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get user")
+	}
+	if !user.EmailVerified {
+		return nil, utils.NewCustomError(http.StatusForbidden, "Verify your email before reporting a restaurant")
+	}
+
+	if _, err := s.Restaurants.GetByID(ctx, restaurantID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, utils.NewCustomError(http.StatusNotFound, "Restaurant not found")
+		}
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get restaurant")
+	}
+
+	pending, err := s.Reports.Find(ctx, repositories.ReportQuery{
+		RestaurantID: restaurantID,
+		UserID:       userID,
+		Status:       models.ReportPending,
+		Type:         input.Type,
+	})
+	if err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to check existing reports")
+	}
+	if len(pending) > 0 {
+		return nil, utils.NewCustomError(http.StatusConflict, "You already reported this restaurant, wait for a moderator")
+	}
+
+	report := &models.RestaurantReport{
+		RestaurantID:    restaurantID,
+		UserID:          userID,
+		Type:            input.Type,
+		SuggestedStatus: input.SuggestedStatus,
+		MenuItem:        input.MenuItem,
+		Comment:         input.Comment,
+		PhotoURL:        input.PhotoURL,
+		Status:          models.ReportPending,
+	}
+	if err := s.Reports.Create(ctx, report); err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to save report")
+	}
+	return report, nil
+}
+
+func validateReportInput(input *ReportInput) error {
+	input.Comment = strings.TrimSpace(input.Comment)
+	input.PhotoURL = strings.TrimSpace(input.PhotoURL)
+	input.MenuItem = strings.TrimSpace(input.MenuItem)
+
+	if !models.IsReportType(input.Type) {
+		return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("type must be one of %s", strings.Join(models.ReportTypes, ", ")))
+	}
+	if input.Type == models.ReportTypeWrongStatus {
+		if !models.IsRestaurantStatus(input.SuggestedStatus) {
+			return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("suggested_status must be one of %s", strings.Join(models.RestaurantStatuses, ", ")))
+		}
+	} else {
+		input.SuggestedStatus = ""
+	}
+	if input.Type != models.ReportTypePorkOnMenu {
+		input.MenuItem = ""
+	}
+	if len(input.MenuItem) > maxReportMenuItemLength || strings.ContainsAny(input.MenuItem, "\r\n") {
+		return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("menu_item must be a single line of at most %d characters", maxReportMenuItemLength))
+	}
+	if len(input.Comment) > maxReportCommentLength {
+		return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("comment must be at most %d characters", maxReportCommentLength))
+	}
+	if input.PhotoURL != "" && !isWebURL(input.PhotoURL) {
+		return utils.NewCustomError(http.StatusBadRequest, "photo_url must be an http or https URL")
+	}
+	return nil
+}
+
+// ListUserReports returns the reports sent by a user, oldest first
+func (s *ReportService) ListUserReports(ctx context.Context, userID string) ([]*models.RestaurantReport, error) {
+	reports, err := s.Reports.Find(ctx, repositories.ReportQuery{UserID: userID})
+	if err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get reports")
+	}
+	return reports, nil
+}
+
+// ListReports returns the moderation queue, pending reports unless another status is asked for
+func (s *ReportService) ListReports(ctx context.Context, status string) ([]*models.RestaurantReport, error) {
+	if status == "" {
+		status = models.ReportPending
+	}
+	if status != models.ReportPending && status != models.ReportAccepted && status != models.ReportRejected {
+		return nil, utils.NewCustomError(http.StatusBadRequest, "status must be one of pending, accepted, rejected")
+	}
+
+	reports, err := s.Reports.Find(ctx, repositories.ReportQuery{Status: status})
+	if err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get reports")
+	}
+	return reports, nil
+}
+
+// ModerateReport accepts or rejects a pending report. Accepting re-evaluates the restaurant,
+// which is returned along with the report; it is nil for rejected reports.
+func (s *ReportService) ModerateReport(ctx context.Context, moderatorID, reportID string, accept bool, note string) (*models.RestaurantReport, *models.Restaurant, error) {
+	report, err := s.Reports.Update(ctx, reportID, func(report *models.RestaurantReport) error {
+		if report.Status != models.ReportPending {
+			return errReportAlreadyReviewed
+		}
+
+		now := time.Now()
+		report.Status = models.ReportRejected
+		if accept {
+			report.Status = models.ReportAccepted
+		}
+		report.ModeratorID = moderatorID
+		report.ModeratorNote = strings.TrimSpace(note)
+		report.ReviewedAt = &now
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrNotFound):
+			return nil, nil, utils.NewCustomError(http.StatusNotFound, "Report not found")
+		case errors.Is(err, errReportAlreadyReviewed):
+			return nil, nil, utils.NewCustomError(http.StatusConflict, "Report was already reviewed")
+		}
+		return nil, nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to update report")
+	}
+
+	if !accept {
+		return report, nil, nil
+	}
+
+	restaurant, err := s.Reevaluate(ctx, report.RestaurantID)
+	if err != nil {
+		log.Printf("Failed to re-evaluate restaurant %s after report %s: %v", report.RestaurantID, report.ID, err)
+		// The report goes back to the queue, so accepting it again applies it
+		s.reopenReport(context.WithoutCancel(ctx), moderatorID, reportID)
+		return nil, nil, utils.NewCustomError(http.StatusInternalServerError, "The restaurant could not be updated, the report is still pending")
+	}
+	return report, restaurant, nil
+}
+
+// reopenReport moves a report the moderator just accepted back to pending
+func (s *ReportService) reopenReport(ctx context.Context, moderatorID, reportID string) {
+	_, err := s.Reports.Update(ctx, reportID, func(report *models.RestaurantReport) error {
+		if report.Status != models.ReportAccepted || report.ModeratorID != moderatorID {
+			return errReportAlreadyReviewed
+		}
+		report.Status = models.ReportPending
+		report.ModeratorID = ""
+		report.ModeratorNote = ""
+		report.ReviewedAt = nil
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to reopen report %s: %v", reportID, err)
+	}
+}
+
+// Reevaluate recomputes the status of a restaurant from its accepted reports. The users who
+// bookmarked the restaurant are notified when its status changes.
+func (s *ReportService) Reevaluate(ctx context.Context, restaurantID string) (*models.Restaurant, error) {
+	reports, err := s.Reports.Find(ctx, repositories.ReportQuery{
+		RestaurantID: restaurantID,
+		Status:       models.ReportAccepted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var previousStatus string
+	restaurant, err := s.Restaurants.Update(ctx, restaurantID, func(restaurant *models.Restaurant) error {
+		previousStatus = restaurant.Status
+		applyAcceptedReports(restaurant, reports)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if restaurant.Status != previousStatus {
+		s.Notifications.NotifyRestaurantStatusChanged(ctx, restaurant, previousStatus)
+	}
+	return restaurant, nil
+}
+
+// reportStatus returns the halal status a report argues for, empty for reports that do not touch the status
+func reportStatus(report *models.RestaurantReport) string {
+	switch report.Type {
+	case models.ReportTypeWrongStatus:
+		return report.SuggestedStatus
+	case models.ReportTypePorkOnMenu:
+		return models.RestaurantStatusHaram
+	case models.ReportTypeCertificateSeen:
+		return models.RestaurantStatusHalal
+	}
+	return ""
+}
+
+// applyAcceptedReports updates the restaurant from its accepted reports, oldest first.
+// The most recent report about the status decides it; every earlier report agreeing with it
+// raises the confidence, and a seen certificate is trusted the most.
+func applyAcceptedReports(restaurant *models.Restaurant, reports []*models.RestaurantReport) {
+	var latest *models.RestaurantReport
+	for _, report := range reports {
+		if report.Type == models.ReportTypeClosed {
+			restaurant.Closed = true
+		}
+		if reportStatus(report) != "" {
+			latest = report
+		}
+	}
+	if latest == nil {
+		return
+	}
+
+	status := reportStatus(latest)
+	agreeing := 0
+	var offending []string
+	for _, report := range reports {
+		if reportStatus(report) != status {
+			continue
+		}
+		agreeing++
+		// Only the dish named by the reporter is a menu item, the comment is free text
+		if report.Type == models.ReportTypePorkOnMenu && report.MenuItem != "" {
+			offending = append(offending, report.MenuItem)
+		}
+	}
+
+	source := models.VerdictSourceUserReport
+	confidence := math.Min(0.5+0.15*float64(agreeing), 0.9)
+	if latest.Type == models.ReportTypeCertificateSeen {
+		source = models.VerdictSourceCertificate
+		confidence = 0.95
+	}
+
+	restaurant.Verdict = models.NewHalalVerdict(status, source, confidence, fmt.Sprintf("%d accepted community report(s)", agreeing), offending)
+	restaurant.Status = status
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestApplyAcceptedReports(t *testing.T) {
+	wrongStatus := func(status string) *models.RestaurantReport {
+		return &models.RestaurantReport{Type: models.ReportTypeWrongStatus, SuggestedStatus: status}
+	}
+	pork := func(menuItem string) *models.RestaurantReport {
+		return &models.RestaurantReport{Type: models.ReportTypePorkOnMenu, MenuItem: menuItem, Comment: "Saw it next to the counter"}
+	}
+	closed := &models.RestaurantReport{Type: models.ReportTypeClosed}
+	certificate := &models.RestaurantReport{Type: models.ReportTypeCertificateSeen}
+
+	tests := []struct {
+		name       string
+		reports    []*models.RestaurantReport
+		closed     bool
+		status     string
+		source     string
+		confidence float64
+		offending  []string
+	}{
+		{
+			name:    "closed only",
+			reports: []*models.RestaurantReport{closed},
+			closed:  true,
+			status:  models.RestaurantStatusUnknown,
+		},
+		{
+			name:       "pork on the menu",
+			reports:    []*models.RestaurantReport{pork("Babi panggang")},
+			status:     models.RestaurantStatusHaram,
+			source:     models.VerdictSourceUserReport,
+			confidence: 0.65,
+			offending:  []string{"Babi panggang"},
+		},
+		{
+			name:       "comment is not a menu item",
+			reports:    []*models.RestaurantReport{{Type: models.ReportTypePorkOnMenu, Comment: "saw babi panggang next to the counter"}},
+			status:     models.RestaurantStatusHaram,
+			source:     models.VerdictSourceUserReport,
+			confidence: 0.65,
+			offending:  []string{},
+		},
+		{
+			name:       "latest report decides",
+			reports:    []*models.RestaurantReport{pork("Babi panggang"), wrongStatus(models.RestaurantStatusHalal)},
+			status:     models.RestaurantStatusHalal,
+			source:     models.VerdictSourceUserReport,
+			confidence: 0.65,
+			offending:  []string{},
+		},
+		{
+			name:       "agreeing reports raise the confidence",
+			reports:    []*models.RestaurantReport{wrongStatus(models.RestaurantStatusSyubhat), closed, wrongStatus(models.RestaurantStatusSyubhat)},
+			closed:     true,
+			status:     models.RestaurantStatusSyubhat,
+			source:     models.VerdictSourceUserReport,
+			confidence: 0.8,
+			offending:  []string{},
+		},
+		{
+			name:       "confidence is capped",
+			reports:    []*models.RestaurantReport{pork(""), pork("Lapchiong"), pork(""), pork("Char siu")},
+			status:     models.RestaurantStatusHaram,
+			source:     models.VerdictSourceUserReport,
+			confidence: 0.9,
+			offending:  []string{"Lapchiong", "Char siu"},
+		},
+		{
+			name:       "certificate seen last",
+			reports:    []*models.RestaurantReport{pork("Babi panggang"), certificate},
+			status:     models.RestaurantStatusHalal,
+			source:     models.VerdictSourceCertificate,
+			confidence: 0.95,
+			offending:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restaurant := &models.Restaurant{Status: models.RestaurantStatusUnknown}
+			applyAcceptedReports(restaurant, tt.reports)
+
+			if restaurant.Closed != tt.closed {
+				t.Errorf("Closed = %v, want %v", restaurant.Closed, tt.closed)
+			}
+			if restaurant.Status != tt.status {
+				t.Errorf("Status = %q, want %q", restaurant.Status, tt.status)
+			}
+			if tt.source == "" {
+				if restaurant.Verdict != nil {
+					t.Errorf("Verdict = %+v, want none", restaurant.Verdict)
+				}
+				return
+			}
+
+			verdict := restaurant.Verdict
+			if verdict == nil {
+				t.Fatal("Verdict is nil")
+			}
+			if verdict.Status != tt.status || verdict.Source != tt.source {
+				t.Errorf("Verdict = %s from %s, want %s from %s", verdict.Status, verdict.Source, tt.status, tt.source)
+			}
+			if math.Abs(verdict.Confidence-tt.confidence) > 1e-9 {
+				t.Errorf("Confidence = %v, want %v", verdict.Confidence, tt.confidence)
+			}
+			if !reflect.DeepEqual(verdict.OffendingItems, tt.offending) {
+				t.Errorf("OffendingItems = %q, want %q", verdict.OffendingItems, tt.offending)
+			}
+		})
+	}
+}
+
+func TestModerateReportReopensOnFailedReevaluation(t *testing.T) {
+	ctx := context.Background()
+	reports := repositories.NewMemoryReportRepository()
+	// The restaurant is missing, so re-evaluating it fails
+	s := &ReportService{Reports: reports, Restaurants: repositories.NewMemoryRestaurantRepository()}
+	report := &models.RestaurantReport{ID: "report-1", RestaurantID: "gone", Type: models.ReportTypeClosed, Status: models.ReportPending}
+	if err := reports.Create(ctx, report); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.ModerateReport(ctx, "moderator-1", report.ID, true, "Confirmed"); err == nil {
+		t.Fatal("accepting the report succeeded")
+	}
+
+	got, err := reports.Get(ctx, report.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.ReportPending || got.ModeratorID != "" || got.ModeratorNote != "" || got.ReviewedAt != nil {
+		t.Errorf("report = %+v, want it back to pending", got)
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"HalalMate/models"
+	"HalalMate/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Values of RestaurantSearch.Sort
+const (
+	RestaurantSortDistance    = "distance"
+	RestaurantSortRating      = "rating"
+	RestaurantSortReviewCount = "review_count"
+)
+
+const (
+	DefaultRestaurantPageSize = 20
+	MaxRestaurantPageSize     = 100
+	MaxSearchRadiusKm         = 50.0
+)
+
+// RestaurantSearch filters, sorts and pages the halal restaurants around a location. Empty filters are ignored.
+type RestaurantSearch struct {
+	Latitude   float64
+	Longitude  float64
+	RadiusKm   float64
+	Category   string
+	MinRating  float64
+	PriceRange string
+	// OpenNow keeps the restaurants whose scraped opening status was open
+	OpenNow bool
+	// Query matches part of the title, case insensitive
+	Query string
+	// Sort is one of the RestaurantSort values, distance by default
+	Sort string
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+	Limit  int
+}
+
+// RestaurantPage is one page of a restaurant search
+type RestaurantPage struct {
+	Restaurants []models.Restaurant `json:"restaurants"`
+	// NextCursor is empty on the last page
+	NextCursor string `json:"next_cursor"`
+}
+
+// restaurantCursor marks the last restaurant of a page by its sort value and id
+type restaurantCursor struct {
+	// Search is the fingerprint of the search the cursor was issued for
+	Search string  `json:"q"`
+	Value  float64 `json:"v"`
+	ID     string  `json:"id"`
+}
+
+// SearchRestaurants returns one page of the halal restaurants matching search
+func (s *RestaurantService) SearchRestaurants(ctx context.Context, userId string, search RestaurantSearch) (*RestaurantPage, error) {
+	if err := normalizeRestaurantSearch(&search); err != nil {
+		return nil, err
+	}
+
+	// A cursor only continues the search it was issued for, another location or filter would skip results
+	fingerprint := restaurantSearchFingerprint(&search)
+	var after *restaurantCursor
+	if search.Cursor != "" {
+		cursor, err := decodeRestaurantCursor(search.Cursor)
+		if err != nil || cursor.Search != fingerprint {
+			return nil, utils.NewCustomError(http.StatusBadRequest, "Invalid cursor")
+		}
+		after = cursor
+	}
+
+	// Geohash range queries are ordered by geohash and ratings are stored as text, so Firestore can
+	// neither sort nor limit by distance or rating. Only the status is filtered in the query, the
+	// rest happens here and the bookmarks are only read for the page.
+	nearby, err := s.halalWithinRadius(ctx, search.Latitude, search.Longitude, search.RadiusKm, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	restaurants := make([]models.Restaurant, 0, len(nearby))
+	for _, restaurant := range nearby {
+		if matchesRestaurantSearch(&restaurant, &search) {
+			restaurants = append(restaurants, restaurant)
+		}
+	}
+
+	// Order by the sort value, then by id so restaurants with equal values keep a stable order
+	value := restaurantSortValue(search.Sort)
+	less := func(aValue float64, aID string, bValue float64, bID string) bool {
+		if aValue != bValue {
+			return aValue < bValue
+		}
+		return aID < bID
+	}
+	sort.Slice(restaurants, func(i, j int) bool {
+		return less(value(&restaurants[i]), restaurants[i].ID, value(&restaurants[j]), restaurants[j].ID)
+	})
+
+	// Start right after the cursor, so inserts between pages do not shift the results
+	start := 0
+	if after != nil {
+		start = sort.Search(len(restaurants), func(i int) bool {
+			return less(after.Value, after.ID, value(&restaurants[i]), restaurants[i].ID)
+		})
+	}
+
+	end := start + search.Limit
+	if end > len(restaurants) {
+		end = len(restaurants)
+	}
+
+	page := &RestaurantPage{Restaurants: restaurants[start:end]}
+	if err := s.markBookmarked(ctx, userId, page.Restaurants); err != nil {
+		return nil, err
+	}
+	if end < len(restaurants) {
+		last := &restaurants[end-1]
+		page.NextCursor = encodeRestaurantCursor(restaurantCursor{Search: fingerprint, Value: value(last), ID: last.ID})
+	}
+	return page, nil
+}
+
+// restaurantSearchFingerprint identifies a normalized search without its cursor and limit
+func restaurantSearchFingerprint(search *RestaurantSearch) string {
+	key := fmt.Sprintf("%.6f|%.6f|%g|%s|%g|%s|%t|%s|%s",
+		search.Latitude, search.Longitude, search.RadiusKm,
+		strings.ToLower(search.Category), search.MinRating, strings.ToLower(search.PriceRange),
+		search.OpenNow, strings.ToLower(search.Query), search.Sort)
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// normalizeRestaurantSearch applies the defaults and rejects out of range values
+func normalizeRestaurantSearch(search *RestaurantSearch) error {
+	if search.RadiusKm == 0 {
+		search.RadiusKm = DefaultSearchRadiusKm
+	}
+	if search.RadiusKm < 0 || search.RadiusKm > MaxSearchRadiusKm {
+		return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("radius must be between 0 and %.0f km", MaxSearchRadiusKm))
+	}
+
+	if search.Limit == 0 {
+		search.Limit = DefaultRestaurantPageSize
+	}
+	if search.Limit < 0 || search.Limit > MaxRestaurantPageSize {
+		return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", MaxRestaurantPageSize))
+	}
+
+	if search.MinRating < 0 || search.MinRating > 5 {
+		return utils.NewCustomError(http.StatusBadRequest, "min_rating must be between 0 and 5")
+	}
+
+	switch search.Sort {
+	case "":
+		search.Sort = RestaurantSortDistance
+	case RestaurantSortDistance, RestaurantSortRating, RestaurantSortReviewCount:
+	default:
+		return utils.NewCustomError(http.StatusBadRequest, "sort must be one of distance, rating, review_count")
+	}
+	return nil
+}
+
+func matchesRestaurantSearch(restaurant *models.Restaurant, search *RestaurantSearch) bool {
+	if search.Category != "" && !strings.EqualFold(strings.TrimSpace(restaurant.Category), search.Category) {
+		return false
+	}
+	if search.PriceRange != "" && !strings.EqualFold(strings.TrimSpace(restaurant.PriceRange), search.PriceRange) {
+		return false
+	}
+	if search.MinRating > 0 && restaurant.RatingValue() < search.MinRating {
+		return false
+	}
+	if search.OpenNow && !restaurant.IsOpen() {
+		return false
+	}
+	if search.Query != "" && !strings.Contains(strings.ToLower(restaurant.Title), strings.ToLower(search.Query)) {
+		return false
+	}
+	return true
+}
+
+// restaurantSortValue returns the ascending sort key, rating and review count are negated to list the best first
+func restaurantSortValue(sortBy string) func(restaurant *models.Restaurant) float64 {
+	switch sortBy {
+	case RestaurantSortRating:
+		return func(restaurant *models.Restaurant) float64 { return -restaurant.RatingValue() }
+	case RestaurantSortReviewCount:
+		return func(restaurant *models.Restaurant) float64 { return -float64(restaurant.ReviewCountValue()) }
+	default:
+		return func(restaurant *models.Restaurant) float64 { return restaurant.DistanceKm() }
+	}
+}
+
+func encodeRestaurantCursor(cursor restaurantCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeRestaurantCursor(encoded string) (*restaurantCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor restaurantCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
@@ -1,30 +1,39 @@
 package services
 
 import (
-	"HalalMate/config/database"
 	"HalalMate/models"
+	"HalalMate/repositories"
 	"HalalMate/utils"
 	"context"
 	"fmt"
+	"log"
 	"math"
 	"net/http"
 	"sort"
 	"strings"
+	"sync"
 
-	"cloud.google.com/go/firestore"
 	"github.com/mmcloughlin/geohash"
-	"google.golang.org/api/iterator"
 	"google.golang.org/genproto/googleapis/type/latlng"
 )
 
 type RestaurantService struct {
-	FirestoreClient *firestore.Client
+	Restaurants repositories.RestaurantRepository
+	Bookmarks   repositories.BookmarkRepository
+	Users       repositories.UserRepository
 }
 
-// NewRestaurantService initializes RestaurantService with Firestore and OpenAI service
+// NewRestaurantService initializes RestaurantService with the default store
 func NewRestaurantService() *RestaurantService {
+	return NewRestaurantServiceWithStore(repositories.GetStore())
+}
+
+// NewRestaurantServiceWithStore initializes RestaurantService with the given repositories
+func NewRestaurantServiceWithStore(store *repositories.Store) *RestaurantService {
 	return &RestaurantService{
-		FirestoreClient: database.GetFirestoreClient(),
+		Restaurants: store.Restaurants,
+		Bookmarks:   store.Bookmarks,
+		Users:       store.Users,
 	}
 }
 
@@ -45,23 +54,19 @@ func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 
 //get restaurant by doc id
 
-func (s *RestaurantService) GetRestaurantByIdAndLocation(ctx context.Context, docID string, latitude, longitude float64, userId string) (map[string]interface{}, error) {
-	doc, err := s.FirestoreClient.Collection("restaurants").Doc(docID).Get(ctx)
+func (s *RestaurantService) GetRestaurantByIdAndLocation(ctx context.Context, docID string, latitude, longitude float64, userId string) (*models.Restaurant, error) {
+	restaurant, err := s.Restaurants.GetByID(ctx, docID)
 	if err != nil {
 		return nil, utils.NewCustomError(http.StatusNotFound, "Restaurant not found")
 	}
 
-	restaurant := make(map[string]interface{})
-	doc.DataTo(&restaurant)
-
-	geoPoint, ok := doc.Data()["location"].(*latlng.LatLng)
-	if !ok {
+	if restaurant.Location == nil {
 		return nil, fmt.Errorf("error getting location data")
 	}
 
 	// Calculate distance
-	distance := haversine(latitude, longitude, geoPoint.Latitude, geoPoint.Longitude)
-	restaurant["distance"] = distance
+	distance := haversine(latitude, longitude, restaurant.Location.Latitude, restaurant.Location.Longitude)
+	restaurant.Distance = &distance
 
 	// 🚀 Use batch fetch to check bookmark status
 	bookmarkedMap, err := s.GetBookmarkedRestaurants(ctx, userId, []string{docID})
@@ -69,288 +74,236 @@ func (s *RestaurantService) GetRestaurantByIdAndLocation(ctx context.Context, do
 		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get bookmarks")
 	}
 
-	restaurant["isBookmarked"] = bookmarkedMap[docID]
+	restaurant.IsBookmarked = bookmarkedMap[docID]
 
 	return restaurant, nil
 }
 
-func (s *RestaurantService) GetRestaurantByID(ctx context.Context, docID string) (map[string]interface{}, error) {
-	doc, err := s.FirestoreClient.Collection("restaurants").Doc(docID).Get(ctx)
+func (s *RestaurantService) GetRestaurantByID(ctx context.Context, docID string) (*models.Restaurant, error) {
+	restaurant, err := s.Restaurants.GetByID(ctx, docID)
 	if err != nil {
 		return nil, utils.NewCustomError(http.StatusNotFound, "Restaurant not found")
 	}
 
-	restaurant := make(map[string]interface{})
-	doc.DataTo(&restaurant)
-
 	return restaurant, nil
 }
 
-func (c *RestaurantService) GetRestaurantsByIDs(ctx context.Context, restaurantIDs []string, latitude, longitude float64) ([]map[string]interface{}, error) {
+// GetRestaurantsByIDs returns the bookmarked restaurants with their distance to the given location
+func (c *RestaurantService) GetRestaurantsByIDs(ctx context.Context, restaurantIDs []string, latitude, longitude float64) ([]models.Restaurant, error) {
 	// Firestore `In` query to fetch all restaurants in one go
-	iter := c.FirestoreClient.Collection("restaurants").Where("id", "in", restaurantIDs).Documents(ctx)
-	var restaurants []map[string]interface{}
-
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-
-		restaurant := make(map[string]interface{})
-		if err := doc.DataTo(&restaurant); err != nil {
-			return nil, err
-		}
+	restaurants, err := c.Restaurants.GetByIDs(ctx, restaurantIDs)
+	if err != nil {
+		return nil, err
+	}
 
-		geoPoint, ok := doc.Data()["location"].(*latlng.LatLng)
-		if !ok {
+	for i := range restaurants {
+		if restaurants[i].Location == nil {
 			return nil, fmt.Errorf("error getting location data")
 		}
 
 		// Calculate distance
-		distance := haversine(latitude, longitude, geoPoint.Latitude, geoPoint.Longitude)
-		restaurant["distance"] = distance
-
-		restaurant["bookmark"] = true
-		restaurants = append(restaurants, restaurant)
+		distance := haversine(latitude, longitude, restaurants[i].Location.Latitude, restaurants[i].Location.Longitude)
+		restaurants[i].Distance = &distance
+		restaurants[i].IsBookmarked = true
 	}
 
 	return restaurants, nil
 }
 
-// function to save restaurant to firestore
-
-func (s *RestaurantService) SaveRestaurant(ctx context.Context, restaurant *models.Place) error {
-	geoHash := geohash.Encode(restaurant.Location.Latitude, restaurant.Location.Longitude)
-	cleanedAddress := strings.TrimPrefix(restaurant.Address, "Alamat: ")
-
-	// Create a new document reference with an auto-generated ID
-	docRef := s.FirestoreClient.Collection("restaurants").NewDoc()
-
-	// Convert GeoLocation to Firestore GeoPoint
-	data := map[string]interface{}{
-		"id":             docRef.ID, // Store the document ID
-		"title":          restaurant.Title,
-		"rating":         restaurant.Rating,
-		"address":        cleanedAddress,
-		"location":       &latlng.LatLng{Latitude: restaurant.Location.Latitude, Longitude: restaurant.Location.Longitude},
-		"geohash":        geoHash,
-		"price_range":    restaurant.PriceRange,
-		"category":       restaurant.Category,
-		"opening_status": restaurant.OpeningStatus,
-		"image_url":      restaurant.ImageURL,
-		"maps_link":      restaurant.MapsLink,
-		"menu_link":      restaurant.MenuLink,
-		"reviews":        restaurant.Reviews,
-		"menu":           restaurant.Menu,
-		"review_count":   restaurant.ReviewCount,
-	}
-
-	// Save the document with the generated ID
-	_, err := docRef.Set(ctx, data)
-	if err != nil {
-		return err
+// newRestaurant converts a scraped place into a restaurant, its status comes from the verdict
+func newRestaurant(place *models.Place, verdict *models.HalalVerdict) *models.Restaurant {
+	return &models.Restaurant{
+		Title:               place.Title,
+		Rating:              place.Rating,
+		Address:             strings.TrimPrefix(place.Address, "Alamat: "),
+		ReviewCount:         place.ReviewCount,
+		Location:            &latlng.LatLng{Latitude: place.Location.Latitude, Longitude: place.Location.Longitude},
+		Geohash:             geohash.Encode(place.Location.Latitude, place.Location.Longitude),
+		PriceRange:          place.PriceRange,
+		Category:            place.Category,
+		OpeningStatus:       place.OpeningStatus,
+		ImageURL:            place.ImageURL,
+		MapsLink:            place.MapsLink,
+		MenuLink:            place.MenuLink,
+		Reviews:             place.Reviews,
+		Menu:                place.Menu,
+		ApproximateLocation: place.ApproximateLocation,
+		Status:              verdict.Status,
+		Verdict:             verdict,
 	}
-	return nil
 }
 
-func (s *RestaurantService) SaveRestaurants(ctx context.Context, restaurants []*models.Place) error {
-	batch := s.FirestoreClient.Batch()
-
-	for _, restaurant := range restaurants {
-		docRef := s.FirestoreClient.Collection("restaurants").NewDoc()
-
-		geoHash := geohash.Encode(restaurant.Location.Latitude, restaurant.Location.Longitude)
-		cleanedAddress := strings.TrimPrefix(restaurant.Address, "Alamat: ")
-
-		// Convert GeoLocation to Firestore GeoPoint
-		data := map[string]interface{}{
-			"id":             docRef.ID, // Store Firestore document ID
-			"title":          restaurant.Title,
-			"rating":         restaurant.Rating,
-			"address":        cleanedAddress,
-			"location":       &latlng.LatLng{Latitude: restaurant.Location.Latitude, Longitude: restaurant.Location.Longitude},
-			"geohash":        geoHash,
-			"price_range":    restaurant.PriceRange,
-			"category":       restaurant.Category,
-			"opening_status": restaurant.OpeningStatus,
-			"image_url":      restaurant.ImageURL,
-			"maps_link":      restaurant.MapsLink,
-			"menu_link":      restaurant.MenuLink,
-			"reviews":        restaurant.Reviews,
-			"menu":           restaurant.Menu,
-			"review_count":   restaurant.ReviewCount,
-			"status":         "halal",
-			"createdAt":      firestore.ServerTimestamp,
-			"updateAt":       firestore.ServerTimestamp,
+// SaveRestaurants stores scraped places in one batch. Each status comes from the place verdict,
+// places without a verdict are saved as unknown.
+func (s *RestaurantService) SaveRestaurants(ctx context.Context, places []*models.Place) error {
+	restaurants := make([]*models.Restaurant, 0, len(places))
+	for _, place := range places {
+		verdict := place.Verdict
+		if verdict == nil {
+			verdict = models.NewHalalVerdict(models.RestaurantStatusUnknown, models.VerdictSourceMenuAI, 0, "Not analysed", nil)
 		}
-
-		// Add the set operation to the batch
-		batch.Set(docRef, data)
-	}
-
-	// Commit the batch operation
-	_, err := batch.Commit(ctx)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (s *RestaurantService) SaveRestaurantsHaram(ctx context.Context, restaurants []*models.Place) error {
-	batch := s.FirestoreClient.Batch()
-
-	for _, restaurant := range restaurants {
-		docRef := s.FirestoreClient.Collection("restaurants").NewDoc()
-
-		geoHash := geohash.Encode(restaurant.Location.Latitude, restaurant.Location.Longitude)
-		cleanedAddress := strings.TrimPrefix(restaurant.Address, "Alamat: ")
-
-		// Convert GeoLocation to Firestore GeoPoint
-		data := map[string]interface{}{
-			"id":             docRef.ID, // Store Firestore document ID
-			"title":          restaurant.Title,
-			"rating":         restaurant.Rating,
-			"address":        cleanedAddress,
-			"location":       &latlng.LatLng{Latitude: restaurant.Location.Latitude, Longitude: restaurant.Location.Longitude},
-			"geohash":        geoHash,
-			"price_range":    restaurant.PriceRange,
-			"category":       restaurant.Category,
-			"opening_status": restaurant.OpeningStatus,
-			"image_url":      restaurant.ImageURL,
-			"maps_link":      restaurant.MapsLink,
-			"menu_link":      restaurant.MenuLink,
-			"reviews":        restaurant.Reviews,
-			"menu":           restaurant.Menu,
-			"review_count":   restaurant.ReviewCount,
-			"status":         "haram",
-			"createdAt":      firestore.ServerTimestamp,
-			"updateAt":       firestore.ServerTimestamp,
+		if err := verdict.Validate(); err != nil {
+			return fmt.Errorf("invalid verdict for %s: %w", place.Title, err)
 		}
-
-		// Add the set operation to the batch
-		batch.Set(docRef, data)
+		restaurants = append(restaurants, newRestaurant(place, verdict))
 	}
 
-	// Commit the batch operation
-	_, err := batch.Commit(ctx)
-	if err != nil {
-		return err
-	}
-	return nil
+	return s.Restaurants.CreateMany(ctx, restaurants)
 }
 
+// duplicateRadiusKm is how far apart two places with the same title are still the same restaurant
+const duplicateRadiusKm = 3.0
+
 //function to check if restaurant exists on database by on lat and long
 
 func (s *RestaurantService) CheckRestaurantExists(ctx context.Context, latitude, longitude float64, title string) (bool, string, error) {
-	// Generate geohash for the given location
-	targetGeoHash := geohash.Encode(latitude, longitude)
-
-	// Get a list of nearby geohashes (small variations)
-	geohashPrefix := targetGeoHash[:5] // Use only the first 5 characters for a 3 km range
-
-	// Query Firestore using geohash prefix and title
-	iter := s.FirestoreClient.Collection("restaurants").
-		Where("geohash", ">=", geohashPrefix).
-		Where("geohash", "<=", geohashPrefix+"~").
-		Where("title", "==", title).
-		Documents(ctx)
-
-	// Get first document
-	doc, err := iter.Next()
-	if err == iterator.Done {
-		return false, "", nil // No document found
-	}
+	// Query the cells around the location by title
+	docs, err := s.findWithinRadius(ctx, latitude, longitude, duplicateRadiusKm, repositories.RestaurantQuery{
+		Title: title,
+	})
 	if err != nil {
 		return false, "", err // Return error if something goes wrong
 	}
+	if len(docs) == 0 {
+		return false, "", nil // No document found
+	}
 
 	// Get the status from the document
-	status, ok := doc.Data()["status"].(string)
-	if !ok {
-		return false, "", fmt.Errorf("status field is missing or not a string")
+	if docs[0].Status == "" {
+		return false, "", fmt.Errorf("status field is missing")
 	}
 
-	return true, status, nil // Return the existence flag, status, and any errors
+	return true, docs[0].Status, nil // Return the existence flag, status, and any errors
 }
 
+// DefaultSearchRadiusKm is the radius used when the caller does not ask for one
+const DefaultSearchRadiusKm = 10.0
 
-func (s *RestaurantService) GetAllRestaurantByLocation(ctx context.Context, latitude, longitude float64, userId string) ([]map[string]interface{}, error) {
-	// Debug: Print input parameters
-	fmt.Printf("GetAllRestaurantByLocation called with latitude=%f, longitude=%f, userId=%s\n", latitude, longitude, userId)
-
-	// Generate geohash for the given location
-	targetGeoHash := geohash.Encode(latitude, longitude)
-	geohashPrefix := targetGeoHash[:5] // 3 km range
+// GetAllRestaurantByLocation returns the halal restaurants within DefaultSearchRadiusKm meeting the
+// dietary preferences of the user, closest first
+func (s *RestaurantService) GetAllRestaurantByLocation(ctx context.Context, latitude, longitude float64, userId string) ([]models.Restaurant, error) {
+	return s.GetRestaurantsWithinRadius(ctx, latitude, longitude, DefaultSearchRadiusKm, userId)
+}
 
-	fmt.Printf("Generated geohash: %s, geohashPrefix: %s\n", targetGeoHash, geohashPrefix)
+// GetRestaurantsWithinRadius returns the halal restaurants within radiusKm meeting the dietary preferences of the user, closest first
+func (s *RestaurantService) GetRestaurantsWithinRadius(ctx context.Context, latitude, longitude, radiusKm float64, userId string) ([]models.Restaurant, error) {
+	// Debug: Print input parameters
+	fmt.Printf("GetRestaurantsWithinRadius called with latitude=%f, longitude=%f, radius=%.2f, userId=%s\n", latitude, longitude, radiusKm, userId)
 
-	// Query Firestore using geohash prefix
-	iter := s.FirestoreClient.Collection("restaurants").
-		Where("geohash", ">=", geohashPrefix).
-		Where("geohash", "<=", geohashPrefix+"~").
-		Where("status", "==", "halal").
-		Documents(ctx)
+	restaurants, err := s.halalWithinRadius(ctx, latitude, longitude, radiusKm, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.markBookmarked(ctx, userId, restaurants); err != nil {
+		return nil, err
+	}
 
-	var restaurants []map[string]interface{}
-	var restaurantIDs []string
+	fmt.Printf("Returning %d restaurants\n", len(restaurants))
+	return restaurants, nil
+}
 
-	count := 0
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			fmt.Printf("Error iterating restaurants: %v\n", err)
-			return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get restaurants")
-		}
+// halalWithinRadius returns the halal restaurants within radiusKm meeting the dietary preferences of the user,
+// closest first, without their bookmark status
+func (s *RestaurantService) halalWithinRadius(ctx context.Context, latitude, longitude, radiusKm float64, userId string) ([]models.Restaurant, error) {
+	restaurants, err := s.findWithinRadius(ctx, latitude, longitude, radiusKm, repositories.RestaurantQuery{
+		Status: models.RestaurantStatusHalal,
+	})
+	if err != nil {
+		fmt.Printf("Error iterating restaurants: %v\n", err)
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get restaurants")
+	}
+	fmt.Printf("Total restaurants within %.2fkm: %d\n", radiusKm, len(restaurants))
 
-		data := doc.Data()
-		docID := doc.Ref.ID
-		geoPoint, ok := data["location"].(*latlng.LatLng)
-		if !ok {
-			fmt.Printf("Skipping docID=%s: location is not *latlng.LatLng\n", docID)
-			continue
+	prefs, err := dietaryPreferences(ctx, s.Users, userId)
+	if err != nil {
+		fmt.Printf("Error getting dietary preferences: %v\n", err)
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get dietary preferences")
+	}
+	if !prefs.IsDefault() {
+		matching := restaurants[:0]
+		for _, restaurant := range restaurants {
+			if matchesDietaryPreferences(&restaurant, prefs) {
+				matching = append(matching, restaurant)
+			}
 		}
+		restaurants = matching
+	}
+	return restaurants, nil
+}
 
-		// Haversine filter
-		distance := haversine(latitude, longitude, geoPoint.Latitude, geoPoint.Longitude)
-		fmt.Printf("docID=%s, distance=%.2f\n", docID, distance)
-		if distance <= 10.0 {
-			restaurant := make(map[string]interface{})
-			doc.DataTo(&restaurant)
-			restaurant["distance"] = distance
-
-			restaurants = append(restaurants, restaurant)
-			restaurantIDs = append(restaurantIDs, docID) // Collect restaurant IDs for batch bookmark check
-			count++
-		}
+// markBookmarked sets the bookmark status of the restaurants for the user
+func (s *RestaurantService) markBookmarked(ctx context.Context, userId string, restaurants []models.Restaurant) error {
+	restaurantIDs := make([]string, 0, len(restaurants)) // Collect restaurant IDs for batch bookmark check
+	for _, restaurant := range restaurants {
+		restaurantIDs = append(restaurantIDs, restaurant.ID)
 	}
-	fmt.Printf("Total restaurants within 10km: %d\n", count)
 
 	// 🚀 **Batch check bookmarks in ONE query**
 	bookmarkedMap, err := s.GetBookmarkedRestaurants(ctx, userId, restaurantIDs)
 	if err != nil {
 		fmt.Printf("Error getting bookmarks: %v\n", err)
-		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get bookmarks")
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to get bookmarks")
 	}
 
 	// Assign bookmark status to restaurants
 	for i := range restaurants {
-		docID := restaurantIDs[i]
-		restaurants[i]["isBookmarked"] = bookmarkedMap[docID] // Set bookmark status
+		restaurants[i].IsBookmarked = bookmarkedMap[restaurants[i].ID] // Set bookmark status
+	}
+	return nil
+}
+
+// findWithinRadius queries every geohash cell covering the circle in parallel and returns the
+// deduplicated restaurants within radiusKm, closest first, with their distance set
+func (s *RestaurantService) findWithinRadius(ctx context.Context, latitude, longitude, radiusKm float64, query repositories.RestaurantQuery) ([]models.Restaurant, error) {
+	cells := geohashCover(latitude, longitude, radiusKm)
+
+	results := make([][]models.Restaurant, len(cells))
+	errs := make([]error, len(cells))
+
+	var wg sync.WaitGroup
+	for i, cell := range cells {
+		wg.Add(1)
+		go func(i int, cell string) {
+			defer wg.Done()
+			cellQuery := query
+			cellQuery.GeohashPrefix = cell
+			results[i], errs[i] = s.Restaurants.Find(ctx, cellQuery)
+		}(i, cell)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	restaurants := []models.Restaurant{}
+	for i := range cells {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		for _, restaurant := range results[i] {
+			if seen[restaurant.ID] {
+				continue
+			}
+			seen[restaurant.ID] = true
+
+			if restaurant.Location == nil {
+				log.Printf("Skipping docID=%s: location is missing", restaurant.ID)
+				continue
+			}
+			if restaurant.Closed {
+				continue
+			}
+
+			// Haversine filter
+			distance := haversine(latitude, longitude, restaurant.Location.Latitude, restaurant.Location.Longitude)
+			if distance <= radiusKm {
+				restaurant.Distance = &distance
+				restaurants = append(restaurants, restaurant)
+			}
+		}
 	}
 
-	// Sort by distance
 	sort.Slice(restaurants, func(i, j int) bool {
-		return restaurants[i]["distance"].(float64) < restaurants[j]["distance"].(float64)
+		return restaurants[i].DistanceKm() < restaurants[j].DistanceKm()
 	})
-
-	fmt.Printf("Returning %d restaurants\n", len(restaurants))
 	return restaurants, nil
 }
 
@@ -360,34 +313,70 @@ func (s *RestaurantService) GetBookmarkedRestaurants(ctx context.Context, userID
 		return map[string]bool{}, nil
 	}
 
-	bookmarkedMap := make(map[string]bool)
-	batchSize := 10 // Firestore allows max 10 IDs per "IN" query
+	// Firestore allows max 10 IDs per "IN" query, the repository batches them
+	return s.Bookmarks.BookmarkedRestaurantIDs(ctx, userID, restaurantIDs)
+}
 
-	for i := 0; i < len(restaurantIDs); i += batchSize {
-		end := i + batchSize
-		if end > len(restaurantIDs) {
-			end = len(restaurantIDs)
-		}
+// LocationBackfillResult counts what BackfillLocations found
+type LocationBackfillResult struct {
+	Scanned int `json:"scanned"`
+	// Updated restaurants got their real coordinates from maps_link
+	Updated int `json:"updated"`
+	// Approximate restaurants have no coordinates in maps_link, they are flagged if they were not yet
+	Approximate int `json:"approximate"`
+	// Unchanged restaurants already had the coordinates of their maps_link
+	Unchanged int `json:"unchanged"`
+}
 
-		iter := s.FirestoreClient.Collection("users").Doc(userID).Collection("bookmarks").
-			Where("restaurantId", "in", restaurantIDs[i:end]).
-			Documents(ctx)
+// BackfillLocations re-reads the coordinates of every stored restaurant from its maps_link.
+// Restaurants saved with the old synthetic offsets get their real location and geohash back,
+// the ones whose link has no coordinates are flagged as approximate. With dryRun nothing is written.
+func (s *RestaurantService) BackfillLocations(ctx context.Context, dryRun bool) (*LocationBackfillResult, error) {
+	restaurants, err := s.Restaurants.Find(ctx, repositories.RestaurantQuery{})
+	if err != nil {
+		return nil, err
+	}
 
-		for {
-			doc, err := iter.Next()
-			if err == iterator.Done {
-				break
+	result := &LocationBackfillResult{}
+	for _, restaurant := range restaurants {
+		result.Scanned++
+
+		latitude, longitude, err := extractLatLong(restaurant.MapsLink)
+		found := err == nil
+
+		// Every restaurant is counted in one bucket, so the totals add up to Scanned
+		var update func(restaurant *models.Restaurant) error
+		switch {
+		case !found:
+			result.Approximate++
+			if restaurant.ApproximateLocation {
+				continue
 			}
-			if err != nil {
-				return nil, err
+			update = func(restaurant *models.Restaurant) error {
+				restaurant.ApproximateLocation = true
+				return nil
 			}
-
-			restaurantID, ok := doc.Data()["restaurantId"].(string)
-			if ok {
-				bookmarkedMap[restaurantID] = true
+		case found && (restaurant.Location == nil || restaurant.Location.Latitude != latitude ||
+			restaurant.Location.Longitude != longitude || restaurant.ApproximateLocation):
+			result.Updated++
+			update = func(restaurant *models.Restaurant) error {
+				restaurant.Location = &latlng.LatLng{Latitude: latitude, Longitude: longitude}
+				restaurant.Geohash = geohash.Encode(latitude, longitude)
+				restaurant.ApproximateLocation = false
+				return nil
 			}
+		default:
+			result.Unchanged++
+			continue
+		}
+
+		if dryRun {
+			continue
+		}
+		if _, err := s.Restaurants.Update(ctx, restaurant.ID, update); err != nil {
+			return result, fmt.Errorf("failed to update restaurant %s: %w", restaurant.ID, err)
 		}
 	}
 
-	return bookmarkedMap, nil
+	return result, nil
 }
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+// destination returns the point distanceKm away from the start along the bearing in degrees
+func destination(latitude, longitude, bearing, distanceKm float64) (float64, float64) {
+	lat1 := latitude * math.Pi / 180
+	lng1 := longitude * math.Pi / 180
+	theta := bearing * math.Pi / 180
+	delta := distanceKm / earthRadiusKm
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(delta) + math.Cos(lat1)*math.Sin(delta)*math.Cos(theta))
+	lng2 := lng1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(lat1), math.Cos(delta)-math.Sin(lat1)*math.Sin(lat2))
+	// Points across the antimeridian wrap back into [-180, 180)
+	lng := math.Mod(lng2*180/math.Pi+540, 360) - 180
+	return lat2 * 180 / math.Pi, lng
+}
+
+func TestHaversine(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"same point", -6.1864923, 106.8231457, -6.1864923, 106.8231457, 0},
+		{"one degree of latitude", 0, 0, 1, 0, 111.195},
+		{"Monas to Bundaran HI", -6.1753924, 106.8271528, -6.1950000, 106.8230000, 2.228},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, 111.195},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := haversine(tt.lat1, tt.lng1, tt.lat2, tt.lng2); math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("haversine = %.3f km, want %.3f km", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeohashCover(t *testing.T) {
+	centers := []struct {
+		name                string
+		latitude, longitude float64
+	}{
+		{"Jakarta", -6.1864923, 106.8231457},
+		{"equator and prime meridian", 0, 0},
+		{"antimeridian", -16.5, 179.999},
+		{"far north", 78.2232, 15.6267},
+		// The edge of a precision 5 cell, where a search centered on the border needs both sides
+		{"cell border", -6.15234375, 106.875},
+	}
+	radii := []float64{0.1, 1, 5, 20, 100}
+
+	for _, center := range centers {
+		for _, radius := range radii {
+			cells := geohashCover(center.latitude, center.longitude, radius)
+
+			if len(cells) == 0 || len(cells) > 9 {
+				t.Fatalf("%s %v km: got %d cells, want 1 to 9", center.name, radius, len(cells))
+			}
+			if !strings.HasPrefix(geohash.Encode(center.latitude, center.longitude), cells[0]) {
+				t.Errorf("%s %v km: first cell %s does not hold the center", center.name, radius, cells[0])
+			}
+			seen := make(map[string]bool, len(cells))
+			for _, cell := range cells {
+				if seen[cell] {
+					t.Errorf("%s %v km: cell %s listed twice", center.name, radius, cell)
+				}
+				seen[cell] = true
+				if len(cell) != len(cells[0]) {
+					t.Errorf("%s %v km: cells %v mix precisions", center.name, radius, cells)
+				}
+			}
+
+			// Every point of the circle must fall in one of the cells, or the query misses restaurants
+			for bearing := 0.0; bearing < 360; bearing += 5 {
+				for _, fraction := range []float64{0.5, 0.999} {
+					lat, lng := destination(center.latitude, center.longitude, bearing, radius*fraction)
+					hash := geohash.Encode(lat, lng)
+					if !seen[hash[:len(cells[0])]] {
+						t.Errorf("%s %v km: point (%f, %f) at bearing %v is in none of %v", center.name, radius, lat, lng, bearing, cells)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGeohashCoverSkipsDistantNeighbours(t *testing.T) {
+	// A small circle in the middle of its cell reaches none of the neighbours
+	box := geohash.BoundingBox(geohash.EncodeWithPrecision(-6.1864923, 106.8231457, maxGeohashPrecision))
+	latitude, longitude := box.Center()
+
+	cells := geohashCover(latitude, longitude, 0.01)
+	if len(cells) != 1 {
+		t.Errorf("got %v, want only the cell of the center", cells)
+	}
+}
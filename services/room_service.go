@@ -1,121 +1,309 @@
 package services
 
 import (
-	"HalalMate/config/database"
 	"HalalMate/models"
+	"HalalMate/repositories"
 	"HalalMate/utils"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
+)
 
-	"cloud.google.com/go/firestore"
+const (
+	DefaultRoomPageSize = 20
+	MaxRoomPageSize     = 100
+	maxRoomTitleLength  = 100
+	// maxGeneratedTitleLength caps titles written by the LLM or cut from the prompt
+	maxGeneratedTitleLength = 60
 )
 
+// errRoomTitled skips the title generation of a room that got a title meanwhile
+var errRoomTitled = errors.New("room already has a title")
+
 type RoomService struct {
-	FirestoreClient *firestore.Client
+	Rooms         repositories.RoomRepository
+	OpenAIService *OpenAIService
 }
 
 func NewRoomService() *RoomService {
+	return NewRoomServiceWithStore(repositories.GetStore())
+}
+
+// NewRoomServiceWithStore initializes RoomService with the given repositories
+func NewRoomServiceWithStore(store *repositories.Store) *RoomService {
 	return &RoomService{
-		FirestoreClient: database.GetFirestoreClient(),
+		Rooms:         store.Rooms,
+		OpenAIService: NewOpenAIService(),
 	}
 }
 
+// RoomPage is one page of the rooms of a user, most recently active first
+type RoomPage struct {
+	Rooms []*models.Room `json:"rooms"`
+	// NextCursor is empty on the last page
+	NextCursor string `json:"next_cursor"`
+}
+
+// roomCursor marks the last room of a page by its activity time and id
+type roomCursor struct {
+	At int64  `json:"t"`
+	ID string `json:"id"`
+}
+
 //save room into firebase
 
-func (s *RoomService) SaveRoom(ctx context.Context, userId string, title string) (*models.Room, error) {
+// SaveRoom creates a room. When title is empty and autoTitle is set, the title is
+// generated from the first prompt sent to the room.
+func (s *RoomService) SaveRoom(ctx context.Context, userId string, title string, autoTitle bool) (*models.Room, error) {
+	title = strings.TrimSpace(title)
+	if title == "" && !autoTitle {
+		return nil, utils.NewCustomError(http.StatusBadRequest, "Title is required")
+	}
+	if err := validateRoomTitle(title, title == ""); err != nil {
+		return nil, err
+	}
 
 	var room models.Room
-	// Buat dokumen baru di Firestore (Firestore akan otomatis generate ID)
-	roomRef := s.FirestoreClient.Collection("users").Doc(userId).Collection("rooms").NewDoc()
 
 	// Gunakan waktu sekarang jika CreatedAt tidak diset
-	room.CreatedAt = time.Now().Format(time.RFC3339) // Format waktu standar
+	room.CreatedAt = time.Now().Format(time.RFC3339Nano) // Format waktu standar
 
 	room.UserID = userId
 
 	room.RoomTitle = title
+	room.AutoTitle = title == ""
 
-	// Simpan data ke Firestore
-	_, err := roomRef.Set(ctx, room)
-	if err != nil {
-		return nil, err
+	// Simpan data ke Firestore (Firestore akan otomatis generate ID)
+	if err := s.Rooms.CreateRoom(ctx, &room); err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to create room chat")
 	}
 
 	// Kembalikan objek Room dengan RoomID yang di-generate Firestore
-	return &models.Room{
-		RoomID:    roomRef.ID, // Firestore-generated ID
-		UserID:    room.UserID,
-		RoomTitle: room.RoomTitle,
-		CreatedAt: room.CreatedAt,
-	}, nil
+	return &room, nil
+}
+
+func validateRoomTitle(title string, allowEmpty bool) error {
+	if title == "" && !allowEmpty {
+		return utils.NewCustomError(http.StatusBadRequest, "Title is required")
+	}
+	if utf8.RuneCountInString(title) > maxRoomTitleLength {
+		return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("title must be at most %d characters", maxRoomTitleLength))
+	}
+	return nil
 }
 
 //get all room chat
 
 func (s *RoomService) GetRooms(ctx context.Context, userId string) ([]*models.Room, error) {
-	var rooms []*models.Room
+	return s.Rooms.ListRooms(ctx, userId)
+}
 
-	print(userId)
+// ListRooms returns one page of the rooms of the user, ordered by their last message
+// and by creation time for rooms without messages. Only the rooms of the page are read.
+func (s *RoomService) ListRooms(ctx context.Context, userId, cursor string, limit int) (*RoomPage, error) {
+	if limit == 0 {
+		limit = DefaultRoomPageSize
+	}
+	if limit < 0 || limit > MaxRoomPageSize {
+		return nil, utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", MaxRoomPageSize))
+	}
 
-	// Query rooms collection
-	roomDocs, err := s.FirestoreClient.Collection("users").Doc(userId).Collection("rooms").Documents(ctx).GetAll()
+	var after *repositories.RoomCursor
+	if cursor != "" {
+		decoded, err := decodeRoomCursor(cursor)
+		if err != nil {
+			return nil, utils.NewCustomError(http.StatusBadRequest, "Invalid cursor")
+		}
+		after = &repositories.RoomCursor{At: time.Unix(0, decoded.At), ID: decoded.ID}
+	}
+
+	// One extra room tells whether another page follows
+	rooms, err := s.Rooms.ListRoomsPage(ctx, userId, after, limit+1)
+	if err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to get rooms")
+	}
+
+	page := &RoomPage{Rooms: rooms}
+	if len(rooms) > limit {
+		page.Rooms = rooms[:limit]
+		last := page.Rooms[limit-1]
+		page.NextCursor = encodeRoomCursor(roomCursor{At: last.LastActivityAt.UnixNano(), ID: last.RoomID})
+	}
+	if page.Rooms == nil {
+		page.Rooms = []*models.Room{}
+	}
+	return page, nil
+}
+
+// RoomActivityBackfillResult counts what BackfillActivity found
+type RoomActivityBackfillResult struct {
+	Scanned int `json:"scanned"`
+	Updated int `json:"updated"`
+}
+
+// BackfillActivity sets the LastActivityAt of the rooms saved before it existed, which ListRooms
+// would otherwise leave out. With dryRun nothing is written.
+func (s *RoomService) BackfillActivity(ctx context.Context, dryRun bool) (*RoomActivityBackfillResult, error) {
+	rooms, err := s.Rooms.ListAllRooms(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	print(roomDocs)
+	result := &RoomActivityBackfillResult{}
+	for _, room := range rooms {
+		result.Scanned++
+		if !room.LastActivityAt.IsZero() {
+			continue
+		}
+		result.Updated++
+		if dryRun {
+			continue
+		}
 
-	// Iterate over room documents
-	for _, doc := range roomDocs {
-		var room models.Room
-		if err := doc.DataTo(&room); err != nil {
-			return nil, err
+		_, err := s.Rooms.UpdateRoom(ctx, room.UserID, room.RoomID, func(room *models.Room) error {
+			if room.LastActivityAt.IsZero() {
+				room.LastActivityAt = room.ActivityTime()
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return result, fmt.Errorf("failed to update room %s: %w", room.RoomID, err)
 		}
+	}
+	return result, nil
+}
 
-		room.RoomID = doc.Ref.ID
+func encodeRoomCursor(cursor roomCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
 
-		// Append room to list
-		rooms = append(rooms, &room)
+func decodeRoomCursor(encoded string) (*roomCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
 	}
 
-	return rooms, nil
+	var cursor roomCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
 }
 
 //get room by id
 
 func (s *RoomService) GetRoomByID(ctx context.Context, userId, roomId string) (*models.RoomWithChat, error) {
 	// Query room document
-	roomDoc, err := s.FirestoreClient.Collection("users").Doc(userId).Collection("rooms").Doc(roomId).Get(ctx)
+	room, err := s.Rooms.GetRoom(ctx, userId, roomId)
 	if err != nil {
 		return nil, utils.NewCustomError(http.StatusNotFound, "Room not found")
 	}
 
-	// Convert Firestore document to Room struct
-	var room models.Room
-	if err := roomDoc.DataTo(&room); err != nil {
+	// Query chats collection in the room
+	chats, err := s.Rooms.ListChats(ctx, userId, roomId)
+	if err != nil {
 		return nil, err
 	}
 
-	// Query chats collection in the room
-	chatsSnapshot, err := s.FirestoreClient.Collection("users").Doc(userId).Collection("rooms").Doc(roomId).Collection("chats").OrderBy("CreatedAt", firestore.Asc).Documents(ctx).GetAll()
+	// Return room with chats
+	return &models.RoomWithChat{
+		Room:  *room,
+		Chats: chats,
+	}, nil
+}
+
+// RenameRoom sets the title of the room, a pending generated title is dropped
+func (s *RoomService) RenameRoom(ctx context.Context, userId, roomId, title string) (*models.Room, error) {
+	title = strings.TrimSpace(title)
+	if err := validateRoomTitle(title, false); err != nil {
+		return nil, err
+	}
+
+	room, err := s.Rooms.UpdateRoom(ctx, userId, roomId, func(room *models.Room) error {
+		room.RoomTitle = title
+		room.AutoTitle = false
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, utils.NewCustomError(http.StatusNotFound, "Room not found")
+		}
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to rename room")
+	}
+	return room, nil
+}
+
+// DeleteRoom deletes the room and its chats
+func (s *RoomService) DeleteRoom(ctx context.Context, userId, roomId string) error {
+	if err := s.Rooms.DeleteRoom(ctx, userId, roomId); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return utils.NewCustomError(http.StatusNotFound, "Room not found")
+		}
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to delete room")
+	}
+	return nil
+}
+
+// GenerateTitle titles a room created without one from its first prompt. It returns nil
+// when the room does not wait for a title. The prompt itself is used when the LLM fails.
+func (s *RoomService) GenerateTitle(ctx context.Context, userId, roomId, prompt string) (*models.Room, error) {
+	room, err := s.Rooms.GetRoom(ctx, userId, roomId)
 	if err != nil {
 		return nil, err
 	}
+	if !room.AutoTitle {
+		return nil, nil
+	}
+
+	title, err := s.OpenAIService.RoomTitle(ctx, prompt)
+	if err != nil {
+		log.Printf("[ERROR] Failed to generate the title of room %s: %v", roomId, err)
+	}
+	title = cleanGeneratedTitle(title)
+	if title == "" {
+		title = cleanGeneratedTitle(prompt)
+	}
+	if title == "" {
+		return nil, nil
+	}
 
-	// Convert chat documents to Chat structs
-	var chats []models.Chat
-	for _, chatDoc := range chatsSnapshot {
-		var chat models.Chat
-		if err := chatDoc.DataTo(&chat); err != nil {
-			return nil, err
+	room, err = s.Rooms.UpdateRoom(ctx, userId, roomId, func(room *models.Room) error {
+		// The user may have renamed the room while the title was generated
+		if !room.AutoTitle {
+			return errRoomTitled
+		}
+		room.RoomTitle = title
+		room.AutoTitle = false
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errRoomTitled) {
+			return nil, nil
 		}
-		chats = append(chats, chat)
+		return nil, err
 	}
+	return room, nil
+}
 
-	// Return room with chats
-	return &models.RoomWithChat{
-		Room:  room,
-		Chats: chats,
-	}, nil
+// cleanGeneratedTitle keeps the first line without quotes, cut to maxGeneratedTitleLength at a word boundary
+func cleanGeneratedTitle(title string) string {
+	title, _, _ = strings.Cut(strings.TrimSpace(title), "\n")
+	title = strings.Trim(strings.TrimSpace(title), "\"'`*#. ")
+
+	if utf8.RuneCountInString(title) <= maxGeneratedTitleLength {
+		return title
+	}
+	cut := string([]rune(title)[:maxGeneratedTitleLength])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "…"
 }
@@ -1,9 +1,12 @@
 package services
 
 import (
-	"HalalMate/config/database"
+	"HalalMate/config/environment"
+	"HalalMate/config/selectors"
 	"HalalMate/models"
+	"HalalMate/repositories"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/url"
@@ -13,7 +16,6 @@ import (
 	"sync"
 	"time"
 
-	"cloud.google.com/go/firestore"
 	"github.com/PuerkitoBio/goquery"
 
 	// "github.com/chromedp/cdproto/cdp"
@@ -22,231 +24,234 @@ import (
 )
 
 type ScrapService struct {
-	FirestoreClient   *firestore.Client
 	OpenAIService     *OpenAIService
 	RestaurantService *RestaurantService
+	Selectors         *selectors.MapsSelectors
 }
 
-// NewScrapService initializes ScrapService with Firestore and OpenAI service
+// NewScrapService initializes ScrapService with the default store and OpenAI service
 func NewScrapService(openAIService *OpenAIService) *ScrapService {
+	return NewScrapServiceWithStore(repositories.GetStore(), openAIService)
+}
+
+// NewScrapServiceWithStore initializes ScrapService with the given repositories and OpenAI service
+func NewScrapServiceWithStore(store *repositories.Store, openAIService *OpenAIService) *ScrapService {
+	mapsSelectors, err := selectors.Maps(environment.GetScraperLocale())
+	if err != nil {
+		log.Printf("⚠️ %v, using locale %q\n", err, selectors.DefaultLocale)
+		mapsSelectors, _ = selectors.Maps(selectors.DefaultLocale)
+	}
+
 	return &ScrapService{
-		FirestoreClient:   database.GetFirestoreClient(),
 		OpenAIService:     openAIService,
-		RestaurantService: NewRestaurantService(),
+		RestaurantService: NewRestaurantServiceWithStore(store),
+		Selectors:         mapsSelectors,
 	}
 }
 
-func (s *ScrapService) ScrapePlaces(searchURLs []string, placeChan chan<- models.Place, doneChan chan<- bool, latitude, longitude string) {
-	var wg sync.WaitGroup
+// SearchURL returns the Google Maps search page of a keyword around a location, in the scraper locale
+func (s *ScrapService) SearchURL(keyword string, latitude, longitude float64) string {
+	formattedKeyword := strings.ReplaceAll(keyword, " ", "+")
+	return fmt.Sprintf("https://www.google.com/maps/search/%s/@%.7f,%.7f,18.5z?hl=%s", formattedKeyword, latitude, longitude, s.Selectors.Language)
+}
+
+// jsString quotes a selector for use inside an evaluated script
+func jsString(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+// ScrapePage scrapes one Google Maps search page, sends every new place on placeChan and saves them.
+// It stops starting new places once ctx is cancelled and returns ctx.Err() in that case.
+func (s *ScrapService) ScrapePage(ctx context.Context, pageURL, latitude, longitude string, placeChan chan<- models.Place) error {
+	log.Printf("Scraping started for URL: %s\n", pageURL)
+	places, err := s.scrapeAllData(ctx, pageURL, latitude, longitude)
+	if err != nil {
+		return err
+	}
+
+	var menuWg sync.WaitGroup
+	sem := make(chan struct{}, 3) // Reduced from 5 to 3 concurrent menu scraping goroutines
+
+	// Increase maxPlaces to handle duplicates better
+	maxPlaces := 50 // Increased from 20 to 50
+	if len(places) < maxPlaces {
+		maxPlaces = len(places)
+	}
+
+	var placesToSave []*models.Place // Slice to collect places for batch save
+	var saveMu sync.Mutex
 
-	for _, pageURL := range searchURLs {
-		wg.Add(1)
-		go func(pageURL string) {
-			defer wg.Done()
+	// Track how many places we've processed and how many are new
+	processedCount := 0
+	newPlacesFound := 0
+	targetNewPlaces := 4 // We want to find at least 20 new places
 
-			log.Printf("Scraping started for URL: %s\n", pageURL)
-			places := scrapeAllData(pageURL, latitude, longitude)
+	for i := 0; i < maxPlaces && newPlacesFound < targetNewPlaces && ctx.Err() == nil; i++ {
+		place := places[i] // capture the value here
+		processedCount++
 
-			var menuWg sync.WaitGroup
-			sem := make(chan struct{}, 3) // Reduced from 5 to 3 concurrent menu scraping goroutines
+		// Skip places with empty titles
+		if place.Title == "" {
+			log.Printf("⚠️ Skipping place with empty title (processed %d, found %d new)\n", processedCount, newPlacesFound)
+			continue
+		}
 
-			// Increase maxPlaces to handle duplicates better
-			maxPlaces := 50 // Increased from 20 to 50
-			if len(places) < maxPlaces {
-				maxPlaces = len(places)
+		// Visit the place page when the result link had no coordinates
+		if places[i].ApproximateLocation && places[i].MapsLink != "N/A" {
+			if lat, long, err := s.resolvePlaceLocation(ctx, places[i].MapsLink); err == nil {
+				places[i].Location = models.GeoLocation{Latitude: lat, Longitude: long}
+				places[i].ApproximateLocation = false
+			} else {
+				log.Printf("⚠️ Keeping the search center as approximate location for %s: %v\n", places[i].Title, err)
 			}
+		}
 
-			// var placesToSave []*models.Place // Slice to collect places for batch save
+		exists, _, err := s.RestaurantService.CheckRestaurantExists(context.Background(), places[i].Location.Latitude, places[i].Location.Longitude, places[i].Title)
+		if err != nil {
+			log.Printf("❌ Error checking restaurant existence for %s: %v\n", places[i].Title, err)
+			continue
+		}
+		if exists {
+			log.Printf("⚠️ Skipping duplicate restaurant: %s (processed %d, found %d new)\n", places[i].Title, processedCount, newPlacesFound)
+			continue
+		}
 
-			var placesToSaveHalal []*models.Place // Slice to collect halal places for batch save
-			var placesToSaveHaram []*models.Place // Slice
+		newPlacesFound++
+		log.Printf("✅ Found new restaurant: %s (processed %d, found %d new)\n", places[i].Title, processedCount, newPlacesFound)
 
-			// Track how many places we've processed and how many are new
-			processedCount := 0
-			newPlacesFound := 0
-			targetNewPlaces := 4 // We want to find at least 20 new places
+		menuWg.Add(1)
+		sem <- struct{}{} // Acquire a slot
+		go func(p models.Place) {
+			defer menuWg.Done()
+			defer func() { <-sem }()
 
-			for i := 0; i < maxPlaces && newPlacesFound < targetNewPlaces; i++ {
-				place := places[i] // capture the value here
-				processedCount++
+			// Add timeout for the entire goroutine
+			ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+			defer cancel()
 
-				// Skip places with empty titles
-				if place.Title == "" {
-					log.Printf("⚠️ Skipping place with empty title (processed %d, found %d new)\n", processedCount, newPlacesFound)
-					continue
-				}
+			menuChan := make(chan []string, 1)
+			reviewChan := make(chan []string, 1)
+			errChan := make(chan error, 2)
 
-				exists, _, err := s.RestaurantService.CheckRestaurantExists(context.Background(), places[i].Location.Latitude, places[i].Location.Longitude, places[i].Title)
+			// Menu scraping with timeout
+			go func() {
+				menu, address, err := s.scrapeDataMenu(p.MapsLink)
 				if err != nil {
-					log.Printf("❌ Error checking restaurant existence for %s: %v\n", places[i].Title, err)
-					continue
+					log.Printf("⚠️ Menu scraping failed for %s: %v\n", p.Title, err)
+					errChan <- err
+					menuChan <- nil
+					return
 				}
-				if exists {
-					log.Printf("⚠️ Skipping duplicate restaurant: %s (processed %d, found %d new)\n", places[i].Title, processedCount, newPlacesFound)
-					continue
+				select {
+				case menuChan <- menu:
+				case <-ctx.Done():
+					log.Printf("⚠️ Menu scraping timeout for %s\n", p.Title)
 				}
+				p.Address = address
+			}()
 
-				newPlacesFound++
-				log.Printf("✅ Found new restaurant: %s (processed %d, found %d new)\n", places[i].Title, processedCount, newPlacesFound)
-
-				menuWg.Add(1)
-				sem <- struct{}{} // Acquire a slot
-				go func(p models.Place) {
-					defer menuWg.Done()
-					defer func() { <-sem }()
-
-					// Add timeout for the entire goroutine
-					ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-					defer cancel()
-
-					menuChan := make(chan []string, 1)
-					reviewChan := make(chan []string, 1)
-					errChan := make(chan error, 2)
-
-					// Menu scraping with timeout
-					go func() {
-						menu, address, err := s.scrapeDataMenu(p.MapsLink)
-						if err != nil {
-							log.Printf("⚠️ Menu scraping failed for %s: %v\n", p.Title, err)
-							errChan <- err
-							menuChan <- nil
-							return
-						}
-						select {
-						case menuChan <- menu:
-						case <-ctx.Done():
-							log.Printf("⚠️ Menu scraping timeout for %s\n", p.Title)
-						}
-						p.Address = address
-					}()
-
-					// Review scraping with timeout
-					go func() {
-						reviews, err := scrapeDataReview(p.MapsLink, p.Title)
-						if err != nil {
-							log.Printf("⚠️ Review scraping failed for %s: %v\n", p.Title, err)
-							errChan <- err
-							reviewChan <- nil
-							return
-						}
-						select {
-						case reviewChan <- reviews:
-						case <-ctx.Done():
-							log.Printf("⚠️ Review scraping timeout for %s\n", p.Title)
-						}
-					}()
-
-					// Wait for both operations with timeout
-					var menuLink []string
-					var reviewUser []string
-
-					select {
-					case menuLink = <-menuChan:
-					case <-ctx.Done():
-						log.Printf("⚠️ Menu channel timeout for %s\n", p.Title)
-						menuLink = nil
-					}
-
-					select {
-					case reviewUser = <-reviewChan:
-					case <-ctx.Done():
-						log.Printf("⚠️ Review channel timeout for %s\n", p.Title)
-						reviewUser = nil
-					}
-
-					// Process results even if one operation failed
-					if menuLink != nil || reviewUser != nil {
-						if menuLink != nil {
-							p.MenuLink = menuLink
-						}
-						if reviewUser != nil {
-							p.Reviews = reviewUser
-						}
-
-						// Only analyze images if we have menu links
-						if len(menuLink) > 0 {
-							menuList, err := s.OpenAIService.AnalyzeImages(context.Background(), menuLink)
-							if err != nil {
-								log.Printf("❌ Error analyzing images for %s: %v\n", p.Title, err)
-								// Continue with the place even if image analysis fails
-								placeChan <- p
-								// Mark as haram by default if analysis fails
-								placesToSaveHaram = append(placesToSaveHaram, &p)
-							} else if menuList != nil {
-								// Only set menu if menuList is not nil
-								p.Menu = menuList.Menu
-								placeChan <- p
-
-								if menuList.HalalStatus == "halal" {
-									placesToSaveHalal = append(placesToSaveHalal, &p)
-								} else {
-									placesToSaveHaram = append(placesToSaveHaram, &p)
-								}
-							} else {
-								// Handle case where menuList is nil but no error
-								log.Printf("⚠️ menuList is nil for %s, marking as haram\n", p.Title)
-								placeChan <- p
-								placesToSaveHaram = append(placesToSaveHaram, &p)
-							}
-						} else {
-							// No menu links available, save as haram
-							log.Printf("⚠️ No menu links for %s, marking as haram\n", p.Title)
-							placeChan <- p
-							placesToSaveHaram = append(placesToSaveHaram, &p)
-						}
-					} else {
-						log.Printf("⚠️ Both menu and review scraping failed for %s\n", p.Title)
-					}
+			// Review scraping with timeout
+			go func() {
+				reviews, err := s.scrapeDataReview(p.MapsLink, p.Title)
+				if err != nil {
+					log.Printf("⚠️ Review scraping failed for %s: %v\n", p.Title, err)
+					errChan <- err
+					reviewChan <- nil
+					return
+				}
+				select {
+				case reviewChan <- reviews:
+				case <-ctx.Done():
+					log.Printf("⚠️ Review scraping timeout for %s\n", p.Title)
+				}
+			}()
 
-					close(errChan)
-					for err := range errChan {
-						log.Printf("❌ Error processing %s: %v\n", p.Title, err)
-					}
+			// Wait for both operations with timeout
+			var menuLink []string
+			var reviewUser []string
 
-				}(place)
+			select {
+			case menuLink = <-menuChan:
+			case <-ctx.Done():
+				log.Printf("⚠️ Menu channel timeout for %s\n", p.Title)
+				menuLink = nil
 			}
 
-			log.Printf("📊 Scraping summary: Processed %d places, found %d new restaurants\n", processedCount, newPlacesFound)
+			select {
+			case reviewUser = <-reviewChan:
+			case <-ctx.Done():
+				log.Printf("⚠️ Review channel timeout for %s\n", p.Title)
+				reviewUser = nil
+			}
 
-			menuWg.Wait() // Wait for all scraping goroutines to complete
+			// Process results even if one operation failed
+			if menuLink != nil || reviewUser != nil {
+				if menuLink != nil {
+					p.MenuLink = menuLink
+				}
+				if reviewUser != nil {
+					p.Reviews = reviewUser
+				}
 
-			if len(placesToSaveHalal) > 0 {
-				err := s.RestaurantService.SaveRestaurants(context.Background(), placesToSaveHalal)
-				if err != nil {
-					log.Printf("❌ Bulk save (Halal) failed: %v\n", err)
+				// Only analyze images if we have menu links, without them the status stays unknown
+				var menuList *models.AIResponsAnalyzeMenu
+				var err error
+				if len(menuLink) > 0 {
+					menuList, err = s.OpenAIService.AnalyzeImages(ctx, menuLink)
+					if err != nil {
+						log.Printf("❌ Error analyzing images for %s: %v\n", p.Title, err)
+					} else if menuList != nil {
+						p.Menu = menuList.Menu
+					}
 				} else {
-					log.Printf("✅ Successfully saved %d halal restaurants\n", len(placesToSaveHalal))
+					log.Printf("⚠️ No menu links for %s, status unknown\n", p.Title)
 				}
+				p.Verdict = menuVerdict(menuLink, menuList, err)
+				placeChan <- p
+
+				saveMu.Lock()
+				placesToSave = append(placesToSave, &p)
+				saveMu.Unlock()
+			} else {
+				log.Printf("⚠️ Both menu and review scraping failed for %s\n", p.Title)
 			}
 
-			// Perform bulk save for haram restaurants
-			if len(placesToSaveHaram) > 0 {
-				err := s.RestaurantService.SaveRestaurantsHaram(context.Background(), placesToSaveHaram)
-				if err != nil {
-					log.Printf("❌ Bulk save (Haram) failed: %v\n", err)
-				} else {
-					log.Printf("✅ Successfully saved %d haram restaurants\n", len(placesToSaveHaram))
-				}
+			close(errChan)
+			for err := range errChan {
+				log.Printf("❌ Error processing %s: %v\n", p.Title, err)
 			}
-		}(pageURL)
+
+		}(place)
 	}
 
-	wg.Wait()
-	close(placeChan)
-	doneChan <- true
+	log.Printf("📊 Scraping summary: Processed %d places, found %d new restaurants\n", processedCount, newPlacesFound)
+
+	menuWg.Wait() // Wait for all scraping goroutines to complete
+
+	if len(placesToSave) > 0 {
+		err := s.RestaurantService.SaveRestaurants(context.Background(), placesToSave)
+		if err != nil {
+			log.Printf("❌ Bulk save failed: %v\n", err)
+		} else {
+			log.Printf("✅ Successfully saved %d restaurants\n", len(placesToSave))
+		}
+	}
+
+	return ctx.Err()
 }
 
-func scrapeAllData(pageURL string, latitude, longitude string) []models.Place {
+func (s *ScrapService) scrapeAllData(ctx context.Context, pageURL string, latitude, longitude string) ([]models.Place, error) {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.Flag("disable-geolocation", false),
 		chromedp.Flag("use-mock-keychain", true),
 		// Optional: Use proxy for IP-based location
 	)
 
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	allocCtx, cancel := chromedp.NewExecAllocator(ctx, opts...)
 	defer cancel()
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
+	ctx, cancel = chromedp.NewContext(allocCtx)
 	defer cancel()
 
 	ctx, cancel = context.WithTimeout(ctx, 90*time.Second) // Increased from 60 to 90 seconds
@@ -255,7 +260,7 @@ func scrapeAllData(pageURL string, latitude, longitude string) []models.Place {
 	searchQuery := extractSearchQuery(pageURL)
 	if searchQuery == "" {
 		log.Println("Failed to extract search query from URL")
-		return nil
+		return nil, fmt.Errorf("failed to extract search query from %s", pageURL)
 	}
 
 	var pageHTML string
@@ -284,13 +289,13 @@ func scrapeAllData(pageURL string, latitude, longitude string) []models.Place {
 		}),
 		chromedp.Navigate(pageURL),
 		chromedp.Sleep(2*time.Second),
-		chromedp.WaitVisible(`[aria-label="Hasil untuk `+searchQuery+`"]`, chromedp.ByQuery),
-		scrollMultipleTimes(5, searchQuery),
+		chromedp.WaitVisible(s.Selectors.ResultsFeedFor(searchQuery), chromedp.ByQuery),
+		scrollMultipleTimes(5, s.Selectors.ResultsFeedFor(searchQuery)),
 		chromedp.OuterHTML("body", &pageHTML),
 	)
 	if err != nil {
 		log.Printf("Failed to load page %s: %v\n", pageURL, err)
-		return nil
+		return nil, fmt.Errorf("failed to load page %s: %w", pageURL, err)
 	}
 
 	log.Println("Extracting data from page...")
@@ -300,10 +305,10 @@ func scrapeAllData(pageURL string, latitude, longitude string) []models.Place {
 	searchLong, err2 := strconv.ParseFloat(longitude, 64)
 	if err1 != nil || err2 != nil {
 		log.Printf("Error converting coordinates: %v, %v", err1, err2)
-		return nil
+		return nil, fmt.Errorf("invalid coordinates: %v, %v", err1, err2)
 	}
 
-	return extractData(pageHTML, searchLat, searchLong)
+	return ExtractPlaces(pageHTML, searchLat, searchLong, s.Selectors), nil
 }
 
 func (s *ScrapService) scrapeDataMenu(pageURL string) ([]string, string, error) {
@@ -328,7 +333,7 @@ func (s *ScrapService) scrapeDataMenu(pageURL string) ([]string, string, error)
 			defer cancel()
 
 			var address string
-			err := chromedp.AttributeValue(`button[data-tooltip="Salin alamat"]`, "aria-label", &address, nil).Do(ctx)
+			err := chromedp.AttributeValue(s.Selectors.CopyAddressButton, "aria-label", &address, nil).Do(ctx)
 			if err != nil {
 				log.Printf("⚠️ Could not extract address: %v\n", err)
 				return nil // Don't fail the entire operation
@@ -346,7 +351,7 @@ func (s *ScrapService) scrapeDataMenu(pageURL string) ([]string, string, error)
 			defer cancel()
 
 			var exists bool
-			if err := chromedp.Evaluate(`document.querySelector('div.ofKBgf button.K4UgGe[aria-label="Menu"]') !== null`, &exists).Do(ctx); err != nil {
+			if err := chromedp.Evaluate(`document.querySelector(`+jsString(s.Selectors.MenuButton)+`) !== null`, &exists).Do(ctx); err != nil {
 				log.Printf("⚠️ Could not check menu button: %v\n", err)
 				return nil // Don't fail the entire operation
 			}
@@ -354,7 +359,7 @@ func (s *ScrapService) scrapeDataMenu(pageURL string) ([]string, string, error)
 				log.Println("⚠️ Menu button not found, skipping menu extraction.")
 				return nil // Continue execution without clicking
 			}
-			return chromedp.Click(`div.ofKBgf button.K4UgGe[aria-label="Menu"]`, chromedp.ByQuery).Do(ctx)
+			return chromedp.Click(s.Selectors.MenuButton, chromedp.ByQuery).Do(ctx)
 		}),
 
 		// Wait for menu items loading (with shorter timeout and better error handling)
@@ -362,7 +367,7 @@ func (s *ScrapService) scrapeDataMenu(pageURL string) ([]string, string, error)
 			ctx, cancel := context.WithTimeout(ctx, 8*time.Second) // Reduced from 10 to 8 seconds
 			defer cancel()
 
-			err := chromedp.WaitVisible("div.m6QErb.DxyBCb.kA9KIf.dS8AEf.XiKgde div.m6QErb.XiKgde", chromedp.ByQuery).Do(ctx)
+			err := chromedp.WaitVisible(s.Selectors.MenuPanel, chromedp.ByQuery).Do(ctx)
 			if err != nil {
 				log.Printf("⚠️ Menu items not visible after timeout: %v\n", err)
 				return nil // Don't fail, try to extract what we can
@@ -375,7 +380,7 @@ func (s *ScrapService) scrapeDataMenu(pageURL string) ([]string, string, error)
 			for i := 0; i < 3; i++ { // Reduced from 5 to 3 iterations
 				err := chromedp.Evaluate(`
 			(function() {
-				const el = document.querySelector('div.m6QErb.DxyBCb.kA9KIf.dS8AEf.XiKgde');
+				const el = document.querySelector(`+jsString(s.Selectors.ScrollPanel)+`);
 				if (el) el.scrollTop = el.scrollHeight;
 			})()
 		`, nil).Do(ctx)
@@ -395,7 +400,7 @@ func (s *ScrapService) scrapeDataMenu(pageURL string) ([]string, string, error)
 			defer cancel()
 
 			var imageURLs []string
-			err := chromedp.Evaluate(`Array.from(document.querySelectorAll('div.Uf0tqf.loaded'))
+			err := chromedp.Evaluate(`Array.from(document.querySelectorAll(`+jsString(s.Selectors.MenuImage)+`))
 				.map(el => el.style.backgroundImage.replace(/url\(["']?(.*?)["']?\)/, '$1'))`, &imageURLs).Do(ctx)
 
 			if err != nil {
@@ -417,7 +422,7 @@ func (s *ScrapService) scrapeDataMenu(pageURL string) ([]string, string, error)
 	return imageMenuList, addressRestaurant, nil
 }
 
-func scrapeDataReview(pageURL, nameRestaurant string) ([]string, error) {
+func (s *ScrapService) scrapeDataReview(pageURL, nameRestaurant string) ([]string, error) {
 	ctx, cancel := chromedp.NewContext(context.Background())
 	defer cancel()
 
@@ -426,7 +431,7 @@ func scrapeDataReview(pageURL, nameRestaurant string) ([]string, error) {
 
 	log.Printf("🚀 Navigating to: %s\n", pageURL)
 
-	reviewButtonSelector := fmt.Sprintf(`div.RWPxGd button.hh2c6[aria-label="Ulasan untuk %s"]`, nameRestaurant)
+	reviewButtonSelector := s.Selectors.ReviewButtonFor(nameRestaurant)
 	var reviewTexts []string
 	err := chromedp.Run(ctx,
 		chromedp.Navigate(pageURL),
@@ -438,7 +443,7 @@ func scrapeDataReview(pageURL, nameRestaurant string) ([]string, error) {
 			defer cancel()
 
 			var exists bool
-			if err := chromedp.Evaluate(fmt.Sprintf(`document.querySelector('%s') !== null`, reviewButtonSelector), &exists).Do(ctx); err != nil {
+			if err := chromedp.Evaluate(`document.querySelector(`+jsString(reviewButtonSelector)+`) !== null`, &exists).Do(ctx); err != nil {
 				log.Printf("⚠️ Could not check review button: %v\n", err)
 				return nil // Don't fail the entire operation
 			}
@@ -454,7 +459,7 @@ func scrapeDataReview(pageURL, nameRestaurant string) ([]string, error) {
 			ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
 			defer cancel()
 
-			err := chromedp.WaitVisible("div.m6QErb.XiKgde div.jftiEf.fontBodyMedium div.GHT2ce div.MyEned span.wiI7pd", chromedp.ByQuery).Do(ctx)
+			err := chromedp.WaitVisible(s.Selectors.ReviewText, chromedp.ByQuery).Do(ctx)
 			if err != nil {
 				log.Printf("⚠️ Review elements not visible after timeout: %v\n", err)
 				return nil // Don't fail, try to extract what we can
@@ -467,7 +472,7 @@ func scrapeDataReview(pageURL, nameRestaurant string) ([]string, error) {
 			for i := 0; i < 3; i++ { // Reduced from 5 to 3 iterations
 				err := chromedp.Evaluate(`
 			(function() {
-				const el = document.querySelector('div.m6QErb.DxyBCb.kA9KIf.dS8AEf.XiKgde');
+				const el = document.querySelector(`+jsString(s.Selectors.ScrollPanel)+`);
 				if (el) el.scrollTop = el.scrollHeight;
 			})()
 		`, nil).Do(ctx)
@@ -489,7 +494,7 @@ func scrapeDataReview(pageURL, nameRestaurant string) ([]string, error) {
 			var exists bool
 
 			// Check if at least one review element is present
-			if err := chromedp.Evaluate(`document.querySelector('div.m6QErb.XiKgde div.jftiEf.fontBodyMedium div.GHT2ce div.MyEned span.wiI7pd') !== null`, &exists).Do(ctx); err != nil {
+			if err := chromedp.Evaluate(`document.querySelector(`+jsString(s.Selectors.ReviewText)+`) !== null`, &exists).Do(ctx); err != nil {
 				log.Printf("⚠️ Could not check for review elements: %v\n", err)
 				return nil // Don't fail the entire operation
 			}
@@ -500,7 +505,7 @@ func scrapeDataReview(pageURL, nameRestaurant string) ([]string, error) {
 
 			// Extract inner text of all matched review elements
 			err := chromedp.Evaluate(`
-		Array.from(document.querySelectorAll('div.m6QErb.XiKgde div.jftiEf.fontBodyMedium div.GHT2ce div.MyEned span.wiI7pd'))
+		Array.from(document.querySelectorAll(`+jsString(s.Selectors.ReviewText)+`))
 			.map(el => el.innerText)
 	`, &reviewTexts).Do(ctx)
 
@@ -523,7 +528,8 @@ func scrapeDataReview(pageURL, nameRestaurant string) ([]string, error) {
 	return reviewTexts, nil
 }
 
-func extractData(html string, searchLat, searchLong float64) []models.Place {
+// ExtractPlaces parses the results of a saved Maps search page
+func ExtractPlaces(html string, searchLat, searchLong float64, sel *selectors.MapsSelectors) []models.Place {
 	var places []models.Place
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 	if err != nil {
@@ -531,30 +537,35 @@ func extractData(html string, searchLat, searchLong float64) []models.Place {
 		return nil
 	}
 
-	doc.Find(".Nv2PK").Each(func(i int, s *goquery.Selection) {
-		reviewCount := s.Find(".UY7F9").Text()
+	doc.Find(sel.ResultCard).Each(func(i int, s *goquery.Selection) {
+		reviewCount := s.Find(sel.ResultReviewCount).Text()
 		reviewCountClean := cleanReviewCount(reviewCount)
 
-		rawImageURL := s.Find("img").AttrOr("src", "N/A")
+		rawImageURL := s.Find(sel.ResultImage).AttrOr("src", "N/A")
 		enhancedImageURL := replaceImageProfileQuality(rawImageURL)
 
-		// Use search coordinates as center point
-		// Add small offset based on index to simulate different positions
-		offset := float64(i) * 0.001 // Small offset for each restaurant
-		restaurantLat := searchLat + offset
-		restaurantLong := searchLong + offset
+		mapsLink := s.Find(sel.ResultLink).AttrOr("href", "N/A")
+
+		// The result link carries the place coordinates as !3d<lat>!4d<long>.
+		// Without them fall back to the search center and flag the location.
+		restaurantLat, restaurantLong, err := extractLatLong(mapsLink)
+		approximate := err != nil
+		if approximate {
+			restaurantLat, restaurantLong = searchLat, searchLong
+		}
 
 		place := models.Place{
-			Title:       s.Find(".qBF1Pd").Text(),
-			Rating:      s.Find(".MW4etd").Text(),
+			Title:       s.Find(sel.ResultTitle).Text(),
+			Rating:      s.Find(sel.ResultRating).Text(),
 			ReviewCount: reviewCountClean,
 			Location: models.GeoLocation{
 				Latitude:  restaurantLat,
 				Longitude: restaurantLong,
 			},
-			OpeningStatus: s.Find(".W4Efsd span[style*='color']").Text(),
-			ImageURL:      enhancedImageURL,
-			MapsLink:      s.Find("a[href]").AttrOr("href", "N/A"),
+			ApproximateLocation: approximate,
+			OpeningStatus:       s.Find(sel.ResultOpeningStatus).Text(),
+			ImageURL:            enhancedImageURL,
+			MapsLink:            mapsLink,
 		}
 		places = append(places, place)
 	})
@@ -592,11 +603,11 @@ func extractSearchQuery(pageURL string) string {
 // 	return reviews
 // }
 
-func scrollMultipleTimes(times int, searchQuery string) chromedp.Tasks {
+func scrollMultipleTimes(times int, feedSelector string) chromedp.Tasks {
 	var tasks chromedp.Tasks
 	for i := 0; i < times; i++ {
 		tasks = append(tasks,
-			chromedp.Evaluate(`document.querySelector('[aria-label="Hasil untuk `+searchQuery+`"]').scrollBy(0, 500);`, nil),
+			chromedp.Evaluate(`document.querySelector(`+jsString(feedSelector)+`).scrollBy(0, 500);`, nil),
 			chromedp.Sleep(500*time.Millisecond),
 		)
 		log.Printf("Scrolling down (%d/%d)...\n", i+1, times)
@@ -604,8 +615,30 @@ func scrollMultipleTimes(times int, searchQuery string) chromedp.Tasks {
 	return tasks
 }
 
+// resolvePlaceLocation opens a place page and reads the coordinates from the URL Maps settles on
+func (s *ScrapService) resolvePlaceLocation(ctx context.Context, mapsLink string) (float64, float64, error) {
+	ctx, cancel := chromedp.NewContext(ctx)
+	defer cancel()
+
+	ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var currentURL string
+	err := chromedp.Run(ctx,
+		chromedp.Navigate(mapsLink),
+		chromedp.Sleep(2*time.Second),
+		chromedp.Location(&currentURL),
+	)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open place page: %w", err)
+	}
+
+	return extractLatLong(currentURL)
+}
+
+// extractLatLong reads the !3d<lat>!4d<long> pair of a Maps place URL
 func extractLatLong(url string) (float64, float64, error) {
-	re := regexp.MustCompile(`3d(-?\d+\.\d+)!4d(-?\d+\.\d+)`)
+	re := regexp.MustCompile(`!3d(-?\d+(?:\.\d+)?)!4d(-?\d+(?:\.\d+)?)`)
 	matches := re.FindStringSubmatch(url)
 
 	if len(matches) < 3 {
@@ -623,7 +656,7 @@ func extractLatLong(url string) (float64, float64, error) {
 }
 
 func cleanReviewCount(reviewText string) string {
-	re := regexp.MustCompile(`\d+\.\d+`) // Match a number like "1.297"
+	re := regexp.MustCompile(`\d+(?:[.,]\d+)*`) // Match a number like "87", "1.297" or "1,297"
 	match := re.FindString(reviewText)
 	return match
 }
@@ -642,16 +675,22 @@ func replaceImageProfileQuality(imageURL string) string {
 type RestaurantStatusResponse struct {
 	Status string `json:"status"`
 	Title  string `json:"title"`
+	// Verdict is only set when the restaurant was analysed by this request
+	Verdict *models.HalalVerdict `json:"verdict,omitempty"`
 }
 
 func (s *ScrapService) ScrapeSinglePlace(mapsLink string) (*RestaurantStatusResponse, error) {
 	log.Printf("🔍 Scraping single place: %s\n", mapsLink)
 
 	// Step 1: Scrape basic data from HTML
-	place := scrapeSinglePlaceHTML(mapsLink)
+	place := s.scrapeSinglePlaceHTML(mapsLink)
 	if place == nil {
 		return nil, fmt.Errorf("failed to scrape base place data from: %s", mapsLink)
 	}
+	// A single place has no search center to fall back to, it is not saved at (0,0)
+	if place.ApproximateLocation {
+		return nil, fmt.Errorf("no coordinates found for: %s", mapsLink)
+	}
 
 	// Step 2: Check if restaurant already exists
 	exists, status, err := s.RestaurantService.CheckRestaurantExists(
@@ -702,7 +741,7 @@ func (s *ScrapService) ScrapeSinglePlace(mapsLink string) (*RestaurantStatusResp
 
 	// Review scraping
 	go func() {
-		reviews, err := scrapeDataReview(mapsLink, place.Title)
+		reviews, err := s.scrapeDataReview(mapsLink, place.Title)
 		if err != nil {
 			log.Printf("⚠️ Review scraping failed for %s: %v\n", place.Title, err)
 			errChan <- err
@@ -738,85 +777,63 @@ func (s *ScrapService) ScrapeSinglePlace(mapsLink string) (*RestaurantStatusResp
 	}
 
 	// Step 4: Attach data if available
-	if menuLink != nil || reviewUser != nil {
-		if menuLink != nil {
-			place.MenuLink = menuLink
-		}
-		if reviewUser != nil {
-			place.Reviews = reviewUser
+	if menuLink == nil && reviewUser == nil {
+		return nil, fmt.Errorf("failed to scrape or analyze data for: %s", mapsLink)
+	}
+	if menuLink != nil {
+		place.MenuLink = menuLink
+	}
+	if reviewUser != nil {
+		place.Reviews = reviewUser
+	}
+
+	// Only analyze images if we have menu links, without them the status stays unknown
+	var menuList *models.AIResponsAnalyzeMenu
+	if len(menuLink) > 0 {
+		menuList, err = s.OpenAIService.AnalyzeImages(context.Background(), menuLink)
+		if err != nil {
+			log.Printf("❌ Error analyzing images: %v\n", err)
+		} else if menuList != nil {
+			place.Menu = menuList.Menu
 		}
+	} else {
+		log.Printf("⚠️ No menu links for %s, status unknown\n", place.Title)
+	}
+	place.Verdict = menuVerdict(menuLink, menuList, err)
 
-		// Only analyze images if we have menu links
-		if len(menuLink) > 0 {
-			menuList, err := s.OpenAIService.AnalyzeImages(context.Background(), menuLink)
-			if err != nil {
-				log.Printf("❌ Error analyzing images: %v\n", err)
-				// Continue with the place even if image analysis fails
-				err := s.RestaurantService.SaveRestaurantsHaram(context.Background(), []*models.Place{place})
-				if err != nil {
-					log.Printf("❌ Bulk save (Haram) failed: %v\n", err)
-				}
-				return &RestaurantStatusResponse{
-					Status: "haram",
-					Title:  place.Title,
-				}, nil
-			} else if menuList != nil {
-				place.Menu = menuList.Menu
-				if menuList.HalalStatus == "halal" {
-					// Step 5: Save to DB
-					err = s.RestaurantService.SaveRestaurants(context.Background(), []*models.Place{place})
-					if err != nil {
-						return nil, fmt.Errorf("failed to save restaurant: %w", err)
-					}
+	// Step 5: Save to DB
+	if err := s.RestaurantService.SaveRestaurants(context.Background(), []*models.Place{place}); err != nil {
+		return nil, fmt.Errorf("failed to save restaurant: %w", err)
+	}
 
-					// Return "halal" status with the restaurant title
-					log.Printf("✅ Halal Restaurant saved: %s\n", place.Title)
-					return &RestaurantStatusResponse{
-						Status: "halal",
-						Title:  place.Title,
-					}, nil
-				} else {
-					err := s.RestaurantService.SaveRestaurantsHaram(context.Background(), []*models.Place{place})
-					if err != nil {
-						log.Printf("❌ Bulk save (Haram) failed: %v\n", err)
-					}
-					// Return "haram" status with the restaurant title
-					log.Printf("✅ Haram Restaurant saved: %s\n", place.Title)
-					return &RestaurantStatusResponse{
-						Status: "haram",
-						Title:  place.Title,
-					}, nil
-				}
-			} else {
-				log.Printf("⚠️ menuList is nil for %s, marking as haram\n", place.Title)
-				err := s.RestaurantService.SaveRestaurantsHaram(context.Background(), []*models.Place{place})
-				if err != nil {
-					log.Printf("❌ Bulk save (Haram) failed: %v\n", err)
-				}
-				return &RestaurantStatusResponse{
-					Status: "haram",
-					Title:  place.Title,
-				}, nil
-			}
-		} else {
-			// No menu links available, save as haram
-			log.Printf("⚠️ No menu links for %s, marking as haram\n", place.Title)
-			err := s.RestaurantService.SaveRestaurantsHaram(context.Background(), []*models.Place{place})
-			if err != nil {
-				log.Printf("❌ Bulk save (Haram) failed: %v\n", err)
-			}
-			return &RestaurantStatusResponse{
-				Status: "haram",
-				Title:  place.Title,
-			}, nil
-		}
+	log.Printf("✅ Restaurant saved: %s. Status: %s\n", place.Title, place.Verdict.Status)
+	return &RestaurantStatusResponse{
+		Status:  place.Verdict.Status,
+		Title:   place.Title,
+		Verdict: place.Verdict,
+	}, nil
+}
+
+// menuVerdict turns the menu analysis into a verdict. A place without menu images or
+// whose analysis failed is unknown, not haram.
+func menuVerdict(menuLink []string, menuList *models.AIResponsAnalyzeMenu, err error) *models.HalalVerdict {
+	switch {
+	case len(menuLink) == 0:
+		return models.NewHalalVerdict(models.RestaurantStatusUnknown, models.VerdictSourceMenuAI, 0, "No menu images found", nil)
+	case err != nil:
+		return models.NewHalalVerdict(models.RestaurantStatusUnknown, models.VerdictSourceMenuAI, 0, "Menu analysis failed", nil)
+	case menuList == nil:
+		return models.NewHalalVerdict(models.RestaurantStatusUnknown, models.VerdictSourceMenuAI, 0, "Menu analysis returned no result", nil)
 	}
 
-	// Default return if no valid data
-	return nil, fmt.Errorf("failed to scrape or analyze data for: %s", mapsLink)
+	reason := "No haram items found in the menu"
+	if menuList.HalalStatus != models.MenuStatusHalal {
+		reason = fmt.Sprintf("%d haram or doubtful items found in the menu", len(menuList.HaramItems))
+	}
+	return models.NewHalalVerdict(menuList.HalalStatus, models.VerdictSourceMenuAI, menuList.Confidence, reason, menuList.HaramItems)
 }
 
-func scrapeSinglePlaceHTML(pageURL string) *models.Place {
+func (s *ScrapService) scrapeSinglePlaceHTML(pageURL string) *models.Place {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.Flag("disable-geolocation", false),
 		chromedp.Flag("use-mock-keychain", true),
@@ -850,10 +867,12 @@ func scrapeSinglePlaceHTML(pageURL string) *models.Place {
 	log.Printf("Current loaded URL: %s\n", currentURL)
 	log.Println("Extracting single place data...")
 
-	return extractSinglePlaceData(pageHTML, currentURL) // Kirim URL hasil navigasi
+	return ExtractPlaceDetails(pageHTML, currentURL, s.Selectors) // Kirim URL hasil navigasi
 }
 
-func extractSinglePlaceData(html string, mapsLink string) *models.Place {
+// ExtractPlaceDetails parses a saved Maps place page. When mapsLink has no coordinates the place
+// is flagged approximate and has no location, it must not be saved as is.
+func ExtractPlaceDetails(html string, mapsLink string, sel *selectors.MapsSelectors) *models.Place {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 	if err != nil {
 		log.Println("Error parsing HTML:", err)
@@ -861,19 +880,19 @@ func extractSinglePlaceData(html string, mapsLink string) *models.Place {
 	}
 
 	// Try finding accurate selectors for a single place view
-	title := doc.Find("h1.DUwDvf").Text()
-	address := doc.Find("button[data-item-id='address']").Text()
-	reviewCount := doc.Find(".UY7F9").Text()
+	title := doc.Find(sel.PlaceTitle).Text()
+	address := doc.Find(sel.PlaceAddress).Text()
+	reviewCount := doc.Find(sel.PlaceReviewCount).Text()
 	cleanedReviewCount := cleanReviewCount(reviewCount)
-	imageURL := doc.Find("img[src]").First().AttrOr("src", "N/A")
+	imageURL := doc.Find(sel.PlaceImage).First().AttrOr("src", "N/A")
 	enhancedImageURL := replaceImageProfileQuality(imageURL)
 
-	lat, long, _ := extractLatLong(mapsLink)
+	lat, long, err := extractLatLong(mapsLink)
 
 	return &models.Place{
 		Title:       title,
 		Address:     address,
-		Rating:      doc.Find(".MW4etd").Text(),
+		Rating:      doc.Find(sel.PlaceRating).Text(),
 		ReviewCount: cleanedReviewCount,
 		ImageURL:    enhancedImageURL,
 		MapsLink:    mapsLink,
@@ -881,5 +900,6 @@ func extractSinglePlaceData(html string, mapsLink string) *models.Place {
 			Latitude:  lat,
 			Longitude: long,
 		},
+		ApproximateLocation: err != nil,
 	}
 }
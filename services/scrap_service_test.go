@@ -0,0 +1,229 @@
+package services
+
+import (
+	"HalalMate/config/selectors"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the Maps fixtures with the current parser output")
+
+// Kinds of mapsFixture
+const (
+	fixtureSearch = "search"
+	fixturePlace  = "place"
+)
+
+// mapsFixture is one saved Maps page listed in testdata/maps/<locale>/<version>/fixtures.json.
+// The expected parser output is stored next to it as <name>.golden.json.
+type mapsFixture struct {
+	File string `json:"file"`
+	Kind string `json:"kind"`
+
+	// Search pages
+	Query     string  `json:"query,omitempty"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+
+	// Place pages
+	MapsLink string `json:"maps_link,omitempty"`
+}
+
+// TestMapsFixtures parses the saved pages of every selector locale and compares the result with
+// their golden files. The pages live under testdata/maps/<locale>/<version>, so bumping the selector
+// version requires saving new pages. Run with -update after an intended parser or selector change.
+func TestMapsFixtures(t *testing.T) {
+	for _, locale := range selectors.Locales() {
+		sel, err := selectors.Maps(locale)
+		if err != nil {
+			t.Errorf("maps/%s: %v", locale, err)
+			continue
+		}
+
+		dir := filepath.Join("testdata", "maps", sel.Locale, sel.Version)
+		fixtures, err := readMapsFixtures(dir)
+		if err != nil {
+			t.Errorf("%s: %v", filepath.ToSlash(dir), err)
+			continue
+		}
+
+		for _, fixture := range fixtures {
+			t.Run(sel.Locale+"/"+fixture.File, func(t *testing.T) {
+				actual, err := parseMapsFixture(dir, fixture, sel)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				goldenPath := filepath.Join(dir, strings.TrimSuffix(fixture.File, filepath.Ext(fixture.File))+".golden.json")
+				if *update {
+					if err := os.WriteFile(goldenPath, actual, 0644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				if err := compareGolden(goldenPath, actual); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+// TestMapsFixturesDetectDrift checks the fixtures fail once a selector stops matching the saved pages
+func TestMapsFixturesDetectDrift(t *testing.T) {
+	tests := []struct {
+		name  string
+		kind  string
+		drift func(sel *selectors.MapsSelectors)
+	}{
+		{"results feed", fixtureSearch, func(sel *selectors.MapsSelectors) { sel.ResultsFeed = `div[aria-label="Drifted %s"]` }},
+		{"result card", fixtureSearch, func(sel *selectors.MapsSelectors) { sel.ResultCard = ".drifted" }},
+		{"result title", fixtureSearch, func(sel *selectors.MapsSelectors) { sel.ResultTitle = ".drifted" }},
+		{"result link", fixtureSearch, func(sel *selectors.MapsSelectors) { sel.ResultLink = ".drifted" }},
+		{"result rating", fixtureSearch, func(sel *selectors.MapsSelectors) { sel.ResultRating = ".drifted" }},
+		{"place title", fixturePlace, func(sel *selectors.MapsSelectors) { sel.PlaceTitle = ".drifted" }},
+		{"place address", fixturePlace, func(sel *selectors.MapsSelectors) { sel.PlaceAddress = ".drifted" }},
+		{"place rating", fixturePlace, func(sel *selectors.MapsSelectors) { sel.PlaceRating = ".drifted" }},
+		{"copy address button", fixturePlace, func(sel *selectors.MapsSelectors) { sel.CopyAddressButton = ".drifted" }},
+		{"menu button", fixturePlace, func(sel *selectors.MapsSelectors) { sel.MenuButton = ".drifted" }},
+		{"review button", fixturePlace, func(sel *selectors.MapsSelectors) { sel.ReviewButton = `button[aria-label="Drifted %s"]` }},
+	}
+
+	sel, err := selectors.Maps(selectors.DefaultLocale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join("testdata", "maps", sel.Locale, sel.Version)
+	fixtures, err := readMapsFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drifted := *sel
+			tt.drift(&drifted)
+
+			checked := false
+			for _, fixture := range fixtures {
+				if fixture.Kind != tt.kind {
+					continue
+				}
+				checked = true
+
+				goldenPath := filepath.Join(dir, strings.TrimSuffix(fixture.File, filepath.Ext(fixture.File))+".golden.json")
+				actual, err := parseMapsFixture(dir, fixture, &drifted)
+				if err == nil {
+					err = compareGolden(goldenPath, actual)
+				}
+				if err == nil {
+					t.Errorf("%s: drifted selector went unnoticed", fixture.File)
+				}
+			}
+			if !checked {
+				t.Fatalf("no %s fixture in %s", tt.kind, filepath.ToSlash(dir))
+			}
+		})
+	}
+}
+
+func readMapsFixtures(dir string) ([]mapsFixture, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "fixtures.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no fixtures for this selector version, save pages to %s", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fixtures []mapsFixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("invalid fixtures.json: %w", err)
+	}
+	if len(fixtures) == 0 {
+		return nil, errors.New("fixtures.json lists no pages")
+	}
+	return fixtures, nil
+}
+
+// parseMapsFixture runs the parser of the fixture kind on the saved page and returns its output as indented JSON
+func parseMapsFixture(dir string, fixture mapsFixture, sel *selectors.MapsSelectors) ([]byte, error) {
+	html, err := os.ReadFile(filepath.Join(dir, fixture.File))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed interface{}
+	switch fixture.Kind {
+	case fixtureSearch:
+		places := ExtractPlaces(string(html), fixture.Latitude, fixture.Longitude, sel)
+		if len(places) == 0 {
+			return nil, fmt.Errorf("no place matched %q", sel.ResultCard)
+		}
+		for i, place := range places {
+			if place.Title == "" || place.MapsLink == "N/A" {
+				return nil, fmt.Errorf("place %d has no title or link, check %q and %q", i, sel.ResultTitle, sel.ResultLink)
+			}
+		}
+		if err := expectSelector(doc, sel.ResultsFeedFor(fixture.Query)); err != nil {
+			return nil, err
+		}
+		parsed = places
+
+	case fixturePlace:
+		place := ExtractPlaceDetails(string(html), fixture.MapsLink, sel)
+		if place == nil || place.Title == "" {
+			return nil, fmt.Errorf("no title matched %q", sel.PlaceTitle)
+		}
+		// Selectors only used through the browser must still match the saved page
+		for _, selector := range []string{sel.CopyAddressButton, sel.MenuButton, sel.ReviewButtonFor(place.Title)} {
+			if err := expectSelector(doc, selector); err != nil {
+				return nil, err
+			}
+		}
+		parsed = place
+
+	default:
+		return nil, fmt.Errorf("unknown fixture kind %q", fixture.Kind)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(parsed); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func compareGolden(goldenPath string, actual []byte) error {
+	expected, err := os.ReadFile(goldenPath)
+	if err != nil {
+		return fmt.Errorf("missing golden file, run with -update: %w", err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(expected), bytes.TrimSpace(actual)) {
+		return fmt.Errorf("parser output differs from %s:\n%s", filepath.Base(goldenPath), actual)
+	}
+	return nil
+}
+
+func expectSelector(doc *goquery.Document, selector string) error {
+	if doc.Find(selector).Length() == 0 {
+		return fmt.Errorf("selector %q matches nothing", selector)
+	}
+	return nil
+}
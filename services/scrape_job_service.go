@@ -0,0 +1,495 @@
+package services
+
+import (
+	"HalalMate/config/environment"
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"HalalMate/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultScrapeWorkers = 2
+	// scrapeJobPollInterval also picks up jobs queued by other instances
+	scrapeJobPollInterval = 10 * time.Second
+	scrapeJobEventBuffer  = 64
+	// scrapeJobLease is how long a running job stays with its worker without a heartbeat,
+	// after that another worker may take it over
+	scrapeJobLease     = 2 * time.Minute
+	scrapeJobHeartbeat = 30 * time.Second
+)
+
+var (
+	// errScrapeJobLeaseLost stops a worker whose job was requeued, cancelled or taken over
+	errScrapeJobLeaseLost = errors.New("scrape job lease lost")
+	// errScrapeJobLeaseRenewed leaves a job whose worker renewed its lease before the requeue
+	errScrapeJobLeaseRenewed = errors.New("scrape job lease renewed")
+)
+
+// Types of ScrapeJobEvent
+const (
+	ScrapeJobEventPlace = "place"
+	ScrapeJobEventDone  = "done"
+)
+
+// ScrapeJobEvent is sent to the live viewers of a job
+type ScrapeJobEvent struct {
+	Type  string
+	Place *models.Place
+	Job   *models.ScrapeJob
+}
+
+// ScrapeJobService queues scrape requests in "scrape_jobs" and runs them on a pool of workers
+type ScrapeJobService struct {
+	Jobs          repositories.ScrapeJobRepository
+	ScrapService  *ScrapService
+	Notifications *NotificationService
+
+	// workerID names the workers of this process in the leases they hold
+	workerID    string
+	wake        chan struct{}
+	startOnce   sync.Once
+	mu          sync.Mutex
+	cancels     map[string]context.CancelFunc
+	subscribers map[string]map[chan ScrapeJobEvent]struct{}
+}
+
+// NewScrapeJobService initializes ScrapeJobService with the default store
+func NewScrapeJobService(scrapService *ScrapService) *ScrapeJobService {
+	return NewScrapeJobServiceWithStore(repositories.GetStore(), scrapService)
+}
+
+// NewScrapeJobServiceWithStore initializes ScrapeJobService with the given repositories
+func NewScrapeJobServiceWithStore(store *repositories.Store, scrapService *ScrapService) *ScrapeJobService {
+	return &ScrapeJobService{
+		Jobs:          store.ScrapeJobs,
+		ScrapService:  scrapService,
+		Notifications: NewNotificationServiceWithStore(store),
+		workerID:      newScrapeWorkerID(),
+		wake:          make(chan struct{}, 1),
+		cancels:       make(map[string]context.CancelFunc),
+		subscribers:   make(map[string]map[chan ScrapeJobEvent]struct{}),
+	}
+}
+
+// ScrapeWorkers returns SCRAPE_WORKERS, defaulting to 2
+func ScrapeWorkers() int {
+	value := environment.GetScrapeWorkers()
+	if value == "" {
+		return defaultScrapeWorkers
+	}
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 0 {
+		log.Printf("Invalid SCRAPE_WORKERS %q, using %d", value, defaultScrapeWorkers)
+		return defaultScrapeWorkers
+	}
+	return workers
+}
+
+// newScrapeWorkerID returns an id unique to this process, across instances and restarts
+func newScrapeWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	id, err := generateUUID()
+	if err != nil {
+		id = strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return host + "-" + id
+}
+
+// Start launches the workers and a reaper requeueing the running jobs whose worker stopped
+// renewing its lease. Jobs other instances are running are left alone. Later calls do nothing.
+func (s *ScrapeJobService) Start(workers int) {
+	s.startOnce.Do(func() {
+		if workers == 0 {
+			log.Println("Scrape workers disabled, jobs stay queued")
+			return
+		}
+
+		s.requeueExpired(context.Background())
+		go s.reap()
+		for i := 0; i < workers; i++ {
+			go s.work()
+		}
+	})
+}
+
+// reap periodically requeues the jobs of workers that died
+func (s *ScrapeJobService) reap() {
+	ticker := time.NewTicker(scrapeJobLease / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.requeueExpired(context.Background())
+	}
+}
+
+// requeueExpired moves the running jobs whose lease expired back to the queue
+func (s *ScrapeJobService) requeueExpired(ctx context.Context) {
+	running, err := s.Jobs.ListByStatus(ctx, models.ScrapeJobRunning)
+	if err != nil {
+		log.Printf("❌ Failed to list running scrape jobs: %v\n", err)
+		return
+	}
+
+	requeued := false
+	for _, candidate := range running {
+		if !candidate.LeaseExpired(time.Now()) {
+			continue
+		}
+		previousWorker := candidate.WorkerID
+		_, err := s.Jobs.Update(ctx, candidate.ID, func(job *models.ScrapeJob) error {
+			if !job.LeaseExpired(time.Now()) {
+				return errScrapeJobLeaseRenewed
+			}
+			// The next worker scrapes the page again from the start
+			job.Status = models.ScrapeJobQueued
+			job.StartedAt = nil
+			job.WorkerID = ""
+			job.LeaseUntil = nil
+			job.Places = []models.ScrapeJobPlace{}
+			return nil
+		})
+		if errors.Is(err, errScrapeJobLeaseRenewed) {
+			continue
+		}
+		if err != nil {
+			log.Printf("❌ Failed to requeue scrape job %s: %v\n", candidate.ID, err)
+			continue
+		}
+		log.Printf("Scrape job %s requeued, the lease of worker %q expired\n", candidate.ID, previousWorker)
+		requeued = true
+	}
+	if requeued {
+		s.wakeWorker()
+	}
+}
+
+// wakeWorker makes an idle worker look for queued jobs without waiting for the next poll
+func (s *ScrapeJobService) wakeWorker() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// CreateJob queues a new scrape job
+func (s *ScrapeJobService) CreateJob(ctx context.Context, latitude, longitude float64, keyword, userID string) (*models.ScrapeJob, error) {
+	job := &models.ScrapeJob{
+		Status:    models.ScrapeJobQueued,
+		Latitude:  latitude,
+		Longitude: longitude,
+		Keyword:   keyword,
+		URL:       s.ScrapService.SearchURL(keyword, latitude, longitude),
+		Places:    []models.ScrapeJobPlace{},
+		CreatedBy: userID,
+	}
+
+	if err := s.Jobs.Create(ctx, job); err != nil {
+		log.Printf("❌ Failed to create scrape job: %v\n", err)
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to create scrape job")
+	}
+
+	log.Printf("Scrape job %s queued for %s\n", job.ID, job.URL)
+	s.wakeWorker()
+	return job, nil
+}
+
+// GetJob returns a job with its progress
+func (s *ScrapeJobService) GetJob(ctx context.Context, id string) (*models.ScrapeJob, error) {
+	job, err := s.Jobs.Get(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, utils.NewCustomError(http.StatusNotFound, "Scrape job not found")
+	}
+	if err != nil {
+		log.Printf("❌ Failed to fetch scrape job %s: %v\n", id, err)
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to fetch scrape job")
+	}
+	return job, nil
+}
+
+// CancelJob cancels a queued or running job. Places already scraped stay saved.
+func (s *ScrapeJobService) CancelJob(ctx context.Context, id string) (*models.ScrapeJob, error) {
+	errFinished := errors.New("job already finished")
+
+	job, err := s.Jobs.Update(ctx, id, func(job *models.ScrapeJob) error {
+		if job.IsFinished() {
+			return errFinished
+		}
+		now := time.Now()
+		job.Status = models.ScrapeJobCancelled
+		job.FinishedAt = &now
+		return nil
+	})
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		return nil, utils.NewCustomError(http.StatusNotFound, "Scrape job not found")
+	case errors.Is(err, errFinished):
+		return nil, utils.NewCustomError(http.StatusConflict, "Scrape job already finished")
+	case err != nil:
+		log.Printf("❌ Failed to cancel scrape job %s: %v\n", id, err)
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to cancel scrape job")
+	}
+
+	s.mu.Lock()
+	cancel, running := s.cancels[id]
+	s.mu.Unlock()
+	if running {
+		cancel()
+	} else {
+		// A queued job has no worker to report its end
+		s.publish(id, ScrapeJobEvent{Type: ScrapeJobEventDone, Job: job})
+	}
+
+	log.Printf("Scrape job %s cancelled\n", id)
+	return job, nil
+}
+
+// Subscribe streams the events of a job until it finishes. The channel is closed after the done event
+// or when unsubscribe is called.
+func (s *ScrapeJobService) Subscribe(jobID string) (<-chan ScrapeJobEvent, func()) {
+	events := make(chan ScrapeJobEvent, scrapeJobEventBuffer)
+
+	s.mu.Lock()
+	if s.subscribers[jobID] == nil {
+		s.subscribers[jobID] = make(map[chan ScrapeJobEvent]struct{})
+	}
+	s.subscribers[jobID][events] = struct{}{}
+	s.mu.Unlock()
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subscribers[jobID][events]; ok {
+			delete(s.subscribers[jobID], events)
+			close(events)
+		}
+		if len(s.subscribers[jobID]) == 0 {
+			delete(s.subscribers, jobID)
+		}
+	}
+	return events, unsubscribe
+}
+
+// publish sends an event to every viewer of the job. The done event also closes their channels.
+func (s *ScrapeJobService) publish(jobID string, event ScrapeJobEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for events := range s.subscribers[jobID] {
+		select {
+		case events <- event:
+		default:
+			log.Printf("⚠️ Dropping %s event of scrape job %s for a slow viewer\n", event.Type, jobID)
+		}
+		if event.Type == ScrapeJobEventDone {
+			close(events)
+		}
+	}
+	if event.Type == ScrapeJobEventDone {
+		delete(s.subscribers, jobID)
+	}
+}
+
+// work runs queued jobs one after another
+func (s *ScrapeJobService) work() {
+	ticker := time.NewTicker(scrapeJobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			job, err := s.claimNext(context.Background())
+			if err != nil {
+				log.Printf("❌ Failed to claim scrape job: %v\n", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			s.run(job)
+		}
+
+		select {
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimNext moves the oldest queued job to running under a lease of this worker.
+// It returns nil when nothing is queued.
+func (s *ScrapeJobService) claimNext(ctx context.Context) (*models.ScrapeJob, error) {
+	errTaken := errors.New("job taken by another worker")
+
+	queued, err := s.Jobs.ListByStatus(ctx, models.ScrapeJobQueued)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range queued {
+		job, err := s.Jobs.Update(ctx, candidate.ID, func(job *models.ScrapeJob) error {
+			if job.Status != models.ScrapeJobQueued {
+				return errTaken
+			}
+			now := time.Now()
+			leaseUntil := now.Add(scrapeJobLease)
+			job.Status = models.ScrapeJobRunning
+			job.StartedAt = &now
+			job.WorkerID = s.workerID
+			job.LeaseUntil = &leaseUntil
+			// The scrape starts over, places of an earlier attempt would be counted twice
+			job.Places = []models.ScrapeJobPlace{}
+			return nil
+		})
+		if errors.Is(err, errTaken) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+	return nil, nil
+}
+
+// run scrapes the job, recording every place, and stores the final state
+func (s *ScrapeJobService) run(job *models.ScrapeJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.mu.Lock()
+	s.cancels[job.ID] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancels, job.ID)
+		s.mu.Unlock()
+	}()
+
+	log.Printf("Scrape job %s started\n", job.ID)
+
+	placeChan := make(chan models.Place)
+	recorded := make(chan struct{})
+	go func() {
+		defer close(recorded)
+		for place := range placeChan {
+			s.recordPlace(job.ID, place, cancel)
+		}
+	}()
+
+	stopHeartbeat := make(chan struct{})
+	go s.heartbeat(job.ID, cancel, stopHeartbeat)
+
+	latitude := fmt.Sprintf("%.7f", job.Latitude)
+	longitude := fmt.Sprintf("%.7f", job.Longitude)
+	scrapeErr := s.ScrapService.ScrapePage(ctx, job.URL, latitude, longitude, placeChan)
+	close(placeChan)
+	<-recorded
+	close(stopHeartbeat)
+
+	finished, err := s.Jobs.Update(context.Background(), job.ID, func(job *models.ScrapeJob) error {
+		if job.IsFinished() {
+			return nil // Cancelled while running
+		}
+		if job.WorkerID != s.workerID {
+			return errScrapeJobLeaseLost
+		}
+		now := time.Now()
+		job.FinishedAt = &now
+		job.WorkerID = ""
+		job.LeaseUntil = nil
+		switch {
+		case errors.Is(scrapeErr, context.Canceled):
+			job.Status = models.ScrapeJobCancelled
+		case scrapeErr != nil:
+			job.Status = models.ScrapeJobFailed
+			job.Error = scrapeErr.Error()
+		default:
+			job.Status = models.ScrapeJobSucceeded
+		}
+		return nil
+	})
+	if errors.Is(err, errScrapeJobLeaseLost) {
+		// The worker now holding the job reports its end
+		log.Printf("⚠️ Scrape job %s was taken over by another worker\n", job.ID)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to store the result of scrape job %s: %v\n", job.ID, err)
+		finished = job
+	}
+
+	log.Printf("Scrape job %s finished: %s (%d places)\n", job.ID, finished.Status, len(finished.Places))
+	s.publish(job.ID, ScrapeJobEvent{Type: ScrapeJobEventDone, Job: finished})
+
+	// A cancelled job was stopped by someone watching it, there is nothing to tell
+	if finished.Status == models.ScrapeJobSucceeded || finished.Status == models.ScrapeJobFailed {
+		s.Notifications.NotifyScrapeJobFinished(context.Background(), finished)
+	}
+}
+
+// heartbeat renews the lease of a running job until stop is closed. It cancels the run once the
+// job is no longer this worker's, such as when it was cancelled from another instance.
+func (s *ScrapeJobService) heartbeat(jobID string, cancel context.CancelFunc, stop <-chan struct{}) {
+	ticker := time.NewTicker(scrapeJobHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		_, err := s.Jobs.Update(context.Background(), jobID, func(job *models.ScrapeJob) error {
+			if job.Status != models.ScrapeJobRunning || job.WorkerID != s.workerID {
+				return errScrapeJobLeaseLost
+			}
+			leaseUntil := time.Now().Add(scrapeJobLease)
+			job.LeaseUntil = &leaseUntil
+			return nil
+		})
+		if errors.Is(err, errScrapeJobLeaseLost) {
+			cancel()
+			return
+		}
+		if err != nil {
+			log.Printf("❌ Failed to renew the lease of scrape job %s: %v\n", jobID, err)
+		}
+	}
+}
+
+// recordPlace appends a place to the job progress. Like the heartbeat it cancels the run once the
+// job is no longer this worker's, so a worker that lost its lease stops adding to the job.
+func (s *ScrapeJobService) recordPlace(jobID string, place models.Place, cancel context.CancelFunc) {
+	_, err := s.Jobs.Update(context.Background(), jobID, func(job *models.ScrapeJob) error {
+		if job.Status != models.ScrapeJobRunning || job.WorkerID != s.workerID {
+			return errScrapeJobLeaseLost
+		}
+		job.Places = append(job.Places, models.ScrapeJobPlace{
+			Title:     place.Title,
+			MapsLink:  place.MapsLink,
+			ScrapedAt: time.Now(),
+		})
+		return nil
+	})
+	if errors.Is(err, errScrapeJobLeaseLost) {
+		cancel()
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to record place %s for scrape job %s: %v\n", place.Title, jobID, err)
+	}
+
+	s.publish(jobID, ScrapeJobEvent{Type: ScrapeJobEventPlace, Place: &place})
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestScrapeJobService(jobs repositories.ScrapeJobRepository, workerID string) *ScrapeJobService {
+	return &ScrapeJobService{
+		Jobs:        jobs,
+		workerID:    workerID,
+		wake:        make(chan struct{}, 1),
+		cancels:     make(map[string]context.CancelFunc),
+		subscribers: make(map[string]map[chan ScrapeJobEvent]struct{}),
+	}
+}
+
+// runningJob stores a job running on worker with a lease until leaseUntil and one recorded place
+func runningJob(t *testing.T, jobs repositories.ScrapeJobRepository, worker string, leaseUntil time.Time) *models.ScrapeJob {
+	t.Helper()
+	now := time.Now()
+	job := &models.ScrapeJob{
+		Status:     models.ScrapeJobRunning,
+		StartedAt:  &now,
+		WorkerID:   worker,
+		LeaseUntil: &leaseUntil,
+		Places:     []models.ScrapeJobPlace{{Title: "Sate Pak Haji", MapsLink: "https://maps.google.com/?cid=1"}},
+	}
+	if err := jobs.Create(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestRequeueExpiredScrapeJobs(t *testing.T) {
+	ctx := context.Background()
+	jobs := repositories.NewMemoryScrapeJobRepository()
+	service := newTestScrapeJobService(jobs, "worker-b")
+
+	expired := runningJob(t, jobs, "worker-a", time.Now().Add(-time.Second))
+	alive := runningJob(t, jobs, "worker-a", time.Now().Add(time.Minute))
+	service.requeueExpired(ctx)
+
+	stored, err := jobs.Get(ctx, expired.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.ScrapeJobQueued || stored.WorkerID != "" || stored.LeaseUntil != nil || len(stored.Places) != 0 {
+		t.Errorf("expired job not requeued from scratch: %+v", stored)
+	}
+	select {
+	case <-service.wake:
+	default:
+		t.Error("no worker woken for the requeued job")
+	}
+
+	stored, err = jobs.Get(ctx, alive.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.ScrapeJobRunning || stored.WorkerID != "worker-a" || len(stored.Places) != 1 {
+		t.Errorf("job with a live lease was touched: %+v", stored)
+	}
+
+	// Nothing else expired, no worker is woken
+	service.requeueExpired(ctx)
+	select {
+	case <-service.wake:
+		t.Error("worker woken although nothing was requeued")
+	default:
+	}
+}
+
+func TestClaimNextScrapeJobStartsOver(t *testing.T) {
+	ctx := context.Background()
+	jobs := repositories.NewMemoryScrapeJobRepository()
+	service := newTestScrapeJobService(jobs, "worker-b")
+
+	job := &models.ScrapeJob{
+		Status: models.ScrapeJobQueued,
+		Places: []models.ScrapeJobPlace{{Title: "Sate Pak Haji", MapsLink: "https://maps.google.com/?cid=1"}},
+	}
+	if err := jobs.Create(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := service.claimNext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed == nil || claimed.ID != job.ID || claimed.Status != models.ScrapeJobRunning || claimed.WorkerID != "worker-b" || claimed.LeaseUntil == nil {
+		t.Fatalf("claimNext = %+v", claimed)
+	}
+	if len(claimed.Places) != 0 {
+		t.Errorf("places of the earlier attempt kept: %+v", claimed.Places)
+	}
+
+	if next, err := service.claimNext(ctx); err != nil || next != nil {
+		t.Errorf("claimNext with nothing queued = %+v, %v", next, err)
+	}
+}
+
+func TestRecordPlaceAfterLeaseLost(t *testing.T) {
+	ctx := context.Background()
+	jobs := repositories.NewMemoryScrapeJobRepository()
+	job := runningJob(t, jobs, "worker-b", time.Now().Add(time.Minute))
+
+	place := models.Place{Title: "Soto Betawi", MapsLink: "https://maps.google.com/?cid=2"}
+
+	// The owner records the place and keeps running
+	owner := newTestScrapeJobService(jobs, "worker-b")
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	owner.recordPlace(job.ID, place, cancel)
+	if runCtx.Err() != nil {
+		t.Fatal("the owner of the job was stopped")
+	}
+
+	// A worker whose job was taken over is stopped without touching the job
+	stale := newTestScrapeJobService(jobs, "worker-a")
+	events, unsubscribe := stale.Subscribe(job.ID)
+	defer unsubscribe()
+	staleCtx, staleCancel := context.WithCancel(ctx)
+	defer staleCancel()
+	stale.recordPlace(job.ID, place, staleCancel)
+	if staleCtx.Err() == nil {
+		t.Error("the worker that lost the job kept running")
+	}
+	select {
+	case event := <-events:
+		t.Errorf("the worker that lost the job published %+v", event)
+	default:
+	}
+
+	stored, err := jobs.Get(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Places) != 2 {
+		t.Errorf("places = %+v, want the first place and the one of the owner", stored.Places)
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"HalalMate/config/environment"
+	"HalalMate/models"
+	"HalalMate/repositories"
+	"HalalMate/utils"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+// errRefreshTokenReused aborts a refresh with a token that was already rotated
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// SessionService issues access and refresh tokens and keeps one session per signed in device.
+// Refresh tokens are "<session id>.<secret>" and are replaced on every refresh.
+type SessionService struct {
+	Sessions repositories.SessionRepository
+	Users    repositories.UserRepository
+}
+
+// NewSessionService initializes SessionService with the default store
+func NewSessionService() *SessionService {
+	return NewSessionServiceWithStore(repositories.GetStore())
+}
+
+// NewSessionServiceWithStore initializes SessionService with the given repositories
+func NewSessionServiceWithStore(store *repositories.Store) *SessionService {
+	return &SessionService{
+		Sessions: store.Sessions,
+		Users:    store.Users,
+	}
+}
+
+func refreshTokenTTL() time.Duration {
+	if ttl := environment.GetJWTRefreshTTL(); ttl != "" {
+		if duration, err := time.ParseDuration(ttl); err == nil && duration > 0 {
+			return duration
+		}
+		log.Printf("Invalid JWT_REFRESH_TTL %q, using %s", ttl, defaultRefreshTokenTTL)
+	}
+	return defaultRefreshTokenTTL
+}
+
+// newSecretToken returns a random secret and the hash stored in place of it, for refresh and email tokens
+func newSecretToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(raw)
+	return secret, hashSecretToken(secret), nil
+}
+
+func hashSecretToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *SessionService) issueTokens(user *models.User, session *models.Session, secret string) (*models.AuthTokens, error) {
+	token, err := utils.GenerateToken(user.ID, user.RoleList(), session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuthTokens{
+		Token:        token,
+		RefreshToken: session.ID + "." + secret,
+		ExpiresIn:    int(utils.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// StartSession signs the user in on a device. Signing in again on the same device
+// revokes its previous session.
+func (s *SessionService) StartSession(ctx context.Context, user *models.User, deviceID string) (*models.AuthTokens, error) {
+	deviceID = strings.TrimSpace(deviceID)
+	if deviceID != "" {
+		sessions, err := s.Sessions.ListByUser(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions {
+			if session.DeviceID == deviceID && session.RevokedAt == nil {
+				if err := s.revoke(ctx, session.ID); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	secret, hash, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		UserID:           user.ID,
+		DeviceID:         deviceID,
+		RefreshTokenHash: hash,
+		ExpiresAt:        time.Now().Add(refreshTokenTTL()),
+	}
+	if err := s.Sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user, session, secret)
+}
+
+// Refresh exchanges a refresh token for new tokens and rotates it. Presenting a token that
+// was already rotated revokes the whole session, since it means the token leaked.
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*models.AuthTokens, error) {
+	invalid := utils.NewCustomError(http.StatusUnauthorized, "Invalid or expired refresh token")
+
+	sessionID, presented, ok := strings.Cut(strings.TrimSpace(refreshToken), ".")
+	if !ok || sessionID == "" || presented == "" {
+		return nil, invalid
+	}
+	presentedHash := hashSecretToken(presented)
+
+	secret, hash, err := newSecretToken()
+	if err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to refresh token")
+	}
+
+	session, err := s.Sessions.Update(ctx, sessionID, func(session *models.Session) error {
+		if !session.IsActive(time.Now()) {
+			return repositories.ErrNotFound
+		}
+		if session.PreviousTokenHash != "" && subtle.ConstantTimeCompare([]byte(presentedHash), []byte(session.PreviousTokenHash)) == 1 {
+			return errRefreshTokenReused
+		}
+		if subtle.ConstantTimeCompare([]byte(presentedHash), []byte(session.RefreshTokenHash)) != 1 {
+			return repositories.ErrNotFound
+		}
+
+		session.PreviousTokenHash = session.RefreshTokenHash
+		session.RefreshTokenHash = hash
+		session.ExpiresAt = time.Now().Add(refreshTokenTTL())
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errRefreshTokenReused):
+			log.Printf("Refresh token of session %s was reused, revoking the session", sessionID)
+			if err := s.revoke(ctx, sessionID); err != nil {
+				log.Printf("Failed to revoke session %s: %v", sessionID, err)
+			}
+			return nil, invalid
+		case errors.Is(err, repositories.ErrNotFound):
+			return nil, invalid
+		}
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to refresh token")
+	}
+
+	// Roles are read again so grants and revocations apply from the next refresh
+	user, err := s.Users.GetByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, invalid
+		}
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to refresh token")
+	}
+
+	tokens, err := s.issueTokens(user, session, secret)
+	if err != nil {
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to refresh token")
+	}
+	return tokens, nil
+}
+
+// Logout revokes the session of the current device, or every session of the user when allDevices is set
+func (s *SessionService) Logout(ctx context.Context, userID, sessionID string, allDevices bool) error {
+	if !allDevices {
+		if sessionID == "" {
+			return utils.NewCustomError(http.StatusBadRequest, "Token is not bound to a session")
+		}
+		session, err := s.Sessions.Get(ctx, sessionID)
+		if err != nil || session.UserID != userID {
+			return utils.NewCustomError(http.StatusNotFound, "Session not found")
+		}
+		if err := s.revoke(ctx, sessionID); err != nil {
+			return utils.NewCustomError(http.StatusInternalServerError, "Failed to log out")
+		}
+		return nil
+	}
+
+	if err := s.RevokeAll(ctx, userID); err != nil {
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to log out")
+	}
+	return nil
+}
+
+// RevokeAll revokes every session of the user
+func (s *SessionService) RevokeAll(ctx context.Context, userID string) error {
+	return s.RevokeAllExcept(ctx, userID, "")
+}
+
+// RevokeAllExcept revokes every session of the user but keepID
+func (s *SessionService) RevokeAllExcept(ctx context.Context, userID, keepID string) error {
+	sessions, err := s.Sessions.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.RevokedAt == nil && session.ID != keepID {
+			if err := s.revoke(ctx, session.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SessionService) revoke(ctx context.Context, sessionID string) error {
+	_, err := s.Sessions.Update(ctx, sessionID, func(session *models.Session) error {
+		if session.RevokedAt == nil {
+			now := time.Now()
+			session.RevokedAt = &now
+		}
+		return nil
+	})
+	return err
+}
+
+// IsSessionActive reports whether the access tokens of the session are still accepted
+func (s *SessionService) IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	session, err := s.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return session.IsActive(time.Now()), nil
+}
@@ -1,36 +1,329 @@
 package services
 
 import (
-	"HalalMate/config/database"
 	"HalalMate/models"
+	"HalalMate/repositories"
+	"HalalMate/utils"
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
 
-	"cloud.google.com/go/firestore"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
-	FirestoreClient *firestore.Client
+	Users       repositories.UserRepository
+	Bookmarks   repositories.BookmarkRepository
+	Rooms       repositories.RoomRepository
+	ChatRatings repositories.ChatRatingRepository
+	Sessions    *SessionService
 }
 
-// NewUserService initializes UserService with FirestoreClient
+// NewUserService initializes UserService with the default store
 func NewUserService() *UserService {
+	return NewUserServiceWithStore(repositories.GetStore())
+}
+
+// NewUserServiceWithStore initializes UserService with the given repositories
+func NewUserServiceWithStore(store *repositories.Store) *UserService {
 	return &UserService{
-		FirestoreClient: database.GetFirestoreClient(),
+		Users:       store.Users,
+		Bookmarks:   store.Bookmarks,
+		Rooms:       store.Rooms,
+		ChatRatings: store.ChatRatings,
+		Sessions:    NewSessionServiceWithStore(store),
 	}
 }
 
 //profile service
 
 func (s *UserService) GetUserProfile(ctx context.Context, userId string) (interface{}, error) {
-	doc, err := s.FirestoreClient.Collection("users").Doc(userId).Get(ctx)
+	user, err := s.Users.GetByID(ctx, userId)
 	if err != nil {
 		return nil, err
 	}
 
 	//before return parsing to model profile
-	var profile models.Profile
-	doc.DataTo(&profile)
-	return profile, nil
+	return newProfile(user), nil
+}
+
+func newProfile(user *models.User) models.Profile {
+	return models.Profile{
+		ID:                 user.ID,
+		Email:              user.Email,
+		Username:           user.Username,
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
+		IsGoogleUser:       user.IsGoogleUser,
+		EmailVerified:      user.EmailVerified,
+		HasPassword:        user.Password != "",
+		AvatarURL:          user.AvatarURL,
+		PreferredLanguage:  user.PreferredLanguage,
+		HomeCity:           user.HomeCity,
+		DietaryPreferences: user.DietaryPreferences,
+		Roles:              user.RoleList(),
+	}
+}
+
+// GrantRole adds role to the user. The user keeps its current tokens, the role is
+// carried by the tokens issued from its next login or refresh.
+func (s *UserService) GrantRole(ctx context.Context, userID, role string) (*models.Profile, error) {
+	if !models.IsRole(role) || role == models.RoleUser {
+		return nil, utils.NewCustomError(http.StatusBadRequest, "role must be one of moderator, admin")
+	}
+	return s.updateUser(ctx, userID, func(user *models.User) error {
+		if !user.HasRole(role) {
+			user.Roles = append(user.RoleList(), role)
+		}
+		return nil
+	})
+}
+
+// RevokeRole removes role from the user. Admins cannot revoke their own admin role,
+// so there is always one admin left to grant it back.
+func (s *UserService) RevokeRole(ctx context.Context, adminID, userID, role string) (*models.Profile, error) {
+	if !models.IsRole(role) || role == models.RoleUser {
+		return nil, utils.NewCustomError(http.StatusBadRequest, "role must be one of moderator, admin")
+	}
+	if userID == adminID && role == models.RoleAdmin {
+		return nil, utils.NewCustomError(http.StatusConflict, "You cannot revoke your own admin role")
+	}
+	return s.updateUser(ctx, userID, func(user *models.User) error {
+		roles := make([]string, 0, len(user.RoleList()))
+		for _, granted := range user.RoleList() {
+			if granted != role {
+				roles = append(roles, granted)
+			}
+		}
+		user.Roles = roles
+		return nil
+	})
+}
+
+// updateUser applies fn to the user and returns the updated profile. Errors of fn are returned as is.
+func (s *UserService) updateUser(ctx context.Context, userID string, fn func(user *models.User) error) (*models.Profile, error) {
+	var updated *models.User
+	err := s.Users.Update(ctx, userID, func(user *models.User) error {
+		if err := fn(user); err != nil {
+			return err
+		}
+		updated = user
+		return nil
+	})
+	if err != nil {
+		var customErr *utils.CustomError
+		switch {
+		case errors.As(err, &customErr):
+			return nil, err
+		case errors.Is(err, repositories.ErrNotFound):
+			return nil, utils.NewCustomError(http.StatusNotFound, "User not found")
+		}
+		return nil, utils.NewCustomError(http.StatusInternalServerError, "Failed to update user")
+	}
+
+	profile := newProfile(updated)
+	return &profile, nil
+}
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 30
+	maxHomeCityLength = 100
+)
+
+// ProfileUpdate holds the profile fields to change, nil fields are left as they are
+type ProfileUpdate struct {
+	Username *string
+	// AvatarURL is an http(s) URL, empty removes the avatar
+	AvatarURL *string
+	// PreferredLanguage is one of models.SupportedLanguages, empty resets it
+	PreferredLanguage *string
+	HomeCity          *string
+}
+
+// UpdateProfile changes the profile fields set in update
+func (s *UserService) UpdateProfile(ctx context.Context, userID string, update ProfileUpdate) (*models.Profile, error) {
+	if err := validateProfileUpdate(&update); err != nil {
+		return nil, err
+	}
+
+	return s.updateUser(ctx, userID, func(user *models.User) error {
+		if update.Username != nil {
+			user.Username = *update.Username
+		}
+		if update.AvatarURL != nil {
+			user.AvatarURL = *update.AvatarURL
+		}
+		if update.PreferredLanguage != nil {
+			user.PreferredLanguage = *update.PreferredLanguage
+		}
+		if update.HomeCity != nil {
+			user.HomeCity = *update.HomeCity
+		}
+		return nil
+	})
+}
+
+func validateProfileUpdate(update *ProfileUpdate) error {
+	trim := func(value *string) {
+		if value != nil {
+			*value = strings.TrimSpace(*value)
+		}
+	}
+	trim(update.Username)
+	trim(update.AvatarURL)
+	trim(update.PreferredLanguage)
+	trim(update.HomeCity)
+
+	if update.Username != nil {
+		length := utf8.RuneCountInString(*update.Username)
+		if length < minUsernameLength || length > maxUsernameLength {
+			return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("username must be between %d and %d characters", minUsernameLength, maxUsernameLength))
+		}
+	}
+	if update.AvatarURL != nil && *update.AvatarURL != "" && !isWebURL(*update.AvatarURL) {
+		return utils.NewCustomError(http.StatusBadRequest, "avatar_url must be an http or https URL")
+	}
+	if update.PreferredLanguage != nil {
+		*update.PreferredLanguage = strings.ToLower(*update.PreferredLanguage)
+		if *update.PreferredLanguage != "" && !slices.Contains(models.SupportedLanguages, *update.PreferredLanguage) {
+			return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("preferred_language must be one of %s", strings.Join(models.SupportedLanguages, ", ")))
+		}
+	}
+	if update.HomeCity != nil && utf8.RuneCountInString(*update.HomeCity) > maxHomeCityLength {
+		return utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("home_city must be at most %d characters", maxHomeCityLength))
+	}
+	return nil
+}
+
+// isWebURL reports whether raw is an absolute http or https URL
+func isWebURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// GetDietaryPreferences returns the dietary preferences of the user, the default standard for users without a document
+func (s *UserService) GetDietaryPreferences(ctx context.Context, userID string) (models.DietaryPreferences, error) {
+	prefs, err := dietaryPreferences(ctx, s.Users, userID)
+	if err != nil {
+		return prefs, utils.NewCustomError(http.StatusInternalServerError, "Failed to get dietary preferences")
+	}
+	return prefs, nil
+}
+
+// UpdateDietaryPreferences replaces the dietary preferences of the user
+func (s *UserService) UpdateDietaryPreferences(ctx context.Context, userID string, prefs models.DietaryPreferences) (*models.Profile, error) {
+	return s.updateUser(ctx, userID, func(user *models.User) error {
+		user.DietaryPreferences = prefs
+		return nil
+	})
+}
+
+// ChangePassword replaces the password of the user after checking the current one.
+// Every other device is signed out, the session of the request stays signed in.
+func (s *UserService) ChangePassword(ctx context.Context, userID, sessionID, currentPassword, newPassword string) error {
+	hashedPassword, err := hashNewPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.updateUser(ctx, userID, func(user *models.User) error {
+		if user.Password == "" {
+			return utils.NewCustomError(http.StatusConflict, "This account has no password yet, link one instead")
+		}
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(currentPassword)) != nil {
+			return utils.NewCustomError(http.StatusUnauthorized, "Current password is incorrect")
+		}
+		user.Password = hashedPassword
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.signOutOtherDevices(ctx, userID, sessionID)
+	return nil
+}
+
+// LinkPassword sets a first password on an account created with Google, so it can also log in with email and password
+func (s *UserService) LinkPassword(ctx context.Context, userID, sessionID, password string) error {
+	hashedPassword, err := hashNewPassword(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.updateUser(ctx, userID, func(user *models.User) error {
+		if user.Password != "" {
+			return utils.NewCustomError(http.StatusConflict, "This account already has a password, change it instead")
+		}
+		user.Password = hashedPassword
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-	// return doc.Data(), nil
+	s.signOutOtherDevices(ctx, userID, sessionID)
+	return nil
+}
+
+func hashNewPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", utils.NewCustomError(http.StatusBadRequest, fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", utils.NewCustomError(http.StatusInternalServerError, "Failed to update password")
+	}
+	return string(hashedPassword), nil
+}
+
+func (s *UserService) signOutOtherDevices(ctx context.Context, userID, sessionID string) {
+	if err := s.Sessions.RevokeAllExcept(ctx, userID, sessionID); err != nil {
+		log.Printf("[ERROR] Failed to sign out the other devices of %s: %v", userID, err)
+	}
+}
+
+// DeleteAccount deletes the user with its bookmarks, rooms, chats and chat ratings and signs every device out.
+// Accounts with a password must confirm it. Community reports are kept for the moderators.
+func (s *UserService) DeleteAccount(ctx context.Context, userID, password string) error {
+	user, err := s.Users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return utils.NewCustomError(http.StatusNotFound, "User not found")
+		}
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to get user")
+	}
+	if user.Password != "" && bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return utils.NewCustomError(http.StatusUnauthorized, "Password is incorrect")
+	}
+
+	// Sign out first so a failure halfway leaves no usable token behind, deleting again finishes the job
+	if err := s.Sessions.RevokeAll(ctx, userID); err != nil {
+		log.Printf("[ERROR] Failed to revoke the sessions of %s: %v", userID, err)
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to delete account")
+	}
+	if err := s.Bookmarks.DeleteAll(ctx, userID); err != nil {
+		log.Printf("[ERROR] Failed to delete the bookmarks of %s: %v", userID, err)
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to delete account")
+	}
+	if err := s.Rooms.DeleteAllRooms(ctx, userID); err != nil {
+		log.Printf("[ERROR] Failed to delete the rooms of %s: %v", userID, err)
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to delete account")
+	}
+	if err := s.ChatRatings.DeleteAll(ctx, userID); err != nil {
+		log.Printf("[ERROR] Failed to delete the chat ratings of %s: %v", userID, err)
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to delete account")
+	}
+	if err := s.Users.Delete(ctx, userID); err != nil {
+		log.Printf("[ERROR] Failed to delete user %s: %v", userID, err)
+		return utils.NewCustomError(http.StatusInternalServerError, "Failed to delete account")
+	}
+	return nil
 }
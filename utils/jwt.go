@@ -1,26 +1,208 @@
 package utils
 
 import (
+	"HalalMate/config/environment"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key") // Change this to a strong secret key
+const defaultAccessTokenTTL = 15 * time.Minute
 
-// GenerateToken creates a JWT token
-func GenerateToken(uid string) (string, error) {
+// jwtKeys is the signing configuration loaded from the environment
+type jwtKeys struct {
+	method  jwt.SigningMethod
+	kid     string
+	signKey interface{}
+	// verifyKeys holds the current key and the previous ones still accepted, by kid
+	verifyKeys map[string]interface{}
+	accessTTL  time.Duration
+}
+
+var (
+	jwtKeysOnce sync.Once
+	loadedKeys  *jwtKeys
+	jwtKeysErr  error
+)
+
+// LoadJWTConfig reads the JWT configuration once, main calls it to fail at startup on a bad key
+func LoadJWTConfig() error {
+	jwtKeysOnce.Do(func() {
+		loadedKeys, jwtKeysErr = loadJWTKeys()
+	})
+	return jwtKeysErr
+}
+
+func currentJWTKeys() (*jwtKeys, error) {
+	if err := LoadJWTConfig(); err != nil {
+		return nil, err
+	}
+	return loadedKeys, nil
+}
+
+func loadJWTKeys() (*jwtKeys, error) {
+	keys := &jwtKeys{
+		kid:        environment.GetJWTKeyID(),
+		verifyKeys: make(map[string]interface{}),
+		accessTTL:  defaultAccessTokenTTL,
+	}
+
+	if ttl := environment.GetJWTAccessTTL(); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid JWT_ACCESS_TTL %q", ttl)
+		}
+		keys.accessTTL = duration
+	}
+
+	var verifyKey interface{}
+	switch algorithm := environment.GetJWTAlgorithm(); algorithm {
+	case "", "HS256":
+		keys.method = jwt.SigningMethodHS256
+		secret := []byte(environment.GetJWTSecret())
+		if len(secret) == 0 {
+			// A random secret signs everyone out on every restart and differs between instances
+			if environment.GetJWTAllowEphemeralSecret() != "true" {
+				return nil, errors.New("JWT_SECRET is not set, set JWT_ALLOW_EPHEMERAL_SECRET=true to use a random secret")
+			}
+			log.Println("⚠️  JWT_SECRET is not set, using a random secret: tokens will not survive a restart")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, err
+			}
+		}
+		keys.signKey, verifyKey = secret, secret
+	case "RS256":
+		keys.method = jwt.SigningMethodRS256
+		pem, err := readKeyMaterial(environment.GetJWTPrivateKey())
+		if err != nil {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY: %w", err)
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY: %w", err)
+		}
+		keys.signKey, verifyKey = privateKey, &privateKey.PublicKey
+	case "EdDSA":
+		keys.method = jwt.SigningMethodEdDSA
+		pem, err := readKeyMaterial(environment.GetJWTPrivateKey())
+		if err != nil {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY: %w", err)
+		}
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY: %w", err)
+		}
+		keys.signKey, verifyKey = privateKey, privateKey.(ed25519.PrivateKey).Public()
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", algorithm)
+	}
+	keys.verifyKeys[keys.kid] = verifyKey
+
+	// Previous keys only verify, they let tokens signed before a rotation live until they expire
+	for _, entry := range strings.Split(environment.GetJWTPreviousKeys(), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, value, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || kid == keys.kid {
+			return nil, fmt.Errorf("JWT_PREVIOUS_KEYS: invalid entry %q", entry)
+		}
+
+		key, err := parsePreviousKey(keys.method, value)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_PREVIOUS_KEYS %s: %w", kid, err)
+		}
+		keys.verifyKeys[kid] = key
+	}
+	return keys, nil
+}
+
+func parsePreviousKey(method jwt.SigningMethod, value string) (interface{}, error) {
+	if method == jwt.SigningMethodHS256 {
+		return []byte(value), nil
+	}
+
+	pem, err := readKeyMaterial(value)
+	if err != nil {
+		return nil, err
+	}
+	if method == jwt.SigningMethodRS256 {
+		return jwt.ParseRSAPublicKeyFromPEM(pem)
+	}
+	return jwt.ParseEdPublicKeyFromPEM(pem)
+}
+
+// readKeyMaterial accepts either the PEM itself or the path of a PEM file
+func readKeyMaterial(value string) ([]byte, error) {
+	if value == "" {
+		return nil, errors.New("key is not set")
+	}
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value)
+}
+
+// AccessTokenTTL returns how long the tokens of GenerateToken are valid
+func AccessTokenTTL() time.Duration {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return defaultAccessTokenTTL
+	}
+	return keys.accessTTL
+}
+
+// GenerateToken creates a short-lived access token carrying the user id and roles.
+// sessionID binds the token to a refresh session so logging out revokes it, tokens without one are rejected.
+func GenerateToken(uid string, roles []string, sessionID string) (string, error) {
+	if sessionID == "" {
+		return "", errors.New("access tokens need a session id")
+	}
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"uid":  uid,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
+		"uid":   uid,
+		"roles": roles,
+		"iat":   now.Unix(),
+		"exp":   now.Add(keys.accessTTL).Unix(),
+		"sid":   sessionID,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+
+	token := jwt.NewWithClaims(keys.method, claims)
+	if keys.kid != "" {
+		token.Header["kid"] = keys.kid
+	}
+	return token.SignedString(keys.signKey)
 }
 
-// ValidateToken parses and validates a JWT token
+// ValidateToken parses and validates a JWT token. Only the configured algorithm is
+// accepted and the key is picked by the kid header.
 func ValidateToken(tokenStr string) (*jwt.Token, error) {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return nil, err
+	}
+
 	return jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.verifyKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	}, jwt.WithValidMethods([]string{keys.method.Alg()}), jwt.WithExpirationRequired())
 }
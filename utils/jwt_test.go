@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// setJWTEnv sets the JWT variables of env and clears the others
+func setJWTEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, key := range []string{"JWT_ALGORITHM", "JWT_SECRET", "JWT_ALLOW_EPHEMERAL_SECRET", "JWT_PRIVATE_KEY", "JWT_KEY_ID", "JWT_PREVIOUS_KEYS", "JWT_ACCESS_TTL"} {
+		t.Setenv(key, env[key])
+	}
+}
+
+// useJWTEnv loads the JWT configuration from env in place of the one read at startup
+func useJWTEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	setJWTEnv(t, env)
+
+	keys, err := loadJWTKeys()
+	if err != nil {
+		t.Fatalf("loadJWTKeys: %v", err)
+	}
+	jwtKeysOnce.Do(func() {})
+	previous := loadedKeys
+	loadedKeys, jwtKeysErr = keys, nil
+	t.Cleanup(func() { loadedKeys = previous })
+}
+
+// signClaims signs claims with the current configuration, bypassing GenerateToken
+func signClaims(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	keys, err := currentJWTKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(keys.method, claims)
+	token.Header["kid"] = keys.kid
+	signed, err := token.SignedString(keys.signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestLoadJWTKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{"secret", map[string]string{"JWT_SECRET": "rahasia"}, false},
+		{"missing secret", map[string]string{}, true},
+		{"ephemeral secret", map[string]string{"JWT_ALLOW_EPHEMERAL_SECRET": "true"}, false},
+		{"unknown algorithm", map[string]string{"JWT_ALGORITHM": "none", "JWT_SECRET": "rahasia"}, true},
+		{"missing private key", map[string]string{"JWT_ALGORITHM": "EdDSA"}, true},
+		{"invalid access ttl", map[string]string{"JWT_SECRET": "rahasia", "JWT_ACCESS_TTL": "-1m"}, true},
+		{"previous key without kid", map[string]string{"JWT_SECRET": "rahasia", "JWT_PREVIOUS_KEYS": "lama"}, true},
+		{"previous key reusing the kid", map[string]string{"JWT_SECRET": "rahasia", "JWT_KEY_ID": "k1", "JWT_PREVIOUS_KEYS": "k1=lama"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setJWTEnv(t, tt.env)
+
+			_, err := loadJWTKeys()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadJWTKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	useJWTEnv(t, map[string]string{"JWT_SECRET": "rahasia", "JWT_KEY_ID": "k1", "JWT_ACCESS_TTL": "5m"})
+
+	if _, err := GenerateToken("user-1", []string{"user"}, ""); err == nil {
+		t.Fatal("a token without a session was signed")
+	}
+
+	signed, err := GenerateToken("user-1", []string{"user", "admin"}, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := ValidateToken(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if claims["uid"] != "user-1" || claims["sid"] != "session-1" || token.Header["kid"] != "k1" {
+		t.Errorf("unexpected token %v %v", token.Header, claims)
+	}
+	if roles := claims["roles"]; !reflect.DeepEqual(roles, []interface{}{"user", "admin"}) {
+		t.Errorf("roles = %v", roles)
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil || time.Until(expiresAt.Time) > 5*time.Minute || time.Until(expiresAt.Time) < 4*time.Minute {
+		t.Errorf("exp = %v, want 5 minutes from now", expiresAt)
+	}
+}
+
+func TestValidateTokenExpiry(t *testing.T) {
+	useJWTEnv(t, map[string]string{"JWT_SECRET": "rahasia"})
+
+	expired := signClaims(t, jwt.MapClaims{"uid": "user-1", "sid": "session-1", "exp": time.Now().Add(-time.Minute).Unix()})
+	if _, err := ValidateToken(expired); err == nil {
+		t.Error("an expired token was accepted")
+	}
+
+	withoutExp := signClaims(t, jwt.MapClaims{"uid": "user-1", "sid": "session-1"})
+	if _, err := ValidateToken(withoutExp); err == nil {
+		t.Error("a token without exp was accepted")
+	}
+}
+
+func TestValidateTokenKeyRotation(t *testing.T) {
+	useJWTEnv(t, map[string]string{"JWT_SECRET": "rahasia-lama", "JWT_KEY_ID": "2025"})
+	old, err := GenerateToken("user-1", nil, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new key signs, the old one still verifies the tokens issued before the rotation
+	useJWTEnv(t, map[string]string{"JWT_SECRET": "rahasia-baru", "JWT_KEY_ID": "2026", "JWT_PREVIOUS_KEYS": "2025=rahasia-lama"})
+	if _, err := ValidateToken(old); err != nil {
+		t.Errorf("token of the previous key rejected: %v", err)
+	}
+	current, err := GenerateToken("user-1", nil, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(current); err != nil {
+		t.Errorf("token of the current key rejected: %v", err)
+	}
+
+	// Once the old key is dropped its tokens are rejected
+	useJWTEnv(t, map[string]string{"JWT_SECRET": "rahasia-baru", "JWT_KEY_ID": "2026"})
+	if _, err := ValidateToken(old); err == nil {
+		t.Error("token of a dropped key accepted")
+	}
+	if _, err := ValidateToken(current); err != nil {
+		t.Errorf("token of the current key rejected: %v", err)
+	}
+}
+
+func TestValidateTokenEdDSA(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+
+	useJWTEnv(t, map[string]string{"JWT_SECRET": "rahasia", "JWT_KEY_ID": "hs"})
+	hmacToken, err := GenerateToken("user-1", nil, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useJWTEnv(t, map[string]string{"JWT_ALGORITHM": "EdDSA", "JWT_PRIVATE_KEY": privatePEM, "JWT_KEY_ID": "ed"})
+	signed, err := GenerateToken("user-1", nil, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := ValidateToken(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Method != jwt.SigningMethodEdDSA || !publicKey.Equal(loadedKeys.verifyKeys["ed"]) {
+		t.Errorf("token signed with %v", token.Method.Alg())
+	}
+	// Only the configured algorithm is accepted
+	if _, err := ValidateToken(hmacToken); err == nil {
+		t.Error("HS256 token accepted while EdDSA is configured")
+	}
+}